@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// handleAdmin registers the operational endpoints on mux: status, stats,
+// health, debugging, the Prometheus scrape endpoint, the batches of the
+// inmemory exporter, the runtime configuration and the consumption of the
+// ingest quotas. Every endpoint but
+// /readyz, which probes reach, goes through auth.
+func (ot *otelPlugin) handleAdmin(mux *http.ServeMux, auth func(http.HandlerFunc) http.HandlerFunc) {
+	mux.HandleFunc("/status", recoverHTTP(auth(ot.serveStatus)))
+	mux.HandleFunc("/stats", recoverHTTP(auth(ot.serveStats)))
+	mux.HandleFunc("/readyz", recoverHTTP(ot.serveReady))
+	mux.HandleFunc("/exporters", recoverHTTP(auth(ot.serveExporters)))
+	mux.HandleFunc("/debug/last", recoverHTTP(auth(ot.serveLastEvent)))
+	if ot.scrape != nil {
+		mux.HandleFunc("/metrics", recoverHTTP(auth(ot.serveMetrics)))
+	}
+	if exporterSelected("inmemory") {
+		mux.HandleFunc("/inmemory", recoverHTTP(auth(ot.serveInMemory)))
+	}
+	if ot.runtime != nil {
+		mux.HandleFunc("/pipeline", recoverHTTP(auth(ot.serveRuntimeConfig)))
+		mux.HandleFunc("/pipeline/", recoverHTTP(auth(ot.serveRuntimeConfig)))
+	}
+	if ot.quotas != nil {
+		mux.HandleFunc("/quotas", recoverHTTP(auth(ot.serveQuotas)))
+	}
+}
+
+// noAdminAuth lets every request through.
+func noAdminAuth(h http.HandlerFunc) http.HandlerFunc {
+	return h
+}
+
+// adminAuth returns the authentication of the admin listener: a bearer
+// token held by one of the comma separated environment variables of
+// --admin-tokens, or none without them.
+func adminAuth() (func(http.HandlerFunc) http.HandlerFunc, error) {
+	var tokens [][]byte
+	for _, env := range splitList(plugin.AdminTokens) {
+		token := os.Getenv(env)
+		if token == "" {
+			return nil, fmt.Errorf("--admin-tokens: %s is not set", env)
+		}
+		tokens = append(tokens, []byte(token))
+	}
+	if len(tokens) == 0 {
+		return noAdminAuth, nil
+	}
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			auth := req.Header.Get("Authorization")
+			if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+				presented := []byte(strings.TrimSpace(auth[len("Bearer "):]))
+				for _, token := range tokens {
+					if subtle.ConstantTimeCompare(presented, token) == 1 {
+						h(w, req)
+						return
+					}
+				}
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}, nil
+}
+
+// serveAdmin serves the operational endpoints on their own listener,
+// --admin-listen, so that they are not exposed to event producers.
+func (ot *otelPlugin) serveAdmin() error {
+	auth, err := adminAuth()
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	ot.handleAdmin(mux, auth)
+	lis, err := net.Listen("tcp", plugin.AdminListen)
+	if err != nil {
+		return err
+	}
+	log.Printf("starting admin server on %v...", plugin.AdminListen)
+	go func() {
+		if err := http.Serve(lis, mux); err != nil {
+			log.Fatalf("could not serve admin http: %v", err)
+		}
+	}()
+	return nil
+}
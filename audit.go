@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// auditRecord is one line of the audit log, written for every event the
+// handler tried to export or dropped on purpose.
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	EventID     string    `json:"event_id"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Entity      string    `json:"entity,omitempty"`
+	Check       string    `json:"check,omitempty"`
+	Points      int       `json:"points"`
+	Destination string    `json:"destination"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// auditLog appends audit records as JSON lines to a file.
+type auditLog struct {
+	sync.Mutex
+	file *os.File
+}
+
+func newAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: f}, nil
+}
+
+// Log records the outcome of exporting event to destination.
+func (a *auditLog) Log(event *types.Event, destination string, exportErr error) error {
+	rec := newAuditRecord(event, destination, "exported")
+	if exportErr != nil {
+		rec.Outcome = "failed"
+		rec.Error = exportErr.Error()
+	}
+	return a.write(rec)
+}
+
+// Dropped records that event was not exported to destination, for reason.
+func (a *auditLog) Dropped(event *types.Event, destination, reason string) error {
+	rec := newAuditRecord(event, destination, "dropped")
+	rec.Reason = reason
+	return a.write(rec)
+}
+
+func newAuditRecord(event *types.Event, destination, outcome string) auditRecord {
+	namespace, entity, check := converter.EventNames(event)
+	return auditRecord{
+		Time:        time.Now().UTC(),
+		EventID:     event.GetUUID().String(),
+		Namespace:   namespace,
+		Entity:      entity,
+		Check:       check,
+		Points:      converter.EventPoints(event),
+		Destination: destination,
+		Outcome:     outcome,
+	}
+}
+
+func (a *auditLog) write(rec auditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
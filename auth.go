@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthProvider supplies the credentials added to every export request.
+// Providers refresh expiring credentials themselves and must be safe for
+// concurrent use.
+type AuthProvider interface {
+	Headers(ctx context.Context) (map[string]string, error)
+}
+
+// AuthProviderFactory creates an auth provider from the plugin
+// configuration.
+type AuthProviderFactory func() (AuthProvider, error)
+
+var authProviders = map[string]AuthProviderFactory{}
+
+// RegisterAuthProvider makes an auth provider selectable by name with the
+// --auth option. It is meant to be called from init functions and panics if
+// the name is already taken.
+func RegisterAuthProvider(name string, factory AuthProviderFactory) {
+	if _, ok := authProviders[name]; ok {
+		panic(fmt.Sprintf("auth provider %q registered twice", name))
+	}
+	authProviders[name] = factory
+}
+
+// newAuthProvider creates the auth provider registered as name.
+func newAuthProvider(name string) (AuthProvider, error) {
+	factory, ok := authProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown auth provider %q, expected one of %s", name, strings.Join(authProviderNames(), ", "))
+	}
+	return factory()
+}
+
+// authProviderNames lists the registered auth providers, sorted.
+func authProviderNames() []string {
+	names := make([]string, 0, len(authProviders))
+	for name := range authProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterAuthProvider("none", func() (AuthProvider, error) {
+		return staticAuth(nil), nil
+	})
+	RegisterAuthProvider("static", func() (AuthProvider, error) {
+		return staticAuth{plugin.AuthHeader: headerValue(plugin.AuthHeader, accessToken())}, nil
+	})
+	RegisterAuthProvider("token-file", func() (AuthProvider, error) {
+		if plugin.AuthTokenFile == "" {
+			return nil, fmt.Errorf("the token-file auth provider requires --auth-token-file")
+		}
+		return &tokenFileAuth{path: plugin.AuthTokenFile, header: plugin.AuthHeader}, nil
+	})
+	RegisterAuthProvider("oauth2", func() (AuthProvider, error) {
+		if plugin.OAuth2TokenURL == "" || plugin.OAuth2ClientID == "" {
+			return nil, fmt.Errorf("the oauth2 auth provider requires --oauth2-token-url and --oauth2-client-id")
+		}
+		basic, err := oauth2BasicAuth(plugin.OAuth2AuthStyle)
+		if err != nil {
+			return nil, err
+		}
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if !basic {
+			form.Set("client_id", plugin.OAuth2ClientID)
+			form.Set("client_secret", plugin.OAuth2Secret)
+		}
+		if plugin.OAuth2Scopes != "" {
+			form.Set("scope", strings.Join(strings.Split(plugin.OAuth2Scopes, ","), " "))
+		}
+		if plugin.OAuth2Audience != "" {
+			form.Set("audience", plugin.OAuth2Audience)
+		}
+		tokenURL, clientID, secret := plugin.OAuth2TokenURL, plugin.OAuth2ClientID, plugin.OAuth2Secret
+		return &bearerAuth{fetch: func(ctx context.Context) (*tokenResponse, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if basic {
+				req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+			}
+			return fetchToken(req)
+		}}, nil
+	})
+	RegisterAuthProvider("sigv4", func() (AuthProvider, error) {
+		region := awsRegion(plugin.SigV4Region)
+		if region == "" || plugin.SigV4Service == "" {
+			return nil, fmt.Errorf("the sigv4 auth provider requires --sigv4-service and --sigv4-region or AWS_REGION")
+		}
+		return &sigV4Auth{region: region, service: plugin.SigV4Service, creds: &awsCredentialChain{}}, nil
+	})
+	RegisterAuthProvider("gcp", func() (AuthProvider, error) {
+		fetch, err := gcpTokenSource(gcpCloudPlatformScope)
+		if err != nil {
+			return nil, err
+		}
+		return &bearerAuth{fetch: fetch}, nil
+	})
+	RegisterAuthProvider("gcp-id-token", func() (AuthProvider, error) {
+		if plugin.GCPAudience == "" {
+			return nil, fmt.Errorf("the gcp-id-token auth provider requires --gcp-audience")
+		}
+		fetch, err := gcpIDTokenSource(plugin.GCPAudience)
+		if err != nil {
+			return nil, err
+		}
+		return &bearerAuth{fetch: fetch}, nil
+	})
+}
+
+// RequestSigner is implemented by auth providers whose credentials depend
+// on the request, such as a signature over its body. Exporters that send
+// HTTP requests sign them after adding the headers of the provider.
+type RequestSigner interface {
+	Sign(ctx context.Context, req *http.Request, body []byte) error
+}
+
+// sigV4Auth signs requests with AWS Signature Version 4, with credentials
+// from the AWS credential chain.
+type sigV4Auth struct {
+	region  string
+	service string
+	creds   *awsCredentialChain
+}
+
+func (a *sigV4Auth) Headers(context.Context) (map[string]string, error) {
+	return nil, nil
+}
+
+func (a *sigV4Auth) Sign(ctx context.Context, req *http.Request, body []byte) error {
+	creds, err := a.creds.Get(ctx)
+	if err != nil {
+		return err
+	}
+	signV4(req, body, creds, a.region, a.service, time.Now())
+	return nil
+}
+
+// oauth2BasicAuth checks the --oauth2-auth-style value and reports whether
+// the client credentials are sent with HTTP basic authentication rather
+// than as form parameters.
+func oauth2BasicAuth(style string) (bool, error) {
+	switch style {
+	case "params":
+		return false, nil
+	case "basic":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown OAuth2 auth style %q, expected params or basic", style)
+}
+
+// invalidateAuth drops the cached credentials of auth after the server
+// rejected them. It reports whether auth can fetch new ones.
+func invalidateAuth(auth AuthProvider) bool {
+	i, ok := auth.(interface{ invalidate() })
+	if ok {
+		i.invalidate()
+	}
+	return ok
+}
+
+// accessToken returns the token of the static auth provider: the
+// --access-token option, which can be set from a Sensu secret with
+// OTEL_SENSU_ACCESS_TOKEN, or else LS_ACCESS_TOKEN.
+func accessToken() string {
+	if plugin.AccessToken != "" {
+		return plugin.AccessToken
+	}
+	return os.Getenv("LS_ACCESS_TOKEN")
+}
+
+// headerValue formats a token for header, adding the Bearer scheme for the
+// Authorization header.
+func headerValue(header, token string) string {
+	if strings.EqualFold(header, "authorization") && !strings.Contains(token, " ") {
+		return "Bearer " + token
+	}
+	return token
+}
+
+// staticAuth sends the same headers with every request.
+type staticAuth map[string]string
+
+func (a staticAuth) Headers(context.Context) (map[string]string, error) {
+	return a, nil
+}
+
+// tokenFileAuth reads the token from a file, again whenever the file
+// changes, so that rotated credentials are picked up without a restart.
+type tokenFileAuth struct {
+	path   string
+	header string
+
+	sync.Mutex
+	modTime time.Time
+	headers map[string]string
+}
+
+func (a *tokenFileAuth) Headers(context.Context) (map[string]string, error) {
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	if a.headers != nil && fi.ModTime().Equal(a.modTime) {
+		return a.headers, nil
+	}
+	token, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return nil, err
+	}
+	a.modTime = fi.ModTime()
+	a.headers = map[string]string{a.header: headerValue(a.header, strings.TrimSpace(string(token)))}
+	return a.headers, nil
+}
+
+// tokenResponse is the token endpoint response of OAuth2 and of the cloud
+// metadata servers.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetchToken sends a token request.
+func fetchToken(req *http.Request) (*tokenResponse, error) {
+	body, err := tokenRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	return &token, nil
+}
+
+// tokenRequest sends a token request and returns the response body.
+func tokenRequest(req *http.Request) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// bearerAuth sends a bearer token in the Authorization header, fetching a
+// new one shortly before the current one expires.
+type bearerAuth struct {
+	fetch func(ctx context.Context) (*tokenResponse, error)
+
+	sync.Mutex
+	expiry  time.Time
+	headers map[string]string
+}
+
+func (a *bearerAuth) invalidate() {
+	a.Lock()
+	a.headers = nil
+	a.Unlock()
+}
+
+// tokenRefreshMargin is how long before its expiry a token is replaced.
+const tokenRefreshMargin = time.Minute
+
+func (a *bearerAuth) Headers(ctx context.Context) (map[string]string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.headers != nil && time.Now().Before(a.expiry) {
+		return a.headers, nil
+	}
+	token, err := a.fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch access token: %w", err)
+	}
+	lifetime := time.Duration(token.ExpiresIn)*time.Second - tokenRefreshMargin
+	if token.ExpiresIn == 0 {
+		lifetime = time.Hour
+	}
+	a.expiry = time.Now().Add(lifetime)
+	a.headers = map[string]string{"authorization": "Bearer " + token.AccessToken}
+	return a.headers, nil
+}
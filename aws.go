@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials are the credentials requests to AWS are signed with.
+// Temporary credentials have a session token and an expiration.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// awsCredentialChain looks up credentials the way the AWS SDKs do: from
+// the environment, the shared credentials file, the ECS container
+// credentials endpoint and finally the EC2 instance metadata service.
+// Temporary credentials are refreshed shortly before they expire.
+type awsCredentialChain struct {
+	sync.Mutex
+	creds *awsCredentials
+}
+
+// metadataClient bounds the requests to the cloud metadata services, which
+// are not reachable outside of the cloud.
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// Get returns the current credentials.
+func (c *awsCredentialChain) Get(ctx context.Context) (*awsCredentials, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.creds != nil && (c.creds.Expiration.IsZero() || time.Now().Add(5*time.Minute).Before(c.creds.Expiration)) {
+		return c.creds, nil
+	}
+
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		c.creds = &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		return c.creds, nil
+	}
+	if creds, err := sharedCredentials(); err == nil {
+		c.creds = creds
+		return c.creds, nil
+	}
+	var err error
+	if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" {
+		c.creds, err = containerCredentials(ctx)
+	} else {
+		c.creds, err = instanceCredentials(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials found in the environment, shared credentials file or metadata service: %v", err)
+	}
+	return c.creds, nil
+}
+
+// sharedCredentials reads the profile of AWS_PROFILE, or the default
+// profile, from the shared credentials file.
+func sharedCredentials() (*awsCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profile := getenv("AWS_PROFILE", "default")
+	var section string
+	creds := &awsCredentials{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if section != profile || i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch strings.TrimSpace(line[:i]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyID == "" {
+		return nil, fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	return creds, nil
+}
+
+// metadataCredentials is the credentials document of the ECS and EC2
+// metadata services.
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func containerCredentials(ctx context.Context) (*awsCredentials, error) {
+	uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		uri = "http://169.254.170.2" + rel
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return fetchMetadataCredentials(req)
+}
+
+// instanceCredentials gets the credentials of the instance role from the
+// EC2 instance metadata service, using an IMDSv2 session token.
+func instanceCredentials(ctx context.Context) (*awsCredentials, error) {
+	const imds = "http://169.254.169.254/latest"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imds+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := metadataGet(req)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := metadataGet(req)
+	if err != nil {
+		return nil, err
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/"+role, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return fetchMetadataCredentials(req)
+}
+
+func metadataGet(req *http.Request) (string, error) {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return string(body), nil
+}
+
+func fetchMetadataCredentials(req *http.Request) (*awsCredentials, error) {
+	body, err := metadataGet(req)
+	if err != nil {
+		return nil, err
+	}
+	var m metadataCredentials
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		return nil, fmt.Errorf("invalid credentials document: %v", err)
+	}
+	return &awsCredentials{
+		AccessKeyID:     m.AccessKeyID,
+		SecretAccessKey: m.SecretAccessKey,
+		SessionToken:    m.Token,
+		Expiration:      m.Expiration,
+	}, nil
+}
+
+// awsRegion returns the configured region, falling back to the AWS
+// environment variables.
+func awsRegion(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	return getenv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION"))
+}
+
+// signV4 signs req, whose body is body, with AWS Signature Version 4.
+func signV4(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery sorts and encodes the query string as SigV4 requires.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but the unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSignV4 checks the signatures of the get-vanilla examples of the AWS
+// Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	creds := &awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		url       string
+		signature string
+	}{
+		{"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signV4(req, nil, creds, "us-east-1", "service", now)
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + test.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.url, got, want)
+		}
+	}
+}
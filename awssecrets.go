@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterAuthProvider("aws-secrets", func() (AuthProvider, error) {
+		sources, err := parseAWSSecretHeaders(plugin.AWSSecretsHeaders)
+		if err != nil {
+			return nil, err
+		}
+		region := awsRegion(plugin.AWSSecretsRegion)
+		if region == "" {
+			return nil, fmt.Errorf("the aws-secrets auth provider requires --aws-secrets-region or AWS_REGION")
+		}
+		refresh, err := time.ParseDuration(plugin.AWSSecretsRefresh)
+		if err != nil {
+			return nil, fmt.Errorf("--aws-secrets-refresh: %v", err)
+		}
+		return &awsSecretsAuth{
+			client:  &http.Client{Timeout: exportTimeout},
+			region:  region,
+			sources: sources,
+			refresh: refresh,
+			creds:   &awsCredentialChain{},
+		}, nil
+	})
+}
+
+// awsSecretSource is where the value of a header is kept: a Secrets
+// Manager secret, optionally a key of its JSON value, or an SSM parameter.
+type awsSecretSource struct {
+	header  string
+	service string
+	id      string
+	key     string
+}
+
+// parseAWSSecretHeaders parses the comma separated header=source pairs of
+// --aws-secrets-headers. A source is secretsmanager:<secret id>[#<json
+// key>] or ssm:<parameter name>.
+func parseAWSSecretHeaders(value string) ([]awsSecretSource, error) {
+	var sources []awsSecretSource
+	for _, pair := range splitList(value) {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid secret header %q, expected header=source", pair)
+		}
+		s := awsSecretSource{header: pair[:i]}
+		source := pair[i+1:]
+		j := strings.IndexByte(source, ':')
+		if j <= 0 || j == len(source)-1 {
+			return nil, fmt.Errorf("invalid secret source %q, expected secretsmanager:<id> or ssm:<name>", source)
+		}
+		s.service, s.id = source[:j], source[j+1:]
+		switch s.service {
+		case "secretsmanager":
+			if k := strings.LastIndexByte(s.id, '#'); k > 0 {
+				s.id, s.key = s.id[:k], s.id[k+1:]
+			}
+		case "ssm":
+		default:
+			return nil, fmt.Errorf("unknown secret service %q, expected secretsmanager or ssm", s.service)
+		}
+		sources = append(sources, s)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no secret headers, set --aws-secrets-headers")
+	}
+	return sources, nil
+}
+
+// awsSecretsAuth resolves its headers from Secrets Manager and SSM
+// Parameter Store, at the first export and again after the refresh
+// interval or when the collector rejects them, so rotated secrets are
+// picked up.
+type awsSecretsAuth struct {
+	client  *http.Client
+	region  string
+	sources []awsSecretSource
+	refresh time.Duration
+	creds   *awsCredentialChain
+
+	sync.Mutex
+	headers map[string]string
+	expiry  time.Time
+}
+
+func (a *awsSecretsAuth) Headers(ctx context.Context) (map[string]string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.headers != nil && time.Now().Before(a.expiry) {
+		return a.headers, nil
+	}
+	headers := map[string]string{}
+	for _, s := range a.sources {
+		value, err := a.resolve(ctx, s)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve %s from %s %s: %w", s.header, s.service, s.id, err)
+		}
+		headers[s.header] = headerValue(s.header, value)
+	}
+	a.headers, a.expiry = headers, time.Now().Add(a.refresh)
+	return a.headers, nil
+}
+
+func (a *awsSecretsAuth) invalidate() {
+	a.Lock()
+	a.headers = nil
+	a.Unlock()
+}
+
+func (a *awsSecretsAuth) resolve(ctx context.Context, s awsSecretSource) (string, error) {
+	if s.service == "ssm" {
+		var resp struct {
+			Parameter struct {
+				Value string `json:"Value"`
+			} `json:"Parameter"`
+		}
+		err := a.call(ctx, "ssm", "AmazonSSM.GetParameter", map[string]interface{}{"Name": s.id, "WithDecryption": true}, &resp)
+		return resp.Parameter.Value, err
+	}
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := a.call(ctx, "secretsmanager", "secretsmanager.GetSecretValue", map[string]interface{}{"SecretId": s.id}, &resp); err != nil {
+		return "", err
+	}
+	if s.key == "" {
+		return resp.SecretString, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %v", err)
+	}
+	value, ok := fields[s.key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no string key %q", s.key)
+	}
+	return value, nil
+}
+
+// call sends a signed AWS JSON protocol request to service.
+func (a *awsSecretsAuth) call(ctx context.Context, service, target string, input, output interface{}) error {
+	creds, err := a.creds.Get(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+service+"."+a.region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signV4(req, body, creds, a.region, service, time.Now())
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	return json.Unmarshal(respBody, output)
+}
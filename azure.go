@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// azureIMDSTokenURL is where the Azure instance metadata service hands out
+// managed identity tokens.
+const azureIMDSTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// azureManagedIdentity returns the token fetch of the managed identity for
+// resource: the identity endpoint of App Service and Functions when there
+// is one, the instance metadata service of VMs otherwise. A client ID picks
+// a user assigned identity.
+func azureManagedIdentity(resource, clientID string) func(ctx context.Context) (*tokenResponse, error) {
+	return func(ctx context.Context) (*tokenResponse, error) {
+		query := url.Values{"resource": {resource}}
+		if clientID != "" {
+			query.Set("client_id", clientID)
+		}
+		endpoint, header, headerValue := azureIMDSTokenURL, "Metadata", "true"
+		if e := os.Getenv("IDENTITY_ENDPOINT"); e != "" {
+			endpoint, header, headerValue = e, "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+			query.Set("api-version", "2019-08-01")
+		} else {
+			query.Set("api-version", "2018-02-01")
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(header, headerValue)
+		body, err := metadataGet(req)
+		if err != nil {
+			return nil, err
+		}
+		return parseAzureToken([]byte(body))
+	}
+}
+
+// parseAzureToken parses a managed identity token response, which has the
+// expiry as a string rather than a number.
+func parseAzureToken(body []byte) (*tokenResponse, error) {
+	var token struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	expiresIn, _ := strconv.ParseInt(strings.Trim(string(token.ExpiresIn), `"`), 10, 64)
+	return &tokenResponse{AccessToken: token.AccessToken, ExpiresIn: expiresIn}, nil
+}
+
+// azureConnectionString is the part of an Application Insights connection
+// string the exporter needs.
+type azureConnectionString struct {
+	InstrumentationKey string
+	IngestionEndpoint  string
+}
+
+// parseAzureConnectionString parses the key=value pairs of an Application
+// Insights connection string.
+func parseAzureConnectionString(value string) (*azureConnectionString, error) {
+	cs := &azureConnectionString{IngestionEndpoint: "https://dc.services.visualstudio.com"}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.IndexByte(part, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid connection string part %q, expected key=value", part)
+		}
+		switch strings.ToLower(part[:i]) {
+		case "instrumentationkey":
+			cs.InstrumentationKey = part[i+1:]
+		case "ingestionendpoint":
+			cs.IngestionEndpoint = strings.TrimSuffix(part[i+1:], "/")
+		}
+	}
+	if cs.InstrumentationKey == "" {
+		return nil, fmt.Errorf("connection string has no InstrumentationKey")
+	}
+	return cs, nil
+}
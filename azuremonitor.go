@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("azuremonitor", func() (Exporter, error) {
+		e := &azureMonitorExporter{
+			client:    &http.Client{Timeout: exportTimeout},
+			namespace: plugin.AzureNamespace,
+		}
+		if plugin.AzureConnectionString != "" {
+			cs, err := parseAzureConnectionString(plugin.AzureConnectionString)
+			if err != nil {
+				return nil, err
+			}
+			e.insights = cs
+			e.url = cs.IngestionEndpoint + "/v2/track"
+			return e, nil
+		}
+		if plugin.AzureResourceID == "" || plugin.AzureRegion == "" {
+			return nil, fmt.Errorf("the azuremonitor exporter requires --azure-connection-string, or --azure-resource-id and --azure-region")
+		}
+		e.url = "https://" + plugin.AzureRegion + ".monitoring.azure.com/" + strings.TrimPrefix(plugin.AzureResourceID, "/") + "/metrics"
+		e.auth = &bearerAuth{fetch: azureManagedIdentity("https://monitoring.azure.com/", plugin.AzureClientID)}
+		return e, nil
+	})
+}
+
+// azureMaxDimensions is the number of dimensions of a custom metric.
+const azureMaxDimensions = 10
+
+type azureCustomMetric struct {
+	Time string `json:"time"`
+	Data struct {
+		BaseData azureBaseData `json:"baseData"`
+	} `json:"data"`
+}
+
+type azureBaseData struct {
+	Metric    string        `json:"metric"`
+	Namespace string        `json:"namespace"`
+	DimNames  []string      `json:"dimNames,omitempty"`
+	Series    []azureSeries `json:"series"`
+}
+
+type azureSeries struct {
+	DimValues []string `json:"dimValues,omitempty"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+// insightsEnvelope is an Application Insights telemetry item carrying a
+// metric.
+type insightsEnvelope struct {
+	Name string `json:"name"`
+	Time string `json:"time"`
+	IKey string `json:"iKey"`
+	Data struct {
+		BaseType string `json:"baseType"`
+		BaseData struct {
+			Ver        int               `json:"ver"`
+			Metrics    []insightsMetric  `json:"metrics"`
+			Properties map[string]string `json:"properties,omitempty"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+type insightsMetric struct {
+	Name      string  `json:"name"`
+	Namespace string  `json:"ns,omitempty"`
+	Value     float64 `json:"value"`
+	Count     int     `json:"count"`
+}
+
+// azureMonitorExporter sends points to Azure Monitor. With a resource ID
+// they are custom metrics of that resource, sent with a managed identity
+// token; with a connection string they are metrics of an Application
+// Insights resource. Point attributes and the entity and check names are
+// the dimensions.
+type azureMonitorExporter struct {
+	client    *http.Client
+	url       string
+	namespace string
+	auth      *bearerAuth
+	insights  *azureConnectionString
+}
+
+func (e *azureMonitorExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	dims := map[string]string{}
+	if len(batch.Events) > 0 {
+		_, dims["entity"], dims["check"] = converter.EventNames(batch.Events[0])
+	}
+	if e.insights != nil {
+		return e.track(ctx, batch, dims)
+	}
+
+	// Custom metrics are sent one metric, dimension set and minute at a
+	// time, with the points of a series aggregated.
+	type group struct {
+		metric *azureCustomMetric
+		series map[string]int
+	}
+	groups := map[string]*group{}
+	var keys []string
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			pointDims := map[string]string{}
+			for k, v := range dims {
+				if v != "" {
+					pointDims[k] = v
+				}
+			}
+			for _, kv := range p.Attributes {
+				pointDims[kv.Key] = formatAnyValue(kv.Value)
+			}
+			names := make([]string, 0, len(pointDims))
+			for name := range pointDims {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if len(names) > azureMaxDimensions {
+				names = names[:azureMaxDimensions]
+			}
+			values := make([]string, len(names))
+			for i, name := range names {
+				values[i] = pointDims[name]
+			}
+			ts := time.Unix(0, int64(p.TimeUnixNano)).UTC().Truncate(time.Minute).Format(time.RFC3339)
+
+			key := m.Name + "\x00" + strings.Join(names, "\x00") + "\x00" + ts
+			g, ok := groups[key]
+			if !ok {
+				g = &group{metric: &azureCustomMetric{Time: ts}, series: map[string]int{}}
+				g.metric.Data.BaseData = azureBaseData{Metric: m.Name, Namespace: e.namespace, DimNames: names}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+			value := pointValue(p)
+			seriesKey := strings.Join(values, "\x00")
+			base := &g.metric.Data.BaseData
+			if i, ok := g.series[seriesKey]; ok {
+				s := &base.Series[i]
+				s.Min, s.Max = math.Min(s.Min, value), math.Max(s.Max, value)
+				s.Sum += value
+				s.Count++
+				continue
+			}
+			g.series[seriesKey] = len(base.Series)
+			base.Series = append(base.Series, azureSeries{DimValues: values, Min: value, Max: value, Sum: value, Count: 1})
+		}
+	}
+	for _, key := range keys {
+		if err := e.post(ctx, groups[key].metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// track sends the points as Application Insights metric telemetry.
+func (e *azureMonitorExporter) track(ctx context.Context, batch *Batch, dims map[string]string) error {
+	var envelopes []insightsEnvelope
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			var env insightsEnvelope
+			env.Name = "Microsoft.ApplicationInsights.Metric"
+			env.Time = time.Unix(0, int64(p.TimeUnixNano)).UTC().Format(time.RFC3339Nano)
+			env.IKey = e.insights.InstrumentationKey
+			env.Data.BaseType = "MetricData"
+			env.Data.BaseData.Ver = 2
+			env.Data.BaseData.Metrics = []insightsMetric{{Name: m.Name, Namespace: e.namespace, Value: pointValue(p), Count: 1}}
+			props := map[string]string{}
+			for k, v := range dims {
+				if v != "" {
+					props[k] = v
+				}
+			}
+			for _, kv := range p.Attributes {
+				props[kv.Key] = formatAnyValue(kv.Value)
+			}
+			env.Data.BaseData.Properties = props
+			envelopes = append(envelopes, env)
+		}
+	}
+	return e.post(ctx, envelopes)
+}
+
+func (e *azureMonitorExporter) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if e.auth != nil {
+			headers, err := e.auth.Headers(ctx)
+			if err != nil {
+				return err
+			}
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+		}
+		return doHTTP(e.client, req)
+	})
+}
+
+func (e *azureMonitorExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *azureMonitorExporter) String() string {
+	return "azuremonitor " + e.url
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// applyConfigBundle sets the options of the signed configuration bundle of
+// --config-bundle, if any, once its signature is verified with the public
+// key of --config-bundle-key. The bundle is a JSON object of option names
+// and values, such as
+//
+//	{"routes": "namespace=prod-* => otlp", "exporter-policy": "splunk:queue=100"}
+//
+// Its values override those of the environment, the arguments and the
+// annotations, so the pipeline runs with the attested configuration only.
+func applyConfigBundle() error {
+	if plugin.ConfigBundle == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(plugin.ConfigBundle)
+	if err != nil {
+		return fmt.Errorf("--config-bundle: %v", err)
+	}
+	if plugin.ConfigBundleKey == "" {
+		return fmt.Errorf("--config-bundle-key: required to verify --config-bundle")
+	}
+	key, err := ioutil.ReadFile(plugin.ConfigBundleKey)
+	if err != nil {
+		return fmt.Errorf("--config-bundle-key: %v", err)
+	}
+	minisign := !bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN"))
+	sigFile := plugin.ConfigBundleSignature
+	if sigFile == "" {
+		sigFile = plugin.ConfigBundle + ".sig"
+		if minisign {
+			sigFile = plugin.ConfigBundle + ".minisig"
+		}
+	}
+	sig, err := ioutil.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("--config-bundle-signature: %v", err)
+	}
+	var signer string
+	if minisign {
+		signer, err = verifyMinisign(key, sig, data)
+	} else {
+		signer, err = verifyCosign(key, sig, data)
+	}
+	if err != nil {
+		return fmt.Errorf("--config-bundle: signature verification failed: %v", err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("--config-bundle: %v", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := setBundleOption(name, values[name]); err != nil {
+			return fmt.Errorf("--config-bundle: %v", err)
+		}
+	}
+	log.Printf("applied configuration bundle %s signed by %s: %s", plugin.ConfigBundle, signer, strings.Join(names, ", "))
+	return nil
+}
+
+// setBundleOption sets the option named name to value, a JSON string,
+// boolean or number matching the type of the option.
+func setBundleOption(name string, value interface{}) error {
+	if strings.HasPrefix(name, "config-bundle") {
+		return fmt.Errorf("option %q cannot be set by the bundle", name)
+	}
+	for _, opt := range options {
+		if opt.Argument != name {
+			continue
+		}
+		switch v := opt.Value.(type) {
+		case *string:
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("option %q must be a string", name)
+			}
+			*v = s
+		case *bool:
+			b, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("option %q must be a boolean", name)
+			}
+			*v = b
+		case *int64:
+			f, ok := value.(float64)
+			if !ok || f != float64(int64(f)) {
+				return fmt.Errorf("option %q must be an integer", name)
+			}
+			*v = int64(f)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown option %q", name)
+}
+
+// verifyMinisign verifies a minisign signature of data, legacy or
+// prehashed, including its trusted comment, and returns the key ID.
+func verifyMinisign(key, sig, data []byte) (string, error) {
+	pub, err := minisignLine(key, 1)
+	if err != nil || len(pub) != 42 || string(pub[:2]) != "Ed" {
+		return "", fmt.Errorf("invalid minisign public key")
+	}
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", fmt.Errorf("invalid minisign signature")
+	}
+	s, err1 := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	global, err2 := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err1 != nil || err2 != nil || len(s) != 74 || len(global) != ed25519.SignatureSize {
+		return "", fmt.Errorf("invalid minisign signature")
+	}
+	keyID := fmt.Sprintf("%X", reverse(pub[2:10]))
+	if !bytes.Equal(s[2:10], pub[2:10]) {
+		return "", fmt.Errorf("signed by key %X, not %s", reverse(s[2:10]), keyID)
+	}
+	message := data
+	switch string(s[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	default:
+		return "", fmt.Errorf("unsupported minisign algorithm %q", s[:2])
+	}
+	pk := ed25519.PublicKey(pub[10:])
+	if !ed25519.Verify(pk, message, s[10:]) {
+		return "", fmt.Errorf("invalid signature")
+	}
+	comment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ed25519.Verify(pk, append(append([]byte{}, s[10:]...), comment...), global) {
+		return "", fmt.Errorf("invalid trusted comment signature")
+	}
+	return "minisign key " + keyID, nil
+}
+
+// minisignLine decodes the base64 line i of a minisign file.
+func minisignLine(file []byte, i int) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+	if len(lines) <= i {
+		return nil, fmt.Errorf("truncated file")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(lines[i]))
+}
+
+// reverse returns b in reverse order: minisign key IDs are little endian.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// verifyCosign verifies a signature of data made with cosign sign-blob: the
+// base64 ASN.1 ECDSA signature of its SHA-256 digest, or an Ed25519
+// signature, checked with a PEM public key.
+func verifyCosign(key, sig, data []byte) (string, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return "", fmt.Errorf("invalid PEM public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	fingerprint := sha256.Sum256(block.Bytes)
+	signer := fmt.Sprintf("key sha256:%x", fingerprint[:8])
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(s, &rs); err != nil {
+			return "", fmt.Errorf("invalid ECDSA signature: %v", err)
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.Verify(pub, digest[:], rs.R, rs.S) {
+			return "", fmt.Errorf("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, data, s) {
+			return "", fmt.Errorf("invalid signature")
+		}
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return signer, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyCosign(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	data := []byte(`{"routes": "* => otlp"}`)
+	digest := sha256.Sum256(data)
+	r, ss, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, ss})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := []byte(base64.StdEncoding.EncodeToString(sig))
+
+	if _, err := verifyCosign(key, encoded, data); err != nil {
+		t.Errorf("verifyCosign: %v", err)
+	}
+	if _, err := verifyCosign(key, encoded, []byte(`{"routes": "* => file"}`)); err == nil {
+		t.Errorf("verifyCosign of modified data succeeded, want error")
+	}
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	key := fmt.Sprintf("untrusted comment: minisign public key\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...)))
+	data := []byte(`{"signals": "metrics"}`)
+	s := ed25519.Sign(priv, data)
+	comment := "timestamp:1700000000"
+	global := ed25519.Sign(priv, append(append([]byte{}, s...), comment...))
+	sig := fmt.Sprintf("untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), s...)),
+		comment, base64.StdEncoding.EncodeToString(global))
+
+	if _, err := verifyMinisign([]byte(key), []byte(sig), data); err != nil {
+		t.Errorf("verifyMinisign: %v", err)
+	}
+	if _, err := verifyMinisign([]byte(key), []byte(sig), []byte(`{"signals": "events"}`)); err == nil {
+		t.Errorf("verifyMinisign of modified data succeeded, want error")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// canaryMetric is the metric exported every --canary-interval, whose
+// value, the time it was sent in seconds, tells one canary from another.
+const canaryMetric = "sensu.otel.handler.canary"
+
+// canaryPollInterval is how often the backend is queried until the canary
+// shows up.
+var canaryPollInterval = time.Second
+
+// canaryStatus is the outcome of the last canary, as reported by /readyz.
+type canaryStatus struct {
+	Verified bool      `json:"verified"`
+	Sent     time.Time `json:"sent"`
+	// Delay is how long the canary took to show up in the backend, in
+	// seconds.
+	Delay float64 `json:"delay_seconds,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// canaryCheck confirms that the canaries arrive in the backend, by
+// querying its Prometheus HTTP API, so that delivery is verified end to
+// end rather than assumed from a successful export. Without a query URL
+// only the export is checked.
+type canaryCheck struct {
+	queryURL string
+	query    string
+	token    string
+	timeout  time.Duration
+	client   *http.Client
+
+	sync.Mutex
+	last     *canaryStatus
+	failures int64
+}
+
+func newCanaryCheck(queryURL, query, token string, timeout time.Duration) *canaryCheck {
+	return &canaryCheck{
+		queryURL: strings.TrimSuffix(queryURL, "/"),
+		query:    query,
+		token:    token,
+		timeout:  timeout,
+		client:   &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// runCanary exports a canary every interval and verifies that it arrived.
+// It never returns.
+func (ot *otelPlugin) runCanary(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		value := float64(now.Unix())
+		err := ot.eventToOtel(selfEvent([]*types.MetricPoint{selfPoint(canaryMetric, value, now.UnixNano())}))
+		if err != nil {
+			err = fmt.Errorf("export failed: %v", err)
+		} else if ot.canary.queryURL != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), ot.canary.timeout)
+			err = ot.canary.verify(ctx, value)
+			cancel()
+		}
+		ot.canary.observe(now, time.Now(), err)
+		if err != nil {
+			errorLog.Printf("canary not verified: %v", err)
+		}
+	}
+}
+
+// verify queries the backend until a canary with value shows up or ctx is
+// done.
+func (c *canaryCheck) verify(ctx context.Context, value float64) error {
+	ticker := time.NewTicker(canaryPollInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		found, err := c.lookup(ctx, value)
+		if found {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("not found within %s, last query failed: %v", c.timeout, lastErr)
+			}
+			return fmt.Errorf("not found within %s", c.timeout)
+		}
+	}
+}
+
+// lookup reports whether the result of the query holds a sample equal to
+// value.
+func (c *canaryCheck) lookup(ctx context.Context, value float64) (bool, error) {
+	u := c.queryURL + "/api/v1/query?query=" + url.QueryEscape(c.query)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, &httpStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Value [2]interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, err
+	}
+	if result.Status != "success" {
+		return false, fmt.Errorf("query failed: %s", result.Error)
+	}
+	for _, r := range result.Data.Result {
+		s, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// observe records the outcome of the canary sent at sent.
+func (c *canaryCheck) observe(sent, now time.Time, err error) {
+	c.Lock()
+	defer c.Unlock()
+	c.last = &canaryStatus{Verified: err == nil, Sent: sent}
+	if err != nil {
+		c.last.Error = err.Error()
+		c.failures++
+	} else {
+		c.last.Delay = now.Sub(sent).Seconds()
+	}
+}
+
+// status returns the outcome of the last canary, nil before the first.
+func (c *canaryCheck) status() *canaryStatus {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.last == nil {
+		return nil
+	}
+	s := *c.last
+	return &s
+}
+
+// metricPoints reports whether the last canary was verified, how long it
+// took and the number of canaries that were not.
+func (c *canaryCheck) metricPoints(now time.Time) []*types.MetricPoint {
+	s := c.status()
+	if s == nil {
+		return nil
+	}
+	c.Lock()
+	failures := c.failures
+	c.Unlock()
+	ts := now.UnixNano()
+	verified := 0.0
+	if s.Verified {
+		verified = 1
+	}
+	return []*types.MetricPoint{
+		selfPoint("sensu.otel.canary.verified", verified, ts),
+		selfPoint("sensu.otel.canary.delay_seconds", s.Delay, ts),
+		selfPoint("sensu.otel.canary.failures", float64(failures), ts),
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanaryVerify(t *testing.T) {
+	saved := canaryPollInterval
+	canaryPollInterval = 10 * time.Millisecond
+	defer func() { canaryPollInterval = saved }()
+
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" || r.URL.Query().Get("query") != "sensu_otel_handler_canary" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		queries++
+		// The canary shows up at the third query.
+		value := "1600000000"
+		if queries >= 3 {
+			value = "1600000060"
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1600000061,%q]}]}}`, value)
+	}))
+	defer srv.Close()
+
+	c := newCanaryCheck(srv.URL+"/", "sensu_otel_handler_canary", "secret", time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.verify(ctx, 1600000060); err != nil {
+		t.Fatal(err)
+	}
+	if queries != 3 {
+		t.Errorf("got %d queries, want 3", queries)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.verify(ctx, 1600000120); err == nil {
+		t.Error("missing canary verified")
+	}
+
+	c.token = "wrong"
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.verify(ctx, 1600000060); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got %v, want the failed query", err)
+	}
+}
+
+func TestCanaryReady(t *testing.T) {
+	ot := newOtelPlugin()
+	ot.exporter = &preflightExporter{}
+	ot.canary = newCanaryCheck("", "", "", time.Second)
+
+	ready := func() (int, *canaryStatus) {
+		rec := httptest.NewRecorder()
+		ot.serveReady(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct {
+			Canary *canaryStatus `json:"canary"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body.Canary
+	}
+	if code, canary := ready(); code != http.StatusOK || canary != nil {
+		t.Errorf("before the first canary: got %d, %+v", code, canary)
+	}
+
+	sent := time.Now()
+	ot.canary.observe(sent, sent.Add(2*time.Second), nil)
+	if code, canary := ready(); code != http.StatusOK || !canary.Verified || canary.Delay != 2 {
+		t.Errorf("verified canary: got %d, %+v", code, canary)
+	}
+
+	ot.canary.observe(sent, sent.Add(time.Minute), errors.New("not found within 1m0s"))
+	if code, canary := ready(); code != http.StatusServiceUnavailable || canary.Verified || canary.Error == "" {
+		t.Errorf("lost canary: got %d, %+v", code, canary)
+	}
+	points := ot.canary.metricPoints(sent)
+	if len(points) != 3 || points[0].Value != 0 || points[2].Value != 1 {
+		t.Errorf("got points %v", points)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("carbon", func() (Exporter, error) {
+		if plugin.CarbonAddress == "" {
+			return nil, fmt.Errorf("the carbon exporter requires --carbon-address")
+		}
+		return &carbonExporter{address: plugin.CarbonAddress, template: plugin.CarbonTemplate}, nil
+	})
+}
+
+// carbonPlaceholder matches the {placeholders} of a carbon name template.
+var carbonPlaceholder = regexp.MustCompile(`\{[^{}]+\}`)
+
+// carbonExporter sends points over the Carbon plaintext protocol, one
+// "path value timestamp" line per point, on a TCP connection that is
+// re-established after errors.
+type carbonExporter struct {
+	address  string
+	template string
+
+	sync.Mutex
+	conn net.Conn
+}
+
+func (e *carbonExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	var names map[string]string
+	if len(batch.Events) > 0 {
+		namespace, entity, check := converter.EventNames(batch.Events[0])
+		names = map[string]string{"namespace": namespace, "entity": entity, "check": check}
+	}
+
+	var buf bytes.Buffer
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			path := carbonPath(e.template, m.Name, names, p.Attributes)
+			ts := int64(p.TimeUnixNano) / int64(time.Second)
+			fmt.Fprintf(&buf, "%s %s %d\n", path, strconv.FormatFloat(pointValue(p), 'f', -1, 64), ts)
+		}
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	return retryExport(ctx, func(ctx context.Context) error {
+		if e.conn == nil {
+			conn, err := egressDial(ctx, "tcp", e.address)
+			if err != nil {
+				return err
+			}
+			e.conn = conn
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = e.conn.SetWriteDeadline(deadline)
+		}
+		if _, err := e.conn.Write(buf.Bytes()); err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return err
+		}
+		return nil
+	})
+}
+
+// carbonPath expands a name template such as
+// "sensu.{namespace}.{entity}.{name}". {name} is the metric name, {entity},
+// {check} and {namespace} come from the event and any other placeholder
+// is the value of the point attribute of that name. Values are sanitized
+// into single path nodes; {name} is kept as is since Sensu metric names are
+// usually Graphite paths already.
+func carbonPath(template, name string, names map[string]string, attrs []*commonpb.KeyValue) string {
+	return carbonPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := placeholder[1 : len(placeholder)-1]
+		if key == "name" {
+			return strings.Replace(name, " ", "_", -1)
+		}
+		for _, kv := range attrs {
+			if kv.Key == key {
+				return carbonNode(formatAnyValue(kv.Value))
+			}
+		}
+		if v, ok := names[key]; ok && v != "" {
+			return carbonNode(v)
+		}
+		return "unknown"
+	})
+}
+
+// carbonNode replaces the characters that would split or break a Graphite
+// path node.
+func carbonNode(value string) string {
+	return strings.NewReplacer(".", "_", " ", "_", "/", "_", "\n", "_").Replace(value)
+}
+
+// Shutdown closes the connection.
+func (e *carbonExporter) Shutdown(context.Context) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+func (e *carbonExporter) String() string {
+	return "carbon " + e.address
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chaosFlag is the hidden option injecting export failures. It is left out
+// of options so that it appears neither in the usage nor in the Sensu
+// handler definition; outside of the command line it is read from
+// OTEL_SENSU_CHAOS.
+const (
+	chaosFlag = "chaos"
+	chaosEnv  = "OTEL_SENSU_CHAOS"
+)
+
+// chaosSetting returns the --chaos setting, from the command line or the
+// environment.
+func chaosSetting() string {
+	if plugin.Chaos != "" {
+		return plugin.Chaos
+	}
+	return os.Getenv(chaosEnv)
+}
+
+// chaosConfig is how often exports fail on purpose, to try out retries,
+// the record directory and alerting before relying on them.
+type chaosConfig struct {
+	// errors is the probability of an export failing with a retryable
+	// error.
+	errors float64
+	// partial is the probability of only part of a batch being exported.
+	partial float64
+	// latency is the largest delay added before an export.
+	latency time.Duration
+}
+
+// parseChaos parses the comma separated key=value settings of --chaos,
+// with the keys errors and partial, probabilities between 0 and 1, and
+// latency, for example
+//
+//	errors=0.1,partial=0.05,latency=2s
+//
+// It returns nil when value is empty.
+func parseChaos(value string) (*chaosConfig, error) {
+	settings := splitList(value)
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	c := &chaosConfig{}
+	for _, setting := range settings {
+		i := strings.IndexByte(setting, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid setting %q, expected key=value", setting)
+		}
+		key, v := setting[:i], setting[i+1:]
+		var err error
+		switch key {
+		case "errors":
+			c.errors, err = parseProbability(v)
+		case "partial":
+			c.partial, err = parseProbability(v)
+		case "latency":
+			c.latency, err = time.ParseDuration(v)
+			if err == nil && c.latency < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		default:
+			err = fmt.Errorf("unknown setting, expected errors, partial or latency")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	if c.errors+c.partial > 1 {
+		return nil, fmt.Errorf("errors and partial add up to more than 1")
+	}
+	return c, nil
+}
+
+func parseProbability(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("must be between 0 and 1")
+	}
+	return p, nil
+}
+
+// chaosRand is shared by the exporters, rand.Rand is not safe for
+// concurrent use.
+var chaosRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+func chaosFloat() float64 {
+	chaosRand.Lock()
+	defer chaosRand.Unlock()
+	return chaosRand.Float64()
+}
+
+// withChaos wraps exporter, registered as name, to inject the failures of
+// --chaos, if it is set.
+func withChaos(name string, exporter Exporter) (Exporter, error) {
+	c, err := parseChaos(chaosSetting())
+	if err != nil || c == nil {
+		return exporter, err
+	}
+	log.Printf("warning: %s: injecting export failures with --%s %s", name, chaosFlag, chaosSetting())
+	return &chaosExporter{Exporter: exporter, name: name, chaos: c}, nil
+}
+
+// chaosExporter delays exports and makes them fail at random.
+type chaosExporter struct {
+	Exporter
+	name  string
+	chaos *chaosConfig
+}
+
+func (e *chaosExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.ExportResources(ctx, []ResourceBatch{{Resource: res, Batch: batch}})
+}
+
+// ExportResources injects the failures in the export of several resources
+// at once: a partial export sends the first half of every batch.
+func (e *chaosExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	if e.chaos.latency > 0 {
+		delay := time.Duration(chaosFloat() * float64(e.chaos.latency))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r := chaosFloat()
+	switch {
+	case r < e.chaos.errors:
+		return status.Error(codes.Unavailable, "chaos: injected export failure")
+	case r < e.chaos.errors+e.chaos.partial:
+		parts := make([]ResourceBatch, len(batches))
+		var sent, total Batch
+		for i, b := range batches {
+			parts[i] = ResourceBatch{Resource: b.Resource, Batch: halfBatch(b.Batch)}
+			appendBatch(&sent, parts[i].Batch)
+			appendBatch(&total, b.Batch)
+		}
+		if err := exporter.ExportResources(ctx, e.Exporter, parts); err != nil {
+			return err
+		}
+		return status.Errorf(codes.Unavailable, "chaos: injected partial export, %d of %d metrics, %d of %d logs and %d of %d spans sent",
+			len(sent.Metrics), len(total.Metrics), len(sent.Logs), len(total.Logs), len(sent.Spans), len(total.Spans))
+	}
+	return exporter.ExportResources(ctx, e.Exporter, batches)
+}
+
+func (e *chaosExporter) String() string {
+	return destination(e.name, e.Exporter)
+}
+
+// halfBatch returns the first half, rounded down, of the telemetry of
+// batch.
+func halfBatch(batch *Batch) *Batch {
+	return &Batch{
+		Events:  batch.Events,
+		Metrics: batch.Metrics[:len(batch.Metrics)/2],
+		Logs:    batch.Logs[:len(batch.Logs)/2],
+		Spans:   batch.Spans[:len(batch.Spans)/2],
+	}
+}
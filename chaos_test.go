@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+type countingExporter struct{ metrics int }
+
+func (e *countingExporter) Export(_ context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	e.metrics += len(batch.Metrics)
+	return nil
+}
+func (e *countingExporter) Shutdown(context.Context) error { return nil }
+
+func TestParseChaos(t *testing.T) {
+	c, err := parseChaos("errors=0.1, partial=0.05, latency=2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.errors != 0.1 || c.partial != 0.05 || c.latency.Seconds() != 2 {
+		t.Errorf("got %+v", c)
+	}
+	if c, err := parseChaos(""); c != nil || err != nil {
+		t.Errorf("empty setting: got %v, %v", c, err)
+	}
+	for _, value := range []string{"errors", "errors=2", "partial=-0.1", "latency=-1s", "drop=0.5", "errors=0.6,partial=0.6"} {
+		if _, err := parseChaos(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestChaosExporter(t *testing.T) {
+	batch := &Batch{Metrics: []*metricpb.Metric{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}}
+
+	inner := &countingExporter{}
+	e := &chaosExporter{Exporter: inner, name: "test", chaos: &chaosConfig{errors: 1}}
+	err := e.Export(context.Background(), nil, batch)
+	if exportExitCode(err) != exitRetryable || inner.metrics != 0 {
+		t.Errorf("errors=1: got %v with %d metrics exported", err, inner.metrics)
+	}
+
+	e.chaos = &chaosConfig{partial: 1}
+	err = e.Export(context.Background(), nil, batch)
+	if exportExitCode(err) != exitRetryable || inner.metrics != 2 {
+		t.Errorf("partial=1: got %v with %d metrics exported", err, inner.metrics)
+	}
+
+	e.chaos = &chaosConfig{}
+	if err := e.Export(context.Background(), nil, batch); err != nil || inner.metrics != 6 {
+		t.Errorf("no chaos: got %v with %d metrics exported", err, inner.metrics)
+	}
+}
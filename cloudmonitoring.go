@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("cloudmonitoring", func() (Exporter, error) {
+		if _, err := gcpResourceType(plugin.GCPResource); err != nil {
+			return nil, err
+		}
+		fetch, err := gcpTokenSource(gcpMonitoringScope)
+		if err != nil {
+			return nil, err
+		}
+		return &cloudMonitoringExporter{
+			client:       &http.Client{Timeout: exportTimeout},
+			auth:         &bearerAuth{fetch: fetch},
+			project:      plugin.GCPProject,
+			resourceType: plugin.GCPResource,
+			location:     plugin.GCPLocation,
+		}, nil
+	})
+}
+
+const (
+	// gcpMonitoringScope is the OAuth2 scope of writing time series.
+	gcpMonitoringScope = "https://www.googleapis.com/auth/monitoring.write"
+	// cloudMonitoringMaxSeries is the number of time series of one request.
+	cloudMonitoringMaxSeries = 200
+	// cloudMonitoringMaxLabels is the number of labels of a custom metric.
+	cloudMonitoringMaxLabels = 10
+	// cloudMonitoringMetricPrefix is the metric type prefix of the points.
+	cloudMonitoringMetricPrefix = "custom.googleapis.com/sensu/"
+)
+
+// gcpResourceType checks the --gcp-resource value: auto picks gce_instance
+// for entities labeled with their instance and generic_node otherwise.
+func gcpResourceType(value string) (string, error) {
+	switch value {
+	case "auto", "generic_node", "gce_instance":
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown monitored resource type %q, expected auto, generic_node or gce_instance", value)
+}
+
+type timeSeriesRequest struct {
+	TimeSeries []timeSeries `json:"timeSeries"`
+}
+
+type timeSeries struct {
+	Metric     monitoredObject   `json:"metric"`
+	Resource   monitoredObject   `json:"resource"`
+	MetricKind string            `json:"metricKind"`
+	ValueType  string            `json:"valueType"`
+	Points     []timeSeriesPoint `json:"points"`
+}
+
+type monitoredObject struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type timeSeriesPoint struct {
+	Interval struct {
+		EndTime string `json:"endTime"`
+	} `json:"interval"`
+	Value struct {
+		DoubleValue float64 `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// cloudMonitoringExporter writes points as custom gauge metrics with the
+// Cloud Monitoring API, authenticated with the application default
+// credentials. The entity is the monitored resource: a generic_node, or the
+// gce_instance of the gce_instance_id and gce_zone entity labels.
+type cloudMonitoringExporter struct {
+	client       *http.Client
+	auth         *bearerAuth
+	resourceType string
+	location     string
+
+	sync.Mutex
+	project string
+}
+
+func (e *cloudMonitoringExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	project, err := e.projectID(ctx)
+	if err != nil {
+		return err
+	}
+	resource := monitoredObject{Type: "global", Labels: map[string]string{"project_id": project}}
+	var check string
+	if len(batch.Events) > 0 {
+		resource = e.resource(project, batch.Events[0])
+		_, _, check = converter.EventNames(batch.Events[0])
+	}
+
+	// A request may not write the same time series twice, so a repeated
+	// series starts a new request.
+	var requests []timeSeriesRequest
+	var current timeSeriesRequest
+	seen := map[string]bool{}
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			metric := monitoredObject{
+				Type:   cloudMonitoringMetricPrefix + metricTypeName(m.Name),
+				Labels: metricLabels(check, p.Attributes),
+			}
+			key := seriesKey(metric)
+			if seen[key] || len(current.TimeSeries) == cloudMonitoringMaxSeries {
+				requests = append(requests, current)
+				current, seen = timeSeriesRequest{}, map[string]bool{}
+			}
+			seen[key] = true
+
+			var point timeSeriesPoint
+			point.Interval.EndTime = time.Unix(0, int64(p.TimeUnixNano)).UTC().Format(time.RFC3339Nano)
+			point.Value.DoubleValue = pointValue(p)
+			current.TimeSeries = append(current.TimeSeries, timeSeries{
+				Metric:     metric,
+				Resource:   resource,
+				MetricKind: "GAUGE",
+				ValueType:  "DOUBLE",
+				Points:     []timeSeriesPoint{point},
+			})
+		}
+	}
+	if len(current.TimeSeries) > 0 {
+		requests = append(requests, current)
+	}
+
+	endpoint := "https://monitoring.googleapis.com/v3/projects/" + url.PathEscape(project) + "/timeSeries"
+	for _, r := range requests {
+		body, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err := retryExport(ctx, func(ctx context.Context) error {
+			headers, err := e.auth.Headers(ctx)
+			if err != nil {
+				return err
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			return doHTTP(e.client, req)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectID returns the project the points are written to, looked up once.
+func (e *cloudMonitoringExporter) projectID(ctx context.Context) (string, error) {
+	e.Lock()
+	defer e.Unlock()
+	if e.project != "" {
+		return e.project, nil
+	}
+	project, err := gcpProject(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	e.project = project
+	return project, nil
+}
+
+// resource maps the entity of an event to a monitored resource.
+func (e *cloudMonitoringExporter) resource(project string, event *types.Event) monitoredObject {
+	namespace, entity, _ := converter.EventNames(event)
+	var labels map[string]string
+	if event.Entity != nil {
+		labels = event.Entity.Labels
+	}
+	instance, zone := labels["gce_instance_id"], labels["gce_zone"]
+	if e.resourceType == "gce_instance" || e.resourceType == "auto" && instance != "" && zone != "" {
+		return monitoredObject{Type: "gce_instance", Labels: map[string]string{
+			"project_id":  project,
+			"instance_id": instance,
+			"zone":        zone,
+		}}
+	}
+	return monitoredObject{Type: "generic_node", Labels: map[string]string{
+		"project_id": project,
+		"location":   e.location,
+		"namespace":  namespace,
+		"node_id":    entity,
+	}}
+}
+
+// metricTypeName replaces the characters not allowed in a metric type.
+func metricTypeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '/' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// metricLabels returns the check and the point attributes as metric
+// labels, up to the number of labels a custom metric may have.
+func metricLabels(check string, attrs []*commonpb.KeyValue) map[string]string {
+	labels := map[string]string{}
+	if check != "" {
+		labels["sensu_check"] = check
+	}
+	for _, kv := range attrs {
+		if len(labels) == cloudMonitoringMaxLabels {
+			break
+		}
+		labels[labelKey(kv.Key)] = formatAnyValue(kv.Value)
+	}
+	return labels
+}
+
+// labelKey makes an attribute key a valid label key: lower case letters,
+// digits and underscores, starting with a letter.
+func labelKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, key)
+	if key == "" || key[0] < 'a' || key[0] > 'z' {
+		key = "l_" + key
+	}
+	return key
+}
+
+func seriesKey(metric monitoredObject) string {
+	keys := make([]string, 0, len(metric.Labels))
+	for k, v := range metric.Labels {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return metric.Type + "{" + strings.Join(keys, ",") + "}"
+}
+
+func (e *cloudMonitoringExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *cloudMonitoringExporter) String() string {
+	if e.project == "" {
+		return "cloudmonitoring"
+	}
+	return "cloudmonitoring " + e.project
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("cloudwatch", func() (Exporter, error) {
+		region := awsRegion(plugin.CloudWatchRegion)
+		if region == "" {
+			return nil, fmt.Errorf("the cloudwatch exporter requires --cloudwatch-region or AWS_REGION")
+		}
+		return &cloudWatchExporter{
+			client:     &http.Client{Timeout: exportTimeout},
+			url:        "https://monitoring." + region + ".amazonaws.com/",
+			region:     region,
+			namespace:  plugin.CloudWatchNamespace,
+			dimensions: splitList(plugin.CloudWatchDimensions),
+			creds:      &awsCredentialChain{},
+		}, nil
+	})
+}
+
+const (
+	// cloudWatchMaxData is the number of metric data of one PutMetricData
+	// request.
+	cloudWatchMaxData = 1000
+	// cloudWatchMaxDimensions is the number of dimensions of a metric.
+	cloudWatchMaxDimensions = 30
+)
+
+// cloudWatchExporter sends points to CloudWatch with PutMetricData, signed
+// with credentials from the AWS credential chain. The dimensions of a
+// point are the configured names: entity, check and namespace come from
+// the event, other names from the point attributes.
+type cloudWatchExporter struct {
+	client     *http.Client
+	url        string
+	region     string
+	namespace  string
+	dimensions []string
+	creds      *awsCredentialChain
+}
+
+func (e *cloudWatchExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	names := map[string]string{}
+	if len(batch.Events) > 0 {
+		names["namespace"], names["entity"], names["check"] = converter.EventNames(batch.Events[0])
+	}
+
+	form := e.newForm()
+	n := 0
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			n++
+			prefix := "MetricData.member." + strconv.Itoa(n) + "."
+			form.Set(prefix+"MetricName", m.Name)
+			form.Set(prefix+"Value", strconv.FormatFloat(pointValue(p), 'g', -1, 64))
+			form.Set(prefix+"Timestamp", time.Unix(0, int64(p.TimeUnixNano)).UTC().Format(time.RFC3339))
+			form.Set(prefix+"Unit", "None")
+			for i, dim := range e.pointDimensions(names, p.Attributes) {
+				dimPrefix := prefix + "Dimensions.member." + strconv.Itoa(i+1) + "."
+				form.Set(dimPrefix+"Name", dim[0])
+				form.Set(dimPrefix+"Value", dim[1])
+			}
+			if n == cloudWatchMaxData {
+				if err := e.put(ctx, form); err != nil {
+					return err
+				}
+				form, n = e.newForm(), 0
+			}
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return e.put(ctx, form)
+}
+
+func (e *cloudWatchExporter) newForm() url.Values {
+	return url.Values{
+		"Action":    {"PutMetricData"},
+		"Version":   {"2010-08-01"},
+		"Namespace": {e.namespace},
+	}
+}
+
+// pointDimensions returns the name and value of the dimensions of a point,
+// skipping names without a value.
+func (e *cloudWatchExporter) pointDimensions(names map[string]string, attrs []*commonpb.KeyValue) [][2]string {
+	var dims [][2]string
+	for _, name := range e.dimensions {
+		value := names[name]
+		for _, kv := range attrs {
+			if kv.Key == name {
+				value = formatAnyValue(kv.Value)
+			}
+		}
+		if value == "" {
+			continue
+		}
+		dims = append(dims, [2]string{name, value})
+		if len(dims) == cloudWatchMaxDimensions {
+			break
+		}
+	}
+	return dims
+}
+
+func (e *cloudWatchExporter) put(ctx context.Context, form url.Values) error {
+	body := []byte(form.Encode())
+	return retryExport(ctx, func(ctx context.Context) error {
+		creds, err := e.creds.Get(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, strings.NewReader(string(body)))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		signV4(req, body, creds, e.region, "monitoring", time.Now())
+		err = doHTTP(e.client, req)
+		if se, ok := err.(*httpStatusError); ok && strings.Contains(se.Body, "Throttling") {
+			// Throttling is reported as a client error but worth retrying.
+			return fmt.Errorf("cloudwatch throttled the request: %s", se.Body)
+		}
+		return err
+	})
+}
+
+func (e *cloudWatchExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *cloudWatchExporter) String() string {
+	return "cloudwatch " + e.region + " " + e.namespace
+}
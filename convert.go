@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// runConvert reads a single Sensu event from stdin and writes the OTLP
+// export request it converts to as JSON on stdout, without exporting
+// anything. The event goes through the stages of --pipeline up to convert,
+// configured from the environment as the handler is. It is meant for
+// checking metric output in CI pipelines; --canonical-output makes the
+// JSON stable enough to compare with a snapshot.
+//
+//	$ OTEL_SENSU_METRIC_SCALE='*_ms=/1000 s' ./otel-sensu-handler-plugin convert < test-event.json
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	compact := flags.Bool("compact", false, "write the request on a single line")
+	canonical := flags.Bool("canonical-output", false, "write stable JSON, with sorted fields, metrics, data points and attributes, for snapshot tests")
+	_ = flags.Parse(args)
+
+	var e types.Event
+	if err := json.NewDecoder(os.Stdin).Decode(&e); err != nil {
+		return fmt.Errorf("event parse error: %v", err)
+	}
+
+	ot := newOtelPlugin()
+	if err := ot.setupConversion(); err != nil {
+		return err
+	}
+	stages, err := parsePipeline(plugin.Pipeline)
+	if err != nil {
+		return err
+	}
+	for i, name := range stages {
+		if name == "convert" {
+			stages = stages[:i+1]
+			break
+		}
+	}
+	ot.pipeline = ot.buildPipeline(stages)
+	pe := &pipelineEvent{event: &e}
+	if err := ot.runPipeline(context.Background(), pe); err != nil {
+		return err
+	}
+	var metrics []*metricpb.Metric
+	if pe.batch != nil {
+		metrics = pe.batch.Metrics
+	}
+	req := converter.MetricsRequest(ot.resource(pe.event), metrics)
+
+	var out []byte
+	if *canonical {
+		out, err = canonicalJSON(req, !*compact)
+	} else {
+		opts := protojson.MarshalOptions{Multiline: !*compact, Indent: "  "}
+		out, err = opts.Marshal(req)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", out)
+	return err
+}
+
+// canonicalJSON marshals msg as JSON that only changes when the converted
+// telemetry does. protojson output deliberately varies in whitespace and
+// keeps the order of repeated fields, so the fields of every object are
+// sorted by name, metrics by name, data points by their content and
+// attributes by key.
+func canonicalJSON(msg proto.Message, indent bool) ([]byte, error) {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v = canonicalize("", v)
+	if indent {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// canonicalize sorts the repeated fields of v, found under field, whose
+// order carries no meaning. encoding/json sorts the fields of objects.
+func canonicalize(field string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = canonicalize(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = canonicalize("", child)
+		}
+		var sortKey func(interface{}) string
+		switch {
+		case field == "metrics":
+			sortKey = func(e interface{}) string { return stringField(e, "name") }
+		case field == "dataPoints":
+			sortKey = func(e interface{}) string {
+				b, _ := json.Marshal(e)
+				return string(b)
+			}
+		case field == "attributes" || strings.HasSuffix(field, "Attributes"):
+			sortKey = func(e interface{}) string { return stringField(e, "key") }
+		default:
+			return v
+		}
+		sort.SliceStable(v, func(i, j int) bool { return sortKey(v[i]) < sortKey(v[j]) })
+		return v
+	}
+	return v
+}
+
+func stringField(v interface{}, name string) string {
+	m, _ := v.(map[string]interface{})
+	s, _ := m[name].(string)
+	return s
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("datadog", func() (Exporter, error) {
+		if plugin.DatadogAPIKey == "" {
+			return nil, fmt.Errorf("the datadog exporter requires --datadog-api-key")
+		}
+		tagMap, err := parseTagMap(plugin.DatadogTagMap)
+		if err != nil {
+			return nil, err
+		}
+		return &datadogExporter{
+			client: &http.Client{Timeout: exportTimeout},
+			url:    "https://api." + plugin.DatadogSite + "/api/v2/series",
+			apiKey: plugin.DatadogAPIKey,
+			tagMap: tagMap,
+		}, nil
+	})
+}
+
+// datadogGauge is the metric type of the Datadog v2 series API.
+const datadogGauge = 3
+
+type datadogPayload struct {
+	Series []datadogSeries `json:"series"`
+}
+
+type datadogSeries struct {
+	Metric    string            `json:"metric"`
+	Type      int               `json:"type"`
+	Points    []datadogPoint    `json:"points"`
+	Tags      []string          `json:"tags,omitempty"`
+	Resources []datadogResource `json:"resources,omitempty"`
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// datadogExporter submits points as gauge series to the Datadog v2 metrics
+// intake API. Point attributes become key:value tags, renamed by the tag
+// map, and the entity becomes the host resource of the series.
+type datadogExporter struct {
+	client *http.Client
+	url    string
+	apiKey string
+	tagMap map[string]string
+}
+
+func (e *datadogExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	var resources []datadogResource
+	var check string
+	if len(batch.Events) > 0 {
+		var entity string
+		_, entity, check = converter.EventNames(batch.Events[0])
+		if entity != "" {
+			resources = []datadogResource{{Name: entity, Type: "host"}}
+		}
+	}
+
+	var payload datadogPayload
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			var tags []string
+			if check != "" {
+				tags = append(tags, "sensu_check:"+check)
+			}
+			for _, kv := range p.Attributes {
+				key := kv.Key
+				if mapped, ok := e.tagMap[key]; ok {
+					key = mapped
+				}
+				if key == "" {
+					continue // mapped to nothing: dropped
+				}
+				tags = append(tags, key+":"+formatAnyValue(kv.Value))
+			}
+			payload.Series = append(payload.Series, datadogSeries{
+				Metric:    m.Name,
+				Type:      datadogGauge,
+				Points:    []datadogPoint{{Timestamp: int64(p.TimeUnixNano / 1e9), Value: pointValue(p)}},
+				Tags:      tags,
+				Resources: resources,
+			})
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("DD-API-KEY", e.apiKey)
+		return doHTTP(e.client, req)
+	})
+}
+
+// parseTagMap parses a comma separated list of from=to renames of
+// attribute keys. An empty target drops the attribute.
+func parseTagMap(value string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range splitList(value) {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tag mapping %q, expected from=to", pair)
+		}
+		m[pair[:i]] = pair[i+1:]
+	}
+	return m, nil
+}
+
+func (e *datadogExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *datadogExporter) String() string {
+	return "datadog " + e.url
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// maxLastEvents bounds the number of entity and check combinations whose
+// last event is kept for debugging.
+const maxLastEvents = 10000
+
+// sensitiveKeys are redacted from labels, annotations and environment
+// variables by default, as Sensu agents do.
+var sensitiveKeys = []string{"password", "passwd", "pass", "api_key", "api_token", "access_key", "secret_key", "private_key", "secret"}
+
+type lastEventKey struct {
+	namespace string
+	entity    string
+	check     string
+}
+
+type lastEvent struct {
+	received time.Time
+	event    *types.Event
+	// resource and batch are the telemetry the event was exported as, nil
+	// until it is.
+	resource *resourcepb.Resource
+	batch    *Batch
+}
+
+// lastEvents keeps the most recent event of every entity and check.
+type lastEvents struct {
+	sync.Mutex
+	events map[lastEventKey]*lastEvent
+}
+
+func newLastEvents() *lastEvents {
+	return &lastEvents{events: map[lastEventKey]*lastEvent{}}
+}
+
+// Add keeps a copy of event, as received, out of reach of the pipeline
+// stages changing it, and returns it for Exported.
+func (l *lastEvents) Add(event *types.Event) *lastEvent {
+	namespace, entity, check := converter.EventNames(event)
+	key := lastEventKey{namespace: namespace, entity: entity, check: check}
+	last := &lastEvent{received: time.Now(), event: copyEvent(event)}
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.events[key]; !ok && len(l.events) >= maxLastEvents {
+		for k := range l.events {
+			delete(l.events, k)
+			break
+		}
+	}
+	l.events[key] = last
+	return last
+}
+
+// Exported records the telemetry the event kept as last was exported as.
+func (l *lastEvents) Exported(last *lastEvent, res *resourcepb.Resource, batch *Batch) {
+	if last == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	last.resource, last.batch = res, batch
+}
+
+// Forget forgets the events of an entity.
+func (l *lastEvents) Forget(namespace, entity string) {
+	l.Lock()
+	defer l.Unlock()
+	for key := range l.events {
+		if key.namespace == namespace && key.entity == entity {
+			delete(l.events, key)
+		}
+	}
+}
+
+// Find returns the most recent event matching the non-empty names.
+func (l *lastEvents) Find(namespace, entity, check string) (lastEvent, bool) {
+	l.Lock()
+	defer l.Unlock()
+	var found *lastEvent
+	for key, e := range l.events {
+		if (namespace != "" && key.namespace != namespace) ||
+			(entity != "" && key.entity != entity) ||
+			(check != "" && key.check != check) {
+			continue
+		}
+		if found == nil || e.received.After(found.received) {
+			found = e
+		}
+	}
+	if found == nil {
+		return lastEvent{}, false
+	}
+	return *found, true
+}
+
+// serveLastEvent returns the last raw event received for a check and entity
+// together with the OTLP requests it was exported as, to debug metric
+// mappings. The requests are left out until the event is exported.
+//
+//	$ curl 'localhost:55788/debug/last?check=cpu&entity=web01'
+func (ot *otelPlugin) serveLastEvent(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	last, ok := ot.last.Find(q.Get("namespace"), q.Get("entity"), q.Get("check"))
+	if !ok {
+		http.Error(w, "no matching event received yet", http.StatusNotFound)
+		return
+	}
+
+	var metrics, logs, traces json.RawMessage
+	var err error
+	if b := last.batch; b != nil {
+		if len(b.Metrics) > 0 {
+			metrics, err = otlpJSON(converter.MetricsRequest(last.resource, b.Metrics))
+		}
+		if len(b.Logs) > 0 && err == nil {
+			logs, err = otlpJSON(converter.LogsRequest(last.resource, b.Logs))
+		}
+		if len(b.Spans) > 0 && err == nil {
+			traces, err = otlpJSON(converter.TraceRequest(last.resource, b.Spans))
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(struct {
+		Received time.Time       `json:"received"`
+		Event    *types.Event    `json:"event"`
+		OTLP     json.RawMessage `json:"otlp,omitempty"`
+		Logs     json.RawMessage `json:"logs,omitempty"`
+		Traces   json.RawMessage `json:"traces,omitempty"`
+	}{
+		Received: last.received,
+		Event:    redactEvent(last.event),
+		OTLP:     metrics,
+		Logs:     logs,
+		Traces:   traces,
+	})
+}
+
+// copyEvent returns a deep copy of event, nil if it can't be marshaled.
+func copyEvent(event *types.Event) *types.Event {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	var c types.Event
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil
+	}
+	return &c
+}
+
+// redactEvent returns a copy of the event whose entity and check labels,
+// annotations and environment variables that look sensitive are redacted.
+// The keys listed in the entity's redact field are honored too.
+func redactEvent(event *types.Event) *types.Event {
+	redacted := copyEvent(event)
+	if redacted == nil {
+		return nil
+	}
+
+	keys := sensitiveKeys
+	if redacted.Entity != nil {
+		if len(redacted.Entity.Redact) > 0 {
+			keys = redacted.Entity.Redact
+		}
+		redactMap(redacted.Entity.Labels, keys)
+		redactMap(redacted.Entity.Annotations, keys)
+	}
+	if redacted.Check != nil {
+		redactMap(redacted.Check.Labels, keys)
+		redactMap(redacted.Check.Annotations, keys)
+		for i, env := range redacted.Check.EnvVars {
+			if kv := strings.SplitN(env, "=", 2); len(kv) == 2 && isSensitive(kv[0], keys) {
+				redacted.Check.EnvVars[i] = kv[0] + "=" + redact(kv[1])
+			}
+		}
+	}
+	return redacted
+}
+
+func redactMap(m map[string]string, keys []string) {
+	for k, v := range m {
+		if isSensitive(k, keys) {
+			m[k] = redact(v)
+		}
+	}
+}
+
+func isSensitive(name string, keys []string) bool {
+	name = strings.ToLower(name)
+	for _, k := range keys {
+		if name == strings.ToLower(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventDebug reports whether the processing of event is to be traced: the
+// debug annotation of its check or, without one, of its entity is "true".
+//
+//	sensu.io/plugins/otel-sensu-handler-plugin/config/debug: "true"
+func eventDebug(event *types.Event) bool {
+	value, _ := eventAnnotation(event, "debug")
+	return value == "true"
+}
+
+// stageTrace is the state of a traced event after a pipeline stage: the
+// event, redacted, and the telemetry converted from it so far.
+type stageTrace struct {
+	Stage   string          `json:"stage"`
+	Event   *types.Event    `json:"event"`
+	Metrics json.RawMessage `json:"metrics,omitempty"`
+	Logs    json.RawMessage `json:"logs,omitempty"`
+	Traces  json.RawMessage `json:"traces,omitempty"`
+}
+
+// trace records and logs the state of pe after stage, without changing the
+// global log level.
+func (pe *pipelineEvent) trace(ot *otelPlugin, stage string) {
+	t := stageTrace{Stage: stage, Event: redactEvent(pe.event)}
+	var err error
+	if b := pe.batch; b != nil {
+		if len(b.Metrics) > 0 {
+			t.Metrics, err = otlpJSON(converter.MetricsRequest(ot.resource(pe.event), b.Metrics))
+		}
+		if len(b.Logs) > 0 && err == nil {
+			t.Logs, err = otlpJSON(converter.LogsRequest(ot.resource(pe.event), b.Logs))
+		}
+		if len(b.Spans) > 0 && err == nil {
+			t.Traces, err = otlpJSON(converter.TraceRequest(ot.resource(pe.event), b.Spans))
+		}
+	}
+	if err != nil {
+		errorLog.Printf("could not trace event: %v", err)
+	}
+	pe.traces = append(pe.traces, t)
+	namespace, entity, check := converter.EventNames(pe.event)
+	data, _ := json.Marshal(t)
+	log.Printf("debug %s/%s/%s after %s: %s", namespace, entity, check, stage, data)
+}
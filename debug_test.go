@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestLastEventsCopy(t *testing.T) {
+	event := corev2.FixtureEvent("web01", "cpu")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{{Name: "cpu.idle", Value: 7}}}
+	l := newLastEvents()
+	last := l.Add(event)
+	event.Metrics.Points[0].Name = "renamed"
+	l.Exported(last, nil, &Batch{})
+
+	found, ok := l.Find("", "web01", "cpu")
+	if !ok {
+		t.Fatal("event not found")
+	}
+	if name := found.event.Metrics.Points[0].Name; name != "cpu.idle" {
+		t.Errorf("got point %q, want the point as received", name)
+	}
+	if found.batch == nil {
+		t.Error("exported batch not recorded")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// eventDedup remembers the IDs of the events exported within a window, to
+// skip the retransmissions of Sensu handler retries, which would count the
+// points of counter-like metrics twice.
+type eventDedup struct {
+	sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	swept  time.Time
+}
+
+func newEventDedup(window time.Duration) *eventDedup {
+	return &eventDedup{window: window, seen: map[string]time.Time{}}
+}
+
+// eventID returns the ID of an event, unless it has none.
+func eventID(event *types.Event) (string, bool) {
+	for _, b := range event.ID {
+		if b != 0 {
+			return string(event.ID), true
+		}
+	}
+	return "", false
+}
+
+// Seen reports whether an event with the ID of event was exported within
+// the window before now.
+func (d *eventDedup) Seen(event *types.Event, now time.Time) bool {
+	id, ok := eventID(event)
+	if !ok {
+		return false
+	}
+	d.Lock()
+	defer d.Unlock()
+	exported, ok := d.seen[id]
+	return ok && now.Sub(exported) < d.window
+}
+
+// Add records the export of event at now, forgetting the IDs past the
+// window once per window.
+func (d *eventDedup) Add(event *types.Event, now time.Time) {
+	id, ok := eventID(event)
+	if !ok {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.seen[id] = now
+	if now.Sub(d.swept) < d.window {
+		return
+	}
+	for id, exported := range d.seen {
+		if now.Sub(exported) >= d.window {
+			delete(d.seen, id)
+		}
+	}
+	d.swept = now
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestEventDedup(t *testing.T) {
+	d := newEventDedup(time.Minute)
+	now := time.Now()
+	event := corev2.FixtureEvent("web01", "cpu")
+	event.ID = []byte{1, 2, 3, 4}
+	if d.Seen(event, now) {
+		t.Fatal("new event seen")
+	}
+	d.Add(event, now)
+	if !d.Seen(event, now.Add(30*time.Second)) {
+		t.Error("retransmission within the window not seen")
+	}
+	if d.Seen(event, now.Add(time.Minute)) {
+		t.Error("event seen past the window")
+	}
+
+	other := corev2.FixtureEvent("web01", "cpu")
+	other.ID = []byte{1, 2, 3, 5}
+	if d.Seen(other, now) {
+		t.Error("event with another ID seen")
+	}
+
+	// Events without IDs are never duplicates.
+	anonymous := corev2.FixtureEvent("web01", "cpu")
+	anonymous.ID = make([]byte, 16)
+	d.Add(anonymous, now)
+	if d.Seen(anonymous, now) {
+		t.Error("event without ID seen")
+	}
+
+	d.Add(other, now.Add(2*time.Minute))
+	if _, ok := d.seen[string(event.ID)]; ok || len(d.seen) != 1 {
+		t.Errorf("expired IDs kept: %d", len(d.seen))
+	}
+}
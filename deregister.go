@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// deregistrationCheck is the check of the events Sensu creates when an
+// entity deregisters.
+const deregistrationCheck = "deregistration"
+
+// isDeregistration reports whether event is the deregistration of its
+// entity.
+func isDeregistration(event *types.Event) bool {
+	return event.Check != nil && event.Check.Name == deregistrationCheck && event.Entity != nil
+}
+
+// deregisterEntity clears the state kept for the entity of a
+// deregistration event: its last events, check states, tracked series and
+// scraped series. With --deregistration-metric, the sensu.entity.deregistered
+// point is added to the event for it to be exported.
+func (ot *otelPlugin) deregisterEntity(event *types.Event) {
+	namespace, entity, _ := converter.EventNames(event)
+	ot.last.Forget(namespace, entity)
+	ot.entities.Forget(namespace, entity)
+	if ot.series != nil {
+		ot.series.Forget(namespace, entity)
+	}
+	if ot.scrape != nil {
+		ot.scrape.ForgetEntity(entity)
+	}
+	if plugin.DeregistrationMetric {
+		if event.Metrics == nil {
+			event.Metrics = &types.Metrics{}
+		}
+		ts := time.Now()
+		if event.Timestamp > 0 {
+			ts = time.Unix(event.Timestamp, 0)
+		}
+		event.Metrics.Points = append(event.Metrics.Points, selfPoint("sensu.entity.deregistered", 1, ts.UnixNano()))
+	}
+	log.Printf("entity %s/%s deregistered, its state is cleared", namespace, entity)
+}
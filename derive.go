@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// derivedMetric is a metric of --derived-metrics, computed from the other
+// points of an event.
+type derivedMetric struct {
+	name string
+	expr expr
+}
+
+// expr is an arithmetic expression over the metric points of an event with
+// the same tags.
+type expr interface {
+	// eval returns the value of the expression, or false when a metric is
+	// missing or the value is not finite.
+	eval(values map[string]float64) (float64, bool)
+}
+
+type numberExpr float64
+
+func (n numberExpr) eval(map[string]float64) (float64, bool) { return float64(n), true }
+
+type metricExpr string
+
+func (m metricExpr) eval(values map[string]float64) (float64, bool) {
+	v, ok := values[string(m)]
+	return v, ok
+}
+
+type negateExpr struct{ x expr }
+
+func (n negateExpr) eval(values map[string]float64) (float64, bool) {
+	v, ok := n.x.eval(values)
+	return -v, ok
+}
+
+type binaryExpr struct {
+	op   byte
+	l, r expr
+}
+
+func (b binaryExpr) eval(values map[string]float64) (float64, bool) {
+	l, ok := b.l.eval(values)
+	if !ok {
+		return 0, false
+	}
+	r, ok := b.r.eval(values)
+	if !ok {
+		return 0, false
+	}
+	var v float64
+	switch b.op {
+	case '+':
+		v = l + r
+	case '-':
+		v = l - r
+	case '*':
+		v = l * r
+	case '/':
+		v = l / r
+	}
+	return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// parseDerivedMetrics parses the semicolon separated definitions of
+// --derived-metrics, each a metric name and an expression of numbers, metric
+// names, + - * / and parentheses, such as
+//
+//	mem.used_percent = mem.used / mem.total * 100; disk.free = disk.total - disk.used
+//
+// Definitions can use the metrics defined before them.
+func parseDerivedMetrics(value string) ([]derivedMetric, error) {
+	var defs []derivedMetric
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || !isMetricName(name) {
+			return nil, fmt.Errorf("invalid definition %q, expected name = expression", item)
+		}
+		p := &exprParser{input: kv[1]}
+		e, err := p.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid definition of %s: %v", name, err)
+		}
+		defs = append(defs, derivedMetric{name: name, expr: e})
+	}
+	return defs, nil
+}
+
+func isMetricName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameByte(c byte) bool {
+	return isNameStart(c) || c == '.' || c >= '0' && c <= '9'
+}
+
+// exprParser is a recursive descent parser of expressions:
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/") unary }
+//	unary   = "-" unary | number | name | "(" sum ")"
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) parse() (expr, error) {
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if c := p.peek(); c != 0 {
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+	}
+	return e, nil
+}
+
+// peek skips spaces and returns the next byte, or 0 at the end.
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *exprParser) sum() (expr, error) {
+	e, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '+' || c == '-'; c = p.peek() {
+		p.pos++
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		e = binaryExpr{op: c, l: e, r: r}
+	}
+	return e, nil
+}
+
+func (p *exprParser) product() (expr, error) {
+	e, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '*' || c == '/'; c = p.peek() {
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		e = binaryExpr{op: c, l: e, r: r}
+	}
+	return e, nil
+}
+
+func (p *exprParser) unary() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negateExpr{x}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		p.pos++
+		return e, nil
+	case c == '.' || c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '.' || p.input[p.pos] >= '0' && p.input[p.pos] <= '9') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return numberExpr(n), nil
+	case isNameStart(c):
+		start := p.pos
+		for p.pos < len(p.input) && isNameByte(p.input[p.pos]) {
+			p.pos++
+		}
+		return metricExpr(p.input[start:p.pos]), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+}
+
+// deriveMetrics appends the derived metrics to the points of event. The
+// expressions are evaluated separately for each set of tags, over the points
+// with these tags, and a derived point is only added when every metric of its
+// expression has a point. It returns the number of points added.
+func deriveMetrics(event *types.Event, defs []derivedMetric) int {
+	if event.Metrics == nil {
+		return 0
+	}
+	type group struct {
+		tags      []*types.MetricTag
+		timestamp int64
+		values    map[string]float64
+	}
+	var groups []*group
+	byTags := make(map[string]*group)
+	for _, p := range event.Metrics.Points {
+		key := tagsKey(p.Tags)
+		g, ok := byTags[key]
+		if !ok {
+			g = &group{tags: p.Tags, timestamp: p.Timestamp, values: make(map[string]float64)}
+			byTags[key] = g
+			groups = append(groups, g)
+		}
+		g.values[p.Name] = p.Value
+	}
+
+	added := 0
+	for _, def := range defs {
+		for _, g := range groups {
+			v, ok := def.expr.eval(g.values)
+			if !ok {
+				continue
+			}
+			g.values[def.name] = v
+			tags := make([]*types.MetricTag, len(g.tags))
+			for i, t := range g.tags {
+				tags[i] = &types.MetricTag{Name: t.Name, Value: t.Value}
+			}
+			event.Metrics.Points = append(event.Metrics.Points, &types.MetricPoint{
+				Name:      def.name,
+				Value:     v,
+				Timestamp: g.timestamp,
+				Tags:      tags,
+			})
+			added++
+		}
+	}
+	return added
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestDeriveMetrics(t *testing.T) {
+	defs, err := parseDerivedMetrics("mem.used_percent = mem.used / mem.total * 100; mem.free_percent = 100 - mem.used_percent; disk.ratio = -(disk.a + 1) / disk.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := []*corev2.MetricTag{{Name: "host", Value: "web01"}}
+	event := corev2.FixtureEvent("web01", "mem")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "mem.used", Value: 25, Timestamp: 10, Tags: host},
+		{Name: "mem.total", Value: 200, Timestamp: 10, Tags: host},
+		// mem.total has no point with these tags.
+		{Name: "mem.used", Value: 1, Tags: []*corev2.MetricTag{{Name: "host", Value: "web02"}}},
+		{Name: "disk.a", Value: 1},
+		{Name: "disk.b", Value: 0},
+	}}
+	if n := deriveMetrics(event, defs); n != 2 {
+		t.Fatalf("got %d derived points, want 2", n)
+	}
+	for i, want := range []struct {
+		name  string
+		value float64
+	}{{"mem.used_percent", 12.5}, {"mem.free_percent", 87.5}} {
+		p := event.Metrics.Points[5+i]
+		if p.Name != want.name || p.Value != want.value || p.Timestamp != 10 || tagsKey(p.Tags) != "host=web01" {
+			t.Errorf("got %+v, want %s=%v tagged host=web01", p, want.name, want.value)
+		}
+	}
+
+	for _, bad := range []string{"x", "= a", "x = ", "x = a +", "x = (a", "x = a b", "x = a % b", "x y = a"} {
+		if _, err := parseDerivedMetrics(bad); err == nil {
+			t.Errorf("parseDerivedMetrics(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// egressRule allows connections to the hosts matching a glob, or to the
+// addresses of a CIDR block, optionally on one port only.
+type egressRule struct {
+	host    string
+	network *net.IPNet
+	port    string
+}
+
+// parseEgressRules parses the comma separated rules of --egress-allow:
+// host globs such as *.example.com, IP addresses or CIDR blocks, each
+// optionally followed by :port. IPv6 addresses with a port are bracketed.
+func parseEgressRules(value string) ([]egressRule, error) {
+	var rules []egressRule
+	for _, item := range splitList(value) {
+		var r egressRule
+		host := item
+		if h, p, err := net.SplitHostPort(item); err == nil {
+			host, r.port = h, p
+		}
+		switch {
+		case strings.Contains(host, "/"):
+			_, n, err := net.ParseCIDR(host)
+			if err != nil {
+				return nil, err
+			}
+			r.network = n
+		case net.ParseIP(host) != nil:
+			nets, _ := parseCIDRs(host)
+			r.network = nets[0]
+		default:
+			if _, err := path.Match(host, ""); err != nil {
+				return nil, fmt.Errorf("invalid host pattern %q: %v", host, err)
+			}
+			r.host = strings.ToLower(host)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// egressAllowed reports whether the handler may connect to address, a
+// host:port. Host globs match the name being dialed; CIDR blocks match the
+// address itself or every address the name resolves to.
+func egressAllowed(ctx context.Context, rules []egressRule, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	host = strings.ToLower(host)
+	var ips []net.IP
+	for _, r := range rules {
+		if r.port != "" && r.port != port {
+			continue
+		}
+		if r.network == nil {
+			if ok, _ := path.Match(r.host, host); ok {
+				return nil
+			}
+			continue
+		}
+		if ips == nil {
+			if ip := net.ParseIP(host); ip != nil {
+				ips = []net.IP{ip}
+			} else if addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host); err == nil {
+				for _, a := range addrs {
+					ips = append(ips, a.IP)
+				}
+			}
+		}
+		contained := len(ips) > 0
+		for _, ip := range ips {
+			contained = contained && r.network.Contains(ip)
+		}
+		if contained {
+			return nil
+		}
+	}
+	return fmt.Errorf("connection to %s denied by --egress-allow", address)
+}
+
+// egressRules are the rules of --egress-allow, set by configureEgress.
+var egressRules []egressRule
+
+// egressDial dials address if --egress-allow allows it, and any address
+// without it. Every outbound connection of the exporters and auth
+// providers goes through it.
+func egressDial(ctx context.Context, network, address string) (net.Conn, error) {
+	if len(egressRules) > 0 {
+		if err := egressAllowed(ctx, egressRules, address); err != nil {
+			errorLog.Printf("%v", err)
+			return nil, err
+		}
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// egressDialer adapts egressDial to dialers without a context.
+type egressDialer struct{}
+
+func (egressDialer) Dial(network, address string) (net.Conn, error) {
+	return egressDial(context.Background(), network, address)
+}
+
+// configureEgress parses --egress-allow, validated before, and makes the
+// default HTTP transport, which the HTTP exporters and auth providers use,
+// dial through egressDial.
+func configureEgress() {
+	egressRules, _ = parseEgressRules(plugin.EgressAllow)
+	http.DefaultTransport.(*http.Transport).DialContext = egressDial
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("elasticsearch", func() (Exporter, error) {
+		if plugin.ElasticsearchURL == "" {
+			return nil, fmt.Errorf("the elasticsearch exporter requires --elasticsearch-url")
+		}
+		return &elasticsearchExporter{
+			client:   &http.Client{Timeout: exportTimeout},
+			baseURL:  strings.TrimSuffix(plugin.ElasticsearchURL, "/"),
+			index:    plugin.ElasticsearchIndex,
+			username: plugin.ElasticsearchUser,
+			password: plugin.ElasticsearchPass,
+			apiKey:   plugin.ElasticsearchAPIKey,
+		}, nil
+	})
+}
+
+// eventDocument is the document indexed for an event: the complete Sensu
+// event along with what it was converted to.
+type eventDocument struct {
+	Timestamp  time.Time          `json:"@timestamp"`
+	Event      *types.Event       `json:"sensu"`
+	Conversion conversionMetadata `json:"conversion"`
+}
+
+type conversionMetadata struct {
+	Handler    string `json:"handler"`
+	Metrics    int    `json:"metrics"`
+	Points     int    `json:"points"`
+	LogRecords int    `json:"log_records"`
+	Spans      int    `json:"spans"`
+	TraceID    string `json:"trace_id,omitempty"`
+	SpanID     string `json:"span_id,omitempty"`
+}
+
+// elasticsearchExporter indexes every event of a batch as a document in
+// Elasticsearch or OpenSearch. Documents are stored under the event ID, so
+// retried exports don't create duplicates.
+type elasticsearchExporter struct {
+	client   *http.Client
+	baseURL  string
+	index    string
+	username string
+	password string
+	apiKey   string
+}
+
+func (e *elasticsearchExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	meta := conversionMetadata{
+		Handler:    plugin.Name,
+		Metrics:    len(batch.Metrics),
+		LogRecords: len(batch.Logs),
+		Spans:      len(batch.Spans),
+	}
+	for _, m := range batch.Metrics {
+		meta.Points += len(m.GetGauge().GetDataPoints())
+	}
+	if len(batch.Spans) > 0 {
+		meta.TraceID = hex.EncodeToString(batch.Spans[0].TraceId)
+		meta.SpanID = hex.EncodeToString(batch.Spans[0].SpanId)
+	}
+
+	for _, event := range batch.Events {
+		ts := time.Unix(event.Timestamp, 0).UTC()
+		body, err := json.Marshal(eventDocument{Timestamp: ts, Event: event, Conversion: meta})
+		if err != nil {
+			return err
+		}
+		index := strings.Replace(e.index, "{date}", ts.Format("2006.01.02"), -1)
+		method, docURL := http.MethodPost, e.baseURL+"/"+url.PathEscape(index)+"/_doc"
+		if len(event.ID) > 0 {
+			method, docURL = http.MethodPut, docURL+"/"+hex.EncodeToString(event.ID)
+		}
+		if err := retryExport(ctx, func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, method, docURL, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if e.apiKey != "" {
+				req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+			} else if e.username != "" {
+				req.SetBasicAuth(e.username, e.password)
+			}
+			return doHTTP(e.client, req)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *elasticsearchExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *elasticsearchExporter) String() string {
+	return "elasticsearch " + e.baseURL
+}
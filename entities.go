@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// entityStates names the check statuses counted by sensu.check.entities;
+// other statuses count as unknown.
+var entityStates = []string{"ok", "warning", "critical", "unknown"}
+
+// eventExpiry is how long the state of an event is kept after it was
+// received: three intervals of its check, or an hour for checks without
+// one, such as cron checks.
+func eventExpiry(event *types.Event) time.Duration {
+	if event.Check != nil && event.Check.Interval > 0 {
+		return 3 * time.Duration(event.Check.Interval) * time.Second
+	}
+	return time.Hour
+}
+
+type entityState struct {
+	status  uint32
+	expires time.Time
+}
+
+// checkEntities keeps the status of the last event of every entity of each
+// check, for fleet-level health without per-entity queries.
+type checkEntities struct {
+	sync.Mutex
+	checks map[statsKey]map[string]entityState
+}
+
+func newCheckEntities() *checkEntities {
+	return &checkEntities{checks: map[statsKey]map[string]entityState{}}
+}
+
+// Observe records the status of the check of event for its entity.
+func (c *checkEntities) Observe(event *types.Event, now time.Time) {
+	if event.Check == nil {
+		return
+	}
+	namespace, entity, check := converter.EventNames(event)
+	key := statsKey{Namespace: namespace, Check: check}
+	c.Lock()
+	defer c.Unlock()
+	entities, ok := c.checks[key]
+	if !ok {
+		entities = map[string]entityState{}
+		c.checks[key] = entities
+	}
+	entities[entity] = entityState{status: event.Check.Status, expires: now.Add(eventExpiry(event))}
+}
+
+// Forget stops counting an entity.
+func (c *checkEntities) Forget(namespace, entity string) {
+	c.Lock()
+	defer c.Unlock()
+	for key, entities := range c.checks {
+		if key.Namespace != namespace {
+			continue
+		}
+		delete(entities, entity)
+		if len(entities) == 0 {
+			delete(c.checks, key)
+		}
+	}
+}
+
+// metricPoints counts the entities of every check in each state, as the
+// sensu.check.entities gauge tagged with namespace, check and state.
+// Entities whose events stopped are forgotten.
+func (c *checkEntities) metricPoints(now time.Time) []*types.MetricPoint {
+	c.Lock()
+	defer c.Unlock()
+	keys := make([]statsKey, 0, len(c.checks))
+	for key := range c.checks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].Check < keys[j].Check
+	})
+
+	ts := now.UnixNano()
+	var points []*types.MetricPoint
+	for _, key := range keys {
+		counts := make([]int, len(entityStates))
+		for entity, s := range c.checks[key] {
+			if now.After(s.expires) {
+				delete(c.checks[key], entity)
+				continue
+			}
+			state := int(s.status)
+			if state >= len(entityStates) {
+				state = len(entityStates) - 1
+			}
+			counts[state]++
+		}
+		if len(c.checks[key]) == 0 {
+			delete(c.checks, key)
+			continue
+		}
+		for i, state := range entityStates {
+			points = append(points, selfPoint("sensu.check.entities", float64(counts[i]), ts,
+				&types.MetricTag{Name: "namespace", Value: key.Namespace},
+				&types.MetricTag{Name: "check", Value: key.Check},
+				&types.MetricTag{Name: "state", Value: state},
+			))
+		}
+	}
+	return points
+}
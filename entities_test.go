@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestCheckEntities(t *testing.T) {
+	c := newCheckEntities()
+	now := time.Now()
+	for entity, status := range map[string]uint32{"web01": 0, "web02": 2, "web03": 2, "web04": 127} {
+		event := corev2.FixtureEvent(entity, "http")
+		event.Check.Status = status
+		event.Check.Interval = 60
+		c.Observe(event, now)
+	}
+	event := corev2.FixtureEvent("web02", "http")
+	event.Check.Status = 1
+	event.Check.Interval = 60
+	c.Observe(event, now)
+
+	counts := map[string]float64{}
+	for _, p := range c.metricPoints(now) {
+		counts[p.Tags[2].Value] = p.Value
+	}
+	want := map[string]float64{"ok": 1, "warning": 1, "critical": 1, "unknown": 1}
+	for state, n := range want {
+		if counts[state] != n {
+			t.Errorf("%s: got %v entities, want %v", state, counts[state], n)
+		}
+	}
+
+	if points := c.metricPoints(now.Add(4 * time.Minute)); len(points) != 0 {
+		t.Errorf("got %d points after the entities expired, want none", len(points))
+	}
+}
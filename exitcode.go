@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes of the Sensu handler mode, so pipelines can tell transient
+// failures from ones that won't go away without operator action.
+const (
+	exitOK          = 0
+	exitRetryable   = 1
+	exitConfigError = 2
+)
+
+// handlerSummary is the machine readable line written to stdout at the end
+// of every handler run.
+type handlerSummary struct {
+	Outcome   string `json:"outcome"`
+	ExitCode  int    `json:"exit_code"`
+	EventID   string `json:"event_id,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Entity    string `json:"entity,omitempty"`
+	Check     string `json:"check,omitempty"`
+	Points    int    `json:"points"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handlerExit writes the summary of a handler run and terminates the
+// process with code unless it is exitOK, in which case it returns nil.
+func handlerExit(event *types.Event, code int, err error) error {
+	summary := handlerSummary{
+		ExitCode: code,
+	}
+	switch code {
+	case exitOK:
+		summary.Outcome = "exported"
+	case exitRetryable:
+		summary.Outcome = "retryable"
+	default:
+		summary.Outcome = "config_error"
+	}
+	if event != nil {
+		summary.EventID = event.GetUUID().String()
+		summary.Namespace, summary.Entity, summary.Check = converter.EventNames(event)
+		summary.Points = converter.EventPoints(event)
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	line, _ := json.Marshal(summary)
+	fmt.Fprintln(os.Stdout, string(line))
+
+	if code != exitOK {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// exportExitCode classifies an export error. Errors the backend will keep
+// returning for the same configuration, like a rejected token, are
+// configuration errors; anything else is worth retrying.
+func exportExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s, ok := e.(interface{ GRPCStatus() *status.Status }); ok {
+			switch s.GRPCStatus().Code() {
+			case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied,
+				codes.NotFound, codes.Unimplemented:
+				return exitConfigError
+			}
+			return exitRetryable
+		}
+		if s, ok := e.(interface{ HTTPStatus() int }); ok {
+			switch s.HTTPStatus() {
+			case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+				http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusRequestEntityTooLarge:
+				return exitConfigError
+			}
+			return exitRetryable
+		}
+	}
+	return exitRetryable
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// exportTimeout bounds an export including its retries.
+	exportTimeout = 10 * time.Second
+	// maxBackoff caps the delay between two export attempts.
+	maxBackoff = 2 * time.Second
+)
+
+func init() {
+	RegisterExporter("otlp", func() (Exporter, error) {
+		s, err := otlpSettingsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		build := func(s otlpSettings) (Exporter, error) {
+			return newOTLPExporter(s)
+		}
+		exporter, err := build(s)
+		if err != nil {
+			return nil, err
+		}
+		return withTenants(s, exporter, build)
+	})
+}
+
+// otlpSettings configure the OTLP exporters.
+type otlpSettings struct {
+	endpoint    string
+	insecure    bool
+	auth        AuthProvider
+	compression string
+	// headers are added to every request, before those of auth.
+	headers map[string]string
+	tls     *tls.Config
+}
+
+func otlpSettingsFromEnv() (otlpSettings, error) {
+	auth, err := newAuthProvider(plugin.Auth)
+	if err != nil {
+		return otlpSettings{}, err
+	}
+	headers, err := parseHeaders(plugin.OTLPHeaders)
+	if err != nil {
+		return otlpSettings{}, fmt.Errorf("--otlp-headers: %v", err)
+	}
+	cfg, err := otlpTLSConfig()
+	if err != nil {
+		return otlpSettings{}, err
+	}
+	return otlpSettings{
+		endpoint:    plugin.OTLPEndpoint,
+		insecure:    plugin.OTLPInsecure,
+		auth:        auth,
+		compression: plugin.OTLPCompression,
+		headers:     headers,
+		tls:         cfg,
+	}, nil
+}
+
+// parseHeaders parses comma separated name=value headers.
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, header := range splitList(value) {
+		i := strings.IndexByte(header, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid header %q, expected name=value", header)
+		}
+		name, v := strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:])
+		if err := validateHeaderName("header", name); err != nil {
+			return nil, err
+		}
+		if err := validateHeaderValue(v); err != nil {
+			return nil, fmt.Errorf("header %s: %v", name, err)
+		}
+		headers[name] = v
+	}
+	return headers, nil
+}
+
+// otlpExporter sends OTLP export requests to a collector or backend over
+// gRPC.
+type otlpExporter struct {
+	endpoint string
+	conn     *grpc.ClientConn
+	metrics  colmetricpb.MetricsServiceClient
+	logs     collogspb.LogsServiceClient
+	traces   coltracepb.TraceServiceClient
+	auth     AuthProvider
+	headers  map[string]string
+}
+
+// newOTLPExporter connects lazily to the endpoint of s, which is
+// host:port, and adds the headers of s and of its auth to every request.
+// Requests are compressed when the compression of s is "gzip".
+func newOTLPExporter(s otlpSettings) (*otlpExporter, error) {
+	cfg := s.tls
+	if cfg == nil {
+		cfg = tlsConfig()
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(cfg)),
+	}
+	if s.insecure {
+		dialOpts[0] = grpc.WithInsecure()
+	}
+	dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		return egressDial(ctx, "tcp", address)
+	}))
+	if s.compression == gzip.Name {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	conn, err := grpc.Dial(s.endpoint, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &otlpExporter{
+		endpoint: s.endpoint,
+		conn:     conn,
+		metrics:  colmetricpb.NewMetricsServiceClient(conn),
+		logs:     collogspb.NewLogsServiceClient(conn),
+		traces:   coltracepb.NewTraceServiceClient(conn),
+		auth:     s.auth,
+		headers:  s.headers,
+	}, nil
+}
+
+// Export sends one request per signal present in the batch.
+func (e *otlpExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.ExportResources(ctx, []ResourceBatch{{Resource: res, Batch: batch}})
+}
+
+// ExportResources sends the batches of every tenant in one request per
+// signal present in them, with an entry per resource.
+func (e *otlpExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	for _, group := range tenantGroups(batches) {
+		ctx := withTenantHeader(ctx, group[0].Batch)
+		metrics, logs, traces := otlpRequests(group)
+		if metrics != nil {
+			if err := e.send(ctx, func(ctx context.Context) error {
+				_, err := e.metrics.Export(ctx, metrics)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		if logs != nil {
+			if err := e.send(ctx, func(ctx context.Context) error {
+				_, err := e.logs.Export(ctx, logs)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		if traces != nil {
+			if err := e.send(ctx, func(ctx context.Context) error {
+				_, err := e.traces.Export(ctx, traces)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// otlpRequests returns the export requests of the telemetry of batches,
+// with an entry per resource, or nil for the signals they have none of.
+func otlpRequests(batches []ResourceBatch) (*colmetricpb.ExportMetricsServiceRequest, *collogspb.ExportLogsServiceRequest, *coltracepb.ExportTraceServiceRequest) {
+	var metrics *colmetricpb.ExportMetricsServiceRequest
+	var logs *collogspb.ExportLogsServiceRequest
+	var traces *coltracepb.ExportTraceServiceRequest
+	for _, b := range batches {
+		if len(b.Batch.Metrics) > 0 {
+			req := converter.MetricsRequest(b.Resource, b.Batch.Metrics)
+			if metrics == nil {
+				metrics = req
+			} else {
+				metrics.ResourceMetrics = append(metrics.ResourceMetrics, req.ResourceMetrics...)
+			}
+		}
+		if len(b.Batch.Logs) > 0 {
+			req := converter.LogsRequest(b.Resource, b.Batch.Logs)
+			if logs == nil {
+				logs = req
+			} else {
+				logs.ResourceLogs = append(logs.ResourceLogs, req.ResourceLogs...)
+			}
+		}
+		if len(b.Batch.Spans) > 0 {
+			req := converter.TraceRequest(b.Resource, b.Batch.Spans)
+			if traces == nil {
+				traces = req
+			} else {
+				traces.ResourceSpans = append(traces.ResourceSpans, req.ResourceSpans...)
+			}
+		}
+	}
+	return metrics, logs, traces
+}
+
+// Preflight sends an empty metrics export request, which the collector
+// authenticates and accepts without storing anything.
+func (e *otlpExporter) Preflight(ctx context.Context) error {
+	return e.send(ctx, func(ctx context.Context) error {
+		_, err := e.metrics.Export(ctx, &colmetricpb.ExportMetricsServiceRequest{})
+		return err
+	})
+}
+
+// send retries call with the auth and tenant headers as metadata. Rejected
+// credentials are replaced and the call is made once more.
+func (e *otlpExporter) send(ctx context.Context, call func(context.Context) error) error {
+	authCall := func(ctx context.Context) error {
+		headers, err := e.auth.Headers(ctx)
+		if err != nil {
+			return err
+		}
+		md := metadata.New(e.headers)
+		for k, v := range headers {
+			md.Set(k, v)
+		}
+		for k, v := range tenantHeaderFrom(ctx) {
+			md.Set(k, v)
+		}
+		return call(metadata.NewOutgoingContext(ctx, md))
+	}
+	return retryExport(ctx, func(ctx context.Context) error {
+		err := authCall(ctx)
+		if status.Code(err) == codes.Unauthenticated && invalidateAuth(e.auth) {
+			err = authCall(ctx)
+		}
+		return err
+	})
+}
+
+// Shutdown closes the connection.
+func (e *otlpExporter) Shutdown(context.Context) error {
+	return e.conn.Close()
+}
+
+func (e *otlpExporter) String() string {
+	return "otlp " + e.endpoint
+}
+
+// retryExport calls export with exponential backoff as long as the error is
+// retryable and the export policy of ctx allows: within its timeout and
+// number of retries.
+func retryExport(ctx context.Context, export func(context.Context) error) error {
+	policy := exportPolicyFrom(ctx)
+	ctx, cancel := context.WithTimeout(ctx, policy.timeout)
+	defer cancel()
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := export(ctx)
+		if err == nil || exportExitCode(err) != exitRetryable || attempt == policy.retries {
+			return err
+		}
+		if backoff > policy.maxBackoff {
+			backoff = policy.maxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
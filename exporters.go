@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	"google.golang.org/protobuf/proto"
+)
+
+// Batch, Exporter and ResourceBatch are defined by the exporter package,
+// for programs embedding the conversion.
+type (
+	Batch         = exporter.Batch
+	Exporter      = exporter.Exporter
+	ResourceBatch = exporter.ResourceBatch
+)
+
+// ExporterFactory creates an exporter from the plugin configuration.
+type ExporterFactory func() (Exporter, error)
+
+var exporterFactories = map[string]ExporterFactory{}
+
+// RegisterExporter makes an exporter selectable by name with the
+// --exporter option. It is meant to be called from init functions and
+// panics if the name is already taken.
+func RegisterExporter(name string, factory ExporterFactory) {
+	if _, ok := exporterFactories[name]; ok {
+		panic(fmt.Sprintf("exporter %q registered twice", name))
+	}
+	exporterFactories[name] = factory
+}
+
+// newExporter creates the exporter registered as name.
+func newExporter(name string) (Exporter, error) {
+	factory, ok := exporterFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown exporter %q, expected one of %s", name, strings.Join(exporterNames(), ", "))
+	}
+	exporter, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	if exporter, err = withChaos(name, exporter); err != nil {
+		return nil, err
+	}
+	return applyExportPolicy(name, trackHealth(name, exporter))
+}
+
+// newExporters creates the exporters registered as names. Several
+// exporters are combined into one sending every batch to all of them.
+func newExporters(names []string) (Exporter, error) {
+	if len(names) == 1 {
+		return newExporter(names[0])
+	}
+	var fan exporter.Fanout
+	for _, name := range names {
+		e, err := newExporter(name)
+		if err != nil {
+			_ = fan.Shutdown(context.Background())
+			return nil, err
+		}
+		fan.Add(destination(name, e), e)
+	}
+	return &fan, nil
+}
+
+// exporterSelected reports whether the exporter registered as name is
+// selected with --exporter.
+func exporterSelected(name string) bool {
+	for _, selected := range splitList(plugin.Exporter) {
+		if selected == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exporterNames lists the registered exporters, sorted.
+func exporterNames() []string {
+	names := make([]string, 0, len(exporterFactories))
+	for name := range exporterFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// destination describes where an exporter sends telemetry, for logs and the
+// audit trail.
+func destination(name string, exporter Exporter) string {
+	if s, ok := exporter.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return name
+}
+
+// exportRouted sends every batch with the exporter route picks for it, the
+// batches of an exporter together. The first error is returned.
+func exportRouted(ctx context.Context, batches []ResourceBatch, route func(*Batch) Exporter) error {
+	var targets []Exporter
+	var groups [][]ResourceBatch
+	for _, b := range batches {
+		target, i := route(b.Batch), 0
+		for i < len(targets) && targets[i] != target {
+			i++
+		}
+		if i == len(targets) {
+			targets = append(targets, target)
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], b)
+	}
+	var first error
+	for i, target := range targets {
+		if err := exporter.ExportResources(ctx, target, groups[i]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// mergeResources merges the batches exported under equal resources, so that
+// the telemetry of every resource is exported once. The batches themselves
+// are left untouched.
+func mergeResources(batches []ResourceBatch) []ResourceBatch {
+	var merged []ResourceBatch
+	var copied []bool
+next:
+	for _, b := range batches {
+		for i, m := range merged {
+			if !proto.Equal(m.Resource, b.Resource) {
+				continue
+			}
+			if !copied[i] {
+				merged[i].Batch = &Batch{}
+				appendBatch(merged[i].Batch, m.Batch)
+				copied[i] = true
+			}
+			appendBatch(merged[i].Batch, b.Batch)
+			continue next
+		}
+		merged = append(merged, b)
+		copied = append(copied, false)
+	}
+	return merged
+}
+
+// appendBatch appends the events and telemetry of batch to dst.
+func appendBatch(dst, batch *Batch) {
+	dst.Events = append(dst.Events, batch.Events...)
+	dst.Metrics = append(dst.Metrics, batch.Metrics...)
+	dst.Logs = append(dst.Logs, batch.Logs...)
+	dst.Spans = append(dst.Spans, batch.Spans...)
+}
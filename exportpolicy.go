@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// exportPolicy is how an exporter retries and how long it may take.
+type exportPolicy struct {
+	// timeout bounds an export including its retries.
+	timeout time.Duration
+	// retries is the number of retries of a failed export, -1 for as many
+	// as fit in the timeout.
+	retries int
+	// maxBackoff caps the delay between two attempts.
+	maxBackoff time.Duration
+	// queue is the number of batches buffered for an exporter sending in
+	// the background, 0 to send synchronously.
+	queue int
+}
+
+var defaultExportPolicy = exportPolicy{timeout: exportTimeout, retries: -1, maxBackoff: maxBackoff}
+
+type exportPolicyKey struct{}
+
+// withExportPolicy returns a context whose exports follow p.
+func withExportPolicy(ctx context.Context, p exportPolicy) context.Context {
+	return context.WithValue(ctx, exportPolicyKey{}, p)
+}
+
+// exportPolicyFrom returns the export policy of ctx, the default policy if
+// it has none.
+func exportPolicyFrom(ctx context.Context) exportPolicy {
+	if p, ok := ctx.Value(exportPolicyKey{}).(exportPolicy); ok {
+		return p
+	}
+	return defaultExportPolicy
+}
+
+// parseExportPolicies parses the per exporter policies of
+// --exporter-policy: semicolon separated name:key=value,... entries with
+// the keys timeout, retries, backoff and queue, for example
+//
+//	splunk:timeout=3s,retries=1,queue=100; loki:timeout=2s
+func parseExportPolicies(value string) (map[string]exportPolicy, error) {
+	policies := map[string]exportPolicy{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.IndexByte(entry, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid exporter policy %q, expected name:key=value,...", entry)
+		}
+		name := strings.TrimSpace(entry[:i])
+		p := defaultExportPolicy
+		for _, setting := range splitList(entry[i+1:]) {
+			j := strings.IndexByte(setting, '=')
+			if j <= 0 {
+				return nil, fmt.Errorf("%s: invalid setting %q, expected key=value", name, setting)
+			}
+			key, v := setting[:j], setting[j+1:]
+			var err error
+			switch key {
+			case "timeout":
+				p.timeout, err = time.ParseDuration(v)
+				if err == nil && p.timeout <= 0 {
+					err = fmt.Errorf("must be positive")
+				}
+			case "backoff":
+				p.maxBackoff, err = time.ParseDuration(v)
+			case "retries":
+				p.retries, err = strconv.Atoi(v)
+				if err == nil && p.retries < -1 {
+					err = fmt.Errorf("must be -1 or more")
+				}
+			case "queue":
+				p.queue, err = strconv.Atoi(v)
+				if err == nil && p.queue < 0 {
+					err = fmt.Errorf("must not be negative")
+				}
+			default:
+				err = fmt.Errorf("unknown setting, expected timeout, retries, backoff or queue")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", name, key, err)
+			}
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// applyExportPolicy wraps exporter, registered as name, to follow its
+// policy from --exporter-policy, if it has one.
+func applyExportPolicy(name string, exporter Exporter) (Exporter, error) {
+	policies, err := parseExportPolicies(plugin.ExporterPolicy)
+	if err != nil {
+		return nil, err
+	}
+	policy, ok := policies[name]
+	switch {
+	case !ok:
+		return exporter, nil
+	case policy.queue > 0:
+		return newQueuedExporter(name, exporter, policy), nil
+	default:
+		return &policyExporter{Exporter: exporter, name: name, policy: policy}, nil
+	}
+}
+
+// policyExporter applies an export policy to the exports of an exporter.
+type policyExporter struct {
+	Exporter
+	name   string
+	policy exportPolicy
+}
+
+func (e *policyExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.Exporter.Export(withExportPolicy(ctx, e.policy), res, batch)
+}
+
+func (e *policyExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	return exporter.ExportResources(withExportPolicy(ctx, e.policy), e.Exporter, batches)
+}
+
+func (e *policyExporter) String() string {
+	return destination(e.name, e.Exporter)
+}
+
+// queuedExporter sends batches in the background so that a slow exporter
+// doesn't hold up the others. When its queue is full, batches are dropped.
+// Failed exports are only logged.
+type queuedExporter struct {
+	dropped int64
+
+	name     string
+	exporter Exporter
+	policy   exportPolicy
+	queue    chan queuedBatch
+	done     chan struct{}
+	once     sync.Once
+}
+
+type queuedBatch struct {
+	res   *resourcepb.Resource
+	batch *Batch
+}
+
+func newQueuedExporter(name string, exporter Exporter, policy exportPolicy) *queuedExporter {
+	e := &queuedExporter{
+		name:     name,
+		exporter: exporter,
+		policy:   policy,
+		queue:    make(chan queuedBatch, policy.queue),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *queuedExporter) run() {
+	defer close(e.done)
+	for item := range e.queue {
+		ctx := withExportPolicy(context.Background(), e.policy)
+		if err := e.exporter.Export(ctx, item.res, item.batch); err != nil {
+			errorLog.Printf("%s: queued export failed: %v", e.name, err)
+		}
+	}
+}
+
+func (e *queuedExporter) Export(_ context.Context, res *resourcepb.Resource, batch *Batch) error {
+	select {
+	case e.queue <- queuedBatch{res: res, batch: batch}:
+		return nil
+	default:
+		n := atomic.AddInt64(&e.dropped, 1)
+		return fmt.Errorf("export queue is full, %d batches dropped so far", n)
+	}
+}
+
+// Shutdown sends the queued batches, as far as ctx allows, and shuts the
+// exporter down.
+func (e *queuedExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.queue) })
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		errorLog.Printf("%s: %d queued batches not sent at shutdown", e.name, len(e.queue))
+	}
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e *queuedExporter) String() string {
+	return destination(e.name, e.exporter)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// featureGates describes the behaviors that ship disabled until they have
+// proven themselves, enabled per deployment with --feature-gates.
+var featureGates = map[string]string{
+	"counterSums": "export the points named *_total or *_count as cumulative monotonic sums instead of gauges",
+}
+
+// parseFeatureGates parses a comma separated list of gates, each either a
+// name, enabling it, or name=true|false.
+func parseFeatureGates(value string) (map[string]bool, error) {
+	gates := map[string]bool{}
+	for _, gate := range splitList(value) {
+		name, enabled := gate, true
+		if i := strings.Index(gate, "="); i >= 0 {
+			var err error
+			if enabled, err = strconv.ParseBool(gate[i+1:]); err != nil {
+				return nil, fmt.Errorf("invalid value for feature gate %q: %v", gate[:i], err)
+			}
+			name = gate[:i]
+		}
+		if _, ok := featureGates[name]; !ok {
+			return nil, fmt.Errorf("unknown feature gate %q, expected one of %s", name, strings.Join(featureGateNames(), ", "))
+		}
+		gates[name] = enabled
+	}
+	return gates, nil
+}
+
+func featureGateNames() []string {
+	names := make([]string, 0, len(featureGates))
+	for name := range featureGates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// feature reports whether a gate is enabled.
+func (ot *otelPlugin) feature(name string) bool {
+	return ot.features[name]
+}
+
+// counterSums turns the gauges named after Prometheus counters into
+// cumulative monotonic sums, for backends computing rates from them.
+func counterSums(metrics []*metricpb.Metric) {
+	for _, m := range metrics {
+		gauge, ok := m.Data.(*metricpb.Metric_Gauge)
+		if !ok || !(strings.HasSuffix(m.Name, "_total") || strings.HasSuffix(m.Name, "_count")) {
+			continue
+		}
+		// The start of the counters is unknown: a start time just before
+		// each point would read as a reset.
+		for _, p := range gauge.Gauge.DataPoints {
+			p.StartTimeUnixNano = 0
+		}
+		m.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             gauge.Gauge.DataPoints,
+			AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	gates, err := parseFeatureGates("")
+	if err != nil || len(gates) != 0 {
+		t.Fatalf("got %v, %v, want no gates", gates, err)
+	}
+	gates, err = parseFeatureGates("counterSums")
+	if err != nil || !gates["counterSums"] {
+		t.Fatalf("got %v, %v, want counterSums enabled", gates, err)
+	}
+	gates, err = parseFeatureGates("counterSums=false")
+	if err != nil || gates["counterSums"] {
+		t.Fatalf("got %v, %v, want counterSums disabled", gates, err)
+	}
+	for _, value := range []string{"pdataConverter", "counterSums=maybe"} {
+		if _, err := parseFeatureGates(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestCounterSums(t *testing.T) {
+	point := &metricpb.NumberDataPoint{StartTimeUnixNano: 1, TimeUnixNano: 2}
+	metrics := []*metricpb.Metric{
+		{Name: "http_requests_total", Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: []*metricpb.NumberDataPoint{point}}}},
+		{Name: "cpu_usage", Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{}}},
+	}
+	counterSums(metrics)
+	sum, ok := metrics[0].Data.(*metricpb.Metric_Sum)
+	if !ok {
+		t.Fatalf("expected a sum, got %T", metrics[0].Data)
+	}
+	if !sum.Sum.IsMonotonic || sum.Sum.AggregationTemporality != metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Errorf("expected a cumulative monotonic sum, got %+v", sum.Sum)
+	}
+	if len(sum.Sum.DataPoints) != 1 || point.StartTimeUnixNano != 0 {
+		t.Errorf("unexpected data points %+v", sum.Sum.DataPoints)
+	}
+	if _, ok := metrics[1].Data.(*metricpb.Metric_Gauge); !ok {
+		t.Errorf("expected cpu_usage to stay a gauge, got %T", metrics[1].Data)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterExporter("file", func() (Exporter, error) {
+		if plugin.ExporterFile == "" {
+			return nil, fmt.Errorf("the file exporter requires --exporter-file")
+		}
+		f, err := os.OpenFile(plugin.ExporterFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, err
+		}
+		return &fileExporter{w: f, name: "file " + plugin.ExporterFile}, nil
+	})
+	RegisterExporter("stdout", func() (Exporter, error) {
+		return &fileExporter{w: os.Stdout, name: "stdout"}, nil
+	})
+}
+
+// fileExporter writes OTLP export requests as JSON lines, one line per
+// signal present in a batch.
+type fileExporter struct {
+	sync.Mutex
+	w    io.Writer
+	name string
+}
+
+func (e *fileExporter) Export(_ context.Context, res *resourcepb.Resource, batch *Batch) error {
+	var msgs []proto.Message
+	if len(batch.Metrics) > 0 {
+		msgs = append(msgs, converter.MetricsRequest(res, batch.Metrics))
+	}
+	if len(batch.Logs) > 0 {
+		msgs = append(msgs, converter.LogsRequest(res, batch.Logs))
+	}
+	if len(batch.Spans) > 0 {
+		msgs = append(msgs, converter.TraceRequest(res, batch.Spans))
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	for _, msg := range msgs {
+		line, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := e.w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) Shutdown(context.Context) error {
+	if c, ok := e.w.(io.Closer); ok && e.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
+
+func (e *fileExporter) String() string {
+	return e.name
+}
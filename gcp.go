@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gcpMetadataURL is the root of the GCE metadata server.
+const gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+// gcpTokenURL is where the GCE metadata server hands out access tokens of
+// the default service account.
+const gcpTokenURL = gcpMetadataURL + "/instance/service-accounts/default/token"
+
+// gcpCloudPlatformScope is the OAuth2 scope of the Google Cloud APIs.
+const gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpCredentialsFile is a Google credentials file, either a service account
+// key or the authorized user credentials written by gcloud.
+type gcpCredentialsFile struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcpCredentialsPath returns the path of the application default
+// credentials file: GOOGLE_APPLICATION_CREDENTIALS or the file written by
+// gcloud auth application-default login. It is empty if there is none.
+func gcpCredentialsPath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+func readGCPCredentials(path string) (*gcpCredentialsFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var creds gcpCredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %v", path, err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &creds, nil
+}
+
+// gcpTokenSource returns the token fetch of the application default
+// credentials for scope: a credentials file if there is one, the metadata
+// server otherwise.
+func gcpTokenSource(scope string) (func(ctx context.Context) (*tokenResponse, error), error) {
+	path := gcpCredentialsPath()
+	if path == "" {
+		return func(ctx context.Context) (*tokenResponse, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpTokenURL, nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Metadata-Flavor", "Google")
+			return fetchToken(req)
+		}, nil
+	}
+	creds, err := readGCPCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	switch creds.Type {
+	case "service_account":
+		key, err := parseRSAKey(creds.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %v", path, err)
+		}
+		return func(ctx context.Context) (*tokenResponse, error) {
+			assertion, err := signJWT(key, map[string]interface{}{
+				"iss":   creds.ClientEmail,
+				"scope": scope,
+				"aud":   creds.TokenURI,
+			}, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return postTokenForm(ctx, creds.TokenURI, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}, nil
+	case "authorized_user":
+		return func(ctx context.Context) (*tokenResponse, error) {
+			return postTokenForm(ctx, creds.TokenURI, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {creds.ClientID},
+				"client_secret": {creds.ClientSecret},
+				"refresh_token": {creds.RefreshToken},
+			})
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported credentials type %q in %s", creds.Type, path)
+}
+
+func postTokenForm(ctx context.Context, tokenURL string, form url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetchToken(req)
+}
+
+// gcpProject returns the configured project, falling back to the
+// environment, the credentials file and the metadata server.
+func gcpProject(ctx context.Context, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	if project := getenv("GOOGLE_CLOUD_PROJECT", os.Getenv("GCLOUD_PROJECT")); project != "" {
+		return project, nil
+	}
+	if path := gcpCredentialsPath(); path != "" {
+		if creds, err := readGCPCredentials(path); err == nil && creds.ProjectID != "" {
+			return creds.ProjectID, nil
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataURL+"/project/project-id", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	project, err := metadataGet(req)
+	if err != nil {
+		return "", fmt.Errorf("no project configured and the metadata server is not reachable: %v", err)
+	}
+	return strings.TrimSpace(project), nil
+}
+
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return key, nil
+}
+
+// signJWT returns claims, valid for an hour from now, as a JWT signed with
+// RS256.
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}, now time.Time) (string, error) {
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// gcpIDTokenSource returns the token fetch of Google ID tokens for
+// audience, as IAP and Cloud Run expect: signed by the metadata server, or
+// exchanged for a JWT signed with the service account key of the
+// application default credentials. The token goes where access tokens go,
+// with its lifetime taken from its expiry.
+func gcpIDTokenSource(audience string) (func(ctx context.Context) (*tokenResponse, error), error) {
+	path := gcpCredentialsPath()
+	if path == "" {
+		return func(ctx context.Context) (*tokenResponse, error) {
+			query := url.Values{"audience": {audience}, "format": {"full"}}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataURL+"/instance/service-accounts/default/identity?"+query.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Metadata-Flavor", "Google")
+			token, err := metadataGet(req)
+			if err != nil {
+				return nil, err
+			}
+			return idTokenResponse(strings.TrimSpace(token))
+		}, nil
+	}
+	creds, err := readGCPCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	if creds.Type != "service_account" {
+		return nil, fmt.Errorf("ID tokens need service account credentials or the metadata server, %s has %q credentials", path, creds.Type)
+	}
+	key, err := parseRSAKey(creds.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in %s: %v", path, err)
+	}
+	return func(ctx context.Context) (*tokenResponse, error) {
+		assertion, err := signJWT(key, map[string]interface{}{
+			"iss":             creds.ClientEmail,
+			"aud":             creds.TokenURI,
+			"target_audience": audience,
+		}, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		body, err := tokenRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			IDToken string `json:"id_token"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("invalid token response: %v", err)
+		}
+		return idTokenResponse(resp.IDToken)
+	}, nil
+}
+
+// idTokenResponse returns an ID token as a token response, with the
+// lifetime of its exp claim.
+func idTokenResponse(token string) (*tokenResponse, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("ID token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %v", err)
+	}
+	return &tokenResponse{AccessToken: token, ExpiresIn: claims.Exp - time.Now().Unix()}, nil
+}
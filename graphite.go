@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// graphiteTemplate decomposes the dotted metric names matching filter into
+// a metric name and tags. Each node of the template names what the node of
+// the same position in the metric name is:
+//
+//   - measurement: a node of the metric name, joined with the other
+//     measurement nodes by dots
+//   - _ or nothing: a node that is dropped
+//   - anything else: the name of the tag the node is the value of
+//
+// A last node * or measurement* takes the remaining nodes into the metric
+// name. Without it, the metric name must have as many nodes as the template.
+type graphiteTemplate struct {
+	filter []string
+	nodes  []string
+	rest   bool
+}
+
+// parseGraphiteTemplates parses the semicolon separated templates of
+// --graphite-templates, each optionally preceded by a filter of dotted glob
+// nodes matching the first nodes of the metric names, such as
+//
+//	servers.* _.host.measurement*; measurement.host.region.*
+//
+// The first template whose filter matches applies.
+func parseGraphiteTemplates(value string) ([]graphiteTemplate, error) {
+	var templates []graphiteTemplate
+	for _, item := range strings.Split(value, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid template %q, expected [filter] template", item)
+		}
+		var t graphiteTemplate
+		if len(fields) == 2 {
+			t.filter = strings.Split(fields[0], ".")
+			for _, node := range t.filter {
+				if _, err := path.Match(node, ""); err != nil || node == "" {
+					return nil, fmt.Errorf("invalid template %q: invalid filter %q", item, fields[0])
+				}
+			}
+		}
+		t.nodes = strings.Split(fields[len(fields)-1], ".")
+		if last := t.nodes[len(t.nodes)-1]; last == "*" || last == "measurement*" {
+			t.rest = true
+			t.nodes = t.nodes[:len(t.nodes)-1]
+			if last == "measurement*" {
+				t.nodes = append(t.nodes, "measurement")
+			}
+		}
+		measurement := t.rest
+		for _, node := range t.nodes {
+			if strings.Contains(node, "*") {
+				return nil, fmt.Errorf("invalid template %q: * only allowed last", item)
+			}
+			measurement = measurement || node == "measurement"
+		}
+		if !measurement {
+			return nil, fmt.Errorf("invalid template %q: no measurement node", item)
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// match reports whether the template applies to the nodes of a metric name.
+func (t *graphiteTemplate) match(nodes []string) bool {
+	if len(nodes) < len(t.filter) || len(nodes) < len(t.nodes) || !t.rest && len(nodes) != len(t.nodes) {
+		return false
+	}
+	for i, pattern := range t.filter {
+		if ok, _ := path.Match(pattern, nodes[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// decompose returns the metric name and the tags of the nodes of a name.
+func (t *graphiteTemplate) decompose(nodes []string) (string, []*types.MetricTag) {
+	var name []string
+	var tags []*types.MetricTag
+	for i, node := range t.nodes {
+		switch node {
+		case "measurement":
+			name = append(name, nodes[i])
+		case "", "_":
+		default:
+			tags = append(tags, &types.MetricTag{Name: node, Value: nodes[i]})
+		}
+	}
+	if t.rest {
+		name = append(name, nodes[len(t.nodes):]...)
+	}
+	return strings.Join(name, "."), tags
+}
+
+// decomposeGraphiteNames rewrites the names of the points of event matching
+// a template, adding the tags the template extracts. A template leaving no
+// metric name does not apply. Tags extracted from the
+// name replace the tags of the point of the same name.
+func decomposeGraphiteNames(event *types.Event, templates []graphiteTemplate) {
+	if event.Metrics == nil {
+		return
+	}
+	for _, p := range event.Metrics.Points {
+		nodes := strings.Split(p.Name, ".")
+		for i := range templates {
+			if !templates[i].match(nodes) {
+				continue
+			}
+			name, tags := templates[i].decompose(nodes)
+			if name == "" {
+				continue
+			}
+			p.Name = name
+			for _, tag := range tags {
+				setTag(p, tag)
+			}
+			break
+		}
+	}
+}
+
+// setTag sets the value of a tag of p, adding it if p has no such tag.
+func setTag(p *types.MetricPoint, tag *types.MetricTag) {
+	for _, t := range p.Tags {
+		if t.Name == tag.Name {
+			t.Value = tag.Value
+			return
+		}
+	}
+	p.Tags = append(p.Tags, tag)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestGraphiteTemplates(t *testing.T) {
+	templates, err := parseGraphiteTemplates("servers.* _.host.measurement*; app.* _.measurement.env; measurement.host.region.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "graphite")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "servers.web01.cpu.idle"},
+		{Name: "cpu.web01.us-east.user.idle", Tags: []*corev2.MetricTag{{Name: "host", Value: "old"}}},
+		{Name: "app.requests.prod"},
+		// Too many nodes for the last template.
+		{Name: "app.requests.prod.extra.x"},
+		// Too few nodes for the second one.
+		{Name: "load"},
+	}}
+	decomposeGraphiteNames(event, templates)
+	want := []string{
+		"cpu.idle{host=web01}",
+		"cpu.user.idle{host=web01,region=us-east}",
+		"requests{env=prod}",
+		"app.extra.x{host=requests,region=prod}",
+		"load{}",
+	}
+	for i, p := range event.Metrics.Points {
+		if got := p.Name + "{" + tagsKey(p.Tags) + "}"; got != want[i] {
+			t.Errorf("point %d: got %s, want %s", i, got, want[i])
+		}
+	}
+
+	for _, bad := range []string{"host.region", "a b c", "measurement.*.host", "[.x measurement"} {
+		if _, err := parseGraphiteTemplates(bad); err == nil {
+			t.Errorf("parseGraphiteTemplates(%q) accepted", bad)
+		}
+	}
+}
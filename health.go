@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// exporterDownAfter is the number of consecutive failed exports after which
+// an exporter is considered down.
+const exporterDownAfter = 3
+
+// exporterHealth tracks the outcome of the exports of one exporter. The
+// counters come first to keep them 64-bit aligned for atomic access on
+// 32-bit platforms.
+type exporterHealth struct {
+	exports             int64
+	failures            int64
+	consecutiveFailures int64
+
+	name     string
+	exporter Exporter
+	sync.Mutex
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+	// preflight is the outcome of the pre-flight of --preflight: passed,
+	// failed, or unsupported. A failed pre-flight keeps the exporter down
+	// until an export succeeds.
+	preflight string
+}
+
+// exporterHealthStatus is the health of an exporter as reported by
+// /readyz, /exporters and the status page.
+type exporterHealthStatus struct {
+	Name                string    `json:"name"`
+	Up                  bool      `json:"up"`
+	Exports             int64     `json:"exports"`
+	Failures            int64     `json:"failures"`
+	ConsecutiveFailures int64     `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure"`
+	LastSuccess         time.Time `json:"last_success"`
+	Preflight           string    `json:"preflight,omitempty"`
+}
+
+var exporterHealths = struct {
+	sync.Mutex
+	byName map[string]*exporterHealth
+}{byName: map[string]*exporterHealth{}}
+
+// trackHealth wraps exporter, registered as name, to record the outcome of
+// its exports.
+func trackHealth(name string, exporter Exporter) Exporter {
+	h := &exporterHealth{name: name, exporter: exporter}
+	exporterHealths.Lock()
+	exporterHealths.byName[name] = h
+	exporterHealths.Unlock()
+	return &healthExporter{Exporter: exporter, health: h}
+}
+
+func (h *exporterHealth) observe(err error) {
+	atomic.AddInt64(&h.exports, 1)
+	h.Lock()
+	defer h.Unlock()
+	if err == nil {
+		atomic.StoreInt64(&h.consecutiveFailures, 0)
+		h.lastSuccess = time.Now()
+		if h.preflight == preflightFailed {
+			h.preflight = preflightPassed
+		}
+		return
+	}
+	atomic.AddInt64(&h.failures, 1)
+	atomic.AddInt64(&h.consecutiveFailures, 1)
+	h.lastError = err.Error()
+	h.lastFailure = time.Now()
+}
+
+func (h *exporterHealth) status() exporterHealthStatus {
+	h.Lock()
+	defer h.Unlock()
+	consecutive := atomic.LoadInt64(&h.consecutiveFailures)
+	return exporterHealthStatus{
+		Name:                h.name,
+		Up:                  consecutive < exporterDownAfter && h.preflight != preflightFailed,
+		Exports:             atomic.LoadInt64(&h.exports),
+		Failures:            atomic.LoadInt64(&h.failures),
+		ConsecutiveFailures: consecutive,
+		LastError:           h.lastError,
+		LastFailure:         h.lastFailure,
+		LastSuccess:         h.lastSuccess,
+		Preflight:           h.preflight,
+	}
+}
+
+// exporterHealthStatuses returns the health of every exporter, by name.
+func exporterHealthStatuses() []exporterHealthStatus {
+	exporterHealths.Lock()
+	defer exporterHealths.Unlock()
+	statuses := make([]exporterHealthStatus, 0, len(exporterHealths.byName))
+	for _, h := range exporterHealths.byName {
+		statuses = append(statuses, h.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// healthExporter records the outcome of the exports of an exporter.
+type healthExporter struct {
+	Exporter
+	health *exporterHealth
+}
+
+func (e *healthExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	err := e.Exporter.Export(ctx, res, batch)
+	e.health.observe(err)
+	return err
+}
+
+func (e *healthExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	err := exporter.ExportResources(ctx, e.Exporter, batches)
+	e.health.observe(err)
+	return err
+}
+
+func (e *healthExporter) String() string {
+	return destination(e.health.name, e.Exporter)
+}
+
+// serveReady reports whether the handler can export: 200 while every
+// exporter is up, 503 once one of them failed its pre-flight or failed
+// exporterDownAfter times in a row, or the last canary of --canary-interval
+// was not verified. The body has the health of every exporter and the
+// outcome of the last canary.
+//
+//	$ curl -i localhost:55788/readyz
+func (ot *otelPlugin) serveReady(w http.ResponseWriter, _ *http.Request) {
+	statuses := exporterHealthStatuses()
+	ready := ot.exporter != nil
+	for _, s := range statuses {
+		ready = ready && s.Up
+	}
+	canary := ot.canary.status()
+	if canary != nil {
+		ready = ready && canary.Verified
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(struct {
+		Ready     bool                   `json:"ready"`
+		Exporters []exporterHealthStatus `json:"exporters"`
+		Canary    *canaryStatus          `json:"canary,omitempty"`
+	}{ready, statuses, canary})
+}
+
+// serveExporters returns the health of every exporter as JSON.
+func (ot *otelPlugin) serveExporters(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(exporterHealthStatuses())
+}
+
+// exporterHealthPoints reports the health of every exporter, tagged with
+// its name.
+func exporterHealthPoints(now time.Time) []*types.MetricPoint {
+	ts := now.UnixNano()
+	var points []*types.MetricPoint
+	for _, s := range exporterHealthStatuses() {
+		tag := &types.MetricTag{Name: "exporter", Value: s.Name}
+		up := 0.0
+		if s.Up {
+			up = 1
+		}
+		points = append(points,
+			selfPoint("sensu.otel.exporter.up", up, ts, tag),
+			selfPoint("sensu.otel.exporter.exports", float64(s.Exports), ts, tag),
+			selfPoint("sensu.otel.exporter.failures", float64(s.Failures), ts, tag),
+			selfPoint("sensu.otel.exporter.consecutive_failures", float64(s.ConsecutiveFailures), ts, tag),
+		)
+	}
+	return points
+}
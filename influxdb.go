@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("influxdb", func() (Exporter, error) {
+		if plugin.InfluxDBURL == "" || plugin.InfluxDBBucket == "" {
+			return nil, fmt.Errorf("the influxdb exporter requires --influxdb-url and --influxdb-bucket")
+		}
+		query := url.Values{
+			"org":       {plugin.InfluxDBOrg},
+			"bucket":    {plugin.InfluxDBBucket},
+			"precision": {"ns"},
+		}
+		return &influxDBExporter{
+			client:   &http.Client{Timeout: exportTimeout},
+			writeURL: strings.TrimSuffix(plugin.InfluxDBURL, "/") + "/api/v2/write?" + query.Encode(),
+			token:    plugin.InfluxDBToken,
+		}, nil
+	})
+}
+
+// influxDBExporter writes points as line protocol to the InfluxDB v2 write
+// API. Every point is a measurement named after the metric with a single
+// "value" field, tagged with the point attributes and the entity.
+type influxDBExporter struct {
+	client   *http.Client
+	writeURL string
+	token    string
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+func (e *influxDBExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	var entity string
+	if len(batch.Events) > 0 {
+		_, entity, _ = converter.EventNames(batch.Events[0])
+	}
+
+	var body bytes.Buffer
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			body.WriteString(influxMeasurementEscaper.Replace(m.Name))
+			body.WriteString(influxTags(entity, p.Attributes))
+			body.WriteString(" value=")
+			body.WriteString(strconv.FormatFloat(pointValue(p), 'g', -1, 64))
+			body.WriteByte(' ')
+			body.WriteString(strconv.FormatUint(p.TimeUnixNano, 10))
+			body.WriteByte('\n')
+		}
+	}
+
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.writeURL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if e.token != "" {
+			req.Header.Set("Authorization", "Token "+e.token)
+		}
+		return doHTTP(e.client, req)
+	})
+}
+
+// influxTags renders the tag set of a point, sorted by key as InfluxDB
+// recommends.
+func influxTags(entity string, attrs []*commonpb.KeyValue) string {
+	tags := make([]string, 0, len(attrs)+1)
+	if entity != "" {
+		tags = append(tags, "sensu_entity="+influxTagEscaper.Replace(entity))
+	}
+	for _, kv := range attrs {
+		value := formatAnyValue(kv.Value)
+		if value == "" {
+			continue // empty tag values are invalid
+		}
+		tags = append(tags, influxTagEscaper.Replace(kv.Key)+"="+influxTagEscaper.Replace(value))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.Strings(tags)
+	return "," + strings.Join(tags, ",")
+}
+
+func (e *influxDBExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *influxDBExporter) String() string {
+	u, err := url.Parse(e.writeURL)
+	if err != nil {
+		return "influxdb"
+	}
+	return "influxdb " + u.Scheme + "://" + u.Host + " bucket " + u.Query().Get("bucket")
+}
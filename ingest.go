@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// ingestGuard decides which requests may post events to the ingest
+// server.
+type ingestGuard struct {
+	allow []*net.IPNet
+	// clientCert requires a verified client certificate, with a name
+	// matching one of clientNames if any.
+	clientCert  bool
+	clientNames []string
+	// tokens are the bearer tokens accepted, none meaning that no token is
+	// required.
+	tokens []ingestToken
+}
+
+// ingestToken is a bearer token accepted by the ingest server, read from
+// an environment variable, and the globs of the namespaces of the events it
+// may post; without globs it may post to any namespace.
+type ingestToken struct {
+	env        string
+	token      string
+	namespaces []string
+}
+
+// parseIngestTokens parses --ingest-tokens: environment variables holding
+// a token, separated by semicolons, each optionally followed by = and the
+// comma separated namespaces it is limited to, for example
+//
+//	TEAM_A_INGEST_TOKEN=team-a,team-a-*; SENSU_INGEST_TOKEN
+//
+// The tokens themselves are read by newIngestGuard.
+func parseIngestTokens(value string) ([]ingestToken, error) {
+	var tokens []ingestToken
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		t := ingestToken{env: item}
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			t.env, t.namespaces = strings.TrimSpace(item[:i]), splitList(item[i+1:])
+			if len(t.namespaces) == 0 {
+				return nil, fmt.Errorf("token %s has no namespaces after =", t.env)
+			}
+		}
+		if t.env == "" {
+			return nil, fmt.Errorf("invalid token %q, expected VARIABLE[=namespaces]", item)
+		}
+		for _, pattern := range t.namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid namespace %q: %v", pattern, err)
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func newIngestGuard() (*ingestGuard, error) {
+	allow, err := parseCIDRs(plugin.IngestAllowCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("--ingest-allow-cidrs: %v", err)
+	}
+	tokens, err := parseIngestTokens(plugin.IngestTokens)
+	if err != nil {
+		return nil, fmt.Errorf("--ingest-tokens: %v", err)
+	}
+	for i := range tokens {
+		if tokens[i].token = os.Getenv(tokens[i].env); tokens[i].token == "" {
+			return nil, fmt.Errorf("--ingest-tokens: %s is not set", tokens[i].env)
+		}
+	}
+	return &ingestGuard{
+		allow:       allow,
+		clientCert:  plugin.IngestClientCAFile != "",
+		clientNames: splitList(plugin.IngestClientNames),
+		tokens:      tokens,
+	}, nil
+}
+
+// ingestTLSConfig returns the TLS configuration of the HTTP server, or nil
+// to serve plain HTTP without --ingest-tls-cert-file. Client certificates
+// are verified against --ingest-client-ca-file; they are only required to
+// post events, so probes and scrapers can still reach the other endpoints
+// without one.
+func ingestTLSConfig() (*tls.Config, error) {
+	if plugin.IngestTLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(plugin.IngestTLSCertFile, plugin.IngestTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("--ingest-tls-cert-file: %v", err)
+	}
+	cfg := tlsConfig()
+	cfg.Certificates = []tls.Certificate{cert}
+	if plugin.IngestClientCAFile != "" {
+		ca, err := ioutil.ReadFile(plugin.IngestClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("--ingest-client-ca-file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("--ingest-client-ca-file: no certificates in %s", plugin.IngestClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// parseCIDRs parses comma separated CIDR blocks; a plain IP address is a
+// block of that address only.
+func parseCIDRs(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range splitList(value) {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// allowed reports whether the client of req may post events: any client
+// without an allowlist, else only those whose address is in one of its
+// blocks. The address is the one of the connection; forwarding headers are
+// not trusted.
+func (g *ingestGuard) allowed(req *http.Request) bool {
+	if len(g.allow) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteHost(req))
+	if ip == nil {
+		return false
+	}
+	for _, n := range g.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAuthorized reports whether the client of req presented a verified
+// certificate whose common name or one of whose DNS names matches a glob
+// of --ingest-client-names, or any verified certificate without them.
+func (g *ingestGuard) clientAuthorized(req *http.Request) (string, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return "no verified client certificate", false
+	}
+	leaf := req.TLS.VerifiedChains[0][0]
+	if len(g.clientNames) == 0 {
+		return "", true
+	}
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for _, pattern := range g.clientNames {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok && name != "" {
+				return "", true
+			}
+		}
+	}
+	return fmt.Sprintf("client certificate %q not in --ingest-client-names", leaf.Subject.CommonName), false
+}
+
+// bearerToken returns the accepted token req carries, if any.
+func (g *ingestGuard) bearerToken(req *http.Request) (*ingestToken, bool) {
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return nil, false
+	}
+	presented := []byte(strings.TrimSpace(auth[len("Bearer "):]))
+	for i := range g.tokens {
+		if subtle.ConstantTimeCompare(presented, []byte(g.tokens[i].token)) == 1 {
+			return &g.tokens[i], true
+		}
+	}
+	return nil, false
+}
+
+type ingestTokenKey struct{}
+
+// ingestTokenOf returns the token the request of ctx was authorized with,
+// if any.
+func ingestTokenOf(ctx context.Context) (*ingestToken, bool) {
+	t, ok := ctx.Value(ingestTokenKey{}).(*ingestToken)
+	return t, ok
+}
+
+// namespaceAllowed reports whether the token the request of ctx was
+// authorized with may post events to namespace.
+func namespaceAllowed(ctx context.Context, namespace string) bool {
+	t, ok := ingestTokenOf(ctx)
+	if !ok || len(t.namespaces) == 0 {
+		return true
+	}
+	for _, pattern := range t.namespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the address of the client of req without its port,
+// which changes with every connection and would make each rejection a
+// message of its own to errorLog.
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// wrap rejects the requests of clients that may not post events before
+// they reach h, which checks the namespace of the event with
+// namespaceAllowed.
+func (g *ingestGuard) wrap(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !g.allowed(req) {
+			errorLog.Printf("rejected event from %s: address not in --ingest-allow-cidrs", remoteHost(req))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if g.clientCert {
+			if reason, ok := g.clientAuthorized(req); !ok {
+				errorLog.Printf("rejected event from %s: %s", remoteHost(req), reason)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		if len(g.tokens) > 0 {
+			t, ok := g.bearerToken(req)
+			if !ok {
+				errorLog.Printf("rejected event from %s: missing or unknown bearer token", remoteHost(req))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			req = req.WithContext(context.WithValue(req.Context(), ingestTokenKey{}, t))
+		}
+		h(w, req)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// ingestQueue holds the posted events of --ingest-queue until its workers
+// export them, so that a slow or unreachable backend doesn't hold up
+// Sensu. The counter comes first to keep it 64-bit aligned for atomic
+// access on 32-bit platforms.
+type ingestQueue struct {
+	dropped int64
+
+	queue chan *types.Event
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// newIngestQueue starts workers exporting the events of a queue of size
+// events with export, up to batch of them at a time: those already queued
+// when a worker is done with the previous ones.
+func newIngestQueue(size, workers, batch int, export func([]*types.Event)) *ingestQueue {
+	q := &ingestQueue{queue: make(chan *types.Event, size)}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer q.wg.Done()
+			for event := range q.queue {
+				export(q.next(event, batch))
+			}
+		}()
+	}
+	return q
+}
+
+// next returns first and the events queued after it, up to batch events.
+func (q *ingestQueue) next(first *types.Event, batch int) []*types.Event {
+	events := []*types.Event{first}
+	for len(events) < batch {
+		select {
+		case event, ok := <-q.queue:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+	return events
+}
+
+// exportQueued is the export of the workers of the ingest queue: events go
+// through the pipeline together within --ingest-deadline, failures being
+// logged. Their batches are exported as an export group.
+func (ot *otelPlugin) exportQueued(events []*types.Event) {
+	ctx := context.Background()
+	if ot.ingestDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ot.ingestDeadline)
+		defer cancel()
+	}
+	g := newExportGroup(ctx, len(events))
+	var wg sync.WaitGroup
+	wg.Add(len(events))
+	for _, event := range events {
+		go func(event *types.Event) {
+			defer wg.Done()
+			m := &exportMember{group: g}
+			_, err := ot.receiveEvent(context.WithValue(ctx, exportMemberKey{}, m), event, false)
+			if !m.exported {
+				g.leave()
+			}
+			if err != nil {
+				namespace, entity, check := converter.EventNames(event)
+				errorLog.Printf("could not export queued event of %s/%s/%s: %v", namespace, entity, check, err)
+			}
+		}(event)
+	}
+	wg.Wait()
+}
+
+// exportGroup exports the batches of a group of events, which go through
+// the pipeline concurrently, together: once every event has either reached
+// the export stage or left the pipeline, the batches of each exporter are
+// merged by resource and exported at once, in a single request per signal
+// for the OTLP exporters.
+type exportGroup struct {
+	ctx  context.Context
+	done chan struct{}
+
+	mu sync.Mutex
+	// pending is the number of events neither exported nor gone.
+	pending   int
+	exporters []Exporter
+	batches   [][]ResourceBatch
+	// errs are the errors of exporters, set before done is closed.
+	errs []error
+}
+
+// exportMember is the membership of an event in its export group, carried
+// by the context of the pipeline.
+type exportMember struct {
+	group    *exportGroup
+	exported bool
+}
+
+type exportMemberKey struct{}
+
+// exportMemberFrom returns the export group membership of ctx, if any.
+func exportMemberFrom(ctx context.Context) *exportMember {
+	m, _ := ctx.Value(exportMemberKey{}).(*exportMember)
+	return m
+}
+
+// newExportGroup returns the group of events exports, exported with ctx.
+func newExportGroup(ctx context.Context, events int) *exportGroup {
+	return &exportGroup{ctx: ctx, done: make(chan struct{}), pending: events}
+}
+
+// export adds the batch of the event of m, to be exported under res with
+// exporter, and waits for the group to be exported. It returns the error of
+// exporter.
+func (m *exportMember) export(exporter Exporter, res *resourcepb.Resource, batch *Batch) error {
+	m.exported = true
+	g := m.group
+	g.mu.Lock()
+	i := 0
+	for i < len(g.exporters) && g.exporters[i] != exporter {
+		i++
+	}
+	if i == len(g.exporters) {
+		g.exporters = append(g.exporters, exporter)
+		g.batches = append(g.batches, nil)
+	}
+	g.batches[i] = append(g.batches[i], ResourceBatch{Resource: res, Batch: batch})
+	g.arrive()
+	<-g.done
+	return g.errs[i]
+}
+
+// leave removes an event that left the pipeline without being exported.
+func (g *exportGroup) leave() {
+	g.mu.Lock()
+	g.arrive()
+}
+
+// arrive counts an event out of the pending ones, with g.mu held, which it
+// releases. The last one exports the group.
+func (g *exportGroup) arrive() {
+	g.pending--
+	last := g.pending == 0
+	g.mu.Unlock()
+	if !last {
+		return
+	}
+	g.errs = make([]error, len(g.exporters))
+	for i, e := range g.exporters {
+		g.errs[i] = exporter.ExportResources(g.ctx, e, mergeResources(g.batches[i]))
+	}
+	close(g.done)
+}
+
+// add queues event, and reports false when the queue is full.
+func (q *ingestQueue) add(event *types.Event) bool {
+	select {
+	case q.queue <- event:
+		return true
+	default:
+		atomic.AddInt64(&q.dropped, 1)
+		return false
+	}
+}
+
+// close stops accepting events and waits for the queued ones to be
+// exported, as long as ctx allows. It reports the events left.
+func (q *ingestQueue) close(ctx context.Context) int {
+	q.once.Do(func() { close(q.queue) })
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return len(q.queue)
+}
+
+// metricPoints reports the number of queued events and of events refused
+// because the queue was full.
+func (q *ingestQueue) metricPoints(now time.Time) []*types.MetricPoint {
+	ts := now.UnixNano()
+	return []*types.MetricPoint{
+		selfPoint("sensu.otel.ingest.queue.depth", float64(len(q.queue)), ts),
+		selfPoint("sensu.otel.ingest.queue.dropped", float64(atomic.LoadInt64(&q.dropped)), ts),
+	}
+}
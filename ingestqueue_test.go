@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/sensu/sensu-go/types"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestIngestQueuePost(t *testing.T) {
+	body := []byte(`{"entity": {"metadata": {"name": "web01", "namespace": "default"}}, "metrics": {"points": [{"name": "cpu.idle", "value": 90}]}}`)
+	ot := newOtelPlugin()
+	// Without workers the queue fills up after one event.
+	ot.queue = newIngestQueue(1, 0, 1, nil)
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ot.postEvent(w, req)
+		return w
+	}
+	if w := post(); w.Code != http.StatusAccepted {
+		t.Errorf("first post: got %d %q, want 202", w.Code, w.Body.String())
+	}
+	if w := post(); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("post to a full queue: got %d %q, want 503", w.Code, w.Body.String())
+	}
+	points := ot.queue.metricPoints(time.Now())
+	if points[0].Value != 1 || points[1].Value != 1 {
+		t.Errorf("got depth %v and dropped %v, want 1 and 1", points[0].Value, points[1].Value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if left := ot.queue.close(ctx); left != 1 {
+		t.Errorf("got %d events left at shutdown, want 1", left)
+	}
+}
+
+func TestIngestQueueDrain(t *testing.T) {
+	exported := make(chan *types.Event, 3)
+	q := newIngestQueue(3, 2, 2, func(events []*types.Event) {
+		for _, event := range events {
+			exported <- event
+		}
+	})
+	for i := 0; i < 3; i++ {
+		if !q.add(corev2.FixtureEvent("web01", "cpu")) {
+			t.Fatalf("event %d refused", i)
+		}
+	}
+	if left := q.close(context.Background()); left != 0 {
+		t.Errorf("got %d events left, want 0", left)
+	}
+	if len(exported) != 3 {
+		t.Errorf("got %d events exported, want 3", len(exported))
+	}
+}
+
+// resourcesExporter records the batches of its exports of several
+// resources.
+type resourcesExporter struct {
+	mu      sync.Mutex
+	exports [][]ResourceBatch
+}
+
+func (e *resourcesExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.ExportResources(ctx, []ResourceBatch{{Resource: res, Batch: batch}})
+}
+
+func (e *resourcesExporter) ExportResources(_ context.Context, batches []ResourceBatch) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exports = append(e.exports, batches)
+	return nil
+}
+
+func (e *resourcesExporter) Shutdown(context.Context) error { return nil }
+
+func TestExportGroup(t *testing.T) {
+	ot := newOtelPlugin()
+	e := &resourcesExporter{}
+	// Two events of web01, one of web02 and one leaving the pipeline.
+	g := newExportGroup(context.Background(), 4)
+	var wg sync.WaitGroup
+	for _, entity := range []string{"web01", "web01", "web02", ""} {
+		wg.Add(1)
+		go func(entity string) {
+			defer wg.Done()
+			if entity == "" {
+				g.leave()
+				return
+			}
+			event := corev2.FixtureEvent(entity, "cpu")
+			batch := &Batch{Events: []*types.Event{event}, Metrics: []*metricpb.Metric{{Name: "cpu.idle"}}}
+			m := &exportMember{group: g}
+			if err := m.export(e, ot.resource(event), batch); err != nil {
+				t.Error(err)
+			}
+		}(entity)
+	}
+	wg.Wait()
+
+	if len(e.exports) != 1 || len(e.exports[0]) != 2 {
+		t.Fatalf("got exports %v, want one of two resources", e.exports)
+	}
+	metrics, logs, traces := otlpRequests(e.exports[0])
+	if logs != nil || traces != nil {
+		t.Errorf("got logs %v and traces %v, want none", logs, traces)
+	}
+	points := map[string]int{}
+	for _, rm := range metrics.ResourceMetrics {
+		for _, attr := range rm.Resource.Attributes {
+			if attr.Key == "host.name" {
+				points[attr.Value.GetStringValue()] += len(rm.InstrumentationLibraryMetrics[0].Metrics)
+			}
+		}
+	}
+	if want := map[string]int{"web01": 2, "web02": 1}; !reflect.DeepEqual(points, want) {
+		t.Errorf("got metrics by host %v, want %v", points, want)
+	}
+}
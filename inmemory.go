@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterExporter("inmemory", func() (Exporter, error) {
+		memoryBatches.Lock()
+		memoryBatches.max = int(plugin.InMemoryMaxBatches)
+		memoryBatches.Unlock()
+		return &memoryExporter{}, nil
+	})
+}
+
+// memoryBatch is a batch recorded by the inmemory exporter.
+type memoryBatch struct {
+	received  time.Time
+	namespace string
+	entity    string
+	check     string
+	res       *resourcepb.Resource
+	batch     *Batch
+}
+
+// memoryBatches holds the most recent batches of the inmemory exporter,
+// for /inmemory to query.
+var memoryBatches struct {
+	sync.Mutex
+	batches []memoryBatch
+	max     int
+}
+
+// memoryExporter records the batches in memory instead of sending them, so
+// that integration tests can check the output of the whole pipeline
+// without a network.
+type memoryExporter struct{}
+
+func (e *memoryExporter) Export(_ context.Context, res *resourcepb.Resource, batch *Batch) error {
+	b := memoryBatch{received: time.Now(), res: res, batch: batch}
+	if len(batch.Events) > 0 {
+		b.namespace, b.entity, b.check = converter.EventNames(batch.Events[0])
+	}
+	memoryBatches.Lock()
+	defer memoryBatches.Unlock()
+	memoryBatches.batches = append(memoryBatches.batches, b)
+	if over := len(memoryBatches.batches) - memoryBatches.max; memoryBatches.max > 0 && over > 0 {
+		memoryBatches.batches = append([]memoryBatch(nil), memoryBatches.batches[over:]...)
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func (e *memoryExporter) String() string {
+	return "inmemory"
+}
+
+// serveInMemory returns the batches recorded by the inmemory exporter, the
+// oldest first, as OTLP JSON export requests. They can be filtered by
+// namespace, entity, check and metric name. DELETE forgets them all, to
+// start a test from scratch.
+//
+//	$ curl 'localhost:55788/inmemory?check=cpu&metric=cpu.idle'
+//	$ curl -X DELETE localhost:55788/inmemory
+func (ot *otelPlugin) serveInMemory(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodDelete {
+		memoryBatches.Lock()
+		memoryBatches.batches = nil
+		memoryBatches.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	q := req.URL.Query()
+	memoryBatches.Lock()
+	batches := append([]memoryBatch(nil), memoryBatches.batches...)
+	memoryBatches.Unlock()
+
+	type recorded struct {
+		Received  time.Time       `json:"received"`
+		Namespace string          `json:"namespace"`
+		Entity    string          `json:"entity"`
+		Check     string          `json:"check"`
+		Metrics   json.RawMessage `json:"metrics,omitempty"`
+		Logs      json.RawMessage `json:"logs,omitempty"`
+		Traces    json.RawMessage `json:"traces,omitempty"`
+	}
+	results := []recorded{}
+	for _, b := range batches {
+		if (q.Get("namespace") != "" && b.namespace != q.Get("namespace")) ||
+			(q.Get("entity") != "" && b.entity != q.Get("entity")) ||
+			(q.Get("check") != "" && b.check != q.Get("check")) ||
+			(q.Get("metric") != "" && !hasMetric(b.batch, q.Get("metric"))) {
+			continue
+		}
+		r := recorded{Received: b.received, Namespace: b.namespace, Entity: b.entity, Check: b.check}
+		var err error
+		if len(b.batch.Metrics) > 0 {
+			r.Metrics, err = otlpJSON(converter.MetricsRequest(b.res, b.batch.Metrics))
+		}
+		if len(b.batch.Logs) > 0 && err == nil {
+			r.Logs, err = otlpJSON(converter.LogsRequest(b.res, b.batch.Logs))
+		}
+		if len(b.batch.Spans) > 0 && err == nil {
+			r.Traces, err = otlpJSON(converter.TraceRequest(b.res, b.batch.Spans))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		results = append(results, r)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(results)
+}
+
+func hasMetric(batch *Batch, name string) bool {
+	for _, m := range batch.Metrics {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func otlpJSON(msg proto.Message) (json.RawMessage, error) {
+	return protojson.Marshal(msg)
+}
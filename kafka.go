@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterExporter("kafka", newKafkaExporter)
+}
+
+// kafkaExporter publishes OTLP metric export requests, serialized as
+// protobuf, to a Kafka topic. This is the otlp_proto encoding the kafka
+// receiver of the OpenTelemetry collector consumes. Messages are keyed by
+// entity so that the points of an entity stay in order on one partition.
+type kafkaExporter struct {
+	writer *kafka.Writer
+}
+
+func newKafkaExporter() (Exporter, error) {
+	brokers := splitList(plugin.KafkaBrokers)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("the kafka exporter requires --kafka-brokers")
+	}
+	transport := &kafka.Transport{Dial: egressDial}
+	if plugin.KafkaTLS {
+		transport.TLS = tlsConfig()
+	}
+	mechanism, err := kafkaSASL(plugin.KafkaSASL, plugin.KafkaUsername, plugin.KafkaPassword)
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+
+	return &kafkaExporter{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        plugin.KafkaTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		Transport:    transport,
+	}}, nil
+}
+
+// kafkaSASL returns the SASL mechanism named by mechanism, nil for none.
+func kafkaSASL(mechanism, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(mechanism) {
+	case "", "none":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unknown SASL mechanism %q, expected plain, scram-sha-256 or scram-sha-512", mechanism)
+}
+
+// Export publishes the metrics of the batch. Logs and spans are not sent,
+// the collector kafka receiver expects a single signal per topic.
+func (e *kafkaExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	value, err := proto.Marshal(converter.MetricsRequest(res, batch.Metrics))
+	if err != nil {
+		return err
+	}
+	var key []byte
+	if len(batch.Events) == 1 {
+		_, entity, _ := converter.EventNames(batch.Events[0])
+		key = []byte(entity)
+	}
+	ctx, cancel := context.WithTimeout(ctx, exportPolicyFrom(ctx).timeout)
+	defer cancel()
+	return e.writer.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+}
+
+// Shutdown flushes pending messages and closes the connections.
+func (e *kafkaExporter) Shutdown(context.Context) error {
+	return e.writer.Close()
+}
+
+func (e *kafkaExporter) String() string {
+	return "kafka " + e.writer.Topic
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
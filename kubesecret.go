@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterAuthProvider("kubernetes-secret", func() (AuthProvider, error) {
+		namespace, name, err := kubernetesSecretRef(plugin.KubernetesSecret)
+		if err != nil {
+			return nil, err
+		}
+		client, apiServer, err := kubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		return &kubeSecretAuth{
+			client:    client,
+			apiServer: apiServer,
+			namespace: namespace,
+			name:      name,
+			key:       plugin.KubernetesSecretKey,
+			header:    plugin.AuthHeader,
+		}, nil
+	})
+}
+
+// kubernetesServiceAccountDir is where pods find the token, namespace and
+// cluster CA of their service account, a variable for tests.
+var kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubernetesSecretRef parses the --kubernetes-secret reference.
+func kubernetesSecretRef(ref string) (namespace, name string, err error) {
+	if ref == "" {
+		return "", "", fmt.Errorf("the kubernetes-secret auth provider requires --kubernetes-secret")
+	}
+	namespace, name, err = kubernetesObjectRef(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("no namespace in --kubernetes-secret and not running in a pod: %v", err)
+	}
+	return namespace, name, nil
+}
+
+// kubernetesObjectRef parses a [namespace/]name object reference; the
+// namespace defaults to the one of the pod.
+func kubernetesObjectRef(ref string) (namespace, name string, err error) {
+	if i := strings.IndexByte(ref, '/'); i >= 0 {
+		return ref[:i], ref[i+1:], nil
+	}
+	ns, err := ioutil.ReadFile(kubernetesServiceAccountDir + "/namespace")
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(string(ns)), ref, nil
+}
+
+// kubernetesClient returns a client of the API server trusting the cluster
+// CA, for the in-cluster service account.
+func kubernetesClient() (*http.Client, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST is not set")
+	}
+	ca, err := ioutil.ReadFile(kubernetesServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, "", err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", fmt.Errorf("no certificates in the cluster CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig()
+	transport.TLSClientConfig.RootCAs = pool
+	// No client timeout: watches stay open for minutes.
+	return &http.Client{Transport: transport}, "https://" + net.JoinHostPort(host, port), nil
+}
+
+// kubeSecretAuth sends a key of a Kubernetes Secret in the auth header. It
+// reads the Secret at the first export and then watches it, so rotated
+// tokens are used as soon as the Secret changes, without a restart.
+type kubeSecretAuth struct {
+	client    *http.Client
+	apiServer string
+	namespace string
+	name      string
+	key       string
+	header    string
+
+	sync.Mutex
+	headers  map[string]string
+	version  string
+	watching bool
+}
+
+type kubeSecret struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string][]byte `json:"data"`
+}
+
+func (a *kubeSecretAuth) Headers(ctx context.Context) (map[string]string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.headers != nil {
+		return a.headers, nil
+	}
+	var secret kubeSecret
+	if err := a.get(ctx, "/api/v1/namespaces/"+a.namespace+"/secrets/"+a.name, &secret); err != nil {
+		return nil, fmt.Errorf("could not read secret %s/%s: %w", a.namespace, a.name, err)
+	}
+	if err := a.update(&secret); err != nil {
+		return nil, err
+	}
+	if !a.watching {
+		a.watching = true
+		go a.watch()
+	}
+	return a.headers, nil
+}
+
+// update swaps the headers for the ones of secret. It is called with the
+// lock held.
+func (a *kubeSecretAuth) update(secret *kubeSecret) error {
+	value, ok := secret.Data[a.key]
+	if !ok || len(value) == 0 {
+		return fmt.Errorf("secret %s/%s has no key %q", a.namespace, a.name, a.key)
+	}
+	a.headers = map[string]string{a.header: headerValue(a.header, strings.TrimSpace(string(value)))}
+	a.version = secret.Metadata.ResourceVersion
+	return nil
+}
+
+func (a *kubeSecretAuth) invalidate() {
+	a.Lock()
+	a.headers = nil
+	a.Unlock()
+}
+
+// watch follows the changes of the secret for as long as the process runs,
+// reconnecting when the API server ends the watch.
+func (a *kubeSecretAuth) watch() {
+	backoff := time.Second
+	for {
+		err := a.watchOnce()
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		errorLog.Printf("watch of secret %s/%s failed: %v", a.namespace, a.name, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+func (a *kubeSecretAuth) watchOnce() error {
+	a.Lock()
+	version := a.version
+	a.Unlock()
+	query := url.Values{
+		"watch":           {"true"},
+		"fieldSelector":   {"metadata.name=" + a.name},
+		"resourceVersion": {version},
+	}
+	req, err := a.request(context.Background(), "/api/v1/namespaces/"+a.namespace+"/secrets?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type   string     `json:"type"`
+			Object kubeSecret `json:"object"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("invalid watch event: %v", err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			a.Lock()
+			err := a.update(&event.Object)
+			a.Unlock()
+			if err != nil {
+				errorLog.Printf("ignoring change of secret: %v", err)
+			}
+		case "DELETED":
+			errorLog.Printf("secret %s/%s was deleted, keeping the last token", a.namespace, a.name)
+		case "ERROR":
+			// Most likely the resource version expired: read the secret
+			// again before watching.
+			a.invalidate()
+			a.Lock()
+			a.version = ""
+			a.Unlock()
+			return fmt.Errorf("watch error: %s", scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+func (a *kubeSecretAuth) get(ctx context.Context, path string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	req, err := a.request(ctx, path)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return json.Unmarshal(body, v)
+}
+
+// request prepares a GET request of the API server.
+func (a *kubeSecretAuth) request(ctx context.Context, path string) (*http.Request, error) {
+	return kubernetesRequest(ctx, http.MethodGet, a.apiServer+path, nil)
+}
+
+// kubernetesRequest prepares an API server request with the service account
+// token, read for every request because the kubelet rotates it.
+func kubernetesRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	token, err := ioutil.ReadFile(kubernetesServiceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
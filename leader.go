@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// leaderElector acquires or renews the leadership of a replica.
+type leaderElector interface {
+	// acquire returns whether the replica holds the leadership until at
+	// least the next call, made within the lease duration.
+	acquire(ctx context.Context) (bool, error)
+}
+
+// leaderElection elects the replica running the singleton duties, the
+// heartbeats and the export of the stale markers, among the replicas of an
+// HA deployment.
+type leaderElection struct {
+	leading  int32
+	elector  leaderElector
+	duration time.Duration
+}
+
+// newLeaderElection parses --leader-election: file:<path> for a lock file
+// shared by the replicas of a host or of a shared volume, or
+// lease:[namespace/]name for a Kubernetes Lease.
+func newLeaderElection(value string, duration time.Duration) (*leaderElection, error) {
+	kind, target, err := parseLeaderElection(value)
+	if err != nil {
+		return nil, err
+	}
+	var elector leaderElector
+	if kind == "file" {
+		elector = &fileLock{path: target}
+	} else {
+		namespace, name, err := kubernetesObjectRef(target)
+		if err != nil {
+			return nil, fmt.Errorf("no namespace in %q and not running in a pod: %v", value, err)
+		}
+		client, apiServer, err := kubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		elector = &leaseElector{
+			client:    client,
+			apiServer: apiServer,
+			namespace: namespace,
+			name:      name,
+			identity:  leaderIdentity(),
+			duration:  duration,
+		}
+	}
+	return &leaderElection{elector: elector, duration: duration}, nil
+}
+
+// parseLeaderElection splits --leader-election into its kind and target.
+func parseLeaderElection(value string) (kind, target string, err error) {
+	i := strings.Index(value, ":")
+	if i < 0 || i == len(value)-1 || (value[:i] != "file" && value[:i] != "lease") ||
+		(value[:i] == "lease" && strings.Count(value[i+1:], "/") > 1) {
+		return "", "", fmt.Errorf("invalid value %q, expected file:<path> or lease:[namespace/]name", value)
+	}
+	return value[:i], value[i+1:], nil
+}
+
+// leaderIdentity names the replica in leases.
+func leaderIdentity() string {
+	hostname, _ := os.Hostname()
+	return hostname + "-" + strconv.Itoa(os.Getpid())
+}
+
+// run tries to acquire or renew the leadership three times per lease
+// duration, for as long as the process runs.
+func (l *leaderElection) run() {
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), l.duration/3)
+		leading, err := l.elector.acquire(ctx)
+		cancel()
+		if err != nil {
+			errorLog.Printf("leader election: %v", err)
+		}
+		if was := atomic.SwapInt32(&l.leading, boolInt32(leading)) == 1; was != leading {
+			if leading {
+				log.Printf("leading the singleton duties")
+			} else {
+				log.Printf("no longer leading the singleton duties")
+			}
+		}
+	}
+}
+
+func boolInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// leading reports whether the replica runs the singleton duties: always
+// without --leader-election.
+func (ot *otelPlugin) leading() bool {
+	return ot.leader == nil || atomic.LoadInt32(&ot.leader.leading) == 1
+}
+
+// leaseElector holds a coordination.k8s.io/v1 Lease, as client-go leader
+// election does, with the service account of the pod.
+type leaseElector struct {
+	client    *http.Client
+	apiServer string
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+}
+
+type kubeLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+	} `json:"spec"`
+}
+
+// kubeMicroTime is the format of the times of a Lease.
+const kubeMicroTime = "2006-01-02T15:04:05.000000Z07:00"
+
+func (e *leaseElector) acquire(ctx context.Context) (bool, error) {
+	path := "/apis/coordination.k8s.io/v1/namespaces/" + e.namespace + "/leases"
+	now := time.Now().UTC()
+	var lease kubeLease
+	err := e.do(ctx, http.MethodGet, path+"/"+e.name, nil, &lease)
+	if status, ok := err.(*httpStatusError); ok && status.StatusCode == http.StatusNotFound {
+		lease.APIVersion, lease.Kind = "coordination.k8s.io/v1", "Lease"
+		lease.Metadata.Name, lease.Metadata.Namespace = e.name, e.namespace
+		e.hold(&lease, now)
+		return e.result(e.do(ctx, http.MethodPost, path, &lease, nil))
+	}
+	if err != nil {
+		return false, err
+	}
+	if lease.Spec.HolderIdentity != e.identity {
+		renewed, _ := time.Parse(kubeMicroTime, lease.Spec.RenewTime)
+		expiry := renewed.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second)
+		if lease.Spec.HolderIdentity != "" && now.Before(expiry) {
+			return false, nil
+		}
+		lease.Spec.AcquireTime = ""
+	}
+	e.hold(&lease, now)
+	// The resource version makes the update fail if another replica took
+	// the lease in the meantime.
+	return e.result(e.do(ctx, http.MethodPut, path+"/"+e.name, &lease, nil))
+}
+
+// hold makes the replica the holder of lease, renewed at now.
+func (e *leaseElector) hold(lease *kubeLease, now time.Time) {
+	if lease.Spec.HolderIdentity != e.identity || lease.Spec.AcquireTime == "" {
+		lease.Spec.AcquireTime = now.Format(kubeMicroTime)
+	}
+	lease.Spec.HolderIdentity = e.identity
+	lease.Spec.LeaseDurationSeconds = int(e.duration / time.Second)
+	lease.Spec.RenewTime = now.Format(kubeMicroTime)
+}
+
+// result is the outcome of a create or update of the lease: conflicts mean
+// another replica holds it.
+func (e *leaseElector) result(err error) (bool, error) {
+	if status, ok := err.(*httpStatusError); ok && status.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *leaseElector) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := kubernetesRequest(ctx, method, e.apiServer+path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+)
+
+// fileLock holds an exclusive flock of a file shared by the replicas. The
+// kernel releases it when the process exits, letting another replica take
+// over at its next attempt.
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+func (l *fileLock) acquire(ctx context.Context) (bool, error) {
+	if l.file != nil {
+		return true, nil
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+	l.file = f
+	return true, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLeaderElection(t *testing.T) {
+	for value, want := range map[string][2]string{
+		"file:/var/run/otel.lock": {"file", "/var/run/otel.lock"},
+		"lease:monitoring/otel":   {"lease", "monitoring/otel"},
+		"lease:otel":              {"lease", "otel"},
+	} {
+		kind, target, err := parseLeaderElection(value)
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+		} else if kind != want[0] || target != want[1] {
+			t.Errorf("%q: got %s %s, want %s %s", value, kind, target, want[0], want[1])
+		}
+	}
+	for _, value := range []string{"", "file:", "lease:", "etcd:/otel", "/var/run/otel.lock", "lease:a/b/c"} {
+		if _, _, err := parseLeaderElection(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestLeaderElectionFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "otel.lock")
+
+	first, second := &fileLock{path: path}, &fileLock{path: path}
+	if ok, err := first.acquire(context.Background()); !ok || err != nil {
+		t.Fatalf("first replica: got %v, %v", ok, err)
+	}
+	if ok, err := first.acquire(context.Background()); !ok || err != nil {
+		t.Fatalf("first replica renewal: got %v, %v", ok, err)
+	}
+	if ok, err := second.acquire(context.Background()); ok || err != nil {
+		t.Fatalf("second replica: got %v, %v", ok, err)
+	}
+	first.file.Close()
+	if ok, err := second.acquire(context.Background()); !ok || err != nil {
+		t.Fatalf("second replica after release: got %v, %v", ok, err)
+	}
+	second.file.Close()
+}
+
+func TestLeaderElectionLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serviceaccount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous string) { kubernetesServiceAccountDir = previous }(kubernetesServiceAccountDir)
+	kubernetesServiceAccountDir = dir
+
+	// A minimal lease API enforcing the resource version on updates.
+	var (
+		mu      sync.Mutex
+		stored  *kubeLease
+		version int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var lease kubeLease
+		switch r.Method {
+		case http.MethodGet:
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(stored)
+			return
+		case http.MethodPost:
+			if stored != nil {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		case http.MethodPut:
+			json.NewDecoder(r.Body).Decode(&lease)
+			if lease.Metadata.ResourceVersion != stored.Metadata.ResourceVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&lease)
+		}
+		version++
+		lease.Metadata.ResourceVersion = strconv.Itoa(version)
+		stored = &lease
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	elector := func(identity string) *leaseElector {
+		return &leaseElector{
+			client:    server.Client(),
+			apiServer: server.URL,
+			namespace: "monitoring",
+			name:      "otel",
+			identity:  identity,
+			duration:  15 * time.Second,
+		}
+	}
+	a, b := elector("a"), elector("b")
+	for i, step := range []struct {
+		elector *leaseElector
+		want    bool
+	}{
+		{a, true},
+		{b, false},
+		{a, true},
+		{b, false},
+	} {
+		if ok, err := step.elector.acquire(context.Background()); ok != step.want || err != nil {
+			t.Fatalf("step %d: got %v, %v, want %v", i, ok, err, step.want)
+		}
+	}
+	if stored.Spec.HolderIdentity != "a" || stored.Spec.LeaseDurationSeconds != 15 {
+		t.Fatalf("unexpected lease %+v", stored.Spec)
+	}
+
+	// Once expired, the lease is taken over.
+	stored.Spec.RenewTime = time.Now().Add(-time.Minute).UTC().Format(kubeMicroTime)
+	if ok, err := b.acquire(context.Background()); !ok || err != nil {
+		t.Fatalf("takeover: got %v, %v", ok, err)
+	}
+	if stored.Spec.HolderIdentity != "b" {
+		t.Fatalf("expected b to hold the lease, got %q", stored.Spec.HolderIdentity)
+	}
+	if ok, err := a.acquire(context.Background()); ok || err != nil {
+		t.Fatalf("previous holder: got %v, %v", ok, err)
+	}
+}
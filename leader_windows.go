@@ -0,0 +1,15 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// fileLock is not supported on Windows, which has no flock.
+type fileLock struct {
+	path string
+}
+
+func (l *fileLock) acquire(ctx context.Context) (bool, error) {
+	return false, errors.New("file leader election is not supported on windows")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// errorLog samples repetitive error messages, e.g. the identical export
+// error every event runs into while the collector is down.
+var errorLog = &sampledLog{every: 100, counts: map[string]int64{}}
+
+// sampledLog logs the first occurrence of a message, then one in every
+// occurrences, and periodically summarizes how often each message was seen.
+type sampledLog struct {
+	sync.Mutex
+	every  int64
+	counts map[string]int64
+}
+
+// Printf logs the formatted message subject to sampling.
+func (s *sampledLog) Printf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.Lock()
+	s.counts[msg]++
+	n := s.counts[msg]
+	every := s.every
+	s.Unlock()
+
+	switch {
+	case n == 1:
+		log.Print(msg)
+	case every > 0 && n%every == 0:
+		log.Printf("%s (repeated %d times)", msg, n)
+	}
+}
+
+// SetEvery changes the sampling rate, 1 logs every message.
+func (s *sampledLog) SetEvery(every int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.every = every
+}
+
+// runSummary logs the number of occurrences of every repeated message at
+// each interval and starts sampling afresh. It never returns.
+func (s *sampledLog) runSummary(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Lock()
+		counts := s.counts
+		s.counts = map[string]int64{}
+		s.Unlock()
+		for msg, n := range counts {
+			if n > 1 {
+				log.Printf("%q occurred %d times in the last %v", msg, n, interval)
+			}
+		}
+	}
+}
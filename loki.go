@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("loki", func() (Exporter, error) {
+		if plugin.LokiURL == "" {
+			return nil, fmt.Errorf("the loki exporter requires --loki-url")
+		}
+		return &lokiExporter{
+			client: &http.Client{Timeout: exportTimeout},
+			url:    strings.TrimSuffix(plugin.LokiURL, "/") + "/loki/api/v1/push",
+			tenant: plugin.LokiTenant,
+		}, nil
+	})
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiExporter pushes the check output log records to Loki, one stream per
+// namespace, entity, check and severity. It only sends logs, so it is
+// selected along with an exporter for the metrics and the logs signal must
+// be enabled.
+type lokiExporter struct {
+	client *http.Client
+	url    string
+	tenant string
+}
+
+func (e *lokiExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Logs) == 0 {
+		return nil
+	}
+	labels := map[string]string{"job": "sensu"}
+	if len(batch.Events) > 0 {
+		namespace, entity, check := converter.EventNames(batch.Events[0])
+		labels["namespace"], labels["entity"], labels["check"] = namespace, entity, check
+	}
+
+	var push lokiPush
+	for _, record := range batch.Logs {
+		stream := map[string]string{"level": strings.ToLower(record.SeverityText)}
+		for k, v := range labels {
+			if v != "" {
+				stream[k] = v
+			}
+		}
+		push.Streams = append(push.Streams, lokiStream{
+			Stream: stream,
+			Values: [][2]string{{strconv.FormatUint(record.TimeUnixNano, 10), record.Body.GetStringValue()}},
+		})
+	}
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if e.tenant != "" {
+			req.Header.Set("X-Scope-OrgID", e.tenant)
+		}
+		if tenant := eventTenant(batch); tenant != "" {
+			req.Header.Set(plugin.TenantHeader, tenant)
+		}
+		return doHTTP(e.client, req)
+	})
+}
+
+func (e *lokiExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *lokiExporter) String() string {
+	return "loki " + e.url
+}
@@ -2,34 +2,195 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric/number"
-	"go.opentelemetry.io/otel/metric/sdkapi"
-	"go.opentelemetry.io/otel/sdk/instrumentation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"google.golang.org/grpc/credentials"
-
 	"net/http"
 
 	"github.com/sensu-community/sensu-plugin-sdk/sensu"
 	"github.com/sensu/sensu-go/types"
 
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	sdkexport "go.opentelemetry.io/otel/sdk/export/metric"
-	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
 )
 
 // Config represents the handler plugin config.
 type Config struct {
 	sensu.PluginConfig
+	RecordDir             string
+	RecordMaxSize         int64
+	RecordMaxFiles        int64
+	RecordMaxAge          string
+	RecordMaxTotalSize    int64
+	SelfTelemetry         string
+	AuditLog              string
+	SLOLatency            string
+	SLOObjective          string
+	SLOWindow             string
+	LogSampleRate         int64
+	LogSummary            string
+	Signals               string
+	Pipeline              string
+	RuntimeConfig         string
+	LogSeverityMap        string
+	StaleSeries           bool
+	StaleMarkers          bool
+	DeregistrationMetric  bool
+	DedupWindow           string
+	ClockSkewWindow       string
+	ClockSkewAction       string
+	TimestampAlignment    string
+	MetricScale           string
+	MetricKinds           string
+	DerivedMetrics        string
+	TopK                  string
+	SplitTags             string
+	GraphiteTemplates     string
+	Profiles              string
+	Preflight             bool
+	SocketListen          string
+	SocketNamespace       string
+	StatsdListen          string
+	StatsdFlushInterval   string
+	StatsdEntity          string
+	StatsdNamespace       string
+	ScrapeProxyTargets    string
+	ScrapeProxyInterval   string
+	ScrapeProxyNamespace  string
+	HeartbeatInterval     string
+	CanaryInterval        string
+	CanaryQueryURL        string
+	CanaryQuery           string
+	CanaryQueryToken      string
+	CanaryTimeout         string
+	LeaderElection        string
+	LeaderElectionLease   string
+	FeatureGates          string
+	ResourceLabels        string
+	LogSeverityOverrides  string
+	Exporter              string
+	OTLPEndpoint          string
+	OTLPInsecure          bool
+	OTLPCompression       string
+	OTLPHeaders           string
+	OTLPCAFile            string
+	OTLPCertFile          string
+	OTLPKeyFile           string
+	ExportTimeout         string
+	Listen                string
+	ExporterFile          string
+	Routes                string
+	ExporterPolicy        string
+	Chaos                 string
+	Tenants               string
+	TenantLabel           string
+	TenantHeader          string
+	TenantDefault         string
+	IngestAllowCIDRs      string
+	IngestTLSCertFile     string
+	IngestTLSKeyFile      string
+	IngestClientCAFile    string
+	IngestClientNames     string
+	IngestTokens          string
+	IngestDeadline        string
+	IngestQuotas          string
+	IngestResponse        string
+	IngestQueue           int64
+	IngestWorkers         int64
+	IngestBatch           int64
+	TLSMinVersion         string
+	TLSCipherSuites       string
+	ConfigBundle          string
+	ConfigBundleKey       string
+	ConfigBundleSignature string
+	AdminListen           string
+	AdminTokens           string
+	EgressAllow           string
+	InMemoryMaxBatches    int64
+	Auth                  string
+	AuthHeader            string
+	AuthTokenFile         string
+	AccessToken           string
+	OAuth2TokenURL        string
+	OAuth2ClientID        string
+	OAuth2Secret          string
+	OAuth2Scopes          string
+	OAuth2Audience        string
+	OAuth2AuthStyle       string
+	SigV4Region           string
+	SigV4Service          string
+	GCPAudience           string
+	VaultAddr             string
+	VaultPath             string
+	VaultField            string
+	VaultRole             string
+	VaultAuthMount        string
+	VaultRefresh          string
+	AWSSecretsHeaders     string
+	AWSSecretsRegion      string
+	AWSSecretsRefresh     string
+	KubernetesSecret      string
+	KubernetesSecretKey   string
+	KafkaBrokers          string
+	KafkaTopic            string
+	KafkaTLS              bool
+	KafkaSASL             string
+	KafkaUsername         string
+	KafkaPassword         string
+	NATSURL               string
+	NATSSubject           string
+	NATSJetStream         bool
+	NATSCredentials       string
+	PushgatewayURL        string
+	PushgatewayJob        string
+	PushgatewayInstance   string
+	ScrapeEndpoint        bool
+	ScrapeStaleness       string
+	CarbonAddress         string
+	CarbonTemplate        string
+	InfluxDBURL           string
+	InfluxDBOrg           string
+	InfluxDBBucket        string
+	InfluxDBToken         string
+	DatadogSite           string
+	DatadogAPIKey         string
+	DatadogTagMap         string
+	SplunkURL             string
+	SplunkToken           string
+	SplunkIndex           string
+	SplunkEvents          bool
+	LokiURL               string
+	LokiTenant            string
+	ElasticsearchURL      string
+	ElasticsearchIndex    string
+	ElasticsearchUser     string
+	ElasticsearchPass     string
+	ElasticsearchAPIKey   string
+	SyslogAddress         string
+	SyslogTLS             bool
+	SyslogFacility        string
+	CloudWatchRegion      string
+	CloudWatchNamespace   string
+	CloudWatchDimensions  string
+	GCPProject            string
+	GCPResource           string
+	GCPLocation           string
+	AzureResourceID       string
+	AzureRegion           string
+	AzureNamespace        string
+	AzureClientID         string
+	AzureConnectionString string
+	WebhookURL            string
+	WebhookTemplateFile   string
 }
 
 var (
@@ -40,8 +201,1318 @@ var (
 			Keyspace: "sensu.io/plugins/otel-sensu-handler-plugin/config",
 		},
 	}
-	port    = ":55788"
-	options []*sensu.PluginConfigOption
+	options = []*sensu.PluginConfigOption{
+		{
+			// No path: annotations must not choose where received events are recorded for replay.
+			Env:      "OTEL_SENSU_RECORD_DIR",
+			Argument: "record-dir",
+			Default:  "",
+			Usage:    "Directory to record every received event to, as rotating JSON lines files",
+			Value:    &plugin.RecordDir,
+		},
+		{
+			Path:     "record-max-size",
+			Env:      "OTEL_SENSU_RECORD_MAX_SIZE",
+			Argument: "record-max-size",
+			Default:  int64(64),
+			Usage:    "Size in MB after which a record file is rotated",
+			Value:    &plugin.RecordMaxSize,
+		},
+		{
+			Path:     "record-max-files",
+			Env:      "OTEL_SENSU_RECORD_MAX_FILES",
+			Argument: "record-max-files",
+			Default:  int64(10),
+			Usage:    "Number of record files to keep, 0 keeps all of them",
+			Value:    &plugin.RecordMaxFiles,
+		},
+		{
+			Path:     "record-max-age",
+			Env:      "OTEL_SENSU_RECORD_MAX_AGE",
+			Argument: "record-max-age",
+			Default:  "",
+			Usage:    "Age of the last write after which a record file is removed, e.g. 168h (no limit when empty)",
+			Value:    &plugin.RecordMaxAge,
+		},
+		{
+			Path:     "record-max-total-size",
+			Env:      "OTEL_SENSU_RECORD_MAX_TOTAL_SIZE",
+			Argument: "record-max-total-size",
+			Default:  int64(0),
+			Usage:    "Total size in MB of the record files beyond which the oldest are removed, 0 for no limit",
+			Value:    &plugin.RecordMaxTotalSize,
+		},
+		{
+			Path:     "self-telemetry-interval",
+			Env:      "OTEL_SENSU_SELF_TELEMETRY_INTERVAL",
+			Argument: "self-telemetry-interval",
+			Default:  "",
+			Usage:    "Interval at which the server exports its own runtime metrics, e.g. 1m (disabled when empty)",
+			Value:    &plugin.SelfTelemetry,
+		},
+		{
+			Path:     "heartbeat-interval",
+			Env:      "OTEL_SENSU_HEARTBEAT_INTERVAL",
+			Argument: "heartbeat-interval",
+			Default:  "",
+			Usage:    "Interval to export the sensu.otel.handler.heartbeat metric at in server mode, for absence alerts on the handler",
+			Value:    &plugin.HeartbeatInterval,
+		},
+		{
+			Path:     "canary-interval",
+			Env:      "OTEL_SENSU_CANARY_INTERVAL",
+			Argument: "canary-interval",
+			Default:  "",
+			Usage:    "Interval to export the sensu.otel.handler.canary metric at in server mode, whose delivery /readyz reports (disabled when empty)",
+			Value:    &plugin.CanaryInterval,
+		},
+		{
+			// No path: annotations must not send the query token elsewhere.
+			Env:      "OTEL_SENSU_CANARY_QUERY_URL",
+			Argument: "canary-query-url",
+			Default:  "",
+			Usage:    "Prometheus HTTP API of the backend, e.g. http://prometheus:9090, queried to confirm that each canary arrived rather than only that its export succeeded",
+			Value:    &plugin.CanaryQueryURL,
+		},
+		{
+			Path:     "canary-query",
+			Env:      "OTEL_SENSU_CANARY_QUERY",
+			Argument: "canary-query",
+			Default:  "sensu_otel_handler_canary",
+			Usage:    "PromQL query returning the canaries as stored by the backend",
+			Value:    &plugin.CanaryQuery,
+		},
+		{
+			// No path: the token is a credential, not a setting of a check.
+			Env:      "OTEL_SENSU_CANARY_QUERY_TOKEN",
+			Argument: "canary-query-token",
+			Default:  "",
+			Usage:    "Variable holding the bearer token of --canary-query-url, e.g. a Lightstep API key",
+			Value:    &plugin.CanaryQueryToken,
+		},
+		{
+			Path:     "canary-timeout",
+			Env:      "OTEL_SENSU_CANARY_TIMEOUT",
+			Argument: "canary-timeout",
+			Default:  "60s",
+			Usage:    "Time for a canary to show up in --canary-query-url before it counts as lost",
+			Value:    &plugin.CanaryTimeout,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_LEADER_ELECTION",
+			Argument: "leader-election",
+			Default:  "",
+			Usage:    "Elect one replica to run heartbeats and stale markers, through a lock file (file:<path>) or a Kubernetes Lease (lease:[namespace/]name)",
+			Value:    &plugin.LeaderElection,
+		},
+		{
+			Path:     "leader-election-lease",
+			Env:      "OTEL_SENSU_LEADER_ELECTION_LEASE",
+			Argument: "leader-election-lease",
+			Default:  "15s",
+			Usage:    "Duration of the leadership, renewed every third of it, before another replica may take over",
+			Value:    &plugin.LeaderElectionLease,
+		},
+		{
+			// No path: annotations must not redirect the audit trail.
+			Env:      "OTEL_SENSU_AUDIT_LOG",
+			Argument: "audit-log",
+			Default:  "",
+			Usage:    "File to append a JSON line to for every event exported or failed to export",
+			Value:    &plugin.AuditLog,
+		},
+		{
+			Path:     "slo-latency",
+			Env:      "OTEL_SENSU_SLO_LATENCY",
+			Argument: "slo-latency",
+			Default:  "1s",
+			Usage:    "Latency from receiving an event to its export being acknowledged that meets the SLO",
+			Value:    &plugin.SLOLatency,
+		},
+		{
+			Path:     "slo-objective",
+			Env:      "OTEL_SENSU_SLO_OBJECTIVE",
+			Argument: "slo-objective",
+			Default:  "99.9",
+			Usage:    "Percentage of exports expected to meet the latency SLO",
+			Value:    &plugin.SLOObjective,
+		},
+		{
+			Path:     "slo-window",
+			Env:      "OTEL_SENSU_SLO_WINDOW",
+			Argument: "slo-window",
+			Default:  "5m",
+			Usage:    "Sliding window over which the latency SLO burn rate is computed",
+			Value:    &plugin.SLOWindow,
+		},
+		{
+			Path:     "log-sample-rate",
+			Env:      "OTEL_SENSU_LOG_SAMPLE_RATE",
+			Argument: "log-sample-rate",
+			Default:  int64(100),
+			Usage:    "Log only the first and then one in this many occurrences of a repeated error, 1 logs all of them",
+			Value:    &plugin.LogSampleRate,
+		},
+		{
+			Path:     "log-summary-interval",
+			Env:      "OTEL_SENSU_LOG_SUMMARY_INTERVAL",
+			Argument: "log-summary-interval",
+			Default:  "1m",
+			Usage:    "Interval at which the number of occurrences of repeated errors is logged",
+			Value:    &plugin.LogSummary,
+		},
+		{
+			Path:     "signals",
+			Env:      "OTEL_SENSU_SIGNALS",
+			Argument: "signals",
+			Default:  "metrics,checks",
+			Usage:    "Comma separated telemetry signals generated from events: metrics, logs, traces, checks (status, duration and occurrences gauges of the check result), events (the events themselves)",
+			Value:    &plugin.Signals,
+		},
+		{
+			Path:     "resource-labels",
+			Env:      "OTEL_SENSU_RESOURCE_LABELS",
+			Argument: "resource-labels",
+			Default:  "",
+			Usage:    "Comma separated globs of the entity and check label and annotation keys to promote to resource attributes",
+			Value:    &plugin.ResourceLabels,
+		},
+		{
+			Path:     "log-severity-map",
+			Env:      "OTEL_SENSU_LOG_SEVERITY_MAP",
+			Argument: "log-severity-map",
+			Default:  "",
+			Usage:    "Comma separated status=severity severities of the logs of the check statuses, e.g. critical=error,unknown=warn (default: ok=info,warning=warn,critical=error,unknown=error)",
+			Value:    &plugin.LogSeverityMap,
+		},
+		{
+			Path:     "log-severity-overrides",
+			Env:      "OTEL_SENSU_LOG_SEVERITY_OVERRIDES",
+			Argument: "log-severity-overrides",
+			Default:  "",
+			Usage:    "Semicolon separated regexp => severity severities of the logs of the check outputs matching the regexp, e.g. OOM => fatal",
+			Value:    &plugin.LogSeverityOverrides,
+		},
+		{
+			Path:     "pipeline",
+			Env:      "OTEL_SENSU_PIPELINE",
+			Argument: "pipeline",
+			Default:  defaultPipeline,
+			Usage:    "Comma separated order of the processing stages: decode, filter, enrich, transform, convert and export",
+			Value:    &plugin.Pipeline,
+		},
+		{
+			Path:     "feature-gates",
+			Env:      "OTEL_SENSU_FEATURE_GATES",
+			Argument: "feature-gates",
+			Default:  "",
+			Usage:    "Comma separated list of features to enable (name or name=true|false), all disabled by default (see README)",
+			Value:    &plugin.FeatureGates,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_RUNTIME_CONFIG",
+			Argument: "runtime-config",
+			Default:  "",
+			Usage:    "JSON file the filters, rename rules and sinks changed through the /pipeline admin endpoint are saved to and loaded from",
+			Value:    &plugin.RuntimeConfig,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_PROFILES",
+			Argument: "profiles",
+			Default:  "",
+			Usage:    "JSON file of named profiles of filters, metric name prefix and sinks, and of the namespaces bound to them",
+			Value:    &plugin.Profiles,
+		},
+		{
+			Path:     "preflight",
+			Env:      "OTEL_SENSU_PREFLIGHT",
+			Argument: "preflight",
+			Default:  false,
+			Usage:    "Check the connection and credentials of the OTLP exporters with an empty export at server startup; failures keep /readyz down until an export succeeds",
+			Value:    &plugin.Preflight,
+		},
+		{
+			Path:     "socket-listen",
+			Env:      "OTEL_SENSU_SOCKET_LISTEN",
+			Argument: "socket-listen",
+			Default:  "",
+			Usage:    "host:port to accept Sensu 1.x style check results and Sensu events as JSON lines on, over TCP and UDP, e.g. 127.0.0.1:3030",
+			Value:    &plugin.SocketListen,
+		},
+		{
+			Path:     "socket-namespace",
+			Env:      "OTEL_SENSU_SOCKET_NAMESPACE",
+			Argument: "socket-namespace",
+			Default:  "default",
+			Usage:    "Namespace of the check results received by --socket-listen",
+			Value:    &plugin.SocketNamespace,
+		},
+		{
+			Path:     "statsd-listen",
+			Env:      "OTEL_SENSU_STATSD_LISTEN",
+			Argument: "statsd-listen",
+			Default:  "",
+			Usage:    "host:port to accept StatsD metrics on over UDP, e.g. 127.0.0.1:8125, exported every --statsd-flush-interval",
+			Value:    &plugin.StatsdListen,
+		},
+		{
+			Path:     "statsd-flush-interval",
+			Env:      "OTEL_SENSU_STATSD_FLUSH_INTERVAL",
+			Argument: "statsd-flush-interval",
+			Default:  "10s",
+			Usage:    "Interval the StatsD metrics are aggregated over",
+			Value:    &plugin.StatsdFlushInterval,
+		},
+		{
+			Path:     "statsd-entity",
+			Env:      "OTEL_SENSU_STATSD_ENTITY",
+			Argument: "statsd-entity",
+			Default:  "peer",
+			Usage:    "Entity of the StatsD metrics: peer for the sending host, tag:<name> for the value of a DogStatsD tag, or an entity name",
+			Value:    &plugin.StatsdEntity,
+		},
+		{
+			Path:     "statsd-namespace",
+			Env:      "OTEL_SENSU_STATSD_NAMESPACE",
+			Argument: "statsd-namespace",
+			Default:  "default",
+			Usage:    "Namespace of the StatsD metrics",
+			Value:    &plugin.StatsdNamespace,
+		},
+		{
+			// No path: annotations must not pick the hosts scraped.
+			Env:      "OTEL_SENSU_SCRAPE_PROXY_TARGETS",
+			Argument: "scrape-proxy-targets",
+			Default:  "",
+			Usage:    "Comma separated [check=]url Prometheus endpoints to scrape every --scrape-proxy-interval, exporting their samples as the checks of the handler host",
+			Value:    &plugin.ScrapeProxyTargets,
+		},
+		{
+			Path:     "scrape-proxy-interval",
+			Env:      "OTEL_SENSU_SCRAPE_PROXY_INTERVAL",
+			Argument: "scrape-proxy-interval",
+			Default:  "60s",
+			Usage:    "Interval the --scrape-proxy-targets are scraped at",
+			Value:    &plugin.ScrapeProxyInterval,
+		},
+		{
+			Path:     "scrape-proxy-namespace",
+			Env:      "OTEL_SENSU_SCRAPE_PROXY_NAMESPACE",
+			Argument: "scrape-proxy-namespace",
+			Default:  "default",
+			Usage:    "Namespace of the events of the --scrape-proxy-targets",
+			Value:    &plugin.ScrapeProxyNamespace,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_EXPORTER",
+			Argument: "exporter",
+			Default:  "otlp",
+			Usage:    "Comma separated exporters sending the converted telemetry: otlp, otlphttp, kafka, nats, pushgateway, prometheus, carbon, influxdb, datadog, splunk, loki, elasticsearch, syslog, cloudwatch, cloudmonitoring, azuremonitor, webhook, file, stdout or inmemory",
+			Value:    &plugin.Exporter,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_EXPORTER_OTLP_METRIC_ENDPOINT",
+			Argument: "otlp-endpoint",
+			Default:  "ingest.lightstep.com:443",
+			Usage:    "host:port of the backend or collector of the otlp and otlphttp exporters",
+			Value:    &plugin.OTLPEndpoint,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_EXPORTER_OTLP_METRIC_INSECURE",
+			Argument: "otlp-insecure",
+			Default:  false,
+			Usage:    "Connect to --otlp-endpoint without TLS",
+			Value:    &plugin.OTLPInsecure,
+		},
+		{
+			Path:     "otlp-compression",
+			Env:      "OTEL_EXPORTER_OTLP_METRIC_COMPRESSION",
+			Argument: "otlp-compression",
+			Default:  "",
+			Usage:    "Compression of the otlp and otlphttp export requests: gzip or none",
+			Value:    &plugin.OTLPCompression,
+		},
+		{
+			// No path: the headers usually hold the credentials of the backend.
+			Env:      "OTEL_EXPORTER_OTLP_HEADERS",
+			Argument: "otlp-headers",
+			Default:  "",
+			Usage:    "Comma separated headers added to the otlp and otlphttp export requests, e.g. 'x-scope-orgid=team-a,x-env=prod'",
+			Value:    &plugin.OTLPHeaders,
+		},
+		{
+			// No path: annotations must not replace the trust roots.
+			Env:      "OTEL_EXPORTER_OTLP_CERTIFICATE",
+			Argument: "otlp-ca-file",
+			Default:  "",
+			Usage:    "PEM file of the certificate authorities verifying --otlp-endpoint, instead of the system ones",
+			Value:    &plugin.OTLPCAFile,
+		},
+		{
+			Env:      "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE",
+			Argument: "otlp-cert-file",
+			Default:  "",
+			Usage:    "PEM certificate file the otlp and otlphttp exporters authenticate with, along with --otlp-key-file",
+			Value:    &plugin.OTLPCertFile,
+		},
+		{
+			Env:      "OTEL_EXPORTER_OTLP_CLIENT_KEY",
+			Argument: "otlp-key-file",
+			Default:  "",
+			Usage:    "PEM private key file of --otlp-cert-file",
+			Value:    &plugin.OTLPKeyFile,
+		},
+		{
+			Path:     "export-timeout",
+			Env:      "OTEL_SENSU_EXPORT_TIMEOUT",
+			Argument: "export-timeout",
+			Default:  "10s",
+			Usage:    "Time an export may take including its retries, unless --exporter-policy sets one for the exporter",
+			Value:    &plugin.ExportTimeout,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_ROUTES",
+			Argument: "routes",
+			Default:  "",
+			Usage:    "Routing table sending events to some of the exporters, e.g. 'namespace=prod-* => otlp; label.env=lab => otlphttp' (unmatched events go to all)",
+			Value:    &plugin.Routes,
+		},
+		{
+			Path:     "exporter-policy",
+			Env:      "OTEL_SENSU_EXPORTER_POLICY",
+			Argument: "exporter-policy",
+			Default:  "",
+			Usage:    "Per exporter timeout, retries, backoff and background queue size, e.g. 'splunk:timeout=3s,retries=1,queue=100; loki:timeout=2s'",
+			Value:    &plugin.ExporterPolicy,
+		},
+		{
+			// No path: annotations must not pick the token variables and their endpoints.
+			Env:      "OTEL_SENSU_TENANTS",
+			Argument: "tenants",
+			Default:  "",
+			Usage:    "Backend tenants of the OTLP exporters by Sensu namespace, each with the variable holding its token and an optional endpoint, e.g. 'team-a=TEAM_A_TOKEN; team-b-*=TEAM_B_TOKEN@otlp.team-b.example:443'",
+			Value:    &plugin.Tenants,
+		},
+		{
+			Path:     "tenant-label",
+			Env:      "OTEL_SENSU_TENANT_LABEL",
+			Argument: "tenant-label",
+			Default:  "",
+			Usage:    "Check or entity label whose value the otlp, otlphttp and loki exporters send in --tenant-header, e.g. team",
+			Value:    &plugin.TenantLabel,
+		},
+		{
+			Path:     "tenant-header",
+			Env:      "OTEL_SENSU_TENANT_HEADER",
+			Argument: "tenant-header",
+			Default:  "X-Scope-OrgID",
+			Usage:    "Header carrying the tenant of --tenant-label (X-Scope-OrgID for Mimir, Loki and Cortex)",
+			Value:    &plugin.TenantHeader,
+		},
+		{
+			Path:     "tenant-default",
+			Env:      "OTEL_SENSU_TENANT_DEFAULT",
+			Argument: "tenant-default",
+			Default:  "",
+			Usage:    "Tenant of the events without the --tenant-label label; without it they are sent with no tenant header",
+			Value:    &plugin.TenantDefault,
+		},
+		{
+			Path:     "listen",
+			Env:      "OTEL_SENSU_LISTEN",
+			Argument: "listen",
+			Default:  ":55788",
+			Usage:    "Address the server accepts posted events on",
+			Value:    &plugin.Listen,
+		},
+		{
+			Path:     "ingest-allow-cidrs",
+			Env:      "OTEL_SENSU_INGEST_ALLOW_CIDRS",
+			Argument: "ingest-allow-cidrs",
+			Default:  "",
+			Usage:    "Comma separated CIDR blocks or addresses of the clients allowed to post events to the HTTP server, e.g. the Sensu backends (default: any)",
+			Value:    &plugin.IngestAllowCIDRs,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_INGEST_TLS_CERT_FILE",
+			Argument: "ingest-tls-cert-file",
+			Default:  "",
+			Usage:    "PEM certificate file to serve HTTPS with instead of HTTP",
+			Value:    &plugin.IngestTLSCertFile,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_INGEST_TLS_KEY_FILE",
+			Argument: "ingest-tls-key-file",
+			Default:  "",
+			Usage:    "PEM private key file of --ingest-tls-cert-file",
+			Value:    &plugin.IngestTLSKeyFile,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_INGEST_CLIENT_CA_FILE",
+			Argument: "ingest-client-ca-file",
+			Default:  "",
+			Usage:    "PEM CA bundle client certificates must be issued by to post events (mutual TLS)",
+			Value:    &plugin.IngestClientCAFile,
+		},
+		{
+			Path:     "ingest-client-names",
+			Env:      "OTEL_SENSU_INGEST_CLIENT_NAMES",
+			Argument: "ingest-client-names",
+			Default:  "",
+			Usage:    "Comma separated globs of the common or DNS names of the client certificates allowed to post events, e.g. sensu-backend-*.example.com (default: any)",
+			Value:    &plugin.IngestClientNames,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_INGEST_TOKENS",
+			Argument: "ingest-tokens",
+			Default:  "",
+			Usage:    "Variables holding the bearer tokens required to post events, each optionally limited to namespaces, e.g. 'TEAM_A_INGEST_TOKEN=team-a,team-a-*; SENSU_INGEST_TOKEN'",
+			Value:    &plugin.IngestTokens,
+		},
+		{
+			Path:     "ingest-quotas",
+			Env:      "OTEL_SENSU_INGEST_QUOTAS",
+			Argument: "ingest-quotas",
+			Default:  "",
+			Usage:    "Quotas of events and points per minute or day of the tokens of --ingest-tokens, e.g. 'TEAM_A_INGEST_TOKEN=events:600/m,points:1000000/d'",
+			Value:    &plugin.IngestQuotas,
+		},
+		{
+			Path:     "ingest-deadline",
+			Env:      "OTEL_SENSU_INGEST_DEADLINE",
+			Argument: "ingest-deadline",
+			Default:  "30s",
+			Usage:    "Deadline to process a posted event in, after which the server answers 504 with how far the event got (disabled when empty)",
+			Value:    &plugin.IngestDeadline,
+		},
+		{
+			Path:     "ingest-response",
+			Env:      "OTEL_SENSU_INGEST_RESPONSE",
+			Argument: "ingest-response",
+			Default:  responseSummary,
+			Usage:    "Detail of the responses to posted events: minimal (204 No Content), summary (point counts) or verbose (every exported point, as JSON)",
+			Value:    &plugin.IngestResponse,
+		},
+		{
+			Path:     "ingest-queue",
+			Env:      "OTEL_SENSU_INGEST_QUEUE",
+			Argument: "ingest-queue",
+			Default:  int64(0),
+			Usage:    "Number of posted events queued for export in the background, answered with 202 at once and 503 while the queue is full (0 exports them before answering)",
+			Value:    &plugin.IngestQueue,
+		},
+		{
+			Path:     "ingest-workers",
+			Env:      "OTEL_SENSU_INGEST_WORKERS",
+			Argument: "ingest-workers",
+			Default:  int64(4),
+			Usage:    "Number of events of --ingest-queue exported at the same time",
+			Value:    &plugin.IngestWorkers,
+		},
+		{
+			Path:     "ingest-batch",
+			Env:      "OTEL_SENSU_INGEST_BATCH",
+			Argument: "ingest-batch",
+			Default:  int64(100),
+			Usage:    "Maximum number of events of --ingest-queue a worker exports together, the telemetry of every resource in one entry of the requests",
+			Value:    &plugin.IngestBatch,
+		},
+		{
+			Path:     "tls-min-version",
+			Env:      "OTEL_SENSU_TLS_MIN_VERSION",
+			Argument: "tls-min-version",
+			Default:  "1.2",
+			Usage:    "Minimum TLS version of the connections the handler accepts and makes: 1.0, 1.1, 1.2 or 1.3",
+			Value:    &plugin.TLSMinVersion,
+		},
+		{
+			Path:     "tls-cipher-suites",
+			Env:      "OTEL_SENSU_TLS_CIPHER_SUITES",
+			Argument: "tls-cipher-suites",
+			Default:  "",
+			Usage:    "Comma separated TLS 1.0-1.2 cipher suites allowed, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (default: the Go defaults)",
+			Value:    &plugin.TLSCipherSuites,
+		},
+		{
+			// No path: annotations must not pick the configuration applied.
+			Env:      "OTEL_SENSU_CONFIG_BUNDLE",
+			Argument: "config-bundle",
+			Default:  "",
+			Usage:    "Signed JSON file of option values, e.g. routes and exporter policies, applied over all other settings once verified",
+			Value:    &plugin.ConfigBundle,
+		},
+		{
+			// No path: annotations must not pick the key bundles are verified with.
+			Env:      "OTEL_SENSU_CONFIG_BUNDLE_KEY",
+			Argument: "config-bundle-key",
+			Default:  "",
+			Usage:    "minisign or cosign (PEM) public key file verifying --config-bundle",
+			Value:    &plugin.ConfigBundleKey,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_CONFIG_BUNDLE_SIGNATURE",
+			Argument: "config-bundle-signature",
+			Default:  "",
+			Usage:    "Signature file of --config-bundle (default: the bundle with .minisig or, with a PEM key, .sig appended)",
+			Value:    &plugin.ConfigBundleSignature,
+		},
+		{
+			Path:     "admin-listen",
+			Env:      "OTEL_SENSU_ADMIN_LISTEN",
+			Argument: "admin-listen",
+			Default:  "",
+			Usage:    "Address of a separate listener for the status, stats, health, debug and metrics endpoints, e.g. 127.0.0.1:55789 (default: served with the events)",
+			Value:    &plugin.AdminListen,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_ADMIN_TOKENS",
+			Argument: "admin-tokens",
+			Default:  "",
+			Usage:    "Comma separated variables holding the bearer tokens required by the --admin-listen endpoints but /readyz",
+			Value:    &plugin.AdminTokens,
+		},
+		{
+			// No path: annotations must not widen the allowlist.
+			Env:      "OTEL_SENSU_EGRESS_ALLOW",
+			Argument: "egress-allow",
+			Default:  "",
+			Usage:    "Comma separated host globs, addresses or CIDR blocks, each with an optional :port, the handler may connect to, e.g. '*.lightstep.com:443,10.0.0.0/8' (default: any)",
+			Value:    &plugin.EgressAllow,
+		},
+		{
+			// No path: annotations must not choose which file is written.
+			Env:      "OTEL_SENSU_EXPORTER_FILE",
+			Argument: "exporter-file",
+			Default:  "",
+			Usage:    "File the file exporter appends OTLP JSON lines to",
+			Value:    &plugin.ExporterFile,
+		},
+		{
+			Path:     "inmemory-max-batches",
+			Env:      "OTEL_SENSU_INMEMORY_MAX_BATCHES",
+			Argument: "inmemory-max-batches",
+			Default:  int64(1000),
+			Usage:    "Number of most recent batches the inmemory exporter keeps for /inmemory",
+			Value:    &plugin.InMemoryMaxBatches,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_AUTH",
+			Argument: "auth",
+			Default:  "static",
+			Usage:    "Credentials of OTLP exports: none, static (--access-token), token-file, oauth2, sigv4 (otlphttp only), gcp, gcp-id-token, vault, aws-secrets or kubernetes-secret",
+			Value:    &plugin.Auth,
+		},
+		{
+			Path:     "auth-header",
+			Env:      "OTEL_SENSU_AUTH_HEADER",
+			Argument: "auth-header",
+			Default:  "lightstep-access-token",
+			Usage:    "Header carrying the token of the static and token-file auth providers",
+			Value:    &plugin.AuthHeader,
+		},
+		{
+			// No path: annotations must not choose which file is read and sent.
+			Env:      "OTEL_SENSU_AUTH_TOKEN_FILE",
+			Argument: "auth-token-file",
+			Default:  "",
+			Usage:    "File holding the token of the token-file auth provider, re-read when it changes",
+			Value:    &plugin.AuthTokenFile,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_ACCESS_TOKEN",
+			Argument: "access-token",
+			Default:  "",
+			Usage:    "Token of the static auth provider (default: LS_ACCESS_TOKEN); set it from the environment or a Sensu secret rather than the command line",
+			Value:    &plugin.AccessToken,
+		},
+		{
+			// No path: annotations must not send the client credentials elsewhere.
+			Env:      "OTEL_SENSU_OAUTH2_TOKEN_URL",
+			Argument: "oauth2-token-url",
+			Default:  "",
+			Usage:    "Token endpoint of the oauth2 auth provider (client credentials grant)",
+			Value:    &plugin.OAuth2TokenURL,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_OAUTH2_CLIENT_ID",
+			Argument: "oauth2-client-id",
+			Default:  "",
+			Usage:    "Client ID of the oauth2 auth provider",
+			Value:    &plugin.OAuth2ClientID,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_OAUTH2_CLIENT_SECRET",
+			Argument: "oauth2-client-secret",
+			Default:  "",
+			Usage:    "Client secret of the oauth2 auth provider",
+			Value:    &plugin.OAuth2Secret,
+		},
+		{
+			Path:     "oauth2-scopes",
+			Env:      "OTEL_SENSU_OAUTH2_SCOPES",
+			Argument: "oauth2-scopes",
+			Default:  "",
+			Usage:    "Comma separated scopes requested by the oauth2 auth provider",
+			Value:    &plugin.OAuth2Scopes,
+		},
+		{
+			Path:     "oauth2-audience",
+			Env:      "OTEL_SENSU_OAUTH2_AUDIENCE",
+			Argument: "oauth2-audience",
+			Default:  "",
+			Usage:    "Audience requested by the oauth2 auth provider, for identity providers that require one",
+			Value:    &plugin.OAuth2Audience,
+		},
+		{
+			Path:     "oauth2-auth-style",
+			Env:      "OTEL_SENSU_OAUTH2_AUTH_STYLE",
+			Argument: "oauth2-auth-style",
+			Default:  "params",
+			Usage:    "How the oauth2 auth provider sends the client credentials: params (form parameters) or basic (HTTP basic authentication)",
+			Value:    &plugin.OAuth2AuthStyle,
+		},
+		{
+			Path:     "sigv4-region",
+			Env:      "OTEL_SENSU_SIGV4_REGION",
+			Argument: "sigv4-region",
+			Default:  "",
+			Usage:    "AWS region the sigv4 auth provider signs for (default: AWS_REGION)",
+			Value:    &plugin.SigV4Region,
+		},
+		{
+			Path:     "sigv4-service",
+			Env:      "OTEL_SENSU_SIGV4_SERVICE",
+			Argument: "sigv4-service",
+			Default:  "",
+			Usage:    "AWS service the sigv4 auth provider signs for, e.g. xray, logs or aps",
+			Value:    &plugin.SigV4Service,
+		},
+		{
+			Path:     "gcp-audience",
+			Env:      "OTEL_SENSU_GCP_AUDIENCE",
+			Argument: "gcp-audience",
+			Default:  "",
+			Usage:    "Audience of the ID tokens of the gcp-id-token auth provider: the Cloud Run service URL or the IAP OAuth client ID",
+			Value:    &plugin.GCPAudience,
+		},
+		{
+			// No path: annotations must not send the Vault token elsewhere.
+			Env:      "OTEL_SENSU_VAULT_ADDR",
+			Argument: "vault-addr",
+			Default:  "",
+			Usage:    "Address of the Vault server of the vault auth provider (default: VAULT_ADDR)",
+			Value:    &plugin.VaultAddr,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_VAULT_PATH",
+			Argument: "vault-path",
+			Default:  "",
+			Usage:    "Path of the Vault secret holding the export token, e.g. secret/data/otel",
+			Value:    &plugin.VaultPath,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_VAULT_FIELD",
+			Argument: "vault-field",
+			Default:  "token",
+			Usage:    "Field of the Vault secret holding the export token",
+			Value:    &plugin.VaultField,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_VAULT_ROLE",
+			Argument: "vault-role",
+			Default:  "",
+			Usage:    "Vault role to log in as with the Kubernetes service account (without VAULT_TOKEN or VAULT_ROLE_ID)",
+			Value:    &plugin.VaultRole,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_VAULT_AUTH_MOUNT",
+			Argument: "vault-auth-mount",
+			Default:  "",
+			Usage:    "Mount path of the Vault auth method (default: approle or kubernetes)",
+			Value:    &plugin.VaultAuthMount,
+		},
+		{
+			Path:     "vault-refresh",
+			Env:      "OTEL_SENSU_VAULT_REFRESH",
+			Argument: "vault-refresh",
+			Default:  "5m",
+			Usage:    "How often the Vault secret is read again, sooner if its lease ends",
+			Value:    &plugin.VaultRefresh,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_AWS_SECRETS_HEADERS",
+			Argument: "aws-secrets-headers",
+			Default:  "",
+			Usage:    "Comma separated header=source pairs of the aws-secrets auth provider, sources being secretsmanager:<id>[#<json key>] or ssm:<parameter>",
+			Value:    &plugin.AWSSecretsHeaders,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_AWS_SECRETS_REGION",
+			Argument: "aws-secrets-region",
+			Default:  "",
+			Usage:    "AWS region of the secrets and parameters (default: AWS_REGION)",
+			Value:    &plugin.AWSSecretsRegion,
+		},
+		{
+			Path:     "aws-secrets-refresh",
+			Env:      "OTEL_SENSU_AWS_SECRETS_REFRESH",
+			Argument: "aws-secrets-refresh",
+			Default:  "5m",
+			Usage:    "How often the secrets and parameters are resolved again",
+			Value:    &plugin.AWSSecretsRefresh,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_KUBERNETES_SECRET",
+			Argument: "kubernetes-secret",
+			Default:  "",
+			Usage:    "[namespace/]name of the Secret the kubernetes-secret auth provider watches for the token (default namespace: the pod's)",
+			Value:    &plugin.KubernetesSecret,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_KUBERNETES_SECRET_KEY",
+			Argument: "kubernetes-secret-key",
+			Default:  "token",
+			Usage:    "Key of the Secret holding the token",
+			Value:    &plugin.KubernetesSecretKey,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_KAFKA_BROKERS",
+			Argument: "kafka-brokers",
+			Default:  "",
+			Usage:    "Comma separated host:port addresses of the Kafka brokers of the kafka exporter",
+			Value:    &plugin.KafkaBrokers,
+		},
+		{
+			Path:     "kafka-topic",
+			Env:      "OTEL_SENSU_KAFKA_TOPIC",
+			Argument: "kafka-topic",
+			Default:  "otlp_metrics",
+			Usage:    "Kafka topic the kafka exporter publishes OTLP metric requests to",
+			Value:    &plugin.KafkaTopic,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_KAFKA_TLS",
+			Argument: "kafka-tls",
+			Default:  false,
+			Usage:    "Connect to the Kafka brokers with TLS",
+			Value:    &plugin.KafkaTLS,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_KAFKA_SASL_MECHANISM",
+			Argument: "kafka-sasl-mechanism",
+			Default:  "",
+			Usage:    "SASL mechanism for the Kafka brokers: plain, scram-sha-256 or scram-sha-512",
+			Value:    &plugin.KafkaSASL,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_KAFKA_USERNAME",
+			Argument: "kafka-username",
+			Default:  "",
+			Usage:    "SASL username for the Kafka brokers",
+			Value:    &plugin.KafkaUsername,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_KAFKA_PASSWORD",
+			Argument: "kafka-password",
+			Default:  "",
+			Usage:    "SASL password for the Kafka brokers",
+			Value:    &plugin.KafkaPassword,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_NATS_URL",
+			Argument: "nats-url",
+			Default:  "nats://localhost:4222",
+			Usage:    "Comma separated server URLs of the nats exporter",
+			Value:    &plugin.NATSURL,
+		},
+		{
+			Path:     "nats-subject",
+			Env:      "OTEL_SENSU_NATS_SUBJECT",
+			Argument: "nats-subject",
+			Default:  "otlp",
+			Usage:    "Subject prefix of the nats exporter, publishing to <prefix>.metrics, .logs and .traces",
+			Value:    &plugin.NATSSubject,
+		},
+		{
+			Path:     "nats-jetstream",
+			Env:      "OTEL_SENSU_NATS_JETSTREAM",
+			Argument: "nats-jetstream",
+			Default:  true,
+			Usage:    "Publish to NATS with JetStream and wait for the stream to persist each message",
+			Value:    &plugin.NATSJetStream,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_NATS_CREDENTIALS",
+			Argument: "nats-credentials",
+			Default:  "",
+			Usage:    "NATS user credentials file (JWT and NKey seed)",
+			Value:    &plugin.NATSCredentials,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_PUSHGATEWAY_URL",
+			Argument: "pushgateway-url",
+			Default:  "",
+			Usage:    "Base URL of the Prometheus Pushgateway of the pushgateway exporter",
+			Value:    &plugin.PushgatewayURL,
+		},
+		{
+			Path:     "pushgateway-job",
+			Env:      "OTEL_SENSU_PUSHGATEWAY_JOB",
+			Argument: "pushgateway-job",
+			Default:  "sensu",
+			Usage:    "Job label of the metrics pushed to the Pushgateway",
+			Value:    &plugin.PushgatewayJob,
+		},
+		{
+			Path:     "pushgateway-instance",
+			Env:      "OTEL_SENSU_PUSHGATEWAY_INSTANCE",
+			Argument: "pushgateway-instance",
+			Default:  "",
+			Usage:    "Instance label of the metrics pushed to the Pushgateway (default: the entity name)",
+			Value:    &plugin.PushgatewayInstance,
+		},
+		{
+			Path:     "scrape-endpoint",
+			Env:      "OTEL_SENSU_SCRAPE_ENDPOINT",
+			Argument: "scrape-endpoint",
+			Default:  false,
+			Usage:    "Also serve the converted metrics on /metrics for Prometheus to scrape (always on with --exporter prometheus)",
+			Value:    &plugin.ScrapeEndpoint,
+		},
+		{
+			Path:     "scrape-staleness",
+			Env:      "OTEL_SENSU_SCRAPE_STALENESS",
+			Argument: "scrape-staleness",
+			Default:  "5m",
+			Usage:    "Drop series from /metrics that were not updated for this long",
+			Value:    &plugin.ScrapeStaleness,
+		},
+		{
+			Path:     "stale-series",
+			Env:      "OTEL_SENSU_STALE_SERIES",
+			Argument: "stale-series",
+			Default:  false,
+			Usage:    "End the series not received for three check intervals (an hour without interval) in server mode: they are no longer served on /metrics",
+			Value:    &plugin.StaleSeries,
+		},
+		{
+			Path:     "stale-markers",
+			Env:      "OTEL_SENSU_STALE_MARKERS",
+			Argument: "stale-markers",
+			Default:  false,
+			Usage:    "Export a final point of every stale series, with its last value and the sensu.stale=true attribute",
+			Value:    &plugin.StaleMarkers,
+		},
+		{
+			Path:     "deregistration-metric",
+			Env:      "OTEL_SENSU_DEREGISTRATION_METRIC",
+			Argument: "deregistration-metric",
+			Default:  false,
+			Usage:    "Export a sensu.entity.deregistered point with the deregistration events of entities",
+			Value:    &plugin.DeregistrationMetric,
+		},
+		{
+			Path:     "dedup-window",
+			Env:      "OTEL_SENSU_DEDUP_WINDOW",
+			Argument: "dedup-window",
+			Default:  "",
+			Usage:    "Window within which events with the ID of an exported event are skipped as retransmissions, e.g. 10m (disabled when empty)",
+			Value:    &plugin.DedupWindow,
+		},
+		{
+			Path:     "clock-skew-window",
+			Env:      "OTEL_SENSU_CLOCK_SKEW_WINDOW",
+			Argument: "clock-skew-window",
+			Default:  "",
+			Usage:    "How far in the past or future of the handler clock metric timestamps may be, e.g. 15m; points outside are clamped or rejected and counted",
+			Value:    &plugin.ClockSkewWindow,
+		},
+		{
+			Path:     "clock-skew-action",
+			Env:      "OTEL_SENSU_CLOCK_SKEW_ACTION",
+			Argument: "clock-skew-action",
+			Default:  skewClamp,
+			Usage:    "What to do with the points outside of --clock-skew-window: clamp their timestamp to the window or reject them",
+			Value:    &plugin.ClockSkewAction,
+		},
+		{
+			Path:     "timestamp-alignment",
+			Env:      "OTEL_SENSU_TIMESTAMP_ALIGNMENT",
+			Argument: "timestamp-alignment",
+			Default:  "",
+			Usage:    "Round metric timestamps down to a multiple of this duration, e.g. 10s, for fewer distinct timestamps and aligned series across entities",
+			Value:    &plugin.TimestampAlignment,
+		},
+		{
+			Path:     "metric-scale",
+			Env:      "OTEL_SENSU_METRIC_SCALE",
+			Argument: "metric-scale",
+			Default:  "",
+			Usage:    "Comma separated glob=factor [unit] rules scaling metric values and setting their unit, e.g. '*.latency_ms=/1000 s, disk.*_kb=1024 By'",
+			Value:    &plugin.MetricScale,
+		},
+		{
+			Path:     "metric-kinds",
+			Env:      "OTEL_SENSU_METRIC_KINDS",
+			Argument: "metric-kinds",
+			Default:  "",
+			Usage:    "Comma separated glob=kind[:temporality] rules exporting metrics as gauge, counter or updowncounter, cumulative or delta, e.g. '*_total=counter, *.errors=counter:delta'",
+			Value:    &plugin.MetricKinds,
+		},
+		{
+			Path:     "derived-metrics",
+			Env:      "OTEL_SENSU_DERIVED_METRICS",
+			Argument: "derived-metrics",
+			Default:  "",
+			Usage:    "Semicolon separated name = expression metrics computed from the points of an event with the same tags, e.g. 'mem.used_percent = mem.used / mem.total * 100'",
+			Value:    &plugin.DerivedMetrics,
+		},
+		{
+			Path:     "top-k",
+			Env:      "OTEL_SENSU_TOP_K",
+			Argument: "top-k",
+			Default:  "",
+			Usage:    "Comma separated tag=K rules keeping, per metric of an event, the K values of a high-cardinality tag with the largest values and summing the rest into tag=other, e.g. process=10",
+			Value:    &plugin.TopK,
+		},
+		{
+			Path:     "split-tags",
+			Env:      "OTEL_SENSU_SPLIT_TAGS",
+			Argument: "split-tags",
+			Default:  "",
+			Usage:    "Comma separated tag[:mode] rules splitting the comma separated values of a tag into one point per value (points, the default) or into tags named tag.0, tag.1... (indexed)",
+			Value:    &plugin.SplitTags,
+		},
+		{
+			Path:     "graphite-templates",
+			Env:      "OTEL_SENSU_GRAPHITE_TEMPLATES",
+			Argument: "graphite-templates",
+			Default:  "",
+			Usage:    "Semicolon separated [filter] template rules decomposing dotted metric names into a name and tags, e.g. 'measurement.host.region.*'",
+			Value:    &plugin.GraphiteTemplates,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_CARBON_ADDRESS",
+			Argument: "carbon-address",
+			Default:  "",
+			Usage:    "host:port of the Carbon plaintext listener of the carbon exporter",
+			Value:    &plugin.CarbonAddress,
+		},
+		{
+			Path:     "carbon-template",
+			Env:      "OTEL_SENSU_CARBON_TEMPLATE",
+			Argument: "carbon-template",
+			Default:  "{name}",
+			Usage:    "Graphite path template with {name}, {entity}, {check}, {namespace} and {<tag>} placeholders",
+			Value:    &plugin.CarbonTemplate,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_INFLUXDB_URL",
+			Argument: "influxdb-url",
+			Default:  "",
+			Usage:    "Base URL of the InfluxDB v2 server of the influxdb exporter",
+			Value:    &plugin.InfluxDBURL,
+		},
+		{
+			Path:     "influxdb-org",
+			Env:      "OTEL_SENSU_INFLUXDB_ORG",
+			Argument: "influxdb-org",
+			Default:  "",
+			Usage:    "InfluxDB organization the bucket belongs to",
+			Value:    &plugin.InfluxDBOrg,
+		},
+		{
+			Path:     "influxdb-bucket",
+			Env:      "OTEL_SENSU_INFLUXDB_BUCKET",
+			Argument: "influxdb-bucket",
+			Default:  "",
+			Usage:    "InfluxDB bucket points are written to",
+			Value:    &plugin.InfluxDBBucket,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_INFLUXDB_TOKEN",
+			Argument: "influxdb-token",
+			Default:  "",
+			Usage:    "InfluxDB API token with write access to the bucket",
+			Value:    &plugin.InfluxDBToken,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_DATADOG_SITE",
+			Argument: "datadog-site",
+			Default:  "datadoghq.com",
+			Usage:    "Datadog site of the datadog exporter, e.g. datadoghq.eu",
+			Value:    &plugin.DatadogSite,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_DATADOG_API_KEY",
+			Argument: "datadog-api-key",
+			Default:  "",
+			Usage:    "Datadog API key",
+			Value:    &plugin.DatadogAPIKey,
+		},
+		{
+			Path:     "datadog-tag-map",
+			Env:      "OTEL_SENSU_DATADOG_TAG_MAP",
+			Argument: "datadog-tag-map",
+			Default:  "",
+			Usage:    "Comma separated from=to renames of tags sent to Datadog, an empty target drops the tag",
+			Value:    &plugin.DatadogTagMap,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_SPLUNK_URL",
+			Argument: "splunk-url",
+			Default:  "",
+			Usage:    "Base URL of the Splunk HTTP Event Collector of the splunk exporter",
+			Value:    &plugin.SplunkURL,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_SPLUNK_TOKEN",
+			Argument: "splunk-token",
+			Default:  "",
+			Usage:    "Splunk HTTP Event Collector token",
+			Value:    &plugin.SplunkToken,
+		},
+		{
+			Path:     "splunk-index",
+			Env:      "OTEL_SENSU_SPLUNK_INDEX",
+			Argument: "splunk-index",
+			Default:  "",
+			Usage:    "Splunk index of the sent events (default: the index of the token)",
+			Value:    &plugin.SplunkIndex,
+		},
+		{
+			Path:     "splunk-events",
+			Env:      "OTEL_SENSU_SPLUNK_EVENTS",
+			Argument: "splunk-events",
+			Default:  false,
+			Usage:    "Also send the Sensu event JSON as a sensu:event event along with its metrics",
+			Value:    &plugin.SplunkEvents,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_LOKI_URL",
+			Argument: "loki-url",
+			Default:  "",
+			Usage:    "Base URL of the Loki server the loki exporter pushes check output to",
+			Value:    &plugin.LokiURL,
+		},
+		{
+			Path:     "loki-tenant",
+			Env:      "OTEL_SENSU_LOKI_TENANT",
+			Argument: "loki-tenant",
+			Default:  "",
+			Usage:    "Loki tenant sent as X-Scope-OrgID",
+			Value:    &plugin.LokiTenant,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_ELASTICSEARCH_URL",
+			Argument: "elasticsearch-url",
+			Default:  "",
+			Usage:    "Base URL of the Elasticsearch or OpenSearch cluster the elasticsearch exporter indexes events into",
+			Value:    &plugin.ElasticsearchURL,
+		},
+		{
+			Path:     "elasticsearch-index",
+			Env:      "OTEL_SENSU_ELASTICSEARCH_INDEX",
+			Argument: "elasticsearch-index",
+			Default:  "sensu-events-{date}",
+			Usage:    "Index of the event documents, {date} is replaced by the UTC date of the event",
+			Value:    &plugin.ElasticsearchIndex,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_ELASTICSEARCH_USERNAME",
+			Argument: "elasticsearch-username",
+			Default:  "",
+			Usage:    "Username for basic authentication to Elasticsearch",
+			Value:    &plugin.ElasticsearchUser,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_ELASTICSEARCH_PASSWORD",
+			Argument: "elasticsearch-password",
+			Default:  "",
+			Usage:    "Password for basic authentication to Elasticsearch",
+			Value:    &plugin.ElasticsearchPass,
+		},
+		{
+			// No path: annotations must not choose the credentials.
+			Env:      "OTEL_SENSU_ELASTICSEARCH_API_KEY",
+			Argument: "elasticsearch-api-key",
+			Default:  "",
+			Usage:    "Elasticsearch API key (base64 id:key), instead of basic authentication",
+			Value:    &plugin.ElasticsearchAPIKey,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_SYSLOG_ADDRESS",
+			Argument: "syslog-address",
+			Default:  "",
+			Usage:    "host:port of the TCP syslog receiver the syslog exporter forwards non-OK events to",
+			Value:    &plugin.SyslogAddress,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_SYSLOG_TLS",
+			Argument: "syslog-tls",
+			Default:  false,
+			Usage:    "Connect to the syslog receiver with TLS",
+			Value:    &plugin.SyslogTLS,
+		},
+		{
+			Path:     "syslog-facility",
+			Env:      "OTEL_SENSU_SYSLOG_FACILITY",
+			Argument: "syslog-facility",
+			Default:  "local0",
+			Usage:    "Syslog facility of the forwarded events",
+			Value:    &plugin.SyslogFacility,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_CLOUDWATCH_REGION",
+			Argument: "cloudwatch-region",
+			Default:  "",
+			Usage:    "AWS region of the cloudwatch exporter (default: AWS_REGION)",
+			Value:    &plugin.CloudWatchRegion,
+		},
+		{
+			Path:     "cloudwatch-namespace",
+			Env:      "OTEL_SENSU_CLOUDWATCH_NAMESPACE",
+			Argument: "cloudwatch-namespace",
+			Default:  "Sensu",
+			Usage:    "CloudWatch namespace of the metrics",
+			Value:    &plugin.CloudWatchNamespace,
+		},
+		{
+			Path:     "cloudwatch-dimensions",
+			Env:      "OTEL_SENSU_CLOUDWATCH_DIMENSIONS",
+			Argument: "cloudwatch-dimensions",
+			Default:  "entity,check",
+			Usage:    "Comma separated CloudWatch dimensions: entity, check, namespace or metric tag names",
+			Value:    &plugin.CloudWatchDimensions,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_GCP_PROJECT",
+			Argument: "gcp-project",
+			Default:  "",
+			Usage:    "Google Cloud project of the cloudmonitoring exporter (default: GOOGLE_CLOUD_PROJECT, the credentials or the metadata server)",
+			Value:    &plugin.GCPProject,
+		},
+		{
+			Path:     "gcp-resource",
+			Env:      "OTEL_SENSU_GCP_RESOURCE",
+			Argument: "gcp-resource",
+			Default:  "auto",
+			Usage:    "Monitored resource of the entities: generic_node, gce_instance or auto (gce_instance for entities with gce_instance_id and gce_zone labels)",
+			Value:    &plugin.GCPResource,
+		},
+		{
+			Path:     "gcp-location",
+			Env:      "OTEL_SENSU_GCP_LOCATION",
+			Argument: "gcp-location",
+			Default:  "global",
+			Usage:    "Location label of generic_node resources",
+			Value:    &plugin.GCPLocation,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_AZURE_RESOURCE_ID",
+			Argument: "azure-resource-id",
+			Default:  "",
+			Usage:    "Azure resource the azuremonitor exporter adds custom metrics to, authenticated with the managed identity",
+			Value:    &plugin.AzureResourceID,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_AZURE_REGION",
+			Argument: "azure-region",
+			Default:  "",
+			Usage:    "Azure region of the resource, e.g. eastus",
+			Value:    &plugin.AzureRegion,
+		},
+		{
+			Path:     "azure-namespace",
+			Env:      "OTEL_SENSU_AZURE_NAMESPACE",
+			Argument: "azure-namespace",
+			Default:  "Sensu",
+			Usage:    "Metric namespace of the Azure custom metrics",
+			Value:    &plugin.AzureNamespace,
+		},
+		{
+			Path:     "azure-client-id",
+			Env:      "OTEL_SENSU_AZURE_CLIENT_ID",
+			Argument: "azure-client-id",
+			Default:  "",
+			Usage:    "Client ID of a user assigned managed identity (default: the system assigned identity)",
+			Value:    &plugin.AzureClientID,
+		},
+		{
+			// No path: annotations must not redirect the exports.
+			Env:      "OTEL_SENSU_AZURE_CONNECTION_STRING",
+			Argument: "azure-connection-string",
+			Default:  "",
+			Usage:    "Application Insights connection string; the azuremonitor exporter sends to Application Insights instead of a resource",
+			Value:    &plugin.AzureConnectionString,
+		},
+		{
+			// No path: annotations must not redirect the notifications.
+			Env:      "OTEL_SENSU_WEBHOOK_URL",
+			Argument: "webhook-url",
+			Default:  "",
+			Usage:    "URL the webhook exporter posts a JSON document to for every event",
+			Value:    &plugin.WebhookURL,
+		},
+		{
+			// No path: annotations must not choose which file is read.
+			Env:      "OTEL_SENSU_WEBHOOK_TEMPLATE_FILE",
+			Argument: "webhook-template-file",
+			Default:  "",
+			Usage:    "Go template of the webhook body, rendered from .Handler, .Event and .Points (default: all three as JSON)",
+			Value:    &plugin.WebhookTemplateFile,
+		},
+	}
 )
 
 func getenv(key, fallback string) string {
@@ -53,140 +1524,644 @@ func getenv(key, fallback string) string {
 }
 
 type otelPlugin struct {
-	*resource.Resource
-	*otlpmetric.Exporter
-}
-
-type exportEvent struct {
-	*types.Event
-}
-
-type exportValue struct {
-	value     float64
-	timestamp time.Time
-}
-
-type exportLibraryEvent struct {
-	sync.RWMutex
-	*types.Event
+	*resourcepb.Resource
+	exporter   Exporter
+	recorder   *recorder
+	audit      *auditLog
+	status     *handlerStatus
+	slo        *latencySLO
+	stats      *checkStats
+	last       *lastEvents
+	signals    map[string]bool
+	scrape     *scrapeStore
+	pipeline   pipelineHandler
+	runtime    *runtimeState
+	severities *converter.SeverityMapping
+	entities   *checkEntities
+	series     *seriesTracker
+	skew       *clockSkew
+	alignment  time.Duration
+	scales     []scaleRule
+	derived    []derivedMetric
+	kinds      []kindRule
+	topK       []topKRule
+	splitTags  []splitTagRule
+	templates  []graphiteTemplate
+	profiles   *profileConfig
+	leader     *leaderElection
+	features   map[string]bool
+	// resourceLabels are the globs of --resource-labels.
+	resourceLabels []string
+	ingestDeadline time.Duration
+	quotas         *ingestQuotas
+	dedup          *eventDedup
+	canary         *canaryCheck
+	queue          *ingestQueue
 }
 
 func main() {
-	ctx := context.Background()
-	otelExporter, err := otlpmetric.New(
-		ctx,
-		otlpmetricgrpc.NewClient(
-			otlpmetricgrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")),
-			otlpmetricgrpc.WithEndpoint(getenv("OTEL_EXPORTER_OTLP_METRIC_ENDPOINT", "ingest.lightstep.com:443")),
-			otlpmetricgrpc.WithHeaders(map[string]string{
-				"lightstep-access-token": os.Getenv("LS_ACCESS_TOKEN"),
-			}),
-		),
-	)
-
-	if err != nil {
-		log.Fatalf("failed to initialize otelgrpc pipeline: %v", err)
-	}
-
-	ot := &otelPlugin{
-		Resource: resource.Empty(),
-		Exporter: otelExporter,
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			ot := newOtelPlugin()
+			if err := parseOptions(nil); err != nil {
+				log.Fatalf("invalid environment: %v", err)
+			}
+			if err := applyConfigBundle(); err != nil {
+				log.Fatalf("invalid configuration: %v", err)
+			}
+			if err := ot.setup(); err != nil {
+				log.Fatalf("failed to set up handler: %v", err)
+			}
+			err := ot.replay(os.Args[2:])
+			ot.shutdown()
+			if err != nil {
+				log.Fatalf("replay failed: %v", err)
+			}
+			return
+		case "record":
+			if err := parseOptions(nil); err != nil {
+				log.Fatalf("invalid environment: %v", err)
+			}
+			if err := runRecord(os.Args[2:]); err != nil {
+				log.Fatalf("record failed: %v", err)
+			}
+			return
+		case "convert":
+			if err := parseOptions(nil); err != nil {
+				log.Fatalf("invalid environment: %v", err)
+			}
+			if err := runConvert(os.Args[2:]); err != nil {
+				log.Fatalf("convert failed: %v", err)
+			}
+			return
+		case "schema":
+			if err := runSchema(os.Args[2:]); err != nil {
+				log.Fatalf("schema failed: %v", err)
+			}
+			return
+		case "mock-collector":
+			if err := runMockCollector(os.Args[2:]); err != nil {
+				log.Fatalf("mock collector failed: %v", err)
+			}
+			return
+		}
 	}
 
+	ot := newOtelPlugin()
 	if os.Getenv("ENABLE_SENSU_HANDLER") == "1" {
 		log.Printf("starting sensu handler...")
 		handler := sensu.NewGoHandler(&plugin.PluginConfig, options, checkArgs, ot.executeHandler)
 		handler.Execute()
 	} else {
-		log.Printf("starting http server on port %v...", port)
-		http.HandleFunc("/", ot.postEvent)
-		err = http.ListenAndServe(port, nil)
+		if err := parseOptions(os.Args[1:]); err != nil {
+			log.Fatalf("invalid arguments: %v", err)
+		}
+		if err := applyConfigBundle(); err != nil {
+			log.Fatalf("invalid configuration: %v", err)
+		}
+		if err := validateConfig(); err != nil {
+			for _, e := range err.(configErrors) {
+				log.Printf("configuration problem: %v", e)
+			}
+			log.Fatalf("invalid configuration, exiting")
+		}
+		if err := ot.setup(); err != nil {
+			log.Fatalf("failed to set up handler: %v", err)
+		}
+		if plugin.Preflight {
+			runPreflight()
+		}
+		if plugin.LeaderElection != "" {
+			lease, _ := time.ParseDuration(plugin.LeaderElectionLease)
+			leader, err := newLeaderElection(plugin.LeaderElection, lease)
+			if err != nil {
+				log.Fatalf("failed to set up leader election: %v", err)
+			}
+			ot.leader = leader
+			go leader.run()
+		}
+		if plugin.SelfTelemetry != "" {
+			interval, _ := time.ParseDuration(plugin.SelfTelemetry)
+			go ot.runSelfTelemetry(interval)
+		}
+		if interval, _ := time.ParseDuration(plugin.HeartbeatInterval); interval > 0 {
+			go ot.runHeartbeat(interval)
+		}
+		if interval, _ := time.ParseDuration(plugin.CanaryInterval); interval > 0 {
+			timeout, _ := time.ParseDuration(plugin.CanaryTimeout)
+			ot.canary = newCanaryCheck(plugin.CanaryQueryURL, plugin.CanaryQuery, os.Getenv(plugin.CanaryQueryToken), timeout)
+			go ot.runCanary(interval)
+		}
+		if interval, _ := time.ParseDuration(plugin.LogSummary); interval > 0 {
+			go errorLog.runSummary(interval)
+		}
+		if plugin.StaleSeries {
+			ot.series = newSeriesTracker()
+			go ot.runStaleSeries()
+		}
+		if plugin.SocketListen != "" {
+			if err := ot.serveSocket(plugin.SocketListen); err != nil {
+				log.Fatalf("failed to set up socket listener: %v", err)
+			}
+		}
+		if plugin.StatsdListen != "" {
+			interval, _ := time.ParseDuration(plugin.StatsdFlushInterval)
+			if err := ot.serveStatsd(plugin.StatsdListen, interval); err != nil {
+				log.Fatalf("failed to set up statsd listener: %v", err)
+			}
+		}
+		if plugin.ScrapeProxyTargets != "" {
+			targets, _ := parseScrapeTargets(plugin.ScrapeProxyTargets)
+			interval, _ := time.ParseDuration(plugin.ScrapeProxyInterval)
+			go ot.runScrapeProxy(targets, interval)
+		}
+		if plugin.IngestQueue > 0 {
+			ot.queue = newIngestQueue(int(plugin.IngestQueue), int(plugin.IngestWorkers), int(plugin.IngestBatch), ot.exportQueued)
+		}
+		guard, err := newIngestGuard()
 		if err != nil {
-			log.Fatalf("could not listed on port: %v", err.Error())
+			log.Fatalf("failed to set up http server: %v", err)
+		}
+		log.Printf("starting http server on %v...", plugin.Listen)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", recoverHTTP(guard.wrap(ot.postEvent)))
+		mux.HandleFunc("/openapi.json", recoverHTTP(serveOpenAPI))
+		if plugin.AdminListen != "" {
+			if err := ot.serveAdmin(); err != nil {
+				log.Fatalf("failed to set up admin server: %v", err)
+			}
+		} else {
+			ot.handleAdmin(mux, guard.wrap)
 		}
+		tlsConfig, err := ingestTLSConfig()
+		if err != nil {
+			log.Fatalf("failed to set up http server: %v", err)
+		}
+		lis, err := listen(plugin.Listen)
+		if err != nil {
+			log.Fatalf("could not listen on %s: %v", plugin.Listen, err.Error())
+		}
+		if tlsConfig != nil {
+			lis = tls.NewListener(lis, tlsConfig)
+		}
+		if err := sdNotify("READY=1"); err != nil {
+			log.Printf("could not notify systemd: %v", err)
+		}
+		go runWatchdog()
+		srv := &http.Server{Handler: mux}
+		go ot.stopOnSignal(srv)
+		if err := srv.Serve(lis); err != http.ErrServerClosed {
+			log.Fatalf("could not serve http: %v", err)
+		}
+		// stopOnSignal exits once the queued events are exported.
+		select {}
 	}
 }
 
-func checkArgs(_ *types.Event) error {
-	if len(os.Getenv("LS_ACCESS_TOKEN")) == 0 {
-		return fmt.Errorf("LS_ACCESS_TOKEN is not set")
+func newOtelPlugin() *otelPlugin {
+	return &otelPlugin{
+		Resource: &resourcepb.Resource{},
+		status:   newHandlerStatus(),
+		stats:    newCheckStats(),
+		last:     newLastEvents(),
+		entities: newCheckEntities(),
+	}
+}
+
+// setup prepares the optional parts of the pipeline once the plugin
+// options have been parsed.
+func (ot *otelPlugin) setup() error {
+	errorLog.SetEvery(plugin.LogSampleRate)
+	configureTLS()
+	configureEgress()
+	if timeout, _ := time.ParseDuration(plugin.ExportTimeout); timeout > 0 {
+		defaultExportPolicy.timeout = timeout
+	}
+	started := ot.profiles != nil
+	if err := ot.setupConversion(); err != nil {
+		return err
+	}
+	ot.ingestDeadline, _ = time.ParseDuration(plugin.IngestDeadline)
+	if window, _ := time.ParseDuration(plugin.DedupWindow); window > 0 && ot.dedup == nil {
+		ot.dedup = newEventDedup(window)
+	}
+	var err error
+	if ot.quotas, err = parseIngestQuotas(plugin.IngestQuotas); err != nil {
+		return err
+	}
+	if ot.pipeline == nil {
+		stages, err := parsePipeline(plugin.Pipeline)
+		if err != nil {
+			return err
+		}
+		ot.pipeline = ot.buildPipeline(stages)
+	}
+	if ot.profiles != nil && !started {
+		if err := ot.profiles.start(); err != nil {
+			return err
+		}
+	}
+	if ot.exporter == nil {
+		names := splitList(plugin.Exporter)
+		if ot.runtime != nil && len(ot.runtime.config.Sinks) > 0 {
+			names = ot.runtime.config.Sinks
+		}
+		exporter, err := newConfiguredExporter(names)
+		if err != nil {
+			return err
+		}
+		if ot.runtime != nil {
+			exporter = &runtimeExporter{exporter: exporter}
+		}
+		ot.exporter = exporter
+	}
+	if (plugin.ScrapeEndpoint || exporterSelected("prometheus")) && ot.scrape == nil {
+		staleness, _ := time.ParseDuration(plugin.ScrapeStaleness)
+		ot.scrape = newScrapeStore(staleness)
+	}
+	if plugin.RecordDir != "" && ot.recorder == nil {
+		maxAge, _ := time.ParseDuration(plugin.RecordMaxAge)
+		r, err := newRecorder(plugin.RecordDir, plugin.RecordMaxSize*1024*1024, recordRetention{
+			maxFiles: int(plugin.RecordMaxFiles),
+			maxAge:   maxAge,
+			maxTotal: plugin.RecordMaxTotalSize * 1024 * 1024,
+		})
+		if err != nil {
+			return err
+		}
+		ot.recorder = r
+	}
+	if plugin.AuditLog != "" && ot.audit == nil {
+		a, err := newAuditLog(plugin.AuditLog)
+		if err != nil {
+			return err
+		}
+		ot.audit = a
+	}
+	if ot.slo == nil {
+		threshold, _ := time.ParseDuration(plugin.SLOLatency)
+		objective, _ := parseObjective(plugin.SLOObjective)
+		window, _ := time.ParseDuration(plugin.SLOWindow)
+		ot.slo = newLatencySLO(threshold, objective, window)
 	}
 	return nil
 }
 
-func (ot *otelPlugin) eventToOtel(event *types.Event) error {
-	return ot.Exporter.Export(
-		context.Background(),
-		ot.Resource,
-		&exportEvent{Event: event},
-	)
+// setupConversion parses the options deciding what events are converted
+// to, shared by the handler and the convert command.
+func (ot *otelPlugin) setupConversion() error {
+	signals, err := parseSignals(plugin.Signals)
+	if err != nil {
+		return err
+	}
+	ot.signals = signals
+	ot.resourceLabels = splitList(plugin.ResourceLabels)
+	if ot.features, err = parseFeatureGates(plugin.FeatureGates); err != nil {
+		return err
+	}
+	if ot.skew, err = parseClockSkew(plugin.ClockSkewWindow, plugin.ClockSkewAction); err != nil {
+		return err
+	}
+	ot.alignment, _ = time.ParseDuration(plugin.TimestampAlignment)
+	if ot.scales, err = parseScaleRules(plugin.MetricScale); err != nil {
+		return err
+	}
+	if ot.kinds, err = parseKindRules(plugin.MetricKinds); err != nil {
+		return err
+	}
+	if ot.derived, err = parseDerivedMetrics(plugin.DerivedMetrics); err != nil {
+		return err
+	}
+	if ot.topK, err = parseTopK(plugin.TopK); err != nil {
+		return err
+	}
+	if ot.splitTags, err = parseSplitTags(plugin.SplitTags); err != nil {
+		return err
+	}
+	if ot.templates, err = parseGraphiteTemplates(plugin.GraphiteTemplates); err != nil {
+		return err
+	}
+	if ot.severities, err = parseSeverityMapping(plugin.LogSeverityMap, plugin.LogSeverityOverrides); err != nil {
+		return err
+	}
+	if plugin.RuntimeConfig != "" && ot.runtime == nil {
+		r, err := loadRuntimeConfig(plugin.RuntimeConfig)
+		if err != nil {
+			return err
+		}
+		ot.runtime = r
+	}
+	if plugin.Profiles != "" && ot.profiles == nil {
+		p, err := loadProfiles(plugin.Profiles)
+		if err != nil {
+			return err
+		}
+		ot.profiles = p
+	}
+	return nil
+}
+
+// auditDropped records an event dropped on purpose in the audit log.
+func (ot *otelPlugin) auditDropped(pe *pipelineEvent) {
+	if ot.audit == nil {
+		return
+	}
+	if err := ot.audit.Dropped(pe.event, ot.destination(), pe.dropReason); err != nil {
+		errorLog.Printf("could not write audit log: %v", err)
+	}
 }
 
-func (ex *exportEvent) ForEach(readerFunc func(instrumentation.Library, sdkexport.Reader) error) error {
-	return readerFunc(instrumentation.Library{
-		Name: "sensu-otel",
-	}, &exportLibraryEvent{Event: ex.Event})
+// newConfiguredExporter creates the exporters named, routed with --routes.
+func newConfiguredExporter(names []string) (Exporter, error) {
+	if plugin.Routes != "" {
+		return newRoutingExporter(names, plugin.Routes)
+	}
+	return newExporters(names)
 }
 
-func (ex *exportValue) Kind() aggregation.Kind {
-	return aggregation.LastValueKind
+// destination describes where the events are exported to.
+func (ot *otelPlugin) destination() string {
+	return destination(plugin.Exporter, ot.exporter)
 }
 
-func (ex *exportValue) LastValue() (number.Number, time.Time, error) {
-	return number.NewFloat64Number(ex.value), ex.timestamp, nil
+func checkArgs(event *types.Event) error {
+	warnSecretArguments()
+	if err := applyConfigBundle(); err != nil {
+		return handlerExit(event, exitConfigError, err)
+	}
+	if err := validateConfig(); err != nil {
+		return handlerExit(event, exitConfigError, err)
+	}
+	return nil
 }
 
-func (ex *exportLibraryEvent) ForEach(_ aggregation.TemporalitySelector, recordFunc func(sdkexport.Record) error) error {
-	for _, m := range ex.Event.Metrics.Points {
-		var attrs []attribute.KeyValue
-		for _, t := range m.Tags {
-			attrs = append(attrs, attribute.String(t.Name, t.Value))
+// receiveEvent handles an event accepted by the server or the handler,
+// tracing it when debug is set or the event has the debug annotation.
+func (ot *otelPlugin) receiveEvent(ctx context.Context, event *types.Event, debug bool) (*pipelineEvent, error) {
+	if ot.recorder != nil {
+		if err := ot.recorder.Record(event); err != nil {
+			errorLog.Printf("could not record event: %v", err)
 		}
-		descriptor := sdkapi.NewDescriptor(m.Name, sdkapi.GaugeObserverInstrumentKind, number.Float64Kind, "", "")
+	}
+	received := time.Now()
+	if ot.dedup != nil && ot.dedup.Seen(event, received) {
+		ot.stats.Dropped(event, converter.EventPoints(event), true)
+		pe := &pipelineEvent{event: event, dropped: true, dropReason: "duplicate"}
+		ot.auditDropped(pe)
+		return pe, nil
+	}
+	pe := &pipelineEvent{event: event, debug: debug || eventDebug(event)}
+	deregistration := isDeregistration(event)
+	if deregistration {
+		ot.deregisterEntity(event)
+	} else {
+		pe.last = ot.last.Add(event)
+		ot.entities.Observe(event, received)
+	}
+	atomic.AddInt64(&ot.status.inFlight, 1)
+	err := ot.runPipeline(ctx, pe)
+	atomic.AddInt64(&ot.status.inFlight, -1)
+	if pe.debug {
+		namespace, entity, check := converter.EventNames(event)
+		log.Printf("debug %s/%s/%s: dropped=%v, error=%v", namespace, entity, check, pe.dropped, err)
+	}
+	if pe.dropped {
+		ot.auditDropped(pe)
+		return pe, nil
+	}
+	if ot.series != nil && err == nil && !deregistration {
+		ot.series.Observe(event, received)
+	}
+	if ot.dedup != nil && err == nil {
+		ot.dedup.Add(event, received)
+	}
+	ot.status.Observe(event, err)
+	ot.stats.Exported(event, err)
+	if err != nil {
+		errorLog.Printf("could not export event: %v", err)
+	}
+	if ot.slo != nil {
+		now := time.Now()
+		ot.slo.Observe(now.Sub(received), err == nil, now)
+	}
+	if ot.audit != nil {
+		if auditErr := ot.audit.Log(event, ot.destination(), err); auditErr != nil {
+			errorLog.Printf("could not write audit log: %v", auditErr)
+		}
+	}
+	return pe, err
+}
 
-		attrSet := attribute.NewSet(attrs...)
+// signal reports whether a telemetry signal is generated from event: one
+// of its signals annotation or, without one, of --signals. Only metrics are
+// generated until the options are set up.
+func (ot *otelPlugin) signal(event *types.Event, name string) bool {
+	if signals := eventSignals(event); signals != nil {
+		return signals[name]
+	}
+	if ot.signals == nil {
+		return name == signalMetrics
+	}
+	return ot.signals[name]
+}
 
-		gauge := exportValue{
-			value:     m.Value,
-			timestamp: time.Unix(0, m.Timestamp), // Timestamp is in nanoseconds
-		}
+// hasTelemetry reports whether any enabled signal is generated from event.
+func (ot *otelPlugin) hasTelemetry(event *types.Event) bool {
+	return ot.signal(event, signalEvents) ||
+		(ot.signal(event, signalMetrics) && converter.EventPoints(event) > 0) ||
+		((ot.signal(event, signalLogs) || ot.signal(event, signalTraces) || ot.signal(event, signalChecks)) && event.Check != nil)
+}
 
-		log.Printf("recording metric: %v=%v\n", m.Name, m.Value)
+// eventToOtel runs an event through the pipeline.
+func (ot *otelPlugin) eventToOtel(event *types.Event) error {
+	return ot.runPipeline(context.Background(), &pipelineEvent{event: event})
+}
 
-		if err := recordFunc(
-			sdkexport.NewRecord(
-				&descriptor,
-				&attrSet,
-				&gauge,
-				gauge.timestamp.Add(-time.Microsecond),
-				gauge.timestamp,
-			)); err != nil {
-			return err
+func (ot *otelPlugin) runPipeline(ctx context.Context, pe *pipelineEvent) (err error) {
+	defer recoverError("processing event", &err)
+	if pe.debug {
+		pe.trace(ot, "input")
+	}
+	return ot.pipeline(ctx, pe)
+}
+
+// convertBatch converts an event into the enabled signals.
+func (ot *otelPlugin) convertBatch(event *types.Event) *Batch {
+	batch := &Batch{Events: []*types.Event{event}}
+	if ot.signal(event, signalMetrics) {
+		batch.Metrics = converter.Metrics(event)
+		setUnits(batch.Metrics, ot.scales)
+		setKinds(batch.Metrics, ot.kinds, checkInterval(event))
+		if ot.feature("counterSums") {
+			counterSums(batch.Metrics)
 		}
 	}
-	return nil
+	// The handler's own events aren't check results.
+	if ot.signal(event, signalChecks) && event.Check != nil && event.Check.Name != selfCheckName {
+		batch.Metrics = append(batch.Metrics, converter.CheckMetrics(event)...)
+	}
+	if ot.signal(event, signalLogs) && event.Check != nil {
+		batch.Logs = converter.LogsWithSeverity(event, ot.severities)
+	}
+	if ot.signal(event, signalTraces) && event.Check != nil {
+		batch.Spans = converter.Spans(event)
+	}
+	return batch
+}
+
+// resource returns the resource of the telemetry of an event: the
+// attributes of its entity and check, after those of ot.Resource.
+func (ot *otelPlugin) resource(event *types.Event) *resourcepb.Resource {
+	res := converter.Resource(event, ot.resourceLabels)
+	if ot.Resource != nil {
+		res.Attributes = append(append([]*commonpb.KeyValue(nil), ot.Resource.Attributes...), res.Attributes...)
+	}
+	return res
 }
 
-// curl --data '@test-event.json' http://localhost:55788
+// curl -H 'Content-Type: application/json' --data '@test-event.json' http://localhost:55788
+//
+// With --ingest-queue, events are queued and exported in the background.
+// With ?debug=true, the response is the trace of the event through the
+// pipeline stages. Events are validated against the OpenAPI definition of
+// openapi.go.
 func (ot *otelPlugin) postEvent(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read event: %v", err), http.StatusBadRequest)
+		return
+	}
+	if code, message, problems := validateEventRequest(req.Header.Get("Content-Type"), body); code != http.StatusOK {
+		writeRequestError(w, code, message, problems)
+		return
+	}
 	var e types.Event
-	err := json.NewDecoder(req.Body).Decode(&e)
+	if err := json.Unmarshal(body, &e); err != nil {
+		writeRequestError(w, http.StatusBadRequest, fmt.Sprintf("event parse error: %v", err), nil)
+		return
+	}
+	if namespace, _, _ := converter.EventNames(&e); !namespaceAllowed(req.Context(), namespace) {
+		errorLog.Printf("rejected event from %s: namespace %q not allowed for its token", remoteHost(req), namespace)
+		http.Error(w, fmt.Sprintf("namespace %q not allowed", namespace), http.StatusForbidden)
+		return
+	}
+	points := converter.EventPoints(&e)
+	if t, ok := ingestTokenOf(req.Context()); ok && ot.quotas != nil {
+		now := time.Now()
+		if exceeded, ok := ot.quotas.take(t.env, int64(points), now); !ok {
+			errorLog.Printf("rejected event from %s: %s quota of %s exceeded", remoteHost(req), exceeded.Kind, t.env)
+			setQuotaHeaders(w.Header(), exceeded, now)
+			http.Error(w, fmt.Sprintf("quota of %d %s per %s exceeded", exceeded.Limit, exceeded.Kind, exceeded.Window), http.StatusTooManyRequests)
+			return
+		}
+	}
+	debug := req.URL.Query().Get("debug") == "true"
+	if ot.queue != nil && !debug {
+		if !ot.queue.add(&e) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "ingest queue is full", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if plugin.IngestResponse != responseMinimal {
+			fmt.Fprintf(w, "queued: %d points received\n", points)
+		}
+		return
+	}
+	ctx := req.Context()
+	if ot.ingestDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ot.ingestDeadline)
+		defer cancel()
+	}
+	pe, err := ot.receiveEvent(ctx, &e, debug)
+	code := http.StatusOK
 	if err != nil {
-		http.Error(w, fmt.Sprintf("event parse error: %v", err.Error()), http.StatusBadRequest)
+		// Clients such as pkg/client post the event again on 503.
+		code = http.StatusBadRequest
+		if exportExitCode(err) == exitRetryable {
+			code = http.StatusServiceUnavailable
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			code = http.StatusGatewayTimeout
+		}
+	}
+	if debug {
+		result := struct {
+			Stages  []stageTrace `json:"stages"`
+			Dropped bool         `json:"dropped"`
+			Error   string       `json:"error,omitempty"`
+		}{Stages: pe.traces, Dropped: pe.dropped}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(result)
+		return
+	}
+	if code == http.StatusGatewayTimeout {
+		http.Error(w, fmt.Sprintf("ingest deadline of %s exceeded in the %s stage: %d points received, %d left after filtering, converted: %v",
+			ot.ingestDeadline, pe.stage, points, converter.EventPoints(&e), pe.batch != nil), code)
 		return
 	}
-	err = ot.eventToOtel(&e)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("could not convert event to otel: %v", err.Error()), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("could not convert event to otel: %v", err.Error()), code)
+		return
 	}
-	fmt.Fprintf(w, "ok: %v\n", e.Metrics)
+	writeIngestResponse(w, plugin.IngestResponse, points, pe)
 }
 
 // based on: https://github.com/portertech/sensu-prometheus-pushgateway-handler/blob/main/main.go
+// Failures terminate the process with the exit codes defined in exitcode.go.
 func (ot *otelPlugin) executeHandler(event *types.Event) error {
-	err := ot.eventToOtel(event)
-	if err != nil {
-		return err
+	if err := ot.setup(); err != nil {
+		return handlerExit(event, exitConfigError, err)
+	}
+	_, err := ot.receiveEvent(context.Background(), event, false)
+	ot.shutdown()
+	return handlerExit(event, exportExitCode(err), err)
+}
+
+// shutdownTimeout bounds the shutdown of the server, the export of the
+// queued events included.
+const shutdownTimeout = 30 * time.Second
+
+// stopOnSignal shuts the server down on SIGTERM or an interrupt: posts
+// are no longer accepted, the events of the ingest queue are exported and
+// the exporters shut down before the process exits.
+func (ot *otelPlugin) stopOnSignal(srv *http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	log.Printf("received %v, shutting down...", <-signals)
+	if err := sdNotify("STOPPING=1"); err != nil {
+		log.Printf("could not notify systemd: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		errorLog.Printf("could not shut the http server down: %v", err)
+	}
+	if ot.queue != nil {
+		if left := ot.queue.close(ctx); left > 0 {
+			errorLog.Printf("%d queued events not exported at shutdown", left)
+		}
+	}
+	ot.shutdown()
+	os.Exit(0)
+}
+
+// shutdown shuts the exporters down, sending what they still queue.
+func (ot *otelPlugin) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := ot.exporter.Shutdown(ctx); err != nil {
+		errorLog.Printf("could not shut down %s: %v", ot.destination(), err)
+	}
+	if ot.profiles != nil {
+		ot.profiles.shutdown(ctx)
 	}
-	return nil
 }
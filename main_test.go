@@ -1,35 +1,57 @@
-
 package main
 
 import (
 	"encoding/json"
-	corev2 "github.com/sensu/sensu-go/api/core/v2"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
 	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
 )
 
+// TestMain runs the tests of the package. main serves events until the
+// process is stopped, so it is not run here.
 func TestMain(m *testing.M) {
-	//assert := assert.New(t)
-	file, _ := ioutil.TempFile(os.TempDir(), "otel-sensu-handler-plugin-")
-	defer func() {
-		_ = os.Remove(file.Name())
-	}()
+	os.Exit(m.Run())
+}
+
+// TestHandlerMain runs an event through the handler, exporting it to a
+// file instead of a backend.
+func TestHandlerMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otel-sensu-handler-plugin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := plugin
+	defer func() { plugin = saved }()
+	if err := parseOptions(nil); err != nil {
+		t.Fatal(err)
+	}
+	plugin.Exporter = "file"
+	plugin.ExporterFile = filepath.Join(dir, "export.jsonl")
 
 	event := corev2.FixtureEvent("entity1", "check1")
 	event.Check = nil
 	event.Metrics = corev2.FixtureMetrics()
-	eventJSON, _ := json.Marshal(event)
-	log.Printf("%s", string(eventJSON))
-	_, _ = file.WriteString(string(eventJSON))
-	_, _ = file.Seek(0, 0)
-	os.Stdin = file
-	//requestReceived := false
+	event.Metrics.Points = []*corev2.MetricPoint{{Name: "cpu.usage", Value: 42, Timestamp: 1600000000}}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", eventJSON)
 
-	oldArgs := os.Args
-	os.Setenv("LS_ACCESS_TOKEN", "foo")
-	os.Args = []string{"otel-sensu-handler-plugin"}
-	defer func() { os.Args = oldArgs }()
-	main()
-}
\ No newline at end of file
+	// handlerExit exits the process when the export fails.
+	if err := newOtelPlugin().executeHandler(event); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ioutil.ReadFile(plugin.ExporterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) == 0 {
+		t.Error("nothing exported")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// Instrument kinds of --metric-kinds. Sensu points carry no buckets, so
+// there is no histogram kind.
+const (
+	kindGauge         = "gauge"
+	kindCounter       = "counter"
+	kindUpDownCounter = "updowncounter"
+)
+
+// kindRule exports the metrics whose name matches the glob pattern with
+// the instrument kind and temporality of the rule.
+type kindRule struct {
+	pattern string
+	kind    string
+	delta   bool
+}
+
+// parseKindRules parses the comma separated rules of --metric-kinds, each
+// a glob, an instrument kind, gauge, counter or updowncounter, and for
+// sums an optional temporality, cumulative or delta, such as
+//
+//	*_total=counter, *.errors=counter:delta, queue.depth=updowncounter
+//
+// The first rule matching a metric name applies.
+func parseKindRules(value string) ([]kindRule, error) {
+	var rules []kindRule
+	for _, item := range splitList(value) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rule %q, expected glob=kind[:temporality]", item)
+		}
+		r := kindRule{pattern: strings.TrimSpace(kv[0])}
+		if _, err := path.Match(r.pattern, ""); err != nil || r.pattern == "" {
+			return nil, fmt.Errorf("invalid rule %q: invalid pattern %q", item, r.pattern)
+		}
+		spec := strings.SplitN(strings.TrimSpace(kv[1]), ":", 2)
+		switch r.kind = spec[0]; r.kind {
+		case kindGauge, kindCounter, kindUpDownCounter:
+		default:
+			return nil, fmt.Errorf("invalid rule %q: unknown kind %q, expected gauge, counter or updowncounter", item, r.kind)
+		}
+		if len(spec) == 2 {
+			switch {
+			case r.kind == kindGauge:
+				return nil, fmt.Errorf("invalid rule %q: gauges have no temporality", item)
+			case spec[1] == "delta":
+				r.delta = true
+			case spec[1] != "cumulative":
+				return nil, fmt.Errorf("invalid rule %q: unknown temporality %q, expected cumulative or delta", item, spec[1])
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// setKinds exports the gauges of metrics matching a rule with its kind.
+// The start of cumulative sums is unknown and left unset, that of delta
+// sums is interval before each point, when the check interval is known.
+func setKinds(metrics []*metricpb.Metric, rules []kindRule, interval time.Duration) {
+	for _, m := range metrics {
+		gauge, ok := m.Data.(*metricpb.Metric_Gauge)
+		if !ok {
+			continue
+		}
+		var r *kindRule
+		for i := range rules {
+			if ok, _ := path.Match(rules[i].pattern, m.Name); ok {
+				r = &rules[i]
+				break
+			}
+		}
+		if r == nil || r.kind == kindGauge {
+			continue
+		}
+		temporality := metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+		if r.delta {
+			temporality = metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		}
+		for _, p := range gauge.Gauge.DataPoints {
+			p.StartTimeUnixNano = 0
+			if r.delta && interval > 0 && p.TimeUnixNano > uint64(interval) {
+				p.StartTimeUnixNano = p.TimeUnixNano - uint64(interval)
+			}
+		}
+		m.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             gauge.Gauge.DataPoints,
+			AggregationTemporality: temporality,
+			IsMonotonic:            r.kind == kindCounter,
+		}}
+	}
+}
+
+// checkInterval returns the interval of the check of event, 0 if unknown.
+func checkInterval(event *types.Event) time.Duration {
+	if event.Check == nil {
+		return 0
+	}
+	return time.Duration(event.Check.Interval) * time.Second
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestKindRules(t *testing.T) {
+	rules, err := parseKindRules("*_total=counter, *.errors=counter:delta, queue.*=updowncounter, *=gauge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gauge := func(name string) *metricpb.Metric {
+		return &metricpb.Metric{Name: name, Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{
+			DataPoints: []*metricpb.NumberDataPoint{{StartTimeUnixNano: 1, TimeUnixNano: uint64(2 * time.Minute)}},
+		}}}
+	}
+	metrics := []*metricpb.Metric{gauge("http_total"), gauge("http.errors"), gauge("queue.depth"), gauge("cpu.idle")}
+	setKinds(metrics, rules, time.Minute)
+
+	for i, want := range []struct {
+		temporality metricpb.AggregationTemporality
+		monotonic   bool
+		start       uint64
+	}{
+		{metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, 0},
+		{metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, true, uint64(time.Minute)},
+		{metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, false, 0},
+	} {
+		sum := metrics[i].GetSum()
+		if sum == nil {
+			t.Errorf("%s: not a sum", metrics[i].Name)
+			continue
+		}
+		if sum.AggregationTemporality != want.temporality || sum.IsMonotonic != want.monotonic || sum.DataPoints[0].StartTimeUnixNano != want.start {
+			t.Errorf("%s: got %v, monotonic %v, start %d", metrics[i].Name, sum.AggregationTemporality, sum.IsMonotonic, sum.DataPoints[0].StartTimeUnixNano)
+		}
+	}
+	if metrics[3].GetGauge() == nil {
+		t.Errorf("cpu.idle: not a gauge")
+	}
+
+	for _, bad := range []string{"a", "[=counter", "a=histogram", "a=gauge:delta", "a=counter:monthly"} {
+		if _, err := parseKindRules(bad); err == nil {
+			t.Errorf("parseKindRules(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// mockCollector is a minimal OTLP receiver that prints the metrics, logs
+// and spans it receives, for trying out the handler without a real
+// collector or backend.
+type mockCollector struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	dump bool
+}
+
+// mockLogsService and mockTraceService receive the logs and spans of the
+// mock collector over gRPC, their Export methods clashing with the one of
+// the metrics service.
+type mockLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	mc *mockCollector
+}
+
+type mockTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	mc *mockCollector
+}
+
+// runMockCollector serves OTLP over gRPC and HTTP until one of the servers
+// fails.
+//
+//	$ ./otel-sensu-handler-plugin mock-collector --grpc-addr :4317 --http-addr :4318
+func runMockCollector(args []string) error {
+	flags := flag.NewFlagSet("mock-collector", flag.ExitOnError)
+	grpcAddr := flags.String("grpc-addr", "localhost:4317", "address of the OTLP/gRPC receiver, empty to disable")
+	httpAddr := flags.String("http-addr", "localhost:4318", "address of the OTLP/HTTP receiver, empty to disable")
+	dump := flags.Bool("json", false, "print complete requests as OTLP JSON instead of one line per point")
+	_ = flags.Parse(args)
+
+	mc := &mockCollector{dump: *dump}
+	errs := make(chan error, 2)
+
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		srv := grpc.NewServer()
+		colmetricpb.RegisterMetricsServiceServer(srv, mc)
+		collogspb.RegisterLogsServiceServer(srv, &mockLogsService{mc: mc})
+		coltracepb.RegisterTraceServiceServer(srv, &mockTraceService{mc: mc})
+		log.Printf("mock collector accepting OTLP/gRPC on %v...", lis.Addr())
+		go func() { errs <- srv.Serve(lis) }()
+	}
+	if *httpAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/metrics", mc.post(
+			func() proto.Message { return &colmetricpb.ExportMetricsServiceRequest{} },
+			&colmetricpb.ExportMetricsServiceResponse{},
+			func(r proto.Message) { mc.print(r.(*colmetricpb.ExportMetricsServiceRequest)) }))
+		mux.HandleFunc("/v1/logs", mc.post(
+			func() proto.Message { return &collogspb.ExportLogsServiceRequest{} },
+			&collogspb.ExportLogsServiceResponse{},
+			func(r proto.Message) { mc.printLogs(r.(*collogspb.ExportLogsServiceRequest)) }))
+		mux.HandleFunc("/v1/traces", mc.post(
+			func() proto.Message { return &coltracepb.ExportTraceServiceRequest{} },
+			&coltracepb.ExportTraceServiceResponse{},
+			func(r proto.Message) { mc.printSpans(r.(*coltracepb.ExportTraceServiceRequest)) }))
+		log.Printf("mock collector accepting OTLP/HTTP on %v...", *httpAddr)
+		go func() { errs <- http.ListenAndServe(*httpAddr, mux) }()
+	}
+	if *grpcAddr == "" && *httpAddr == "" {
+		return fmt.Errorf("at least one of --grpc-addr and --http-addr is required")
+	}
+	return <-errs
+}
+
+func (mc *mockCollector) Export(_ context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	mc.print(req)
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (s *mockLogsService) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.mc.printLogs(req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (s *mockTraceService) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.mc.printSpans(req)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// post returns the OTLP/HTTP handler of a signal, parsing the requests
+// created by newRequest, as protobuf or JSON, printing them and answering
+// with response.
+func (mc *mockCollector) post(newRequest func() proto.Message, response proto.Message, print func(proto.Message)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		asJSON := strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+		r := newRequest()
+		if asJSON {
+			err = protojson.Unmarshal(body, r)
+		} else {
+			err = proto.Unmarshal(body, r)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("request parse error: %v", err), http.StatusBadRequest)
+			return
+		}
+		print(r)
+
+		var resp []byte
+		if asJSON {
+			w.Header().Set("Content-Type", "application/json")
+			resp, err = protojson.Marshal(response)
+		} else {
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			resp, err = proto.Marshal(response)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(resp)
+	}
+}
+
+func (mc *mockCollector) print(req *colmetricpb.ExportMetricsServiceRequest) {
+	if mc.dump {
+		log.Printf("%s", protojson.Format(req))
+		return
+	}
+	for _, rm := range req.ResourceMetrics {
+		res := formatAttributes(rm.GetResource().GetAttributes())
+		for _, ilm := range rm.InstrumentationLibraryMetrics {
+			for _, m := range ilm.Metrics {
+				for _, line := range formatMetric(m) {
+					log.Printf("%s %s", res, line)
+				}
+			}
+		}
+	}
+}
+
+// printLogs prints a line per log record, "severity body {attrs} @ time".
+func (mc *mockCollector) printLogs(req *collogspb.ExportLogsServiceRequest) {
+	if mc.dump {
+		log.Printf("%s", protojson.Format(req))
+		return
+	}
+	for _, rl := range req.ResourceLogs {
+		res := formatAttributes(rl.GetResource().GetAttributes())
+		for _, ill := range rl.InstrumentationLibraryLogs {
+			for _, l := range ill.Logs {
+				severity := l.SeverityText
+				if severity == "" {
+					severity = fmt.Sprint(l.SeverityNumber)
+				}
+				log.Printf("%s log %s %q%s @ %s", res, severity, formatAnyValue(l.Body), formatAttributes(l.Attributes),
+					time.Unix(0, int64(l.TimeUnixNano)).UTC().Format(time.RFC3339Nano))
+			}
+		}
+	}
+}
+
+// printSpans prints a line per span, "name{attrs} span trace/span duration @ start".
+func (mc *mockCollector) printSpans(req *coltracepb.ExportTraceServiceRequest) {
+	if mc.dump {
+		log.Printf("%s", protojson.Format(req))
+		return
+	}
+	for _, rs := range req.ResourceSpans {
+		res := formatAttributes(rs.GetResource().GetAttributes())
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, sp := range ils.Spans {
+				log.Printf("%s %s%s span %x/%x %v @ %s", res, sp.Name, formatAttributes(sp.Attributes), sp.TraceId, sp.SpanId,
+					time.Duration(sp.EndTimeUnixNano-sp.StartTimeUnixNano),
+					time.Unix(0, int64(sp.StartTimeUnixNano)).UTC().Format(time.RFC3339Nano))
+			}
+		}
+	}
+}
+
+// formatMetric renders every point of a metric as "name{attrs} kind value @ time".
+func formatMetric(m *metricpb.Metric) []string {
+	var lines []string
+	point := func(kind string, attrs []*commonpb.KeyValue, ts uint64, value string) {
+		lines = append(lines, fmt.Sprintf("%s%s %s %s @ %s",
+			m.Name, formatAttributes(attrs), kind, value,
+			time.Unix(0, int64(ts)).UTC().Format(time.RFC3339Nano)))
+	}
+	switch data := m.Data.(type) {
+	case *metricpb.Metric_Gauge:
+		for _, p := range data.Gauge.DataPoints {
+			point("gauge", p.Attributes, p.TimeUnixNano, formatNumber(p))
+		}
+	case *metricpb.Metric_Sum:
+		for _, p := range data.Sum.DataPoints {
+			point("sum", p.Attributes, p.TimeUnixNano, formatNumber(p))
+		}
+	case *metricpb.Metric_Histogram:
+		for _, p := range data.Histogram.DataPoints {
+			point("histogram", p.Attributes, p.TimeUnixNano,
+				fmt.Sprintf("count=%d sum=%g bounds=%v counts=%v", p.Count, p.Sum, p.ExplicitBounds, p.BucketCounts))
+		}
+	case *metricpb.Metric_Summary:
+		for _, p := range data.Summary.DataPoints {
+			point("summary", p.Attributes, p.TimeUnixNano, fmt.Sprintf("count=%d sum=%g", p.Count, p.Sum))
+		}
+	default:
+		lines = append(lines, fmt.Sprintf("%s unsupported data %T", m.Name, m.Data))
+	}
+	return lines
+}
+
+func formatNumber(p *metricpb.NumberDataPoint) string {
+	switch v := p.Value.(type) {
+	case *metricpb.NumberDataPoint_AsDouble:
+		return fmt.Sprint(v.AsDouble)
+	case *metricpb.NumberDataPoint_AsInt:
+		return fmt.Sprint(v.AsInt)
+	}
+	return "<none>"
+}
+
+// formatAttributes renders attributes as {k1=v1,k2=v2}, sorted by key.
+func formatAttributes(attrs []*commonpb.KeyValue) string {
+	pairs := make([]string, 0, len(attrs))
+	for _, kv := range attrs {
+		pairs = append(pairs, kv.Key+"="+formatAnyValue(kv.Value))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatAnyValue(v *commonpb.AnyValue) string {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return fmt.Sprint(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return fmt.Sprint(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return fmt.Sprint(v.DoubleValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]string, 0, len(v.ArrayValue.GetValues()))
+		for _, e := range v.ArrayValue.GetValues() {
+			values = append(values, formatAnyValue(e))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	case *commonpb.AnyValue_KvlistValue:
+		return formatAttributes(v.KvlistValue.GetValues())
+	}
+	return ""
+}
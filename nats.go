@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterExporter("nats", newNATSExporter)
+}
+
+// natsExporter publishes OTLP export requests, serialized as protobuf, to
+// the subjects <prefix>.metrics, <prefix>.logs and <prefix>.traces. With
+// JetStream every publish waits for the stream to acknowledge that the
+// message was persisted.
+type natsExporter struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+func newNATSExporter() (Exporter, error) {
+	opts := []nats.Option{nats.Name(plugin.Name), nats.SetCustomDialer(egressDialer{})}
+	if plugin.NATSCredentials != "" {
+		opts = append(opts, nats.UserCredentials(plugin.NATSCredentials))
+	}
+	if strings.HasPrefix(plugin.NATSURL, "tls://") {
+		opts = append(opts, nats.Secure(tlsConfig()))
+	}
+	conn, err := nats.Connect(plugin.NATSURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	e := &natsExporter{conn: conn, prefix: plugin.NATSSubject}
+	if plugin.NATSJetStream {
+		if e.js, err = conn.JetStream(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *natsExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	ctx, cancel := context.WithTimeout(ctx, exportPolicyFrom(ctx).timeout)
+	defer cancel()
+	if len(batch.Metrics) > 0 {
+		if err := e.publish(ctx, "metrics", converter.MetricsRequest(res, batch.Metrics)); err != nil {
+			return err
+		}
+	}
+	if len(batch.Logs) > 0 {
+		if err := e.publish(ctx, "logs", converter.LogsRequest(res, batch.Logs)); err != nil {
+			return err
+		}
+	}
+	if len(batch.Spans) > 0 {
+		if err := e.publish(ctx, "traces", converter.TraceRequest(res, batch.Spans)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *natsExporter) publish(ctx context.Context, signal string, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	subject := e.prefix + "." + signal
+	if e.js != nil {
+		if _, err := e.js.Publish(subject, data, nats.Context(ctx)); err != nil {
+			return fmt.Errorf("publishing to %s: %w", subject, err)
+		}
+		return nil
+	}
+	if err := e.conn.Publish(subject, data); err != nil {
+		return fmt.Errorf("publishing to %s: %w", subject, err)
+	}
+	return e.conn.FlushWithContext(ctx)
+}
+
+// Shutdown flushes pending messages and closes the connection.
+func (e *natsExporter) Shutdown(context.Context) error {
+	return e.conn.Drain()
+}
+
+func (e *natsExporter) String() string {
+	return "nats " + e.prefix + ".*"
+}
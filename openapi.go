@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openAPISpec describes the ingest API, served on /openapi.json for client
+// generation. The schema of its request body is the one posted events are
+// validated against.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "otel-sensu-handler-plugin ingest API",
+    "description": "Receives Sensu events and exports them as OpenTelemetry signals.",
+    "version": "1"
+  },
+  "paths": {
+    "/": {
+      "post": {
+        "summary": "Export a Sensu event",
+        "parameters": [
+          {"name": "debug", "in": "query", "required": false, "schema": {"type": "boolean"}, "description": "Return the trace of the event through the pipeline stages"}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Event"}}}
+        },
+        "responses": {
+          "200": {"description": "Exported, with the number of points received and exported, or every exported point with --ingest-response verbose"},
+          "202": {"description": "Queued for export, with --ingest-queue"},
+          "204": {"description": "Exported, with --ingest-response minimal"},
+          "400": {"description": "Invalid event", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "401": {"description": "Missing or unknown bearer token"},
+          "403": {"description": "Client or namespace not allowed"},
+          "415": {"description": "Unsupported content type", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "429": {"description": "Quota of the token exceeded"},
+          "503": {"description": "Export failed or --ingest-queue full, to be retried"},
+          "504": {"description": "Ingest deadline exceeded"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This definition",
+        "responses": {"200": {"description": "The OpenAPI definition of the ingest API"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Event": {
+        "type": "object",
+        "required": ["entity"],
+        "properties": {
+          "timestamp": {"type": "integer"},
+          "id": {"type": "string"},
+          "metadata": {"$ref": "#/components/schemas/ObjectMeta"},
+          "entity": {
+            "type": "object",
+            "required": ["metadata"],
+            "properties": {
+              "metadata": {"$ref": "#/components/schemas/NamedObjectMeta"},
+              "entity_class": {"type": "string"},
+              "subscriptions": {"type": "array", "items": {"type": "string"}}
+            }
+          },
+          "check": {
+            "type": "object",
+            "required": ["metadata"],
+            "properties": {
+              "metadata": {"$ref": "#/components/schemas/NamedObjectMeta"},
+              "status": {"type": "integer"},
+              "output": {"type": "string"},
+              "interval": {"type": "integer"},
+              "executed": {"type": "integer"},
+              "output_metric_format": {"type": "string"}
+            }
+          },
+          "metrics": {
+            "type": "object",
+            "properties": {
+              "handlers": {"type": "array", "items": {"type": "string"}},
+              "points": {"type": "array", "items": {"$ref": "#/components/schemas/MetricPoint"}}
+            }
+          }
+        }
+      },
+      "ObjectMeta": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "namespace": {"type": "string"},
+          "labels": {"type": "object"},
+          "annotations": {"type": "object"}
+        }
+      },
+      "NamedObjectMeta": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "name": {"type": "string"},
+          "namespace": {"type": "string"},
+          "labels": {"type": "object"},
+          "annotations": {"type": "object"}
+        }
+      },
+      "MetricPoint": {
+        "type": "object",
+        "required": ["name", "value"],
+        "properties": {
+          "name": {"type": "string"},
+          "value": {"type": "number"},
+          "timestamp": {"type": "integer"},
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["name", "value"],
+              "properties": {"name": {"type": "string"}, "value": {"type": "string"}}
+            }
+          }
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {"type": "string"},
+          "problems": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {"path": {"type": "string"}, "message": {"type": "string"}}
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// jsonSchema is the subset of the OpenAPI schema objects the ingest API
+// uses: types, required and nested properties, items and references.
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+}
+
+// eventSchema is the schema of the posted events, with schemas holding the
+// components its references point to.
+var eventSchema, schemas = func() (*jsonSchema, map[string]*jsonSchema) {
+	var spec struct {
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema *jsonSchema `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]*jsonSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		panic(fmt.Sprintf("invalid OpenAPI definition: %v", err))
+	}
+	return spec.Paths["/"]["post"].RequestBody.Content["application/json"].Schema, spec.Components.Schemas
+}()
+
+// schemaProblem is a part of a request not matching the schema.
+type schemaProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// validateSchema appends to problems the parts of value, a decoded JSON
+// document at path, that don't match s.
+func validateSchema(s *jsonSchema, value interface{}, path string, problems []schemaProblem) []schemaProblem {
+	if s.Ref != "" {
+		s = schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	problem := func(format string, args ...interface{}) []schemaProblem {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		return append(problems, schemaProblem{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return problem("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, schemaProblem{Path: path + "/" + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// Properties not in the schema, such as the many fields of
+			// Sensu entities, are allowed.
+			if property, ok := s.Properties[name]; ok && object[name] != nil {
+				problems = validateSchema(property, object[name], path+"/"+name, problems)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return problem("must be an array")
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				problems = validateSchema(s.Items, item, fmt.Sprintf("%s/%d", path, i), problems)
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return problem("must be a string")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return problem("must be a number")
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return problem("must be an integer")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return problem("must be a boolean")
+		}
+	}
+	return problems
+}
+
+// validateEventRequest checks the content type and body of a posted event
+// against the OpenAPI definition, returning the status and problems of an
+// invalid request. Requests without a content type are taken as JSON.
+func validateEventRequest(contentType string, body []byte) (int, string, []schemaProblem) {
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q, expected application/json", contentType), nil
+		}
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return http.StatusBadRequest, fmt.Sprintf("event parse error: %v", err), nil
+	}
+	if problems := validateSchema(eventSchema, value, "", nil); len(problems) > 0 {
+		return http.StatusBadRequest, "invalid event", problems
+	}
+	return http.StatusOK, "", nil
+}
+
+// writeRequestError answers an invalid request with a JSON error listing
+// its problems.
+func writeRequestError(w http.ResponseWriter, code int, message string, problems []schemaProblem) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error    string          `json:"error"`
+		Problems []schemaProblem `json:"problems,omitempty"`
+	}{Error: message, Problems: problems})
+}
+
+// serveOpenAPI serves the OpenAPI definition of the ingest API.
+//
+//	$ curl localhost:55788/openapi.json
+func serveOpenAPI(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestValidateEventRequest(t *testing.T) {
+	event, err := ioutil.ReadFile("test-event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, contentType := range []string{"application/json", "application/json; charset=utf-8", ""} {
+		if code, message, problems := validateEventRequest(contentType, event); code != http.StatusOK {
+			t.Errorf("%q: got %d %s %v", contentType, code, message, problems)
+		}
+	}
+	if code, _, _ := validateEventRequest("application/x-www-form-urlencoded", event); code != http.StatusUnsupportedMediaType {
+		t.Errorf("form content type: got %d", code)
+	}
+	if code, _, _ := validateEventRequest("application/json", []byte("{")); code != http.StatusBadRequest {
+		t.Errorf("truncated event: got %d", code)
+	}
+
+	code, _, problems := validateEventRequest("application/json", []byte(`{
+		"entity": {"metadata": {}},
+		"check": {"metadata": {"name": "cpu"}, "status": 1.5},
+		"metrics": {"points": [{"name": "cpu.user", "value": "12"}, {"value": 1, "tags": [{"name": "cpu"}]}]}
+	}`))
+	want := []schemaProblem{
+		{Path: "/check/status", Message: "must be an integer"},
+		{Path: "/entity/metadata/name", Message: "is required"},
+		{Path: "/metrics/points/0/value", Message: "must be a number"},
+		{Path: "/metrics/points/1/name", Message: "is required"},
+		{Path: "/metrics/points/1/tags/0/value", Message: "is required"},
+	}
+	if code != http.StatusBadRequest || !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %d %+v, want %+v", code, problems, want)
+	}
+	if _, _, problems := validateEventRequest("", []byte(`[]`)); len(problems) != 1 || problems[0].Path != "/" {
+		t.Errorf("array body: got %+v", problems)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// parseOptions applies defaults, environment variables and command line
+// arguments to the plugin options when running outside of the Sensu
+// handler, which parses them itself.
+func parseOptions(args []string) error {
+	flags := flag.NewFlagSet(plugin.Name, flag.ContinueOnError)
+	for _, opt := range options {
+		env, hasEnv := os.LookupEnv(opt.Env)
+		switch value := opt.Value.(type) {
+		case *string:
+			def, _ := opt.Default.(string)
+			if hasEnv {
+				def = env
+			}
+			flags.StringVar(value, opt.Argument, def, opt.Usage)
+		case *bool:
+			def, _ := opt.Default.(bool)
+			if hasEnv {
+				b, err := strconv.ParseBool(env)
+				if err != nil {
+					return fmt.Errorf("%s: %v", opt.Env, err)
+				}
+				def = b
+			}
+			flags.BoolVar(value, opt.Argument, def, opt.Usage)
+		case *int64:
+			def, _ := opt.Default.(int64)
+			if hasEnv {
+				i, err := strconv.ParseInt(env, 10, 64)
+				if err != nil {
+					return fmt.Errorf("%s: %v", opt.Env, err)
+				}
+				def = i
+			}
+			flags.Int64Var(value, opt.Argument, def, opt.Usage)
+		default:
+			return fmt.Errorf("option %s has unsupported type %T", opt.Argument, opt.Value)
+		}
+	}
+	// --chaos is registered last and left out of the usage.
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", plugin.Name)
+		flags.VisitAll(func(f *flag.Flag) {
+			if f.Name != chaosFlag {
+				fmt.Fprintf(flags.Output(), "  -%s\n    \t%s\n", f.Name, f.Usage)
+			}
+		})
+	}
+	flags.StringVar(&plugin.Chaos, chaosFlag, os.Getenv(chaosEnv), "")
+	return flags.Parse(args)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterExporter("otlphttp", func() (Exporter, error) {
+		s, err := otlpSettingsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return withTenants(s, newOTLPHTTPExporter(s), func(s otlpSettings) (Exporter, error) {
+			return newOTLPHTTPExporter(s), nil
+		})
+	})
+}
+
+// otlpHTTPExporter sends OTLP export requests as protobuf over HTTP, to
+// the /v1/metrics, /v1/logs and /v1/traces paths of the endpoint.
+type otlpHTTPExporter struct {
+	client   *http.Client
+	baseURL  string
+	auth     AuthProvider
+	headers  map[string]string
+	compress bool
+}
+
+func newOTLPHTTPExporter(s otlpSettings) *otlpHTTPExporter {
+	scheme := "https"
+	if s.insecure {
+		scheme = "http"
+	}
+	// The exports are bounded by their export policy.
+	client := &http.Client{}
+	if s.tls != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = s.tls
+		client.Transport = transport
+	}
+	return &otlpHTTPExporter{
+		client:   client,
+		baseURL:  scheme + "://" + s.endpoint,
+		auth:     s.auth,
+		headers:  s.headers,
+		compress: s.compression == "gzip",
+	}
+}
+
+func (e *otlpHTTPExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.ExportResources(ctx, []ResourceBatch{{Resource: res, Batch: batch}})
+}
+
+// ExportResources posts the batches of every tenant in one request per
+// signal present in them, with an entry per resource.
+func (e *otlpHTTPExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	for _, group := range tenantGroups(batches) {
+		ctx := withTenantHeader(ctx, group[0].Batch)
+		metrics, logs, traces := otlpRequests(group)
+		if metrics != nil {
+			if err := e.post(ctx, "/v1/metrics", metrics); err != nil {
+				return err
+			}
+		}
+		if logs != nil {
+			if err := e.post(ctx, "/v1/logs", logs); err != nil {
+				return err
+			}
+		}
+		if traces != nil {
+			if err := e.post(ctx, "/v1/traces", traces); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Preflight posts an empty metrics export request, which the collector
+// authenticates and accepts without storing anything.
+func (e *otlpHTTPExporter) Preflight(ctx context.Context) error {
+	return e.post(ctx, "/v1/metrics", &colmetricpb.ExportMetricsServiceRequest{})
+}
+
+func (e *otlpHTTPExporter) post(ctx context.Context, path string, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if e.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	send := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		if e.compress {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+		headers, err := e.auth.Headers(ctx)
+		if err != nil {
+			return err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		for k, v := range tenantHeaderFrom(ctx) {
+			req.Header.Set(k, v)
+		}
+		if signer, ok := e.auth.(RequestSigner); ok {
+			if err := signer.Sign(ctx, req, body); err != nil {
+				return err
+			}
+		}
+		return doHTTP(e.client, req)
+	}
+	return retryExport(ctx, func(ctx context.Context) error {
+		err := send(ctx)
+		// Rejected credentials are replaced and the request sent once more.
+		if se, ok := err.(*httpStatusError); ok && se.StatusCode == http.StatusUnauthorized && invalidateAuth(e.auth) {
+			err = send(ctx)
+		}
+		return err
+	})
+}
+
+func (e *otlpHTTPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *otlpHTTPExporter) String() string {
+	return "otlphttp " + e.baseURL
+}
+
+// httpStatusError is returned for non-2xx responses of HTTP based sinks.
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPStatus lets exportExitCode classify the error.
+func (e *httpStatusError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// doHTTP sends req and turns non-2xx responses into an httpStatusError
+// holding the beginning of the response body.
+func doHTTP(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return &httpStatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(msg))}
+}
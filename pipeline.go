@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// pipelineEvent is what flows through the pipeline: the event and, once
+// converted, its batch.
+type pipelineEvent struct {
+	event *types.Event
+	batch *Batch
+	// dropped is set by the stages that stop an event on purpose, with the
+	// reason recorded in the audit log.
+	dropped    bool
+	dropReason string
+	// stale is set for the final points of stale series, which are
+	// exported but not served for scraping.
+	stale bool
+	// debug traces the event through the stages, into traces.
+	debug  bool
+	traces []stageTrace
+	// stage is the last stage the event entered, reported for the events
+	// past their ingest deadline.
+	stage string
+	// last is the copy of the event served by /debug/last, told what the
+	// event was exported as.
+	last *lastEvent
+}
+
+// pipelineHandler processes an event, passing it on to the rest of the
+// pipeline or not.
+type pipelineHandler func(ctx context.Context, pe *pipelineEvent) error
+
+// pipelineStage wraps the rest of the pipeline, next, into a handler doing
+// one step of the processing before calling it.
+type pipelineStage func(ot *otelPlugin, next pipelineHandler) pipelineHandler
+
+// defaultPipeline is the order of the stages of --pipeline.
+const defaultPipeline = "decode,filter,enrich,transform,convert,export"
+
+var pipelineStages = map[string]pipelineStage{
+	// decode parses the metrics of the check output and decomposes their
+	// Graphite names, then clamps or rejects the points with skewed
+	// timestamps and aligns the others.
+	"decode": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			if err := converter.ParseOutputMetrics(pe.event); err != nil {
+				errorLog.Printf("could not parse check output: %v", err)
+			}
+			if len(ot.templates) > 0 {
+				decomposeGraphiteNames(pe.event, ot.templates)
+			}
+			if ot.skew != nil {
+				if skewed, removed := ot.skew.guard(pe.event, time.Now()); skewed > 0 {
+					ot.stats.Skewed(pe.event, skewed, removed)
+				}
+			}
+			if ot.alignment > 0 {
+				alignTimestamps(pe.event, ot.alignment)
+			}
+			return next(ctx, pe)
+		}
+	},
+	// filter drops the metric points of the runtime filters and of the
+	// profile of the namespace, then the events no enabled signal is
+	// generated from.
+	"filter": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			if ot.runtime != nil {
+				if n := ot.runtime.filterPoints(pe.event); n > 0 {
+					ot.stats.Dropped(pe.event, n, false)
+				}
+			}
+			if p := ot.profiles.lookup(pe.event); p != nil {
+				if n := p.filterPoints(pe.event); n > 0 {
+					ot.stats.Dropped(pe.event, n, false)
+				}
+			}
+			if !ot.hasTelemetry(pe.event) {
+				ot.stats.Dropped(pe.event, converter.EventPoints(pe.event), true)
+				pe.dropped, pe.dropReason = true, "no enabled signal"
+				return nil
+			}
+			return next(ctx, pe)
+		}
+	},
+	// enrich adds the derived metrics.
+	"enrich": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			if len(ot.derived) > 0 {
+				deriveMetrics(pe.event, ot.derived)
+			}
+			return next(ctx, pe)
+		}
+	},
+	// transform applies the runtime rename rules, then splits the multi-value
+	// tags, aggregates the points beyond the top K and scales the values of
+	// the renamed points, and finally adds the prefix of the profile.
+	"transform": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			if ot.runtime != nil {
+				ot.runtime.rename(pe.event)
+			}
+			if len(ot.splitTags) > 0 {
+				splitTags(pe.event, ot.splitTags)
+			}
+			if len(ot.topK) > 0 {
+				aggregateTopK(pe.event, ot.topK)
+			}
+			if len(ot.scales) > 0 {
+				scalePoints(pe.event, ot.scales)
+			}
+			if p := ot.profiles.lookup(pe.event); p != nil {
+				p.addPrefix(pe.event)
+			}
+			return next(ctx, pe)
+		}
+	},
+	// convert converts the event into the enabled signals.
+	"convert": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			pe.batch = ot.convertBatch(pe.event)
+			if ot.scrape != nil && len(pe.batch.Metrics) > 0 && !pe.stale {
+				ot.scrape.Add(pe.event, pe.batch.Metrics, time.Now())
+			}
+			if pe.batch.Empty() && !ot.signal(pe.event, signalEvents) {
+				return nil
+			}
+			return next(ctx, pe)
+		}
+	},
+	// export sends the batch to the exporters, or to the sinks of the
+	// profile of the namespace, along with the batches of the export group
+	// of the event, if any.
+	"export": func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			exporter := ot.exporter
+			if p := ot.profiles.lookup(pe.event); p != nil && p.exporter != nil {
+				exporter = p.exporter
+			}
+			res := ot.resource(pe.event)
+			var err error
+			if m := exportMemberFrom(ctx); m != nil {
+				err = m.export(exporter, res, pe.batch)
+			} else {
+				err = exporter.Export(ctx, res, pe.batch)
+			}
+			if err != nil {
+				return err
+			}
+			ot.last.Exported(pe.last, res, pe.batch)
+			return next(ctx, pe)
+		}
+	},
+}
+
+// parsePipeline parses the comma separated stages of --pipeline. Each stage
+// runs at most once; decode, convert and export are required, decode first
+// of the three and export last, and the filter, enrich and transform stages
+// work on the metrics decode parses, before convert turns them into the
+// batch.
+func parsePipeline(value string) ([]string, error) {
+	names := splitList(value)
+	position := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := pipelineStages[name]; !ok {
+			return nil, fmt.Errorf("unknown stage %q, expected one of %s", name, defaultPipeline)
+		}
+		if _, ok := position[name]; ok {
+			return nil, fmt.Errorf("stage %q listed twice", name)
+		}
+		position[name] = i
+	}
+	for _, name := range []string{"decode", "convert", "export"} {
+		if _, ok := position[name]; !ok {
+			return nil, fmt.Errorf("missing stage %q", name)
+		}
+	}
+	if position["decode"] > position["convert"] || position["convert"] > position["export"] {
+		return nil, fmt.Errorf("decode, convert and export must run in this order")
+	}
+	for _, name := range []string{"filter", "enrich", "transform"} {
+		if i, ok := position[name]; ok && (i < position["decode"] || i > position["convert"]) {
+			return nil, fmt.Errorf("%s must run after decode and before convert", name)
+		}
+	}
+	return names, nil
+}
+
+// buildPipeline chains the stages named in order. The events to debug are
+// traced after every stage that passes them on.
+func (ot *otelPlugin) buildPipeline(names []string) pipelineHandler {
+	var handler pipelineHandler = func(ctx context.Context, pe *pipelineEvent) error { return nil }
+	for i := len(names) - 1; i >= 0; i-- {
+		name, next := names[i], handler
+		traced := func(ctx context.Context, pe *pipelineEvent) error {
+			if pe.debug {
+				pe.trace(ot, name)
+			}
+			return next(ctx, pe)
+		}
+		stage := pipelineStages[name](ot, traced)
+		handler = func(ctx context.Context, pe *pipelineEvent) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			pe.stage = name
+			return stage(ctx, pe)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	stages, err := parsePipeline(defaultPipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"decode", "filter", "enrich", "transform", "convert", "export"}; !reflect.DeepEqual(stages, want) {
+		t.Errorf("got %v, want %v", stages, want)
+	}
+	if _, err := parsePipeline("decode, transform, convert, export"); err != nil {
+		t.Errorf("pipeline without filter and enrich: %v", err)
+	}
+	for _, invalid := range []string{"decode,convert", "decode,export,convert", "filter,decode,convert,export", "enrich,decode,convert,export", "decode,decode,convert,export", "decode,sample,convert,export", "decode,convert,transform,export", "decode,convert,export,filter", "transform,decode,convert,export"} {
+		if _, err := parsePipeline(invalid); err == nil {
+			t.Errorf("parsePipeline(%q) succeeded, want error", invalid)
+		}
+	}
+
+	var order []string
+	for _, name := range []string{"first", "second"} {
+		name := name
+		pipelineStages[name] = func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+			return func(ctx context.Context, pe *pipelineEvent) error {
+				order = append(order, name)
+				return next(ctx, pe)
+			}
+		}
+		defer delete(pipelineStages, name)
+	}
+	ot := &otelPlugin{}
+	if err := ot.buildPipeline([]string{"second", "first"})(context.Background(), &pipelineEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"second", "first"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("stages ran in order %v, want %v", order, want)
+	}
+}
+
+func TestPipelineDeadline(t *testing.T) {
+	pipelineStages["slow"] = func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			<-ctx.Done()
+			return next(ctx, pe)
+		}
+	}
+	defer delete(pipelineStages, "slow")
+	ran := false
+	pipelineStages["after"] = func(ot *otelPlugin, next pipelineHandler) pipelineHandler {
+		return func(ctx context.Context, pe *pipelineEvent) error {
+			ran = true
+			return next(ctx, pe)
+		}
+	}
+	defer delete(pipelineStages, "after")
+
+	ot := &otelPlugin{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	pe := &pipelineEvent{}
+	if err := ot.buildPipeline([]string{"slow", "after"})(ctx, pe); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if ran || pe.stage != "slow" {
+		t.Errorf("got stage %q and ran=%v, want the pipeline to stop in the slow stage", pe.stage, ran)
+	}
+}
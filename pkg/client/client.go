@@ -0,0 +1,117 @@
+// Package client posts Sensu events to the HTTP ingest API of the
+// otel-sensu-handler-plugin, for programs forwarding events to the handler
+// without going through a Sensu backend.
+//
+//	c := client.New("https://otel-handler.example.com:55788")
+//	c.Token = os.Getenv("SENSU_INGEST_TOKEN")
+//	err := c.Post(ctx, event)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// Client posts events to the handler. Its fields must not be changed once
+// it is in use.
+type Client struct {
+	// URL is the address of the handler, such as http://localhost:55788.
+	URL string
+	// Token is sent as a bearer token, for handlers run with
+	// --ingest-tokens.
+	Token string
+	// HTTPClient sends the requests; use one with a TLS configuration
+	// holding a client certificate for handlers requiring mutual TLS.
+	HTTPClient *http.Client
+	// Retries is the number of times a failed post is sent again.
+	Retries int
+	// MaxBackoff caps the delay between two attempts, which doubles from
+	// 100ms.
+	MaxBackoff time.Duration
+}
+
+// New returns a client of the handler at url, retrying 3 times with at
+// most 2s between attempts.
+func New(url string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Retries:    3,
+		MaxBackoff: 2 * time.Second,
+	}
+}
+
+// StatusError is returned when the handler rejects an event.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("handler returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the post may succeed when sent again: the
+// handler was overloaded or failed to export the event.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Post sends an event to the handler. Network errors and temporary
+// rejections are retried until ctx is done; events the handler refuses,
+// such as those of a namespace the token may not post to, are not.
+func (c *Client) Post(ctx context.Context, event *types.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = c.post(ctx, body)
+		if se, ok := err.(*StatusError); err == nil || (ok && !se.Temporary()) || attempt >= c.Retries {
+			return err
+		}
+		if c.MaxBackoff > 0 && backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	return nil
+}
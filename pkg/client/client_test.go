@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestPost(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		posts++
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if posts == 1 {
+			http.Error(w, "export failed", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.Token = "secret"
+	c.MaxBackoff = time.Millisecond
+	if err := c.Post(context.Background(), corev2.FixtureEvent("entity1", "check1")); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if posts != 2 {
+		t.Errorf("got %d posts, want 2", posts)
+	}
+
+	posts = 0
+	c.Token = "wrong"
+	err := c.Post(context.Background(), corev2.FixtureEvent("entity1", "check1"))
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Post with a wrong token: %v, want a 401 StatusError", err)
+	}
+	if posts != 1 {
+		t.Errorf("rejected post sent %d times, want once", posts)
+	}
+}
@@ -0,0 +1,137 @@
+// Package config describes the configuration of the
+// otel-sensu-handler-plugin as a JSON Schema, so deployment tooling can check
+// a configuration before rolling it out. A configuration is a JSON object of
+// option names and values, the format of signed configuration bundles:
+//
+//	{"exporter": "otlp,file", "exporter-file": "/var/lib/otel/batches.jsonl"}
+//
+// The schema of a handler build is written by its schema subcommand:
+//
+//	$ otel-sensu-handler-plugin schema > schema.json
+//
+// and loaded with Parse to validate configurations:
+//
+//	s, err := config.Parse(schemaJSON)
+//	...
+//	err = s.ValidateJSON(configJSON)
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the JSON Schema draft the schemas are written in.
+const SchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// Schema is the subset of JSON Schema describing the handler configuration:
+// an object whose properties are the options, each a string, a boolean or an
+// integer.
+type Schema struct {
+	Schema      string      `json:"$schema,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	// WriteOnly marks secrets, which tooling should not display.
+	WriteOnly  bool               `json:"writeOnly,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is false when unknown options are errors.
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+	// Env is the environment variable setting the option.
+	Env string `json:"x-env,omitempty"`
+}
+
+// Parse reads a schema written by the schema subcommand.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if s.Type != "object" {
+		return nil, fmt.Errorf("invalid schema: type %q, expected object", s.Type)
+	}
+	return &s, nil
+}
+
+// Errors lists every problem found in a configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d configuration problem(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ValidateJSON validates the configuration encoded in data.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	return s.Validate(values)
+}
+
+// Validate checks the type of every option of values and, for the options
+// with a fixed set of values, that the value is one of them. It returns nil
+// or Errors.
+func (s *Schema) Validate(values map[string]interface{}) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs Errors
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Errorf("%s: unknown option", name))
+			}
+			continue
+		}
+		if err := prop.check(values[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// check checks a single option value.
+func (s *Schema) check(value interface{}) error {
+	switch s.Type {
+	case "string":
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		if len(s.Enum) > 0 {
+			for _, e := range s.Enum {
+				if v == e {
+					return nil
+				}
+			}
+			return fmt.Errorf("%q must be one of %s", v, strings.Join(s.Enum, ", "))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != float64(int64(f)) {
+			return fmt.Errorf("must be an integer")
+		}
+	default:
+		return fmt.Errorf("unsupported schema type %q", s.Type)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+)
+
+const testSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "exporter": {"type": "string", "default": "otlp"},
+    "auth": {"type": "string", "enum": ["static", "oauth2"]},
+    "scrape": {"type": "boolean"},
+    "record-max-size": {"type": "integer", "default": 64}
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateJSON([]byte(`{"exporter": "file", "auth": "oauth2", "scrape": true, "record-max-size": 10}`)); err != nil {
+		t.Errorf("valid configuration: %v", err)
+	}
+
+	err = s.ValidateJSON([]byte(`{"exporter": 1, "auth": "basic", "scrape": "yes", "record-max-size": 1.5, "routes": ""}`))
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("got %v, want Errors", err)
+	}
+	want := []string{
+		`auth: "basic" must be one of static, oauth2`,
+		`exporter: must be a string`,
+		`record-max-size: must be an integer`,
+		`routes: unknown option`,
+		`scrape: must be a boolean`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %v, want %d errors", errs, len(want))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d: got %q, want %q", i, err, want[i])
+		}
+	}
+
+	if _, err := Parse([]byte(`{"type": "string"}`)); err == nil {
+		t.Errorf("Parse accepted a schema that is not an object")
+	}
+}
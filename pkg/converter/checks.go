@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// CheckMetrics converts the result of the check of an event into gauges:
+// sensu.check.status, sensu.check.duration, sensu.check.executed and
+// sensu.check.occurrences, with the state of the check as attribute. The
+// entity and check are named by the resource. It returns nil for events
+// without a check.
+func CheckMetrics(event *types.Event) []*metricpb.Metric {
+	check := event.Check
+	if check == nil {
+		return nil
+	}
+	ts := time.Unix(check.Executed, 0)
+	if check.Executed == 0 {
+		ts = time.Unix(event.Timestamp, 0)
+	}
+	attrs := []*commonpb.KeyValue{StringAttribute("sensu.check.state", check.State)}
+	gauge := func(name, unit string, value float64) *metricpb.Metric {
+		return &metricpb.Metric{
+			Name: name,
+			Unit: unit,
+			Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: []*metricpb.NumberDataPoint{{
+				Attributes:        attrs,
+				StartTimeUnixNano: uint64(ts.Add(-time.Microsecond).UnixNano()),
+				TimeUnixNano:      uint64(ts.UnixNano()),
+				Value:             &metricpb.NumberDataPoint_AsDouble{AsDouble: value},
+			}}}},
+		}
+	}
+	return []*metricpb.Metric{
+		gauge("sensu.check.status", "1", float64(check.Status)),
+		gauge("sensu.check.duration", "s", check.Duration),
+		gauge("sensu.check.executed", "s", float64(check.Executed)),
+		gauge("sensu.check.occurrences", "1", float64(check.Occurrences)),
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestCheckMetrics(t *testing.T) {
+	event := corev2.FixtureEvent("web01", "check-disk")
+	event.Check.Status = 2
+	event.Check.State = "failing"
+	event.Check.Duration = 1.5
+	event.Check.Executed = 1600000000
+	event.Check.Occurrences = 3
+
+	want := map[string]float64{
+		"sensu.check.status":      2,
+		"sensu.check.duration":    1.5,
+		"sensu.check.executed":    1600000000,
+		"sensu.check.occurrences": 3,
+	}
+	metrics := CheckMetrics(event)
+	if len(metrics) != len(want) {
+		t.Fatalf("got %d metrics, want %d", len(metrics), len(want))
+	}
+	for _, m := range metrics {
+		gauge, ok := m.Data.(*metricpb.Metric_Gauge)
+		if !ok || len(gauge.Gauge.DataPoints) != 1 {
+			t.Fatalf("%s: got %v, want one gauge point", m.Name, m.Data)
+		}
+		p := gauge.Gauge.DataPoints[0]
+		if v := p.Value.(*metricpb.NumberDataPoint_AsDouble).AsDouble; v != want[m.Name] {
+			t.Errorf("%s: got %v, want %v", m.Name, v, want[m.Name])
+		}
+		if p.TimeUnixNano != 1600000000*1e9 {
+			t.Errorf("%s: got time %d", m.Name, p.TimeUnixNano)
+		}
+		if len(p.Attributes) != 1 || p.Attributes[0].Key != "sensu.check.state" {
+			t.Errorf("%s: got attributes %v", m.Name, p.Attributes)
+		}
+	}
+
+	event.Check = nil
+	if metrics := CheckMetrics(event); metrics != nil {
+		t.Errorf("got %d metrics for an event without check", len(metrics))
+	}
+}
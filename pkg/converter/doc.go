@@ -0,0 +1,12 @@
+// Package converter converts Sensu events into OpenTelemetry protocol
+// metrics, logs and spans, and builds the OTLP export requests carrying
+// them. Metric points can be parsed from the check output first with
+// ParseOutputMetrics, which supports the formats registered with
+// RegisterMetricParser.
+//
+// It is the conversion of the otel-sensu-handler-plugin, for other
+// programs to embed:
+//
+//	converter.ParseOutputMetrics(event)
+//	req := converter.MetricsRequest(resource, converter.Metrics(event))
+package converter
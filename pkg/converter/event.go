@@ -0,0 +1,27 @@
+package converter
+
+import "github.com/sensu/sensu-go/types"
+
+// EventNames returns the namespace, entity and check names of an event,
+// empty for the parts the event doesn't have.
+func EventNames(event *types.Event) (namespace, entity, check string) {
+	namespace = event.Namespace
+	if event.Entity != nil {
+		entity = event.Entity.Name
+		if namespace == "" {
+			namespace = event.Entity.Namespace
+		}
+	}
+	if event.Check != nil {
+		check = event.Check.Name
+	}
+	return namespace, entity, check
+}
+
+// EventPoints returns the number of metric points carried by an event.
+func EventPoints(event *types.Event) int {
+	if event.Metrics == nil {
+		return 0
+	}
+	return len(event.Metrics.Points)
+}
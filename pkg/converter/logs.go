@@ -0,0 +1,151 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// StatusSeverity maps a Sensu check status to a log severity.
+func StatusSeverity(status uint32) (logspb.SeverityNumber, string) {
+	switch status {
+	case 0:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "OK"
+	case 1:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARNING"
+	case 2:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "CRITICAL"
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "UNKNOWN"
+	}
+}
+
+// SeverityMapping replaces the severities of StatusSeverity: Status maps
+// check statuses to severities, and the first of Overrides whose pattern
+// matches the check output wins over it. The severity text remains the
+// name of the check status.
+type SeverityMapping struct {
+	Status    map[uint32]logspb.SeverityNumber
+	Overrides []SeverityOverride
+}
+
+// SeverityOverride sets the severity of the logs of the check outputs
+// matching Pattern.
+type SeverityOverride struct {
+	Pattern  *regexp.Regexp
+	Severity logspb.SeverityNumber
+}
+
+// Severity returns the severity of the log record of check.
+func (m *SeverityMapping) Severity(check *types.Check) (logspb.SeverityNumber, string) {
+	severity, text := StatusSeverity(check.Status)
+	if m == nil {
+		return severity, text
+	}
+	if s, ok := m.Status[check.Status]; ok {
+		severity = s
+	}
+	for _, o := range m.Overrides {
+		if o.Pattern.MatchString(check.Output) {
+			return o.Severity, text
+		}
+	}
+	return severity, text
+}
+
+// ParseSeverity parses an OpenTelemetry severity name, trace, debug, info,
+// warn, error or fatal, optionally followed by 2, 3 or 4 for the finer
+// severities such as error2. Case is ignored.
+func ParseSeverity(name string) (logspb.SeverityNumber, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	n, ok := logspb.SeverityNumber_value["SEVERITY_NUMBER_"+upper]
+	if !ok || upper == "UNSPECIFIED" {
+		return 0, fmt.Errorf("unknown severity %q, expected trace, debug, info, warn, error or fatal", name)
+	}
+	return logspb.SeverityNumber(n), nil
+}
+
+// Logs converts the check output of an event into a log
+// record, with the severity derived from the check status and the check
+// timeline as attributes.
+func Logs(event *types.Event) []*logspb.LogRecord {
+	return LogsWithSeverity(event, nil)
+}
+
+// LogsWithSeverity is Logs with the severities of mapping, the ones of
+// StatusSeverity when it is nil.
+func LogsWithSeverity(event *types.Event, mapping *SeverityMapping) []*logspb.LogRecord {
+	check := event.Check
+	severity, severityText := mapping.Severity(check)
+
+	executed := check.Executed
+	if executed == 0 {
+		executed = event.Timestamp
+	}
+
+	return []*logspb.LogRecord{{
+		TimeUnixNano:   uint64(time.Unix(executed, 0).UnixNano()),
+		SeverityNumber: severity,
+		SeverityText:   severityText,
+		Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: check.Output}},
+		Attributes:     checkAttributes(event),
+	}}
+}
+
+// LogsRequest builds the OTLP export request for log records of a resource.
+func LogsRequest(res *resourcepb.Resource, records []*logspb.LogRecord) *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: res,
+			InstrumentationLibraryLogs: []*logspb.InstrumentationLibraryLogs{{
+				InstrumentationLibrary: instrumentationLibrary,
+				Logs:                   records,
+			}},
+		}},
+	}
+}
+
+// checkAttributes describes the check execution of an event, so that logs
+// can be correlated with the metrics of the same check and entity.
+func checkAttributes(event *types.Event) []*commonpb.KeyValue {
+	namespace, entity, checkName := EventNames(event)
+	check := event.Check
+	attrs := []*commonpb.KeyValue{
+		StringAttribute("sensu.namespace", namespace),
+		StringAttribute("sensu.entity.name", entity),
+		StringAttribute("sensu.check.name", checkName),
+		intAttribute("sensu.check.status", int64(check.Status)),
+		StringAttribute("sensu.check.state", check.State),
+		intAttribute("sensu.check.occurrences", check.Occurrences),
+		intAttribute("sensu.check.issued", check.Issued),
+		intAttribute("sensu.check.executed", check.Executed),
+		intAttribute("sensu.check.last_ok", check.LastOK),
+		doubleAttribute("sensu.check.duration", check.Duration),
+		intAttribute("sensu.check.total_state_change", int64(check.TotalStateChange)),
+	}
+	if len(event.ID) > 0 {
+		attrs = append(attrs, StringAttribute("sensu.event.id", event.GetUUID().String()))
+	}
+	return attrs
+}
+
+func intAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}},
+	}
+}
+
+func doubleAttribute(key string, value float64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value}},
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"regexp"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestSeverityMapping(t *testing.T) {
+	for name, want := range map[string]logspb.SeverityNumber{
+		"warn":   logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+		"ERROR":  logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+		"error2": logspb.SeverityNumber_SEVERITY_NUMBER_ERROR + 1,
+	} {
+		if got, err := ParseSeverity(name); err != nil || got != want {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	for _, invalid := range []string{"", "unspecified", "critical", "warn5"} {
+		if _, err := ParseSeverity(invalid); err == nil {
+			t.Errorf("ParseSeverity(%q) succeeded, want error", invalid)
+		}
+	}
+
+	m := &SeverityMapping{
+		Status: map[uint32]logspb.SeverityNumber{3: logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+		Overrides: []SeverityOverride{
+			{Pattern: regexp.MustCompile("OOM"), Severity: logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+		},
+	}
+	for _, tt := range []struct {
+		status uint32
+		output string
+		want   logspb.SeverityNumber
+		text   string
+	}{
+		{0, "all good", logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "OK"},
+		{2, "disk full", logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "CRITICAL"},
+		{3, "timeout", logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "UNKNOWN"},
+		{2, "killed: OOM", logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, "CRITICAL"},
+	} {
+		check := &corev2.Check{Status: tt.status, Output: tt.output}
+		if got, text := m.Severity(check); got != tt.want || text != tt.text {
+			t.Errorf("status %d, output %q: got %v %s, want %v %s", tt.status, tt.output, got, text, tt.want, tt.text)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package converter
+
+import (
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// instrumentationLibrary names the library of every converted metric.
+var instrumentationLibrary = &commonpb.InstrumentationLibrary{Name: "sensu-otel"}
+
+// Metrics converts the metric points of an event. Every point
+// becomes a gauge data point, grouped into one metric per name.
+func Metrics(event *types.Event) []*metricpb.Metric {
+	var metrics []*metricpb.Metric
+	byName := map[string]*metricpb.Gauge{}
+	if event.Metrics == nil {
+		return nil
+	}
+	for _, m := range event.Metrics.Points {
+		gauge, ok := byName[m.Name]
+		if !ok {
+			gauge = &metricpb.Gauge{}
+			byName[m.Name] = gauge
+			metrics = append(metrics, &metricpb.Metric{
+				Name: m.Name,
+				Data: &metricpb.Metric_Gauge{Gauge: gauge},
+			})
+		}
+
+		ts := time.Unix(0, m.Timestamp) // Timestamp is in nanoseconds
+		gauge.DataPoints = append(gauge.DataPoints, &metricpb.NumberDataPoint{
+			Attributes:        tagsToAttributes(m.Tags),
+			StartTimeUnixNano: uint64(ts.Add(-time.Microsecond).UnixNano()),
+			TimeUnixNano:      uint64(ts.UnixNano()),
+			Value:             &metricpb.NumberDataPoint_AsDouble{AsDouble: m.Value},
+		})
+	}
+	return metrics
+}
+
+// MetricsRequest builds the OTLP export request for metrics of a resource.
+func MetricsRequest(res *resourcepb.Resource, metrics []*metricpb.Metric) *colmetricpb.ExportMetricsServiceRequest {
+	return &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{
+			Resource: res,
+			InstrumentationLibraryMetrics: []*metricpb.InstrumentationLibraryMetrics{{
+				InstrumentationLibrary: instrumentationLibrary,
+				Metrics:                metrics,
+			}},
+		}},
+	}
+}
+
+func tagsToAttributes(tags []*types.MetricTag) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(tags))
+	for _, t := range tags {
+		attrs = append(attrs, StringAttribute(t.Name, t.Value))
+	}
+	return attrs
+}
+
+// StringAttribute returns a string attribute.
+func StringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
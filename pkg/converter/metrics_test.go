@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestEventToMetricsRequest(t *testing.T) {
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Metrics = &corev2.Metrics{
+		Points: []*corev2.MetricPoint{
+			{Name: "cpu.idle", Value: 90, Timestamp: 1000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "0"}}},
+			{Name: "cpu.idle", Value: 80, Timestamp: 1000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "1"}}},
+			{Name: "mem.used", Value: 42, Timestamp: 2000},
+		},
+	}
+
+	req := MetricsRequest(&resourcepb.Resource{}, Metrics(event))
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].InstrumentationLibraryMetrics) != 1 {
+		t.Fatalf("unexpected request shape: %v", req)
+	}
+	metrics := req.ResourceMetrics[0].InstrumentationLibraryMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want 2", len(metrics))
+	}
+
+	cpu := metrics[0].GetGauge().GetDataPoints()
+	if metrics[0].Name != "cpu.idle" || len(cpu) != 2 {
+		t.Fatalf("unexpected cpu.idle metric: %v", metrics[0])
+	}
+	if v := cpu[1].Value.(*metricpb.NumberDataPoint_AsDouble).AsDouble; v != 80 {
+		t.Errorf("cpu.idle value = %v, want 80", v)
+	}
+	if a := cpu[1].Attributes; len(a) != 1 || a[0].Key != "cpu" || a[0].Value.GetStringValue() != "1" {
+		t.Errorf("unexpected cpu.idle attributes: %v", a)
+	}
+	if ts := metrics[1].GetGauge().GetDataPoints()[0].TimeUnixNano; ts != 2000 {
+		t.Errorf("mem.used timestamp = %d, want 2000", ts)
+	}
+}
@@ -0,0 +1,318 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// MetricParser extracts metric points from check output. Parsers are
+// selected by the output_metric_format of the check, for events the Sensu
+// agent did not already extract metrics from.
+type MetricParser interface {
+	Parse(output string, executed time.Time) ([]*types.MetricPoint, error)
+}
+
+// MetricParserFunc adapts a function to the MetricParser interface.
+type MetricParserFunc func(output string, executed time.Time) ([]*types.MetricPoint, error)
+
+// Parse calls f(output, executed).
+func (f MetricParserFunc) Parse(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	return f(output, executed)
+}
+
+var metricParsers = map[string]MetricParser{}
+
+// RegisterMetricParser makes a parser available for checks with the given
+// output_metric_format. It is meant to be called from init functions and
+// panics if the format is already taken.
+func RegisterMetricParser(format string, parser MetricParser) {
+	if _, ok := metricParsers[format]; ok {
+		panic(fmt.Sprintf("metric parser for %q registered twice", format))
+	}
+	metricParsers[format] = parser
+}
+
+// metricParserFormats lists the formats with a registered parser, sorted.
+func metricParserFormats() []string {
+	formats := make([]string, 0, len(metricParsers))
+	for format := range metricParsers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func init() {
+	RegisterMetricParser("graphite_plaintext", MetricParserFunc(parseGraphite))
+	RegisterMetricParser("opentsdb_line", MetricParserFunc(parseOpenTSDB))
+	RegisterMetricParser("influxdb_line", MetricParserFunc(parseInfluxDB))
+	RegisterMetricParser("nagios_perfdata", MetricParserFunc(parseNagiosPerfdata))
+	RegisterMetricParser("prometheus_text", MetricParserFunc(parsePrometheusText))
+}
+
+// ParseOutputMetrics fills in the metrics of an event from its check output
+// when the agent didn't extract any and a parser is registered for the
+// output_metric_format of the check.
+func ParseOutputMetrics(event *types.Event) error {
+	if EventPoints(event) > 0 || event.Check == nil || event.Check.OutputMetricFormat == "" {
+		return nil
+	}
+	parser, ok := metricParsers[event.Check.OutputMetricFormat]
+	if !ok {
+		return fmt.Errorf("no parser for output metric format %q, expected one of %s",
+			event.Check.OutputMetricFormat, strings.Join(metricParserFormats(), ", "))
+	}
+	executed := event.Check.Executed
+	if executed == 0 {
+		executed = event.Timestamp
+	}
+	points, err := parser.Parse(event.Check.Output, time.Unix(executed, 0))
+	if err != nil {
+		return fmt.Errorf("parsing %s output: %v", event.Check.OutputMetricFormat, err)
+	}
+	if len(points) > 0 {
+		event.Metrics = &types.Metrics{Points: points}
+	}
+	return nil
+}
+
+// parseTimestamp converts a Unix timestamp in seconds into the nanoseconds
+// stored in metric points, falling back to the execution time.
+func parseTimestamp(value string, executed time.Time) (int64, error) {
+	if value == "" {
+		return executed.UnixNano(), nil
+	}
+	secs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return time.Unix(secs, 0).UnixNano(), nil
+}
+
+// outputLines returns the non-empty lines of check output.
+func outputLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseGraphite parses "name value [timestamp]" lines.
+func parseGraphite(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	var points []*types.MetricPoint
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q", line)
+		}
+		var ts string
+		if len(fields) == 3 {
+			ts = fields[2]
+		}
+		timestamp, err := parseTimestamp(ts, executed)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, &types.MetricPoint{Name: fields[0], Value: value, Timestamp: timestamp})
+	}
+	return points, nil
+}
+
+// parseOpenTSDB parses "name timestamp value tag=value..." lines.
+func parseOpenTSDB(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	var points []*types.MetricPoint
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(strings.TrimPrefix(line, "put "))
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		timestamp, err := parseTimestamp(fields[1], executed)
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q", line)
+		}
+		tags, err := parseTags(fields[3:])
+		if err != nil {
+			return nil, fmt.Errorf("%v in line %q", err, line)
+		}
+		points = append(points, &types.MetricPoint{Name: fields[0], Value: value, Timestamp: timestamp, Tags: tags})
+	}
+	return points, nil
+}
+
+// parseInfluxDB parses "measurement[,tag=value...] field=value[,...] [timestamp]"
+// lines. Every field becomes a point named measurement.field.
+func parseInfluxDB(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	var points []*types.MetricPoint
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		series := strings.Split(fields[0], ",")
+		tags, err := parseTags(series[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%v in line %q", err, line)
+		}
+		timestamp := executed.UnixNano()
+		if len(fields) == 3 {
+			// Line protocol timestamps are in nanoseconds.
+			if timestamp, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid timestamp in line %q", line)
+			}
+		}
+		for _, field := range strings.Split(fields[1], ",") {
+			i := strings.IndexByte(field, '=')
+			if i < 0 {
+				return nil, fmt.Errorf("invalid field %q in line %q", field, line)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSuffix(field[i+1:], "i"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of field %q in line %q", field[:i], line)
+			}
+			points = append(points, &types.MetricPoint{
+				Name:      series[0] + "." + field[:i],
+				Value:     value,
+				Timestamp: timestamp,
+				Tags:      tags,
+			})
+		}
+	}
+	return points, nil
+}
+
+// parseNagiosPerfdata parses the performance data after the "|" of Nagios
+// plugin output: space separated 'label'=value[UOM];warn;crit;min;max.
+func parseNagiosPerfdata(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	i := strings.IndexByte(output, '|')
+	if i < 0 {
+		return nil, nil
+	}
+	perfdata := output[i+1:]
+	if j := strings.IndexByte(perfdata, '\n'); j >= 0 {
+		perfdata = perfdata[:j]
+	}
+
+	var points []*types.MetricPoint
+	for _, item := range strings.Fields(perfdata) {
+		eq := strings.LastIndexByte(item, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid perfdata %q", item)
+		}
+		label := strings.Trim(item[:eq], "'")
+		value := strings.SplitN(item[eq+1:], ";", 2)[0]
+		value = strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ%")
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid perfdata value %q", item)
+		}
+		points = append(points, &types.MetricPoint{Name: label, Value: v, Timestamp: executed.UnixNano()})
+	}
+	return points, nil
+}
+
+// parsePrometheusText parses the samples of the Prometheus text exposition
+// format: 'name[{label="value",...}] value [timestamp]' lines, the timestamp
+// in milliseconds. Comments, including the HELP and TYPE lines, are skipped.
+func parsePrometheusText(output string, executed time.Time) ([]*types.MetricPoint, error) {
+	var points []*types.MetricPoint
+	for _, line := range outputLines(output) {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ \t"); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		var tags []*types.MetricTag
+		if strings.HasPrefix(rest, "{") {
+			var err error
+			if tags, rest, err = parsePrometheusLabels(rest[1:]); err != nil {
+				return nil, fmt.Errorf("%v in line %q", err, line)
+			}
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 1 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q", line)
+		}
+		timestamp := executed.UnixNano()
+		if len(fields) == 2 {
+			ms, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp in line %q", line)
+			}
+			timestamp = ms * int64(time.Millisecond)
+		}
+		points = append(points, &types.MetricPoint{Name: name, Value: value, Timestamp: timestamp, Tags: tags})
+	}
+	return points, nil
+}
+
+// parsePrometheusLabels parses the labels after the "{" of a sample up to
+// the closing "}", and returns them with the rest of the line.
+func parsePrometheusLabels(s string) ([]*types.MetricTag, string, error) {
+	var tags []*types.MetricTag
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if strings.HasPrefix(s, "}") {
+			return tags, s[1:], nil
+		}
+		i := strings.Index(s, "=\"")
+		if i <= 0 {
+			return nil, "", fmt.Errorf("invalid labels")
+		}
+		name := strings.TrimSpace(s[:i])
+		var value strings.Builder
+		j := i + 2
+		for ; j < len(s) && s[j] != '"'; j++ {
+			if s[j] == '\\' && j+1 < len(s) {
+				j++
+				if s[j] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[j])
+		}
+		if j == len(s) {
+			return nil, "", fmt.Errorf("unterminated value of label %q", name)
+		}
+		tags = append(tags, &types.MetricTag{Name: name, Value: value.String()})
+		s = s[j+1:]
+	}
+}
+
+// parseTags parses tag=value pairs.
+func parseTags(pairs []string) ([]*types.MetricTag, error) {
+	var tags []*types.MetricTag
+	for _, pair := range pairs {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tag %q", pair)
+		}
+		tags = append(tags, &types.MetricTag{Name: pair[:i], Value: pair[i+1:]})
+	}
+	return tags, nil
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricParsers(t *testing.T) {
+	executed := time.Unix(1600000000, 0)
+	tests := []struct {
+		format string
+		output string
+		name   string
+		value  float64
+		tags   int
+		ts     int64
+	}{
+		{"graphite_plaintext", "web01.cpu.idle 90.5 1600000060\n", "web01.cpu.idle", 90.5, 0, 1600000060 * int64(time.Second)},
+		{"graphite_plaintext", "web01.cpu.idle 90.5", "web01.cpu.idle", 90.5, 0, executed.UnixNano()},
+		{"opentsdb_line", "put sys.cpu.user 1600000060 42 host=web01 cpu=0", "sys.cpu.user", 42, 2, 1600000060 * int64(time.Second)},
+		{"influxdb_line", "cpu,host=web01 idle=90i 1600000060000000000", "cpu.idle", 90, 1, 1600000060 * int64(time.Second)},
+		{"prometheus_text", "# TYPE up gauge\nup{job=\"node\",path=\"a \\\"b\\\", c\"} 1 1600000060000\n", "up", 1, 2, 1600000060 * int64(time.Second)},
+		{"prometheus_text", "node_load1 0.5", "node_load1", 0.5, 0, executed.UnixNano()},
+		{"nagios_perfdata", "PING OK - Packet loss = 0%, RTA = 0.80 ms | 'rta'=0.80ms;100;500;0 pl=0%;20;60;0", "rta", 0.8, 0, executed.UnixNano()},
+	}
+	for _, test := range tests {
+		points, err := metricParsers[test.format].Parse(test.output, executed)
+		if err != nil {
+			t.Errorf("%s: %q: %v", test.format, test.output, err)
+			continue
+		}
+		if len(points) == 0 {
+			t.Errorf("%s: %q: no points", test.format, test.output)
+			continue
+		}
+		p := points[0]
+		if p.Name != test.name || p.Value != test.value || len(p.Tags) != test.tags || p.Timestamp != test.ts {
+			t.Errorf("%s: %q: got %v", test.format, test.output, p)
+		}
+	}
+
+	if _, err := parseGraphite("not-a-metric", executed); err == nil {
+		t.Errorf("expected an error for an invalid graphite line")
+	}
+	points, err := parsePrometheusText(`up{path="a \"b\", c"} 1`, executed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := points[0].Tags[0].Value; value != `a "b", c` {
+		t.Errorf("got the label value %q, want %q", value, `a "b", c`)
+	}
+	if _, err := parsePrometheusText(`up{path="a} 1`, executed); err == nil {
+		t.Errorf("expected an error for an unterminated label value")
+	}
+}
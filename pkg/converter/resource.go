@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// Resource describes the entity and check of an event, following the
+// OpenTelemetry semantic conventions where they apply, so that the
+// telemetry of different hosts doesn't collapse into the same series. The
+// labels and annotations of the entity and check whose key matches one of
+// the promote globs become sensu.entity.labels.<key>,
+// sensu.entity.annotations.<key>, sensu.check.labels.<key> and
+// sensu.check.annotations.<key> attributes.
+func Resource(event *types.Event, promote []string) *resourcepb.Resource {
+	namespace, entity, check := EventNames(event)
+	var attrs []*commonpb.KeyValue
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, StringAttribute(key, value))
+		}
+	}
+	add("service.namespace", namespace)
+	add("sensu.entity.name", entity)
+	if e := event.Entity; e != nil {
+		// Proxy entities have no system information: their name is the
+		// one of the device they stand for.
+		hostname := e.System.Hostname
+		if hostname == "" {
+			hostname = e.Name
+		}
+		add("host.name", hostname)
+		add("host.arch", e.System.Arch)
+		add("os.type", e.System.OS)
+		add("os.name", e.System.Platform)
+		add("os.version", e.System.PlatformVersion)
+		add("sensu.entity.class", e.EntityClass)
+		attrs = appendMetadata(attrs, "sensu.entity.labels.", e.Labels, promote)
+		attrs = appendMetadata(attrs, "sensu.entity.annotations.", e.Annotations, promote)
+	}
+	add("sensu.check.name", check)
+	if c := event.Check; c != nil {
+		attrs = appendMetadata(attrs, "sensu.check.labels.", c.Labels, promote)
+		attrs = appendMetadata(attrs, "sensu.check.annotations.", c.Annotations, promote)
+	}
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+// appendMetadata appends the entries of labels or annotations whose key
+// matches one of the globs, sorted by key.
+func appendMetadata(attrs []*commonpb.KeyValue, prefix string, metadata map[string]string, globs []string) []*commonpb.KeyValue {
+	var keys []string
+	for key := range metadata {
+		if matchAny(globs, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attrs = append(attrs, StringAttribute(prefix+key, metadata[key]))
+	}
+	return attrs
+}
+
+func matchAny(globs []string, key string) bool {
+	for _, glob := range globs {
+		// Label keys may contain slashes, which path.Match treats as
+		// separators.
+		if ok, _ := path.Match(strings.Replace(glob, "/", "\x00", -1), strings.Replace(key, "/", "\x00", -1)); ok {
+			return true
+		}
+	}
+	return false
+}
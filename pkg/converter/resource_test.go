@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func TestResource(t *testing.T) {
+	event := corev2.FixtureEvent("web01", "disk")
+	event.Namespace = "production"
+	event.Entity.EntityClass = "agent"
+	event.Entity.System.Hostname = "web01.example.com"
+	event.Entity.System.OS = "linux"
+	event.Entity.System.Arch = "amd64"
+	event.Entity.Labels = map[string]string{"team": "storage", "region": "eu", "secret": "x"}
+	event.Entity.Annotations = map[string]string{"example.com/owner": "ops"}
+	event.Check.Labels = map[string]string{"tier": "gold"}
+
+	res := Resource(event, []string{"team", "region", "tier", "example.com/*"})
+	got := map[string]string{}
+	for _, kv := range res.Attributes {
+		got[kv.Key] = kv.Value.Value.(*commonpb.AnyValue_StringValue).StringValue
+	}
+	want := map[string]string{
+		"service.namespace":          "production",
+		"sensu.entity.name":          "web01",
+		"sensu.entity.class":         "agent",
+		"host.name":                  "web01.example.com",
+		"host.arch":                  "amd64",
+		"os.type":                    "linux",
+		"os.name":                    "Gentoo",
+		"sensu.check.name":           "disk",
+		"sensu.entity.labels.team":   "storage",
+		"sensu.entity.labels.region": "eu",
+		"sensu.entity.annotations.example.com/owner": "ops",
+		"sensu.check.labels.tier":                    "gold",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d attributes, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestResourceProxyEntity(t *testing.T) {
+	event := corev2.FixtureEvent("router01", "snmp")
+	event.Entity.EntityClass = "proxy"
+	for _, kv := range Resource(event, nil).Attributes {
+		if kv.Key == "host.name" && kv.Value.Value.(*commonpb.AnyValue_StringValue).StringValue != "router01" {
+			t.Errorf("expected the entity name as host.name, got %v", kv.Value)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Spans converts the check execution of an event into a span, with an
+// error status unless the check was OK.
+func Spans(event *types.Event) []*tracepb.Span {
+	check := event.Check
+	start := time.Unix(check.Executed, 0)
+	if check.Executed == 0 {
+		start = time.Unix(event.Timestamp, 0)
+	}
+	end := start.Add(time.Duration(check.Duration * float64(time.Second)))
+
+	status := &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK}
+	if check.Status != 0 {
+		_, text := StatusSeverity(check.Status)
+		status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: text}
+	}
+
+	timeline := checkTimeline(check)
+	span := &tracepb.Span{
+		TraceId:           traceID(event),
+		SpanId:            executionSpanID(event, check.Executed),
+		Name:              check.Name,
+		Kind:              tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(end.UnixNano()),
+		Attributes:        checkAttributes(event),
+		Events:            statusChangeEvents(timeline),
+		Status:            status,
+	}
+	// The occurrences of an incident share a trace, each linking to the
+	// previous one.
+	if incident, ok := incidentStart(timeline); ok {
+		span.TraceId = incidentTraceID(event, incident)
+		if n := len(timeline); n >= 2 && timeline[n-2].Executed >= incident {
+			span.Links = []*tracepb.Span_Link{{
+				TraceId: span.TraceId,
+				SpanId:  executionSpanID(event, timeline[n-2].Executed),
+			}}
+		}
+	}
+
+	return []*tracepb.Span{span}
+}
+
+// TraceRequest builds the OTLP export request for spans of a resource.
+func TraceRequest(res *resourcepb.Resource, spans []*tracepb.Span) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: res,
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+				InstrumentationLibrary: instrumentationLibrary,
+				Spans:                  spans,
+			}},
+		}},
+	}
+}
+
+// traceID returns the 16 byte Sensu event ID, or a random ID for events
+// without one.
+func traceID(event *types.Event) []byte {
+	if len(event.ID) == 16 {
+		return event.ID
+	}
+	return randomID(16)
+}
+
+func randomID(n int) []byte {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+	return id
+}
+
+// checkTimeline returns the history of the check including the current
+// execution, oldest first.
+func checkTimeline(check *types.Check) []types.CheckHistory {
+	history := check.History
+	if n := len(history); n == 0 || history[n-1].Executed != check.Executed {
+		history = append(append([]types.CheckHistory(nil), history...), types.CheckHistory{
+			Status:   check.Status,
+			Executed: check.Executed,
+		})
+	}
+	return history
+}
+
+// incidentStart returns when the incident the last execution of timeline
+// belongs to started, if it belongs to one. A resolution belongs to the
+// incident it resolves.
+func incidentStart(timeline []types.CheckHistory) (int64, bool) {
+	last := len(timeline) - 1
+	if timeline[last].Status == 0 {
+		last--
+	}
+	if last < 0 || timeline[last].Status == 0 {
+		return 0, false
+	}
+	for last > 0 && timeline[last-1].Status != 0 {
+		last--
+	}
+	return timeline[last].Executed, true
+}
+
+// statusChangeEvents returns a span event for every status change in the
+// timeline.
+func statusChangeEvents(timeline []types.CheckHistory) []*tracepb.Span_Event {
+	var events []*tracepb.Span_Event
+	for i := 1; i < len(timeline); i++ {
+		prev, cur := timeline[i-1], timeline[i]
+		if prev.Status == cur.Status {
+			continue
+		}
+		events = append(events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(time.Unix(cur.Executed, 0).UnixNano()),
+			Name:         "status change",
+			Attributes: []*commonpb.KeyValue{
+				intAttribute("sensu.check.status", int64(cur.Status)),
+				intAttribute("sensu.check.previous_status", int64(prev.Status)),
+			},
+		})
+	}
+	return events
+}
+
+// executionSpanID derives the span ID of a check execution, so that later
+// occurrences can link to it without keeping any state.
+func executionSpanID(event *types.Event, executed int64) []byte {
+	namespace, entity, check := EventNames(event)
+	return hashID(8, namespace, entity, check, strconv.FormatInt(executed, 10))
+}
+
+// incidentTraceID derives the trace ID shared by all occurrences of an
+// incident.
+func incidentTraceID(event *types.Event, started int64) []byte {
+	namespace, entity, check := EventNames(event)
+	return hashID(16, namespace, entity, check, "incident", strconv.FormatInt(started, 10))
+}
+
+func hashID(n int, parts ...string) []byte {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return sum[:n]
+}
@@ -0,0 +1,35 @@
+package converter
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestIncidentStart(t *testing.T) {
+	history := func(statuses ...uint32) []corev2.CheckHistory {
+		h := make([]corev2.CheckHistory, len(statuses))
+		for i, s := range statuses {
+			h[i] = corev2.CheckHistory{Status: s, Executed: int64(100 + i)}
+		}
+		return h
+	}
+	for _, tt := range []struct {
+		name     string
+		timeline []corev2.CheckHistory
+		start    int64
+		incident bool
+	}{
+		{"ok", history(0, 0, 0), 0, false},
+		{"first failure", history(0, 0, 2), 102, true},
+		{"ongoing", history(0, 1, 2, 2), 101, true},
+		{"resolution", history(0, 2, 2, 0), 101, true},
+		{"after resolution", history(2, 0, 0), 0, false},
+		{"failing since start", history(2, 2), 100, true},
+	} {
+		start, incident := incidentStart(tt.timeline)
+		if start != tt.start || incident != tt.incident {
+			t.Errorf("%s: incidentStart() = %d, %v, want %d, %v", tt.name, start, incident, tt.start, tt.incident)
+		}
+	}
+}
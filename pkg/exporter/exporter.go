@@ -0,0 +1,133 @@
+// Package exporter defines how telemetry converted from Sensu events is
+// sent to its destinations: the Batch of telemetry and the Exporter
+// interface every sink of the otel-sensu-handler-plugin implements, so
+// programs embedding the conversion can plug in their own sinks.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Batch holds the telemetry converted from Sensu events, ready to be
+// exported under a resource. The source events are kept for sinks that
+// need more than the converted telemetry.
+type Batch struct {
+	Events  []*types.Event
+	Metrics []*metricpb.Metric
+	Logs    []*logspb.LogRecord
+	Spans   []*tracepb.Span
+}
+
+// Empty reports whether the batch holds no telemetry at all.
+func (b *Batch) Empty() bool {
+	return len(b.Metrics) == 0 && len(b.Logs) == 0 && len(b.Spans) == 0
+}
+
+// Exporter sends converted telemetry to a destination. Implementations must
+// be safe for concurrent use.
+type Exporter interface {
+	Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error
+	Shutdown(ctx context.Context) error
+}
+
+// ResourceBatch is a batch and the resource it is exported under.
+type ResourceBatch struct {
+	Resource *resourcepb.Resource
+	Batch    *Batch
+}
+
+// ResourcesExporter is implemented by the exporters that send the batches
+// of several resources together, such as the OTLP exporters, which put
+// them in one request per signal.
+type ResourcesExporter interface {
+	ExportResources(ctx context.Context, batches []ResourceBatch) error
+}
+
+// ExportResources sends batches with exporter: together if it is a
+// ResourcesExporter, one after the other otherwise. The first error is
+// returned.
+func ExportResources(ctx context.Context, exporter Exporter, batches []ResourceBatch) error {
+	if e, ok := exporter.(ResourcesExporter); ok {
+		return e.ExportResources(ctx, batches)
+	}
+	var first error
+	for _, b := range batches {
+		if err := exporter.Export(ctx, b.Resource, b.Batch); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Fanout sends every batch to several exporters. Exporters only send the
+// signals they support, so for example metrics can go to an OTLP backend
+// while check output goes to a log store.
+type Fanout struct {
+	names     []string
+	exporters []Exporter
+}
+
+// Add adds an exporter, named in errors and in the description of the
+// fanout.
+func (f *Fanout) Add(name string, exporter Exporter) {
+	f.names = append(f.names, name)
+	f.exporters = append(f.exporters, exporter)
+}
+
+// Export sends the batch to every exporter, even after one failed. The
+// error of the first failed exporter is returned, so that it decides
+// whether the export is retried.
+func (f *Fanout) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return f.each(func(exporter Exporter) error {
+		return exporter.Export(ctx, res, batch)
+	})
+}
+
+// ExportResources sends the batches to every exporter, like Export.
+func (f *Fanout) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	return f.each(func(exporter Exporter) error {
+		return ExportResources(ctx, exporter, batches)
+	})
+}
+
+// each calls export with every exporter and returns the error of the first
+// failed one.
+func (f *Fanout) each(export func(Exporter) error) error {
+	var first error
+	var failed int
+	for i, exporter := range f.exporters {
+		if err := export(exporter); err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s: %w", f.names[i], err)
+			}
+			failed++
+		}
+	}
+	if failed > 1 {
+		return fmt.Errorf("%w (and %d more exporters failed)", first, failed-1)
+	}
+	return first
+}
+
+// Shutdown shuts every exporter down and returns the first error.
+func (f *Fanout) Shutdown(ctx context.Context) error {
+	var first error
+	for _, exporter := range f.exporters {
+		if err := exporter.Shutdown(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f *Fanout) String() string {
+	return strings.Join(f.names, ", ")
+}
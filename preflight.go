@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// Outcomes of the pre-flight of an exporter.
+const (
+	preflightPassed      = "passed"
+	preflightFailed      = "failed"
+	preflightUnsupported = "unsupported"
+)
+
+// preflighter is implemented by the exporters that can check their
+// connection and credentials without exporting telemetry.
+type preflighter interface {
+	Preflight(ctx context.Context) error
+}
+
+// runPreflight checks every exporter that supports it, as --preflight
+// requests at startup, so that a wrong endpoint or token shows in the logs
+// and on /readyz before the first event. Failures are not fatal: the
+// exporter stays down until one of its exports succeeds.
+func runPreflight() {
+	exporterHealths.Lock()
+	healths := make([]*exporterHealth, 0, len(exporterHealths.byName))
+	for _, h := range exporterHealths.byName {
+		healths = append(healths, h)
+	}
+	exporterHealths.Unlock()
+
+	for _, h := range healths {
+		p, ok := h.exporter.(preflighter)
+		if !ok {
+			h.Lock()
+			h.preflight = preflightUnsupported
+			h.Unlock()
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		err := p.Preflight(ctx)
+		cancel()
+		h.observe(err)
+		h.Lock()
+		if err != nil {
+			h.preflight = preflightFailed
+			log.Printf("pre-flight of %s failed: %v", destination(h.name, h.exporter), err)
+		} else {
+			h.preflight = preflightPassed
+			log.Printf("pre-flight of %s passed", destination(h.name, h.exporter))
+		}
+		h.Unlock()
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+type preflightExporter struct{ err error }
+
+func (e *preflightExporter) Export(context.Context, *resourcepb.Resource, *Batch) error { return nil }
+func (e *preflightExporter) Shutdown(context.Context) error                             { return nil }
+func (e *preflightExporter) Preflight(context.Context) error                            { return e.err }
+
+func TestPreflight(t *testing.T) {
+	exporterHealths.Lock()
+	saved := exporterHealths.byName
+	exporterHealths.byName = map[string]*exporterHealth{}
+	exporterHealths.Unlock()
+	defer func() {
+		exporterHealths.Lock()
+		exporterHealths.byName = saved
+		exporterHealths.Unlock()
+	}()
+
+	bad := trackHealth("bad", &preflightExporter{err: errors.New("unauthenticated")})
+	trackHealth("good", &preflightExporter{})
+	// Embedding the interface hides Preflight.
+	trackHealth("other", struct{ Exporter }{&preflightExporter{}})
+	runPreflight()
+
+	want := map[string]struct {
+		preflight string
+		up        bool
+	}{
+		"bad":   {preflightFailed, false},
+		"good":  {preflightPassed, true},
+		"other": {preflightUnsupported, true},
+	}
+	for _, s := range exporterHealthStatuses() {
+		if w := want[s.Name]; s.Preflight != w.preflight || s.Up != w.up {
+			t.Errorf("%s: got preflight %q, up %v, want %q, %v", s.Name, s.Preflight, s.Up, w.preflight, w.up)
+		}
+	}
+
+	// A successful export brings the exporter up.
+	if err := bad.Export(context.Background(), nil, &Batch{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range exporterHealthStatuses() {
+		if s.Name == "bad" && !s.Up {
+			t.Errorf("bad still down after a successful export")
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// profileConfig is the --profiles file: named profiles of defaults and the
+// namespaces bound to them, so that the teams sharing a handler can be
+// configured by namespace rather than one rule at a time.
+//
+//	{
+//	  "profiles": {
+//	    "platform": {"filters": [{"metric": "disk.inode.*"}], "prefix": "platform.", "sinks": ["otlphttp"]}
+//	  },
+//	  "namespaces": [{"namespace": "platform-*", "profile": "platform"}]
+//	}
+type profileConfig struct {
+	Profiles map[string]*profile `json:"profiles"`
+	// Namespaces binds the namespaces matching glob patterns to profiles;
+	// the first binding matching applies.
+	Namespaces []profileBinding `json:"namespaces"`
+}
+
+// profile holds the defaults of the events of the namespaces bound to it.
+type profile struct {
+	// Filters drop metric points as the runtime filters do.
+	Filters []filterRule `json:"filters"`
+	// Prefix is prepended to the metric names.
+	Prefix string `json:"prefix"`
+	// Sinks replaces the exporters of --exporter when not empty.
+	Sinks []string `json:"sinks"`
+
+	exporter Exporter
+}
+
+type profileBinding struct {
+	Namespace string `json:"namespace"`
+	Profile   string `json:"profile"`
+}
+
+// loadProfiles reads and checks the profiles of file.
+func loadProfiles(file string) (*profileConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var c profileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("%s: profile %q: empty", file, name)
+		}
+		rules := runtimeConfig{Filters: p.Filters, Sinks: p.Sinks}
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %v", file, name, err)
+		}
+	}
+	for _, b := range c.Namespaces {
+		if _, ok := c.Profiles[b.Profile]; !ok {
+			return nil, fmt.Errorf("%s: namespace %q bound to unknown profile %q", file, b.Namespace, b.Profile)
+		}
+		if _, err := path.Match(b.Namespace, ""); err != nil || b.Namespace == "" {
+			return nil, fmt.Errorf("%s: invalid namespace pattern %q", file, b.Namespace)
+		}
+	}
+	return &c, nil
+}
+
+// start creates the exporters of the profiles with sinks.
+func (c *profileConfig) start() error {
+	for name, p := range c.Profiles {
+		if len(p.Sinks) == 0 {
+			continue
+		}
+		e, err := newExporters(p.Sinks)
+		if err != nil {
+			c.shutdown(context.Background())
+			return fmt.Errorf("profile %q: %v", name, err)
+		}
+		p.exporter = e
+	}
+	return nil
+}
+
+// shutdown shuts the exporters of the profiles down.
+func (c *profileConfig) shutdown(ctx context.Context) {
+	for name, p := range c.Profiles {
+		if p.exporter == nil {
+			continue
+		}
+		if err := p.exporter.Shutdown(ctx); err != nil {
+			errorLog.Printf("could not shut down %s of profile %q: %v", destination(strings.Join(p.Sinks, ","), p.exporter), name, err)
+		}
+		p.exporter = nil
+	}
+}
+
+// lookup returns the profile of the namespace of event, or nil.
+func (c *profileConfig) lookup(event *types.Event) *profile {
+	if c == nil {
+		return nil
+	}
+	namespace, _, _ := converter.EventNames(event)
+	for _, b := range c.Namespaces {
+		if ok, _ := path.Match(b.Namespace, namespace); ok {
+			return c.Profiles[b.Profile]
+		}
+	}
+	return nil
+}
+
+// filterPoints removes the metric points of event matched by a filter of the
+// profile and returns how many were removed.
+func (p *profile) filterPoints(event *types.Event) int {
+	if len(p.Filters) == 0 || event.Metrics == nil {
+		return 0
+	}
+	_, _, check := converter.EventNames(event)
+	kept := event.Metrics.Points[:0]
+	for _, point := range event.Metrics.Points {
+		if !matchFilters(p.Filters, check, point.Name) {
+			kept = append(kept, point)
+		}
+	}
+	removed := len(event.Metrics.Points) - len(kept)
+	event.Metrics.Points = kept
+	return removed
+}
+
+// addPrefix prepends the prefix of the profile to the metric names.
+func (p *profile) addPrefix(event *types.Event) {
+	if p.Prefix == "" || event.Metrics == nil {
+		return
+	}
+	for _, point := range event.Metrics.Points {
+		point.Name = p.Prefix + point.Name
+	}
+}
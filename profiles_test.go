@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "profiles.json")
+	write := func(data string) {
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{
+  "profiles": {"platform": {"filters": [{"metric": "disk.inode.*"}], "prefix": "platform."}},
+  "namespaces": [{"namespace": "platform-*", "profile": "platform"}]
+}`)
+	c, err := loadProfiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "disk")
+	event.Namespace = "platform-eu"
+	event.Entity.Namespace = "platform-eu"
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "disk.inode.used"},
+		{Name: "disk.used"},
+	}}
+	p := c.lookup(event)
+	if p == nil {
+		t.Fatalf("no profile for namespace platform-eu")
+	}
+	if n := p.filterPoints(event); n != 1 {
+		t.Errorf("got %d points filtered, want 1", n)
+	}
+	p.addPrefix(event)
+	if len(event.Metrics.Points) != 1 || event.Metrics.Points[0].Name != "platform.disk.used" {
+		t.Errorf("got %v, want platform.disk.used", event.Metrics.Points)
+	}
+	event.Namespace = "default"
+	event.Entity.Namespace = "default"
+	if p := c.lookup(event); p != nil {
+		t.Errorf("got a profile for namespace default")
+	}
+
+	for _, invalid := range []string{
+		`{"profiles": {"a": {}}, "namespaces": [{"namespace": "x", "profile": "b"}]}`,
+		`{"profiles": {"a": {"sinks": ["nope"]}}}`,
+		`{"profiles": {"a": {"temporality": "delta"}}}`,
+		`{"profiles": {"a": {}}, "namespaces": [{"namespace": "[", "profile": "a"}]}`,
+	} {
+		write(invalid)
+		if _, err := loadProfiles(file); err == nil {
+			t.Errorf("loadProfiles accepted %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// promFamilies holds the value of every series by metric and label set.
+type promFamilies map[string]map[string]float64
+
+// add adds the gauges of metrics. When a series appears more than once the
+// last point wins.
+func (f promFamilies) add(metrics []*metricpb.Metric) {
+	for _, m := range metrics {
+		gauge := m.GetGauge()
+		if gauge == nil {
+			continue
+		}
+		name := promName(m.Name)
+		family, ok := f[name]
+		if !ok {
+			family = map[string]float64{}
+			f[name] = family
+		}
+		for _, p := range gauge.DataPoints {
+			family[promLabels(p.Attributes)] = pointValue(p)
+		}
+	}
+}
+
+// writeTo writes the families in the Prometheus text exposition format,
+// without timestamps, sorted by name and label set.
+func (f promFamilies) writeTo(w io.Writer) error {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", name); err != nil {
+			return err
+		}
+		family := f[name]
+		labels := make([]string, 0, len(family))
+		for l := range family {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, l, promValue(family[l])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writePromText writes the gauges of metrics in the Prometheus text
+// exposition format.
+func writePromText(w io.Writer, metrics []*metricpb.Metric) error {
+	families := promFamilies{}
+	families.add(metrics)
+	return families.writeTo(w)
+}
+
+// promName maps a metric or label name onto [a-zA-Z_:][a-zA-Z0-9_:]*,
+// replacing other characters such as the dots of Sensu metric names with
+// underscores and prefixing names starting with a digit with one.
+func promName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// promLabels renders attributes as a sorted Prometheus label set, empty
+// for no attributes.
+func promLabels(attrs []*commonpb.KeyValue) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(attrs))
+	for _, kv := range attrs {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(formatAnyValue(kv.Value))
+		pairs = append(pairs, promName(kv.Key)+`="`+value+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// pointValue returns the value of a number data point as a float.
+func pointValue(p *metricpb.NumberDataPoint) float64 {
+	switch v := p.Value.(type) {
+	case *metricpb.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *metricpb.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	}
+	return math.NaN()
+}
+
+func promValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
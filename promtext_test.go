@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+func TestWritePromText(t *testing.T) {
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Metrics = &corev2.Metrics{
+		Points: []*corev2.MetricPoint{
+			{Name: "cpu.idle", Value: 90, Timestamp: 1000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "1"}}},
+			{Name: "cpu.idle", Value: 80.5, Timestamp: 1000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "0"}}},
+			{Name: "9.mem-used", Value: 42, Timestamp: 2000, Tags: []*corev2.MetricTag{{Name: "note", Value: `a "b"`}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writePromText(&buf, converter.Metrics(event)); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE _9_mem_used gauge
+_9_mem_used{note="a \"b\""} 42
+# TYPE cpu_idle gauge
+cpu_idle{cpu="0"} 80.5
+cpu_idle{cpu="1"} 90
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
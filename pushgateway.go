@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("pushgateway", func() (Exporter, error) {
+		if plugin.PushgatewayURL == "" {
+			return nil, fmt.Errorf("the pushgateway exporter requires --pushgateway-url")
+		}
+		return &pushgatewayExporter{
+			client:   &http.Client{Timeout: exportTimeout},
+			baseURL:  strings.TrimSuffix(plugin.PushgatewayURL, "/"),
+			job:      plugin.PushgatewayJob,
+			instance: plugin.PushgatewayInstance,
+		}, nil
+	})
+}
+
+// pushgatewayExporter pushes the metrics of each event to a Prometheus
+// Pushgateway, grouped by job and instance. The instance is the entity of
+// the event unless configured.
+type pushgatewayExporter struct {
+	client   *http.Client
+	baseURL  string
+	job      string
+	instance string
+}
+
+func (e *pushgatewayExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	instance := e.instance
+	if instance == "" && len(batch.Events) > 0 {
+		_, instance, _ = converter.EventNames(batch.Events[0])
+	}
+	return e.push(ctx, instance, batch.Metrics)
+}
+
+func (e *pushgatewayExporter) push(ctx context.Context, instance string, metrics []*metricpb.Metric) error {
+	var body bytes.Buffer
+	if err := writePromText(&body, metrics); err != nil {
+		return err
+	}
+	path := "/metrics/job" + groupingValue(e.job)
+	if instance != "" {
+		path += "/instance" + groupingValue(instance)
+	}
+
+	// POST only replaces the metrics of the same names in the group, so
+	// checks of one entity don't overwrite each other.
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+path, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+		return doHTTP(e.client, req)
+	})
+}
+
+// groupingValue encodes a grouping label value as a URL path segment,
+// using the base64 form of the Pushgateway for values containing slashes.
+func groupingValue(value string) string {
+	if value == "" {
+		return "@base64/="
+	}
+	if strings.Contains(value, "/") {
+		return "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + url.PathEscape(value)
+}
+
+func (e *pushgatewayExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *pushgatewayExporter) String() string {
+	return "pushgateway " + e.baseURL
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaLimit is a limit of the events or points a token may post in a
+// minute or a day.
+type quotaLimit struct {
+	points bool
+	window time.Duration
+	limit  int64
+}
+
+func (l quotaLimit) kind() string {
+	if l.points {
+		return "points"
+	}
+	return "events"
+}
+
+// quotaUsage counts what a token posted in the current window of a limit.
+type quotaUsage struct {
+	start time.Time
+	used  int64
+}
+
+// ingestQuotas enforces the quotas of --ingest-quotas, in fixed windows
+// starting on the minute and on midnight UTC.
+type ingestQuotas struct {
+	sync.Mutex
+	limits map[string][]quotaLimit
+	usage  map[string][]quotaUsage
+}
+
+// quotaStatus is the consumption of a quota, as served on /quotas and
+// reported in the headers of the requests exceeding it.
+type quotaStatus struct {
+	Token  string    `json:"token"`
+	Kind   string    `json:"kind"`
+	Window string    `json:"window"`
+	Limit  int64     `json:"limit"`
+	Used   int64     `json:"used"`
+	Reset  time.Time `json:"reset"`
+}
+
+// parseIngestQuotas parses --ingest-quotas: variables of --ingest-tokens,
+// separated by semicolons, each followed by = and its comma separated
+// limits, for example
+//
+//	TEAM_A_INGEST_TOKEN=events:600/m,points:1000000/d; SENSU_INGEST_TOKEN=points:50000/m
+//
+// It returns nil without quotas.
+func parseIngestQuotas(value string) (*ingestQuotas, error) {
+	limits := map[string][]quotaLimit{}
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.IndexByte(item, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid quota %q, expected VARIABLE=limits", item)
+		}
+		env := strings.TrimSpace(item[:i])
+		if _, ok := limits[env]; ok {
+			return nil, fmt.Errorf("token %s listed twice", env)
+		}
+		for _, spec := range splitList(item[i+1:]) {
+			l, err := parseQuotaLimit(spec)
+			if err != nil {
+				return nil, fmt.Errorf("token %s: %v", env, err)
+			}
+			limits[env] = append(limits[env], l)
+		}
+		if len(limits[env]) == 0 {
+			return nil, fmt.Errorf("token %s has no limits after =", env)
+		}
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	return &ingestQuotas{limits: limits, usage: map[string][]quotaUsage{}}, nil
+}
+
+// parseQuotaLimit parses events:N/m, events:N/d, points:N/m or points:N/d.
+func parseQuotaLimit(spec string) (quotaLimit, error) {
+	invalid := fmt.Errorf("invalid limit %q, expected events or points:N/m or /d", spec)
+	i, j := strings.IndexByte(spec, ':'), strings.LastIndexByte(spec, '/')
+	if i < 0 || j < i {
+		return quotaLimit{}, invalid
+	}
+	var l quotaLimit
+	switch spec[:i] {
+	case "events":
+	case "points":
+		l.points = true
+	default:
+		return quotaLimit{}, invalid
+	}
+	switch spec[j+1:] {
+	case "m":
+		l.window = time.Minute
+	case "d":
+		l.window = 24 * time.Hour
+	default:
+		return quotaLimit{}, invalid
+	}
+	n, err := strconv.ParseInt(spec[i+1:j], 10, 64)
+	if err != nil || n <= 0 {
+		return quotaLimit{}, invalid
+	}
+	l.limit = n
+	return l, nil
+}
+
+// tokens returns the variables of the tokens with quotas.
+func (q *ingestQuotas) tokens() []string {
+	var envs []string
+	for env := range q.limits {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// take counts an event of points against the quotas of token, unless it
+// would exceed one of them, which is then returned.
+func (q *ingestQuotas) take(token string, points int64, now time.Time) (*quotaStatus, bool) {
+	q.Lock()
+	defer q.Unlock()
+	limits := q.limits[token]
+	usage := q.usage[token]
+	if usage == nil {
+		usage = make([]quotaUsage, len(limits))
+		q.usage[token] = usage
+	}
+	for i, l := range limits {
+		if start := now.UTC().Truncate(l.window); !usage[i].start.Equal(start) {
+			usage[i] = quotaUsage{start: start}
+		}
+		n := int64(1)
+		if l.points {
+			n = points
+		}
+		if usage[i].used+n > l.limit {
+			status := q.status(token, l, usage[i])
+			return &status, false
+		}
+	}
+	for i, l := range limits {
+		if l.points {
+			usage[i].used += points
+		} else {
+			usage[i].used++
+		}
+	}
+	return nil, true
+}
+
+func (q *ingestQuotas) status(token string, l quotaLimit, u quotaUsage) quotaStatus {
+	window := "1m"
+	if l.window != time.Minute {
+		window = "1d"
+	}
+	return quotaStatus{Token: token, Kind: l.kind(), Window: window, Limit: l.limit, Used: u.used, Reset: u.start.Add(l.window)}
+}
+
+// Snapshot returns the consumption of every quota at now.
+func (q *ingestQuotas) Snapshot(now time.Time) []quotaStatus {
+	q.Lock()
+	defer q.Unlock()
+	var statuses []quotaStatus
+	for _, token := range q.tokens() {
+		usage := q.usage[token]
+		for i, l := range q.limits[token] {
+			u := quotaUsage{start: now.UTC().Truncate(l.window)}
+			if usage != nil && usage[i].start.Equal(u.start) {
+				u = usage[i]
+			}
+			statuses = append(statuses, q.status(token, l, u))
+		}
+	}
+	return statuses
+}
+
+// setQuotaHeaders describes the exceeded quota s in the headers of a 429
+// response.
+func setQuotaHeaders(h http.Header, s *quotaStatus, now time.Time) {
+	reset := int64(s.Reset.Sub(now)/time.Second) + 1
+	h.Set("RateLimit-Limit", strconv.FormatInt(s.Limit, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(s.Limit-s.Used, 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d %s per %s", s.Limit, s.Kind, s.Window))
+	h.Set("Retry-After", strconv.FormatInt(reset, 10))
+}
+
+// serveQuotas returns the consumption of the quotas of the ingest tokens.
+//
+//	$ curl localhost:55788/quotas
+func (ot *otelPlugin) serveQuotas(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ot.quotas.Snapshot(time.Now()))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseIngestQuotas(t *testing.T) {
+	q, err := parseIngestQuotas("TEAM_A=events:2/m, points:10/d; TEAM_B=points:5/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.tokens(); len(got) != 2 || got[0] != "TEAM_A" || got[1] != "TEAM_B" {
+		t.Errorf("got tokens %v", got)
+	}
+	want := quotaLimit{points: true, window: 24 * time.Hour, limit: 10}
+	if len(q.limits["TEAM_A"]) != 2 || q.limits["TEAM_A"][1] != want {
+		t.Errorf("got limits %+v", q.limits["TEAM_A"])
+	}
+	if q, err := parseIngestQuotas(" "); q != nil || err != nil {
+		t.Errorf("got %v, %v, want no quotas", q, err)
+	}
+	for _, invalid := range []string{"TEAM_A", "=events:1/m", "TEAM_A=", "TEAM_A=events:1/h", "TEAM_A=bytes:1/m", "TEAM_A=events:0/m", "TEAM_A=events:x/m", "TEAM_A=events:1/m; TEAM_A=points:1/m"} {
+		if _, err := parseIngestQuotas(invalid); err == nil {
+			t.Errorf("parseIngestQuotas(%q) succeeded, want error", invalid)
+		}
+	}
+}
+
+func TestIngestQuotas(t *testing.T) {
+	q, err := parseIngestQuotas("TEAM_A=events:2/m,points:10/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 11, 3, 10, 15, 30, 0, time.UTC)
+	if _, ok := q.take("TEAM_A", 4, now); !ok {
+		t.Fatal("first event rejected")
+	}
+	if _, ok := q.take("OTHER", 100, now); !ok {
+		t.Fatal("event of a token without quotas rejected")
+	}
+	if _, ok := q.take("TEAM_A", 4, now); !ok {
+		t.Fatal("second event rejected")
+	}
+	exceeded, ok := q.take("TEAM_A", 1, now)
+	if ok || exceeded.Kind != "events" || exceeded.Window != "1m" || exceeded.Used != 2 {
+		t.Fatalf("third event in the minute: got %+v, %v", exceeded, ok)
+	}
+
+	// A new minute resets the events but not the points of the day.
+	now = now.Add(time.Minute)
+	exceeded, ok = q.take("TEAM_A", 3, now)
+	if ok || exceeded.Kind != "points" || exceeded.Window != "1d" || exceeded.Used != 8 {
+		t.Fatalf("points over the day: got %+v, %v", exceeded, ok)
+	}
+	if !exceeded.Reset.Equal(time.Date(2021, 11, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got reset %v, want midnight", exceeded.Reset)
+	}
+	if _, ok := q.take("TEAM_A", 2, now); !ok {
+		t.Fatal("event within the quotas rejected")
+	}
+
+	snapshot := q.Snapshot(now)
+	if len(snapshot) != 2 || snapshot[0].Used != 1 || snapshot[1].Used != 10 {
+		t.Errorf("got snapshot %+v", snapshot)
+	}
+
+	h := http.Header{}
+	setQuotaHeaders(h, exceeded, now)
+	if h.Get("RateLimit-Limit") != "10" || h.Get("RateLimit-Remaining") != "2" || h.Get("Retry-After") == "" {
+		t.Errorf("got headers %v", h)
+	}
+}
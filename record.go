@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// recordPattern matches the files written by a recorder. Names sort in
+// creation order.
+const recordPattern = "events-*.jsonl"
+
+// recorder tees received events to rotating JSON lines files, in the format
+// read back by the replay subcommand.
+type recorder struct {
+	sync.Mutex
+	dir       string
+	maxBytes  int64
+	retention recordRetention
+	file      *os.File
+	size      int64
+}
+
+// recordRetention bounds the record files kept: their number, the age of
+// their last write and their total size, any of which may be 0 for no
+// limit.
+type recordRetention struct {
+	maxFiles int
+	maxAge   time.Duration
+	maxTotal int64
+}
+
+func newRecorder(dir string, maxBytes int64, retention recordRetention) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	r := &recorder{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		retention: retention,
+	}
+	// Short-lived handler processes may never rotate: apply the retention
+	// when starting too.
+	if files, err := r.files(); err == nil {
+		retention.apply(files, time.Now())
+	}
+	return r, nil
+}
+
+// Record appends the event as a single line to the current record file.
+func (r *recorder) Record(event *types.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil || (r.size > 0 && r.size+int64(len(line)) > r.maxBytes) {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// rotate switches to a new record file and removes the files past the
+// retention. When no file is open yet, the newest existing file is appended
+// to as long as it has room left, so that short-lived handler processes
+// share files.
+func (r *recorder) rotate() error {
+	files, err := r.files()
+	if err != nil {
+		return err
+	}
+
+	if r.file == nil && len(files) > 0 {
+		last := files[len(files)-1]
+		if fi, err := os.Stat(last); err == nil && fi.Size() < r.maxBytes {
+			f, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0640)
+			if err == nil {
+				r.file, r.size = f, fi.Size()
+				return nil
+			}
+		}
+	}
+
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+	name := filepath.Join(r.dir, "events-"+time.Now().UTC().Format("20060102T150405.000000000")+".jsonl")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	r.file, r.size = f, 0
+
+	r.retention.apply(append(files, name), time.Now())
+	return nil
+}
+
+// apply removes the oldest of files, sorted oldest first, until the rest
+// is within the retention, and returns the files kept. The newest file,
+// which may be written to, is always kept.
+func (rt recordRetention) apply(files []string, now time.Time) []string {
+	var total int64
+	sizes := make([]int64, len(files))
+	times := make([]time.Time, len(files))
+	for i, name := range files {
+		if fi, err := os.Stat(name); err == nil {
+			sizes[i], times[i] = fi.Size(), fi.ModTime()
+			total += fi.Size()
+		}
+	}
+	for len(files) > 1 {
+		expired := rt.maxAge > 0 && now.Sub(times[0]) > rt.maxAge
+		if !expired && (rt.maxFiles <= 0 || len(files) <= rt.maxFiles) && (rt.maxTotal <= 0 || total <= rt.maxTotal) {
+			break
+		}
+		_ = os.Remove(files[0])
+		total -= sizes[0]
+		files, sizes, times = files[1:], sizes[1:], times[1:]
+	}
+	return files
+}
+
+// files lists the existing record files, oldest first.
+func (r *recorder) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(r.dir, recordPattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
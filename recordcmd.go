@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// runRecord manages the files of --record-dir, which hold the events to
+// replay after an outage:
+//
+//	$ ./otel-sensu-handler-plugin record ls --dir /var/lib/otel-sensu
+//	$ ./otel-sensu-handler-plugin record export --dir /var/lib/otel-sensu --since 6h > events.jsonl
+//	$ ./otel-sensu-handler-plugin record purge --dir /var/lib/otel-sensu --older-than 72h
+//	$ ./otel-sensu-handler-plugin record compact --dir /var/lib/otel-sensu --max-age 168h
+//
+// The newest file, which a running server may still write to, is left
+// alone by purge and compact.
+func runRecord(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected ls, export, purge or compact")
+	}
+	flags := flag.NewFlagSet("record "+args[0], flag.ExitOnError)
+	dir := flags.String("dir", plugin.RecordDir, "directory of the record files")
+	var run func(files []string) error
+	switch args[0] {
+	case "ls":
+		run = func(files []string) error { return listRecords(os.Stdout, files) }
+	case "export":
+		since := flags.Duration("since", 0, "only export the events of this last period, e.g. 6h (all when 0)")
+		run = func(files []string) error {
+			var after time.Time
+			if *since > 0 {
+				after = time.Now().Add(-*since)
+			}
+			return exportRecords(os.Stdout, files, after)
+		}
+	case "purge":
+		olderThan := flags.Duration("older-than", 0, "only remove the files last written to before this period, e.g. 72h (all when 0)")
+		run = func(files []string) error {
+			removed := 0
+			for _, name := range files[:len(files)-1] {
+				if fi, err := os.Stat(name); err == nil && (*olderThan == 0 || time.Since(fi.ModTime()) > *olderThan) {
+					if err := os.Remove(name); err != nil {
+						return err
+					}
+					removed++
+				}
+			}
+			fmt.Printf("removed %d of %d record files\n", removed, len(files))
+			return nil
+		}
+	case "compact":
+		maxSize := flags.Int64("max-size", plugin.RecordMaxSize, "size in MB of the compacted files")
+		maxAge := flags.Duration("max-age", 0, "drop the events older than this period, e.g. 168h (none when 0)")
+		run = func(files []string) error {
+			var before time.Time
+			if *maxAge > 0 {
+				before = time.Now().Add(-*maxAge)
+			}
+			kept, dropped, err := compactRecords(files[:len(files)-1], *maxSize*1024*1024, before)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("compacted %d record files into %d, %d events dropped\n", len(files)-1, kept, dropped)
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown record command %q, expected ls, export, purge or compact", args[0])
+	}
+	_ = flags.Parse(args[1:])
+	if *dir == "" {
+		return fmt.Errorf("--dir is required")
+	}
+	files, err := (&recorder{dir: *dir}).files()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no record files in %s", *dir)
+	}
+	return run(files)
+}
+
+// recordEventTime returns the time of a recorded event, zero if unknown.
+func recordEventTime(data []byte) time.Time {
+	var e struct {
+		Timestamp int64 `json:"timestamp"`
+	}
+	if json.Unmarshal(data, &e) != nil || e.Timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(e.Timestamp, 0)
+}
+
+// eachRecord calls fn with every recorded event of files, in order.
+func eachRecord(files []string, fn func(data []byte) error) error {
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		var fnErr error
+		err = readEventLines(f, false, func(line int, data []byte) {
+			if fnErr == nil {
+				fnErr = fn(data)
+			}
+		})
+		f.Close()
+		if err == nil {
+			err = fnErr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// listRecords writes the size, number of events and time range of every
+// record file to w.
+func listRecords(w io.Writer, files []string) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSIZE\tEVENTS\tFIRST\tLAST")
+	var totalSize int64
+	var totalEvents int
+	for _, name := range files {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		var events int
+		var first, last time.Time
+		err = eachRecord([]string{name}, func(data []byte) error {
+			events++
+			if t := recordEventTime(data); !t.IsZero() {
+				if first.IsZero() {
+					first = t
+				}
+				last = t
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", filepath.Base(name), fi.Size(), events, formatRecordTime(first), formatRecordTime(last))
+		totalSize += fi.Size()
+		totalEvents += events
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t\t\n", totalSize, totalEvents)
+	return tw.Flush()
+}
+
+func formatRecordTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// exportRecords writes the recorded events of files to w, one per line,
+// skipping those before after.
+func exportRecords(w io.Writer, files []string, after time.Time) error {
+	bw := bufio.NewWriter(w)
+	err := eachRecord(files, func(data []byte) error {
+		if !after.IsZero() && recordEventTime(data).Before(after) {
+			return nil
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// compactRecords rewrites files, sorted oldest first, into as few files of
+// at most maxBytes as possible, dropping the events before before and the
+// lines that are not events. The compacted files take the names of the
+// first of files, so that they keep sorting in creation order. It returns
+// the number of compacted files and of events dropped.
+func compactRecords(files []string, maxBytes int64, before time.Time) (int, int, error) {
+	if len(files) == 0 {
+		return 0, 0, nil
+	}
+	dir := filepath.Dir(files[0])
+	var compacted []*os.File
+	var size int64
+	dropped := 0
+	abort := func(err error) (int, int, error) {
+		for _, f := range compacted {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		return 0, 0, err
+	}
+	err := eachRecord(files, func(data []byte) error {
+		var e types.Event
+		if json.Unmarshal(data, &e) != nil || (!before.IsZero() && e.Timestamp != 0 && time.Unix(e.Timestamp, 0).Before(before)) {
+			dropped++
+			return nil
+		}
+		// The last name available takes the remaining events whatever
+		// their size.
+		if len(compacted) == 0 || (size+int64(len(data))+1 > maxBytes && size > 0 && len(compacted) < len(files)) {
+			f, err := ioutil.TempFile(dir, "compact-*.tmp")
+			if err != nil {
+				return err
+			}
+			compacted, size = append(compacted, f), 0
+		}
+		f := compacted[len(compacted)-1]
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		_, err := f.Write([]byte{'\n'})
+		size += int64(len(data)) + 1
+		return err
+	})
+	if err != nil {
+		return abort(err)
+	}
+	for _, f := range compacted {
+		if err := f.Close(); err != nil {
+			return abort(err)
+		}
+	}
+	// Replacing the files before removing the others at worst leaves
+	// events twice, never loses them.
+	for i, f := range compacted {
+		if err := os.Rename(f.Name(), files[i]); err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, name := range files[len(compacted):] {
+		if err := os.Remove(name); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(compacted), dropped, nil
+}
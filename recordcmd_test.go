@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRecordFiles writes record files of the given events timestamps.
+func writeRecordFiles(t *testing.T, dir string, files ...[]int64) []string {
+	var names []string
+	for i, timestamps := range files {
+		var buf bytes.Buffer
+		for _, ts := range timestamps {
+			fmt.Fprintf(&buf, "{\"timestamp\": %d, \"entity\": {\"metadata\": {\"name\": \"web01\"}}}\n", ts)
+		}
+		name := filepath.Join(dir, fmt.Sprintf("events-2021110%dT000000.000000000.jsonl", i+1))
+		if err := ioutil.WriteFile(name, buf.Bytes(), 0640); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestRecordRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := writeRecordFiles(t, dir, []int64{1}, []int64{2}, []int64{3}, []int64{4})
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	for _, name := range files[:2] {
+		if err := os.Chtimes(name, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if kept := (recordRetention{maxAge: time.Hour}).apply(files, now); len(kept) != 2 || kept[0] != files[2] {
+		t.Fatalf("max age: kept %v", kept)
+	}
+	fi, _ := os.Stat(files[3])
+	if kept := (recordRetention{maxTotal: fi.Size()}).apply(files[2:], now); len(kept) != 1 || kept[0] != files[3] {
+		t.Fatalf("max total size: kept %v", kept)
+	}
+	if kept := (recordRetention{maxFiles: 1, maxAge: time.Nanosecond}).apply(files[3:], now); len(kept) != 1 {
+		t.Fatalf("the newest file was removed")
+	}
+}
+
+func TestCompactRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now().Unix()
+	files := writeRecordFiles(t, dir, []int64{now - 7200, now - 60}, []int64{now - 30}, []int64{now})
+
+	kept, dropped, err := compactRecords(files[:2], 1024*1024, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 || dropped != 1 {
+		t.Errorf("got %d files and %d dropped, want 1 and 1", kept, dropped)
+	}
+	remaining, _ := (&recorder{dir: dir}).files()
+	if len(remaining) != 2 || remaining[0] != files[0] || remaining[1] != files[2] {
+		t.Fatalf("got files %v", remaining)
+	}
+
+	var out bytes.Buffer
+	if err := exportRecords(&out, remaining, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Errorf("exported %d events, want 3:\n%s", lines, out.String())
+	}
+	out.Reset()
+	if err := listRecords(&out, remaining); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "total") || !strings.Contains(out.String(), filepath.Base(files[2])) {
+		t.Errorf("unexpected listing:\n%s", out.String())
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// crashes counts the panics recovered from while handling requests and
+// exporting events.
+var crashes int64
+
+// recoverHTTP turns a panic in h into a 500 response, so that one bad event
+// doesn't take down the whole server.
+func recoverHTTP(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			atomic.AddInt64(&crashes, 1)
+			log.Printf("panic serving %s %s: %v\n%s", req.Method, req.URL.Path, r, debug.Stack())
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}()
+		h(w, req)
+	}
+}
+
+// recoverError turns a panic into an error stored in err. It must be
+// deferred directly.
+func recoverError(what string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&crashes, 1)
+	log.Printf("panic %s: %v\n%s", what, r, debug.Stack())
+	*err = fmt.Errorf("panic %s: %v", what, r)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// maxEventSize bounds a single JSON line read from a capture file.
+const maxEventSize = 16 * 1024 * 1024
+
+// replay re-sends previously captured Sensu events, one JSON document per
+// line, through the conversion and export pipeline. It is used to backfill
+// a backend after an outage or to try out changes against real events.
+// With --dir it drains the files of --record-dir, oldest first by default
+// or newest first so that recent data is available first. Events failing
+// with a retryable error are retried with backoff, which slows the drain
+// down while the backend recovers. With --prioritize-status the incidents
+// and resolutions are replayed first, so that alerting recovers before
+// the bulk of the points backfills dashboards.
+//
+//	$ ./otel-sensu-handler-plugin replay --file events.jsonl --rate 100/s
+//	$ ./otel-sensu-handler-plugin replay --dir /var/lib/otel-sensu --order newest --rate 50/s --prioritize-status
+func (ot *otelPlugin) replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := flags.String("file", "", "file of captured events, one JSON event per line (- for stdin)")
+	dir := flags.String("dir", "", "directory of record files, as written with --record-dir, to replay instead of --file")
+	order := flags.String("order", "oldest", "order to replay the events in: oldest or newest first")
+	rate := flags.String("rate", "100/s", "maximum replay rate, e.g. 100/s or 600/m (0 for unlimited)")
+	retries := flags.Int("retries", 5, "attempts to send again an event failing with a retryable error, with backoff")
+	prioritize := flags.Bool("prioritize-status", false, "replay the events of failing or resolved checks first, then the others")
+	_ = flags.Parse(args)
+
+	if (*file == "") == (*dir == "") {
+		return fmt.Errorf("one of --file and --dir is required")
+	}
+	if *order != "oldest" && *order != "newest" {
+		return fmt.Errorf("invalid --order %q, expected oldest or newest", *order)
+	}
+	if *prioritize && *file == "-" {
+		return fmt.Errorf("--prioritize-status cannot read stdin twice")
+	}
+	newest := *order == "newest"
+	interval, err := parseRate(*rate)
+	if err != nil {
+		return err
+	}
+	files := []string{*file}
+	if *dir != "" {
+		if files, err = (&recorder{dir: *dir}).files(); err != nil {
+			return err
+		}
+		if newest {
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+		}
+	}
+
+	var throttle <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	// Every pass replays the events it selects.
+	passes := []func(data []byte) bool{func([]byte) bool { return true }}
+	if *prioritize {
+		passes = []func(data []byte) bool{
+			statusPriority,
+			func(data []byte) bool { return !statusPriority(data) },
+		}
+	}
+
+	var sent, failed int
+	for _, selected := range passes {
+		for _, name := range files {
+			var in io.ReadCloser = os.Stdin
+			if name != "-" {
+				if in, err = os.Open(name); err != nil {
+					return err
+				}
+			}
+			err := readEventLines(in, newest, func(line int, data []byte) {
+				if !selected(data) {
+					return
+				}
+				if throttle != nil && sent+failed > 0 {
+					<-throttle
+				}
+				if err := ot.replayEvent(data, *retries); err != nil {
+					log.Printf("%s:%d: %v", name, line, err)
+					failed++
+					return
+				}
+				sent++
+			})
+			if name != "-" {
+				in.Close()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("replayed %d events, %d failed", sent, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events could not be replayed", failed, sent+failed)
+	}
+	return nil
+}
+
+// statusPriority reports whether a captured event is one of a failing
+// check or of a check resolution, replayed first with --prioritize-status.
+// Events that cannot be decoded are not, so they are reported once.
+func statusPriority(data []byte) bool {
+	var e types.Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return false
+	}
+	return e.HasCheck() && (e.Check.Status != 0 || e.IsResolution())
+}
+
+// replayRetryBackoff is the first delay before sending an event again,
+// doubled at every attempt up to maxReplayBackoff.
+const (
+	replayRetryBackoff = time.Second
+	maxReplayBackoff   = 30 * time.Second
+)
+
+// replayEvent sends a captured event, again as long as it fails with a
+// retryable error, up to retries times. The event is decoded for every
+// attempt since the pipeline changes it.
+func (ot *otelPlugin) replayEvent(data []byte, retries int) error {
+	backoff := replayRetryBackoff
+	for attempt := 0; ; attempt++ {
+		var e types.Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("event parse error: %v", err)
+		}
+		err := ot.eventToOtel(&e)
+		if err == nil {
+			return nil
+		}
+		if exportExitCode(err) != exitRetryable || attempt >= retries {
+			return fmt.Errorf("could not convert event to otel: %v", err)
+		}
+		log.Printf("could not export event, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxReplayBackoff {
+			backoff = maxReplayBackoff
+		}
+	}
+}
+
+// readEventLines calls fn with every non-empty line of in and its number,
+// from the last line to the first when reverse is set.
+func readEventLines(in io.Reader, reverse bool, fn func(line int, data []byte)) error {
+	type numbered struct {
+		line int
+		data []byte
+	}
+	var lines []numbered
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		if !reverse {
+			fn(line, data)
+			continue
+		}
+		lines = append(lines, numbered{line: line, data: append([]byte(nil), data...)})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		fn(lines[i].line, lines[i].data)
+	}
+	return nil
+}
+
+// parseRate converts a rate such as "100/s", "600/m" or "10" (per second)
+// into the minimum interval between two events. A rate of 0 disables
+// throttling.
+func parseRate(rate string) (time.Duration, error) {
+	count, unit := rate, "s"
+	if i := strings.IndexByte(rate, '/'); i >= 0 {
+		count, unit = rate[:i], rate[i+1:]
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q", rate)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return 0, fmt.Errorf("invalid rate unit %q, expected s, m or h", unit)
+	}
+	return time.Duration(float64(per) / n), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for rate, want := range map[string]time.Duration{
+		"100/s": 10 * time.Millisecond,
+		"60/m":  time.Second,
+		"2":     500 * time.Millisecond,
+		"0":     0,
+	} {
+		got, err := parseRate(rate)
+		if err != nil {
+			t.Errorf("parseRate(%q): %v", rate, err)
+		} else if got != want {
+			t.Errorf("parseRate(%q) = %v, want %v", rate, got, want)
+		}
+	}
+	for _, rate := range []string{"", "fast", "-1/s", "10/d"} {
+		if _, err := parseRate(rate); err == nil {
+			t.Errorf("parseRate(%q) succeeded, want error", rate)
+		}
+	}
+}
+
+func TestReadEventLines(t *testing.T) {
+	in := "{\"a\":1}\n\n{\"b\":2}\n{\"c\":3}\n"
+	for _, reverse := range []bool{false, true} {
+		var got []string
+		err := readEventLines(strings.NewReader(in), reverse, func(line int, data []byte) {
+			got = append(got, fmt.Sprintf("%d:%s", line, data))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{`1:{"a":1}`, `3:{"b":2}`, `4:{"c":3}`}
+		if reverse {
+			want = []string{`4:{"c":3}`, `3:{"b":2}`, `1:{"a":1}`}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("reverse=%v: got %v, want %v", reverse, got, want)
+		}
+	}
+}
+
+func TestStatusPriority(t *testing.T) {
+	for data, want := range map[string]bool{
+		`{"check": {"metadata": {"name": "disk"}, "status": 2}}`: true,
+		`{"check": {"metadata": {"name": "disk"}, "status": 0}}`: false,
+		`{"metrics": {"points": [{"name": "cpu", "value": 1}]}}`: false,
+		`{`: false,
+	} {
+		if got := statusPriority([]byte(data)); got != want {
+			t.Errorf("%s: got %v, want %v", data, got, want)
+		}
+	}
+}
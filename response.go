@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// The detail levels of the responses to posted events, --ingest-response.
+const (
+	responseMinimal = "minimal"
+	responseSummary = "summary"
+	responseVerbose = "verbose"
+)
+
+func validateIngestResponse(value string) error {
+	switch value {
+	case responseMinimal, responseSummary, responseVerbose:
+		return nil
+	}
+	return fmt.Errorf("invalid value %q, expected minimal, summary or verbose", value)
+}
+
+// pointResult is an exported point in verbose responses.
+type pointResult struct {
+	Name      string            `json:"name"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// writeIngestResponse answers the successful post of an event of received
+// points: with 204 and no body for minimal, the number of points received
+// and exported for summary, and those numbers and every exported point,
+// after the pipeline, as JSON for verbose.
+func writeIngestResponse(w http.ResponseWriter, level string, received int, pe *pipelineEvent) {
+	exported := 0
+	if !pe.dropped {
+		exported = converter.EventPoints(pe.event)
+	}
+	switch level {
+	case responseMinimal:
+		w.WriteHeader(http.StatusNoContent)
+	case responseVerbose:
+		result := struct {
+			Received int           `json:"received"`
+			Exported int           `json:"exported"`
+			Dropped  bool          `json:"dropped"`
+			Points   []pointResult `json:"points"`
+		}{Received: received, Exported: exported, Dropped: pe.dropped, Points: []pointResult{}}
+		if exported > 0 {
+			for _, p := range pe.event.Metrics.Points {
+				r := pointResult{Name: p.Name, Value: p.Value, Timestamp: p.Timestamp}
+				for _, t := range p.Tags {
+					if r.Tags == nil {
+						r.Tags = map[string]string{}
+					}
+					r.Tags[t.Name] = t.Value
+				}
+				result.Points = append(result.Points, r)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	default:
+		fmt.Fprintf(w, "ok: %d points received, %d exported\n", received, exported)
+	}
+}
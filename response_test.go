@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestWriteIngestResponse(t *testing.T) {
+	event := corev2.FixtureEvent("web01", "cpu")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "cpu.user", Value: 12.5, Timestamp: 1000, Tags: []*corev2.MetricTag{{Name: "cpu", Value: "0"}}},
+	}}
+	pe := &pipelineEvent{event: event}
+
+	w := httptest.NewRecorder()
+	writeIngestResponse(w, responseMinimal, 2, pe)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("minimal: got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	writeIngestResponse(w, responseSummary, 2, pe)
+	if want := "ok: 2 points received, 1 exported\n"; w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("summary: got %d %q, want %q", w.Code, w.Body.String(), want)
+	}
+
+	w = httptest.NewRecorder()
+	writeIngestResponse(w, responseVerbose, 2, pe)
+	var result struct {
+		Received, Exported int
+		Points             []pointResult
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Received != 2 || result.Exported != 1 || len(result.Points) != 1 || result.Points[0].Tags["cpu"] != "0" {
+		t.Errorf("verbose: got %+v", result)
+	}
+
+	pe.dropped = true
+	w = httptest.NewRecorder()
+	writeIngestResponse(w, responseSummary, 2, pe)
+	if want := "ok: 2 points received, 0 exported\n"; w.Body.String() != want {
+		t.Errorf("dropped: got %q, want %q", w.Body.String(), want)
+	}
+}
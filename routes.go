@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/exporter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// route sends the events matching all of its conditions to its exporters.
+type route struct {
+	conditions []routeCondition
+	exporters  []string
+}
+
+// routeCondition matches a glob pattern against the namespace, entity or
+// check name, or a label of the entity or check.
+type routeCondition struct {
+	field   string
+	pattern string
+}
+
+// parseRoutes parses a routing table: rules separated by semicolons, each
+// comma separated conditions, "=>" and the comma separated exporters the
+// matching events go to, for example
+//
+//	namespace=prod-* => otlp; namespace=lab-*,label.team=db => otlphttp,file
+//
+// A condition is field=glob with namespace, entity, check or label.<name>
+// as the field; a rule of just * matches every event.
+func parseRoutes(value string) ([]route, error) {
+	var routes []route
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.Index(rule, "=>")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid route %q, expected conditions => exporters", rule)
+		}
+		r := route{exporters: splitList(rule[i+2:])}
+		if len(r.exporters) == 0 {
+			return nil, fmt.Errorf("route %q has no exporters", rule)
+		}
+		match := strings.TrimSpace(rule[:i])
+		if match != "*" {
+			for _, cond := range splitList(match) {
+				j := strings.IndexByte(cond, '=')
+				if j <= 0 {
+					return nil, fmt.Errorf("invalid route condition %q, expected field=pattern", cond)
+				}
+				c := routeCondition{field: cond[:j], pattern: cond[j+1:]}
+				switch {
+				case c.field == "namespace", c.field == "entity", c.field == "check":
+				case strings.HasPrefix(c.field, "label.") && len(c.field) > len("label."):
+				default:
+					return nil, fmt.Errorf("unknown route field %q, expected namespace, entity, check or label.<name>", c.field)
+				}
+				if _, err := path.Match(c.pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid route pattern %q: %v", c.pattern, err)
+				}
+				r.conditions = append(r.conditions, c)
+			}
+		}
+		routes = append(routes, r)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no routes")
+	}
+	return routes, nil
+}
+
+// matches reports whether an event matches all the conditions of r.
+func (r *route) matches(event *types.Event) bool {
+	namespace, entity, check := converter.EventNames(event)
+	for _, c := range r.conditions {
+		var value string
+		switch c.field {
+		case "namespace":
+			value = namespace
+		case "entity":
+			value = entity
+		case "check":
+			value = check
+		default:
+			value = eventLabel(event, strings.TrimPrefix(c.field, "label."))
+		}
+		if ok, _ := path.Match(c.pattern, value); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// eventLabel returns a label of the check, or else of the entity.
+func eventLabel(event *types.Event, name string) string {
+	if event.Check != nil {
+		if value, ok := event.Check.Labels[name]; ok {
+			return value
+		}
+	}
+	if event.Entity != nil {
+		return event.Entity.Labels[name]
+	}
+	return ""
+}
+
+// eventAnnotation returns the value of the annotation of the handler
+// keyspace named name, from the check of event or else from its entity.
+func eventAnnotation(event *types.Event, name string) (string, bool) {
+	key := plugin.Keyspace + "/" + name
+	if event.Check != nil {
+		if value, ok := event.Check.Annotations[key]; ok {
+			return value, true
+		}
+	}
+	if event.Entity != nil {
+		value, ok := event.Entity.Annotations[key]
+		return value, ok
+	}
+	return "", false
+}
+
+// routingExporter sends every batch to the exporters of the first route
+// its event matches. Batches matching no route go to all exporters.
+type routingExporter struct {
+	routes  []route
+	targets []*exporter.Fanout
+	all     *exporter.Fanout
+}
+
+// newRoutingExporter creates the exporters registered as names, once each,
+// and routes batches to them with the routing table in value. Routes may
+// only name exporters of names.
+func newRoutingExporter(names []string, value string) (Exporter, error) {
+	routes, err := parseRoutes(value)
+	if err != nil {
+		return nil, err
+	}
+	all := &exporter.Fanout{}
+	byName := map[string]Exporter{}
+	for _, name := range names {
+		e, err := newExporter(name)
+		if err != nil {
+			_ = all.Shutdown(context.Background())
+			return nil, err
+		}
+		byName[name] = e
+		all.Add(destination(name, e), e)
+	}
+	r := &routingExporter{routes: routes, all: all}
+	for _, route := range routes {
+		target := &exporter.Fanout{}
+		for _, name := range route.exporters {
+			e, ok := byName[name]
+			if !ok {
+				_ = all.Shutdown(context.Background())
+				return nil, fmt.Errorf("route to %q, which is not selected with --exporter", name)
+			}
+			target.Add(destination(name, e), e)
+		}
+		r.targets = append(r.targets, target)
+	}
+	return r, nil
+}
+
+func (r *routingExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return r.target(batch).Export(ctx, res, batch)
+}
+
+// ExportResources sends the batches of every route together.
+func (r *routingExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	return exportRouted(ctx, batches, r.target)
+}
+
+// target returns the exporters of the first route matching the event of
+// batch, all of them if none matches.
+func (r *routingExporter) target(batch *Batch) Exporter {
+	if len(batch.Events) > 0 {
+		for i := range r.routes {
+			if r.routes[i].matches(batch.Events[0]) {
+				return r.targets[i]
+			}
+		}
+	}
+	return r.all
+}
+
+// Shutdown shuts every exporter down once; the routes share them.
+func (r *routingExporter) Shutdown(ctx context.Context) error {
+	return r.all.Shutdown(ctx)
+}
+
+func (r *routingExporter) String() string {
+	return "routed to " + r.all.String()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestRoutes(t *testing.T) {
+	routes, err := parseRoutes("namespace=prod-* => otlp; namespace=lab-*,label.team=db => otlphttp,file; * => file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "disk")
+	event.Entity.Labels = map[string]string{"team": "db"}
+	for namespace, want := range map[string]int{"prod-eu": 0, "lab-1": 1, "default": 2} {
+		event.Namespace = namespace
+		for i := range routes {
+			if routes[i].matches(event) {
+				if i != want {
+					t.Errorf("%s: matched route %d, want %d", namespace, i, want)
+				}
+				break
+			}
+		}
+	}
+
+	for _, invalid := range []string{"namespace=prod", "host=web01 => otlp", "check=[ => otlp", "* =>"} {
+		if _, err := parseRoutes(invalid); err == nil {
+			t.Errorf("parseRoutes(%q) succeeded, want error", invalid)
+		}
+	}
+}
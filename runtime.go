@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// runtimeConfig holds the filters, rename rules and sinks changed at
+// runtime through the admin API, so operators can react to cardinality
+// incidents without redeploying. It is saved to --runtime-config after
+// every change and loaded from it at startup.
+type runtimeConfig struct {
+	Filters []filterRule `json:"filters"`
+	Renames []renameRule `json:"renames"`
+	// Sinks replaces the exporters of --exporter when not empty.
+	Sinks []string `json:"sinks"`
+}
+
+// filterRule drops the metric points whose name matches the glob pattern
+// Metric, from the checks matching Check or from every check.
+type filterRule struct {
+	Metric string `json:"metric"`
+	Check  string `json:"check,omitempty"`
+}
+
+// renameRule renames the metric points named Metric, or the tag named Tag
+// of every point, to To. Tags renamed to "" are dropped.
+type renameRule struct {
+	Metric string `json:"metric,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	To     string `json:"to"`
+}
+
+func (c *runtimeConfig) validate() error {
+	for _, f := range c.Filters {
+		if f.Metric == "" {
+			return fmt.Errorf("filter %+v: metric required", f)
+		}
+		for _, pattern := range []string{f.Metric, f.Check} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("filter %+v: invalid pattern %q: %v", f, pattern, err)
+			}
+		}
+	}
+	for _, r := range c.Renames {
+		if (r.Metric == "") == (r.Tag == "") {
+			return fmt.Errorf("rename %+v: exactly one of metric and tag required", r)
+		}
+		if r.Metric != "" && r.To == "" {
+			return fmt.Errorf("rename %+v: metrics cannot be renamed to an empty name, filter them instead", r)
+		}
+	}
+	for _, name := range c.Sinks {
+		if _, ok := exporterFactories[name]; !ok {
+			return fmt.Errorf("unknown exporter %q, expected one of %s", name, strings.Join(exporterNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// runtimeState is the current runtime configuration and the file it is
+// saved to.
+type runtimeState struct {
+	path string
+
+	// updating serializes the updates, which build exporters and write
+	// the file without blocking the events.
+	updating sync.Mutex
+
+	sync.RWMutex
+	config runtimeConfig
+}
+
+// loadRuntimeConfig reads the runtime configuration saved to file, which
+// may not exist yet.
+func loadRuntimeConfig(file string) (*runtimeState, error) {
+	r := &runtimeState{path: file}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.config); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if err := r.config.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return r, nil
+}
+
+// save writes c to the file, replacing it atomically.
+func (r *runtimeState) save(c *runtimeConfig) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// current returns a copy of the runtime configuration.
+func (r *runtimeState) current() runtimeConfig {
+	r.RLock()
+	defer r.RUnlock()
+	return runtimeConfig{
+		Filters: append([]filterRule{}, r.config.Filters...),
+		Renames: append([]renameRule{}, r.config.Renames...),
+		Sinks:   append([]string{}, r.config.Sinks...),
+	}
+}
+
+// filterPoints removes the metric points of event matched by a filter and
+// returns how many were removed.
+func (r *runtimeState) filterPoints(event *types.Event) int {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.config.Filters) == 0 || event.Metrics == nil {
+		return 0
+	}
+	_, _, check := converter.EventNames(event)
+	kept := event.Metrics.Points[:0]
+	for _, p := range event.Metrics.Points {
+		if !matchFilters(r.config.Filters, check, p.Name) {
+			kept = append(kept, p)
+		}
+	}
+	removed := len(event.Metrics.Points) - len(kept)
+	event.Metrics.Points = kept
+	return removed
+}
+
+// matchFilters reports whether a filter drops the metric of the check.
+func matchFilters(filters []filterRule, check, metric string) bool {
+	for _, f := range filters {
+		if ok, _ := path.Match(f.Metric, metric); !ok {
+			continue
+		}
+		if ok, _ := path.Match(f.Check, check); f.Check == "" || ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rename applies the rename rules to the metric points of event.
+func (r *runtimeState) rename(event *types.Event) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.config.Renames) == 0 || event.Metrics == nil {
+		return
+	}
+	for _, p := range event.Metrics.Points {
+		for _, rule := range r.config.Renames {
+			if rule.Metric != "" && p.Name == rule.Metric {
+				p.Name = rule.To
+			}
+			if rule.Tag == "" {
+				continue
+			}
+			tags := p.Tags[:0]
+			for _, t := range p.Tags {
+				if t.Name == rule.Tag {
+					if rule.To == "" {
+						continue
+					}
+					t.Name = rule.To
+				}
+				tags = append(tags, t)
+			}
+			p.Tags = tags
+		}
+	}
+}
+
+// runtimeExporter sends the batches to the exporters of the sinks, which
+// the admin API swaps.
+type runtimeExporter struct {
+	sync.RWMutex
+	exporter Exporter
+}
+
+// Export holds the exporters until the batch is sent so that they are not
+// shut down in the middle of it.
+func (e *runtimeExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	e.RLock()
+	defer e.RUnlock()
+	return e.exporter.Export(ctx, res, batch)
+}
+
+func (e *runtimeExporter) Shutdown(ctx context.Context) error {
+	e.RLock()
+	defer e.RUnlock()
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e *runtimeExporter) String() string {
+	e.RLock()
+	defer e.RUnlock()
+	return destination("", e.exporter)
+}
+
+// swap replaces the exporters and returns the previous ones.
+func (e *runtimeExporter) swap(exporter Exporter) Exporter {
+	e.Lock()
+	defer e.Unlock()
+	old := e.exporter
+	e.exporter = exporter
+	return old
+}
+
+// updateRuntimeConfig applies change to a copy of the runtime
+// configuration, creates the exporters of its sinks if they changed and
+// saves it before making it current. The status code tells whether the
+// error is caused by the change.
+func (ot *otelPlugin) updateRuntimeConfig(change func(*runtimeConfig) error) (int, error) {
+	r := ot.runtime
+	r.updating.Lock()
+	defer r.updating.Unlock()
+
+	c := r.current()
+	if err := change(&c); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := c.validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	var sinks Exporter
+	if strings.Join(c.Sinks, ",") != strings.Join(r.current().Sinks, ",") {
+		names := c.Sinks
+		if len(names) == 0 {
+			names = splitList(plugin.Exporter)
+		}
+		var err error
+		if sinks, err = newConfiguredExporter(names); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("could not create sinks: %v", err)
+		}
+	}
+	if err := r.save(&c); err != nil {
+		if sinks != nil {
+			_ = sinks.Shutdown(context.Background())
+		}
+		return http.StatusInternalServerError, fmt.Errorf("could not save %s: %v", r.path, err)
+	}
+	r.Lock()
+	r.config = c
+	r.Unlock()
+	if sinks != nil {
+		old := ot.exporter.(*runtimeExporter).swap(sinks)
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := old.Shutdown(ctx); err != nil {
+			errorLog.Printf("could not shut down the previous sinks %s: %v", destination("", old), err)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// serveRuntimeConfig serves the runtime configuration on /pipeline: GET
+// returns it and PUT replaces it. POST adds a filter, rename rule or sink,
+// sent as the body, to /pipeline/filters, /pipeline/renames or
+// /pipeline/sinks and DELETE removes it.
+//
+//	$ curl -X POST -d '{"metric": "disk.inode.*", "check": "disk"}' http://localhost:55788/pipeline/filters
+func (ot *otelPlugin) serveRuntimeConfig(w http.ResponseWriter, req *http.Request) {
+	section := strings.TrimPrefix(req.URL.Path, "/pipeline")
+	if req.Method == http.MethodGet && section == "" {
+		w.Header().Set("Content-Type", "application/json")
+		c := ot.runtime.current()
+		_ = json.NewEncoder(w).Encode(&c)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 1024*1024))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var change func(*runtimeConfig) error
+	switch {
+	case section == "" && req.Method == http.MethodPut:
+		change = func(c *runtimeConfig) error {
+			*c = runtimeConfig{}
+			return json.Unmarshal(body, c)
+		}
+	case section == "/filters" && (req.Method == http.MethodPost || req.Method == http.MethodDelete):
+		change = func(c *runtimeConfig) error {
+			var f filterRule
+			if err := json.Unmarshal(body, &f); err != nil {
+				return err
+			}
+			if req.Method == http.MethodPost {
+				c.Filters = append(c.Filters, f)
+				return nil
+			}
+			kept := c.Filters[:0]
+			for _, existing := range c.Filters {
+				if existing != f {
+					kept = append(kept, existing)
+				}
+			}
+			if len(kept) == len(c.Filters) {
+				return fmt.Errorf("no filter %+v", f)
+			}
+			c.Filters = kept
+			return nil
+		}
+	case section == "/renames" && (req.Method == http.MethodPost || req.Method == http.MethodDelete):
+		change = func(c *runtimeConfig) error {
+			var r renameRule
+			if err := json.Unmarshal(body, &r); err != nil {
+				return err
+			}
+			if req.Method == http.MethodPost {
+				c.Renames = append(c.Renames, r)
+				return nil
+			}
+			kept := c.Renames[:0]
+			for _, existing := range c.Renames {
+				if existing != r {
+					kept = append(kept, existing)
+				}
+			}
+			if len(kept) == len(c.Renames) {
+				return fmt.Errorf("no rename rule %+v", r)
+			}
+			c.Renames = kept
+			return nil
+		}
+	case section == "/sinks" && (req.Method == http.MethodPost || req.Method == http.MethodDelete):
+		change = func(c *runtimeConfig) error {
+			var name string
+			if err := json.Unmarshal(body, &name); err != nil {
+				return fmt.Errorf("expected the exporter name as a JSON string: %v", err)
+			}
+			if len(c.Sinks) == 0 {
+				c.Sinks = splitList(plugin.Exporter)
+			}
+			kept := c.Sinks[:0]
+			for _, existing := range c.Sinks {
+				if existing != name {
+					kept = append(kept, existing)
+				}
+			}
+			if req.Method == http.MethodPost {
+				if len(kept) < len(c.Sinks) {
+					return fmt.Errorf("sink %q already exported to", name)
+				}
+				c.Sinks = append(kept, name)
+				return nil
+			}
+			if len(kept) == len(c.Sinks) {
+				return fmt.Errorf("no sink %q", name)
+			}
+			if len(kept) == 0 {
+				return fmt.Errorf("cannot remove the last sink")
+			}
+			c.Sinks = kept
+			return nil
+		}
+	default:
+		http.Error(w, fmt.Sprintf("%s not supported on %s", req.Method, req.URL.Path), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if code, err := ot.updateRuntimeConfig(change); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	log.Printf("runtime configuration changed: %s %s %s", req.Method, req.URL.Path, strings.TrimSpace(string(body)))
+	w.Header().Set("Content-Type", "application/json")
+	c := ot.runtime.current()
+	_ = json.NewEncoder(w).Encode(&c)
+}
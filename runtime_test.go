@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestRuntimeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "runtime.json")
+
+	r, err := loadRuntimeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ot := &otelPlugin{runtime: r}
+	for _, change := range []struct{ method, path, body string }{
+		{http.MethodPost, "/pipeline/filters", `{"metric": "disk.inode.*", "check": "disk"}`},
+		{http.MethodPost, "/pipeline/renames", `{"metric": "disk.used", "to": "disk.used_bytes"}`},
+		{http.MethodPost, "/pipeline/renames", `{"tag": "container_id", "to": ""}`},
+	} {
+		w := httptest.NewRecorder()
+		ot.serveRuntimeConfig(w, httptest.NewRequest(change.method, change.path, strings.NewReader(change.body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d %s", change.method, change.path, w.Code, w.Body)
+		}
+	}
+	w := httptest.NewRecorder()
+	ot.serveRuntimeConfig(w, httptest.NewRequest(http.MethodPost, "/pipeline/renames", strings.NewReader(`{"metric": "disk.used", "to": ""}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid rename rule: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// The changes are saved and loaded again.
+	r, err = loadRuntimeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.config.Filters) != 1 || len(r.config.Renames) != 2 {
+		t.Fatalf("loaded %+v", r.config)
+	}
+
+	event := corev2.FixtureEvent("web01", "disk")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "disk.inode.used", Value: 1},
+		{Name: "disk.used", Value: 2, Tags: []*corev2.MetricTag{{Name: "container_id", Value: "c1"}, {Name: "mount", Value: "/"}}},
+	}}
+	if n := r.filterPoints(event); n != 1 {
+		t.Errorf("filtered %d points, want 1", n)
+	}
+	r.rename(event)
+	if len(event.Metrics.Points) != 1 {
+		t.Fatalf("got %d points, want 1", len(event.Metrics.Points))
+	}
+	p := event.Metrics.Points[0]
+	if p.Name != "disk.used_bytes" || len(p.Tags) != 1 || p.Tags[0].Name != "mount" {
+		t.Errorf("got point %v", p)
+	}
+
+	w = httptest.NewRecorder()
+	ot = &otelPlugin{runtime: r}
+	ot.serveRuntimeConfig(w, httptest.NewRequest(http.MethodDelete, "/pipeline/filters", strings.NewReader(`{"metric": "disk.inode.*", "check": "disk"}`)))
+	if w.Code != http.StatusOK || len(r.current().Filters) != 0 {
+		t.Errorf("filter not removed: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// scaleRule multiplies the values of the metric points whose name matches
+// the glob pattern by factor, and sets the unit of their metrics.
+type scaleRule struct {
+	pattern string
+	factor  float64
+	unit    string
+}
+
+// parseScaleRules parses the comma separated rules of --metric-scale, each
+// a glob, a factor, or a divisor prefixed with a slash, and an optional
+// unit, such as
+//
+//	*.latency_ms=/1000 s, disk.*_kb=1024 By
+//
+// The first rule matching a metric name applies.
+func parseScaleRules(value string) ([]scaleRule, error) {
+	var rules []scaleRule
+	for _, item := range splitList(value) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rule %q, expected glob=factor [unit]", item)
+		}
+		r := scaleRule{pattern: strings.TrimSpace(kv[0])}
+		if _, err := path.Match(r.pattern, ""); err != nil || r.pattern == "" {
+			return nil, fmt.Errorf("invalid rule %q: invalid pattern %q", item, r.pattern)
+		}
+		fields := strings.Fields(kv[1])
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid rule %q, expected glob=factor [unit]", item)
+		}
+		number, divide := strings.TrimPrefix(fields[0], "/"), strings.HasPrefix(fields[0], "/")
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil || f == 0 {
+			return nil, fmt.Errorf("invalid rule %q: invalid factor %q", item, fields[0])
+		}
+		if r.factor = f; divide {
+			r.factor = 1 / f
+		}
+		if len(fields) == 2 {
+			r.unit = fields[1]
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// matchScaleRule returns the rule applying to the metric name, or nil.
+func matchScaleRule(rules []scaleRule, name string) *scaleRule {
+	for i := range rules {
+		if ok, _ := path.Match(rules[i].pattern, name); ok {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// scalePoints scales the values of the metric points of event.
+func scalePoints(event *types.Event, rules []scaleRule) {
+	if event.Metrics == nil {
+		return
+	}
+	for _, p := range event.Metrics.Points {
+		if r := matchScaleRule(rules, p.Name); r != nil {
+			p.Value *= r.factor
+		}
+	}
+}
+
+// setUnits sets the unit of the converted metrics the rules name one for.
+func setUnits(metrics []*metricpb.Metric, rules []scaleRule) {
+	for _, m := range metrics {
+		if r := matchScaleRule(rules, m.Name); r != nil && r.unit != "" {
+			m.Unit = r.unit
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestScaleRules(t *testing.T) {
+	rules, err := parseScaleRules("*.latency_ms=/1000 s, disk.*_kb=1024 By, cpu.*=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "app")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "http.latency_ms", Value: 250},
+		{Name: "disk.free_kb", Value: 2},
+		{Name: "mem.used", Value: 7},
+	}}
+	scalePoints(event, rules)
+	for i, want := range []float64{0.25, 2048, 7} {
+		if got := event.Metrics.Points[i].Value; got != want {
+			t.Errorf("%s: got %v, want %v", event.Metrics.Points[i].Name, got, want)
+		}
+	}
+
+	metrics := []*metricpb.Metric{{Name: "http.latency_ms"}, {Name: "cpu.idle"}, {Name: "mem.used"}}
+	setUnits(metrics, rules)
+	for i, want := range []string{"s", "", ""} {
+		if metrics[i].Unit != want {
+			t.Errorf("%s: got unit %q, want %q", metrics[i].Name, metrics[i].Unit, want)
+		}
+	}
+
+	for _, bad := range []string{"disk.*", "[=2", "a=0", "a=x", "a=2 By extra"} {
+		if _, err := parseScaleRules(bad); err == nil {
+			t.Errorf("parseScaleRules(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/config"
+)
+
+// configSchema returns the JSON Schema of the configuration: one property
+// per option, named after its argument. The config-bundle options are left
+// out as a configuration cannot set them.
+func configSchema() *config.Schema {
+	versions := make([]string, 0, len(tlsVersions))
+	for v := range tlsVersions {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	enums := map[string][]string{
+		"auth":            authProviderNames(),
+		"tls-min-version": versions,
+	}
+
+	closed := false
+	s := &config.Schema{
+		Schema:               config.SchemaVersion,
+		Title:                plugin.Name,
+		Description:          plugin.Short,
+		Type:                 "object",
+		Properties:           make(map[string]*config.Schema, len(options)),
+		AdditionalProperties: &closed,
+	}
+	for _, opt := range options {
+		if strings.HasPrefix(opt.Argument, "config-bundle") {
+			continue
+		}
+		prop := &config.Schema{
+			Description: opt.Usage,
+			Default:     opt.Default,
+			Enum:        enums[opt.Argument],
+			WriteOnly:   isSecretOption(opt.Argument),
+			Env:         opt.Env,
+		}
+		switch opt.Value.(type) {
+		case *string:
+			prop.Type = "string"
+		case *bool:
+			prop.Type = "boolean"
+		case *int64:
+			prop.Type = "integer"
+		}
+		s.Properties[opt.Argument] = prop
+	}
+	return s
+}
+
+// runSchema writes the JSON Schema of the configuration on stdout or, with
+// --validate, checks a configuration file against it and with the checks
+// the handler runs at startup.
+//
+//	$ ./otel-sensu-handler-plugin schema > schema.json
+//	$ ./otel-sensu-handler-plugin schema --validate config.json
+func runSchema(args []string) error {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	validate := flags.String("validate", "", "configuration file, a JSON object of option names and values, to validate instead of writing the schema")
+	_ = flags.Parse(args)
+
+	s := configSchema()
+	if *validate == "" {
+		out, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		return err
+	}
+
+	data, err := ioutil.ReadFile(*validate)
+	if err != nil {
+		return err
+	}
+	if err := s.ValidateJSON(data); err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if err := parseOptions(nil); err != nil {
+		return fmt.Errorf("invalid environment: %v", err)
+	}
+	for name, value := range values {
+		if err := setBundleOption(name, value); err != nil {
+			return err
+		}
+	}
+	if err := validateConfig(); err != nil {
+		return err
+	}
+	fmt.Printf("%s: valid configuration\n", *validate)
+	return nil
+}
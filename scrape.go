@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	// The prometheus exporter doesn't push anywhere: converted metrics are
+	// only served on /metrics, which is enabled along with it.
+	RegisterExporter("prometheus", func() (Exporter, error) {
+		return discardExporter{}, nil
+	})
+}
+
+// discardExporter drops every batch.
+type discardExporter struct{}
+
+func (discardExporter) Export(context.Context, *resourcepb.Resource, *Batch) error { return nil }
+func (discardExporter) Shutdown(context.Context) error                             { return nil }
+
+// scrapeSample is the last value of a series, when it was set and the
+// entity it came from.
+type scrapeSample struct {
+	value   float64
+	updated time.Time
+	entity  string
+}
+
+// scrapeStore retains the last value of every converted series for
+// Prometheus to scrape. Series not updated within the staleness period are
+// dropped, so that checks and entities that went away disappear.
+type scrapeStore struct {
+	sync.Mutex
+	staleness time.Duration
+	series    map[string]map[string]scrapeSample
+}
+
+func newScrapeStore(staleness time.Duration) *scrapeStore {
+	return &scrapeStore{staleness: staleness, series: map[string]map[string]scrapeSample{}}
+}
+
+// Add records the gauges converted from event. The entity becomes the
+// sensu_entity_name label, so series of different entities don't collide.
+func (s *scrapeStore) Add(event *types.Event, metrics []*metricpb.Metric, now time.Time) {
+	_, entity, _ := converter.EventNames(event)
+	s.Lock()
+	defer s.Unlock()
+	for _, m := range metrics {
+		gauge := m.GetGauge()
+		if gauge == nil {
+			continue
+		}
+		name := promName(m.Name)
+		family, ok := s.series[name]
+		if !ok {
+			family = map[string]scrapeSample{}
+			s.series[name] = family
+		}
+		for _, p := range gauge.DataPoints {
+			attrs := append([]*commonpb.KeyValue{converter.StringAttribute("sensu.entity.name", entity)}, p.Attributes...)
+			family[promLabels(attrs)] = scrapeSample{value: pointValue(p), updated: now, entity: entity}
+		}
+	}
+}
+
+// Remove forgets the gauges converted from event, as Add records them.
+func (s *scrapeStore) Remove(event *types.Event, metrics []*metricpb.Metric) {
+	_, entity, _ := converter.EventNames(event)
+	s.Lock()
+	defer s.Unlock()
+	for _, m := range metrics {
+		family, ok := s.series[promName(m.Name)]
+		if !ok || m.GetGauge() == nil {
+			continue
+		}
+		for _, p := range m.GetGauge().DataPoints {
+			attrs := append([]*commonpb.KeyValue{converter.StringAttribute("sensu.entity.name", entity)}, p.Attributes...)
+			delete(family, promLabels(attrs))
+		}
+		if len(family) == 0 {
+			delete(s.series, promName(m.Name))
+		}
+	}
+}
+
+// ForgetEntity forgets the series of entity.
+func (s *scrapeStore) ForgetEntity(entity string) {
+	s.Lock()
+	defer s.Unlock()
+	for name, family := range s.series {
+		for labels, sample := range family {
+			if sample.entity == entity {
+				delete(family, labels)
+			}
+		}
+		if len(family) == 0 {
+			delete(s.series, name)
+		}
+	}
+}
+
+// families returns the series updated within the staleness period and
+// forgets the others.
+func (s *scrapeStore) families(now time.Time) promFamilies {
+	s.Lock()
+	defer s.Unlock()
+	families := promFamilies{}
+	for name, family := range s.series {
+		for labels, sample := range family {
+			if s.staleness > 0 && now.Sub(sample.updated) > s.staleness {
+				delete(family, labels)
+				continue
+			}
+			if families[name] == nil {
+				families[name] = map[string]float64{}
+			}
+			families[name][labels] = sample.value
+		}
+		if len(family) == 0 {
+			delete(s.series, name)
+		}
+	}
+	return families
+}
+
+// serveMetrics exposes the retained series, in the OpenMetrics format when
+// the scraper accepts it and in the Prometheus text format otherwise.
+//
+//	$ curl localhost:55788/metrics
+func (ot *otelPlugin) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	if err := ot.scrape.families(time.Now()).writeTo(w); err != nil {
+		errorLog.Printf("could not write metrics: %v", err)
+		return
+	}
+	if openMetrics {
+		_, _ = w.Write([]byte("# EOF\n"))
+	}
+}
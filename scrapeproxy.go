@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// scrapeProxyMaxBody is the largest Prometheus exposition read from a
+// target.
+const scrapeProxyMaxBody = 16 * 1024 * 1024
+
+// scrapeTarget is a Prometheus endpoint of --scrape-proxy-targets and the
+// check its samples are attributed to.
+type scrapeTarget struct {
+	check string
+	url   string
+}
+
+// parseScrapeTargets parses the comma separated [check=]url targets of
+// --scrape-proxy-targets, such as
+//
+//	node=http://localhost:9100/metrics, http://localhost:9187/metrics
+//
+// Without a check name the check is named after the host and port of the
+// target, prometheus_localhost_9187.
+func parseScrapeTargets(value string) ([]scrapeTarget, error) {
+	var targets []scrapeTarget
+	for _, item := range splitList(value) {
+		t := scrapeTarget{url: item}
+		if i := strings.Index(item, "="); i > 0 && !strings.Contains(item[:i], "/") {
+			t.check, t.url = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		}
+		u, err := url.Parse(t.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid target %q, expected [check=]http(s)://host:port/path", item)
+		}
+		if t.check == "" {
+			t.check = "prometheus_" + strings.NewReplacer(":", "_", ".", "_").Replace(u.Host)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// runScrapeProxy scrapes the targets every interval and handles their
+// samples as the events of checks of the handler host. It never returns.
+func (ot *otelPlugin) runScrapeProxy(targets []scrapeTarget, interval time.Duration) {
+	hostname, _ := os.Hostname()
+	client := &http.Client{Timeout: interval}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := time.Now(); ; now = <-ticker.C {
+		for _, t := range targets {
+			event := scrapeEvent(client, t, hostname, interval, now)
+			if _, err := ot.receiveEvent(context.Background(), event, false); err != nil {
+				errorLog.Printf("could not export the samples of %s: %v", t.url, err)
+			}
+		}
+	}
+}
+
+// scrapeEvent scrapes a target into the event of its check: the exposition
+// is the output of the check, parsed by the decode stage, or the check is
+// critical with the error as output when the scrape fails.
+func scrapeEvent(client *http.Client, t scrapeTarget, hostname string, interval time.Duration, now time.Time) *types.Event {
+	namespace := plugin.ScrapeProxyNamespace
+	event := &types.Event{
+		ObjectMeta: types.ObjectMeta{Namespace: namespace},
+		Timestamp:  now.Unix(),
+		Entity: &types.Entity{
+			ObjectMeta:  types.ObjectMeta{Name: hostname, Namespace: namespace},
+			EntityClass: "proxy",
+		},
+		Check: &types.Check{
+			ObjectMeta: types.ObjectMeta{Name: t.check, Namespace: namespace},
+			Interval:   uint32(interval / time.Second),
+			Executed:   now.Unix(),
+		},
+	}
+	body, err := scrape(client, t.url)
+	if err != nil {
+		event.Check.Status = 2
+		event.Check.Output = fmt.Sprintf("scrape of %s failed: %v", t.url, err)
+		return event
+	}
+	event.Check.Output = body
+	event.Check.OutputMetricFormat = "prometheus_text"
+	return event
+}
+
+func scrape(client *http.Client, target string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, scrapeProxyMaxBody))
+	return string(body), err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+func TestScrapeProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/metrics" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprintln(w, "# TYPE node_load1 gauge")
+		fmt.Fprintln(w, `node_load1{cpu="all"} 0.5`)
+	}))
+	defer srv.Close()
+
+	targets, err := parseScrapeTargets("node=" + srv.URL + "/metrics, " + srv.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	event := scrapeEvent(srv.Client(), targets[0], "edge01", time.Minute, now)
+	if err := converter.ParseOutputMetrics(event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Check.Name != "node" || event.Entity.Name != "edge01" || event.Check.Interval != 60 {
+		t.Errorf("got check %s of %s every %ds", event.Check.Name, event.Entity.Name, event.Check.Interval)
+	}
+	if converter.EventPoints(event) != 1 || event.Metrics.Points[0].Name != "node_load1" {
+		t.Errorf("got metrics %v, want node_load1", event.Metrics)
+	}
+
+	event = scrapeEvent(srv.Client(), targets[1], "edge01", time.Minute, now)
+	if event.Check.Status != 2 || event.Check.OutputMetricFormat != "" {
+		t.Errorf("failed scrape: got status %d, format %q", event.Check.Status, event.Check.OutputMetricFormat)
+	}
+	if want := "prometheus_127_0_0_1_"; len(targets[1].check) <= len(want) || targets[1].check[:len(want)] != want {
+		t.Errorf("got check name %q, want %s<port>", targets[1].check, want)
+	}
+
+	for _, bad := range []string{"localhost:9100", "node=ftp://host/metrics", "http://"} {
+		if _, err := parseScrapeTargets(bad); err == nil {
+			t.Errorf("parseScrapeTargets(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// selfCheckName is the check name of the events carrying the handler's own
+// telemetry.
+const selfCheckName = "otel-sensu-handler"
+
+var startTime = time.Now()
+
+// runSelfTelemetry periodically exports the handler's own metrics through
+// the same pipeline as the Sensu events it receives. It never returns.
+func (ot *otelPlugin) runSelfTelemetry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ot.eventToOtel(selfEvent(ot.selfMetricPoints(time.Now()))); err != nil {
+			errorLog.Printf("could not export self telemetry: %v", err)
+		}
+	}
+}
+
+// runHeartbeat exports sensu.otel.handler.heartbeat every interval, for
+// backends to alert on its absence when the handler is down. It never
+// returns.
+func (ot *otelPlugin) runHeartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if !ot.leading() {
+			continue
+		}
+		if err := ot.eventToOtel(heartbeatEvent(now, interval)); err != nil {
+			errorLog.Printf("could not export heartbeat: %v", err)
+		}
+	}
+}
+
+// heartbeatEvent is the event of a heartbeat, whose check interval lets
+// the absence of the next ones be detected.
+func heartbeatEvent(now time.Time, interval time.Duration) *types.Event {
+	event := selfEvent([]*types.MetricPoint{selfPoint("sensu.otel.handler.heartbeat", 1, now.UnixNano())})
+	event.Check.Interval = uint32(interval / time.Second)
+	return event
+}
+
+// selfMetricPoints collects the current value of every self metric.
+func (ot *otelPlugin) selfMetricPoints(now time.Time) []*types.MetricPoint {
+	points := runtimeMetricPoints(now)
+	points = append(points, selfPoint("sensu.otel.handler.crashes", float64(atomic.LoadInt64(&crashes)), now.UnixNano()))
+	points = append(points, ot.stats.metricPoints(now)...)
+	points = append(points, ot.entities.metricPoints(now)...)
+	points = append(points, exporterHealthPoints(now)...)
+	if ot.slo != nil {
+		points = append(points, ot.slo.metricPoints(now)...)
+	}
+	if ot.canary != nil {
+		points = append(points, ot.canary.metricPoints(now)...)
+	}
+	if ot.queue != nil {
+		points = append(points, ot.queue.metricPoints(now)...)
+	}
+	return points
+}
+
+// runtimeMetricPoints reports process uptime, goroutines, heap and garbage
+// collector statistics.
+func runtimeMetricPoints(now time.Time) []*types.MetricPoint {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	ts := now.UnixNano()
+	points := []*types.MetricPoint{
+		selfPoint("sensu.otel.handler.uptime", now.Sub(startTime).Seconds(), ts),
+		selfPoint("runtime.go.goroutines", float64(runtime.NumGoroutine()), ts),
+		selfPoint("runtime.go.mem.heap_alloc", float64(ms.HeapAlloc), ts),
+		selfPoint("runtime.go.mem.heap_inuse", float64(ms.HeapInuse), ts),
+		selfPoint("runtime.go.mem.heap_objects", float64(ms.HeapObjects), ts),
+		selfPoint("runtime.go.mem.heap_sys", float64(ms.HeapSys), ts),
+		selfPoint("runtime.go.gc.count", float64(ms.NumGC), ts),
+		selfPoint("runtime.go.gc.pause_total_ns", float64(ms.PauseTotalNs), ts),
+	}
+	if ms.NumGC > 0 {
+		points = append(points, selfPoint("runtime.go.gc.pause_ns", float64(ms.PauseNs[(ms.NumGC+255)%256]), ts))
+	}
+	return points
+}
+
+func selfPoint(name string, value float64, ts int64, tags ...*types.MetricTag) *types.MetricPoint {
+	return &types.MetricPoint{
+		Name:      name,
+		Value:     value,
+		Timestamp: ts,
+		Tags:      tags,
+	}
+}
+
+// selfEvent wraps self metrics into an event attributed to the handler host.
+func selfEvent(points []*types.MetricPoint) *types.Event {
+	hostname, _ := os.Hostname()
+	return &types.Event{
+		Timestamp: time.Now().Unix(),
+		Entity: &types.Entity{
+			ObjectMeta:  types.ObjectMeta{Name: hostname},
+			EntityClass: "proxy",
+		},
+		Check: &types.Check{
+			ObjectMeta: types.ObjectMeta{Name: selfCheckName},
+		},
+		Metrics: &types.Metrics{Points: points},
+	}
+}
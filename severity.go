@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// checkStatuses names the check statuses in --log-severity-map.
+var checkStatuses = map[string]uint32{"ok": 0, "warning": 1, "critical": 2, "unknown": 3}
+
+// parseSeverityMapping parses the severities of the logs: the comma
+// separated status=severity pairs of --log-severity-map, such as
+//
+//	warning=warn, critical=error, unknown=warn
+//
+// and the regexp => severity overrides of --log-severity-overrides,
+// separated by semicolons, such as
+//
+//	out of memory|OOM => fatal; (?i)deprecated => info
+//
+// It returns nil when both are empty.
+func parseSeverityMapping(statusMap, overrides string) (*converter.SeverityMapping, error) {
+	if strings.TrimSpace(statusMap) == "" && strings.TrimSpace(overrides) == "" {
+		return nil, nil
+	}
+	m := &converter.SeverityMapping{Status: map[uint32]logspb.SeverityNumber{}}
+	for _, item := range splitList(statusMap) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid mapping %q, expected status=severity", item)
+		}
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		status, ok := checkStatuses[name]
+		if !ok {
+			n, err := strconv.ParseUint(name, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid status %q, expected ok, warning, critical, unknown or a number", kv[0])
+			}
+			status = uint32(n)
+		}
+		severity, err := converter.ParseSeverity(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		m.Status[status] = severity
+	}
+	for _, item := range strings.Split(overrides, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=>")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid override %q, expected regexp => severity", item)
+		}
+		pattern, err := regexp.Compile(strings.TrimSpace(item[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %q: %v", item, err)
+		}
+		severity, err := converter.ParseSeverity(item[i+2:])
+		if err != nil {
+			return nil, err
+		}
+		m.Overrides = append(m.Overrides, converter.SeverityOverride{Pattern: pattern, Severity: severity})
+	}
+	return m, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// Telemetry signals the handler can generate from an event.
+const (
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+	signalTraces  = "traces"
+	// signalChecks generates the sensu.check.* gauges from the check
+	// result, also for checks without metrics.
+	signalChecks = "checks"
+	// signalEvents passes every event to the exporters, also those without
+	// other telemetry, for sinks that store or forward the events
+	// themselves.
+	signalEvents = "events"
+)
+
+// parseSignals parses a comma separated list of signals.
+func parseSignals(value string) (map[string]bool, error) {
+	signals := map[string]bool{}
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		switch s {
+		case signalMetrics, signalLogs, signalTraces, signalChecks, signalEvents:
+			signals[s] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown signal %q", s)
+		}
+	}
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("no signal selected")
+	}
+	return signals, nil
+}
+
+// eventSignals returns the signals of the signals annotation of the check
+// or entity of event, such as
+//
+//	sensu.io/plugins/otel-sensu-handler-plugin/config/signals: "logs"
+//
+// or nil without one. The handler applies the annotation to --signals
+// itself; the server, which handles the events of every check, reads it
+// for every event.
+func eventSignals(event *types.Event) map[string]bool {
+	value, ok := eventAnnotation(event, "signals")
+	if !ok {
+		return nil
+	}
+	signals, err := parseSignals(value)
+	if err != nil {
+		errorLog.Printf("ignoring signals annotation %q: %v", value, err)
+		return nil
+	}
+	return signals
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// Actions on the metric points whose timestamp is outside of the
+// --clock-skew-window of the handler clock.
+const (
+	skewClamp  = "clamp"
+	skewReject = "reject"
+)
+
+// clockSkew guards against the points of agents with a skewed clock.
+type clockSkew struct {
+	window time.Duration
+	reject bool
+}
+
+// parseClockSkew parses --clock-skew-window and --clock-skew-action. It
+// returns nil without a window.
+func parseClockSkew(window, action string) (*clockSkew, error) {
+	if window == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("%q must be positive", window)
+	}
+	switch action {
+	case skewClamp, skewReject:
+	default:
+		return nil, fmt.Errorf("unknown action %q, expected clamp or reject", action)
+	}
+	return &clockSkew{window: d, reject: action == skewReject}, nil
+}
+
+// guard clamps the timestamps of the points of event further than the
+// window from now to the closest edge of the window, or removes the points.
+// It returns the number of points skewed and of points removed.
+func (c *clockSkew) guard(event *types.Event, now time.Time) (skewed, removed int) {
+	if event.Metrics == nil {
+		return 0, 0
+	}
+	earliest, latest := now.Add(-c.window).UnixNano(), now.Add(c.window).UnixNano()
+	kept := event.Metrics.Points[:0]
+	for _, p := range event.Metrics.Points {
+		if p.Timestamp >= earliest && p.Timestamp <= latest {
+			kept = append(kept, p)
+			continue
+		}
+		skewed++
+		if c.reject {
+			continue
+		}
+		if p.Timestamp < earliest {
+			p.Timestamp = earliest
+		} else {
+			p.Timestamp = latest
+		}
+		kept = append(kept, p)
+	}
+	removed = len(event.Metrics.Points) - len(kept)
+	event.Metrics.Points = kept
+	return skewed, removed
+}
+
+// alignTimestamps rounds the timestamps of the points of event down to a
+// multiple of alignment.
+func alignTimestamps(event *types.Event, alignment time.Duration) {
+	if event.Metrics == nil {
+		return
+	}
+	for _, p := range event.Metrics.Points {
+		p.Timestamp -= p.Timestamp % int64(alignment)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestClockSkew(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	points := func() *corev2.Metrics {
+		return &corev2.Metrics{Points: []*corev2.MetricPoint{
+			{Name: "past", Timestamp: now.Add(-time.Hour).UnixNano()},
+			{Name: "now", Timestamp: now.UnixNano()},
+			{Name: "future", Timestamp: now.Add(time.Hour).UnixNano()},
+		}}
+	}
+
+	clamp, err := parseClockSkew("10m", skewClamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "cpu")
+	event.Metrics = points()
+	if skewed, removed := clamp.guard(event, now); skewed != 2 || removed != 0 {
+		t.Errorf("clamp: got %d skewed, %d removed, want 2, 0", skewed, removed)
+	}
+	want := []int64{now.Add(-10 * time.Minute).UnixNano(), now.UnixNano(), now.Add(10 * time.Minute).UnixNano()}
+	for i, p := range event.Metrics.Points {
+		if p.Timestamp != want[i] {
+			t.Errorf("clamp: %s timestamp %d, want %d", p.Name, p.Timestamp, want[i])
+		}
+	}
+
+	reject, err := parseClockSkew("10m", skewReject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Metrics = points()
+	if skewed, removed := reject.guard(event, now); skewed != 2 || removed != 2 {
+		t.Errorf("reject: got %d skewed, %d removed, want 2, 2", skewed, removed)
+	}
+	if len(event.Metrics.Points) != 1 || event.Metrics.Points[0].Name != "now" {
+		t.Errorf("reject: got %v, want the now point", event.Metrics.Points)
+	}
+
+	if c, err := parseClockSkew("", skewReject); c != nil || err != nil {
+		t.Errorf("empty window: got %v, %v", c, err)
+	}
+	for _, bad := range [][2]string{{"soon", skewClamp}, {"-1m", skewClamp}, {"1m", "drop"}} {
+		if _, err := parseClockSkew(bad[0], bad[1]); err == nil {
+			t.Errorf("parseClockSkew(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+}
+
+func TestAlignTimestamps(t *testing.T) {
+	event := corev2.FixtureEvent("web01", "cpu")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "a", Timestamp: time.Unix(1600000007, 500).UnixNano()},
+		{Name: "b", Timestamp: time.Unix(1600000010, 0).UnixNano()},
+	}}
+	alignTimestamps(event, 10*time.Second)
+	for _, p := range event.Metrics.Points {
+		if want := time.Unix(1600000000, 0).UnixNano(); p.Name == "a" && p.Timestamp != want {
+			t.Errorf("a: got %d, want %d", p.Timestamp, want)
+		}
+		if want := time.Unix(1600000010, 0).UnixNano(); p.Name == "b" && p.Timestamp != want {
+			t.Errorf("b: got %d, want %d", p.Timestamp, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// sloBuckets is the number of buckets the SLO window is divided into.
+const sloBuckets = 10
+
+// latencySLO tracks which exports were acknowledged within a latency
+// threshold, from the moment the event was received, over a sliding
+// window.
+type latencySLO struct {
+	sync.Mutex
+	threshold time.Duration
+	objective float64
+	width     time.Duration
+	buckets   [sloBuckets]sloBucket
+	good      int64
+	total     int64
+}
+
+type sloBucket struct {
+	start time.Time
+	good  int64
+	total int64
+	sum   time.Duration
+	max   time.Duration
+}
+
+// sloWindow summarizes the buckets of the current window.
+type sloWindow struct {
+	Good     int64
+	Total    int64
+	Mean     time.Duration
+	Max      time.Duration
+	BurnRate float64
+}
+
+// newLatencySLO tracks the objective, a fraction such as 0.999, of exports
+// completing within threshold over window. It returns nil, tracking
+// nothing, when window is too short to divide into buckets.
+func newLatencySLO(threshold time.Duration, objective float64, window time.Duration) *latencySLO {
+	if window < sloBuckets {
+		return nil
+	}
+	return &latencySLO{
+		threshold: threshold,
+		objective: objective,
+		width:     window / sloBuckets,
+	}
+}
+
+// Observe records an export that took latency and succeeded or not. Failed
+// exports always count against the objective.
+func (s *latencySLO) Observe(latency time.Duration, ok bool, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	start := now.Truncate(s.width)
+	b := &s.buckets[(start.UnixNano()/int64(s.width))%sloBuckets]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	b.total++
+	s.total++
+	if ok && latency <= s.threshold {
+		b.good++
+		s.good++
+	}
+	b.sum += latency
+	if latency > b.max {
+		b.max = latency
+	}
+}
+
+// Window aggregates the observations of the last window. The burn rate is
+// the rate at which the error budget is being spent: 1 means exactly on
+// objective, above 1 means the objective will be missed.
+func (s *latencySLO) Window(now time.Time) sloWindow {
+	s.Lock()
+	defer s.Unlock()
+	var w sloWindow
+	var sum time.Duration
+	oldest := now.Add(-s.width * sloBuckets)
+	for _, b := range s.buckets {
+		if !b.start.After(oldest) {
+			continue
+		}
+		w.Good += b.good
+		w.Total += b.total
+		sum += b.sum
+		if b.max > w.Max {
+			w.Max = b.max
+		}
+	}
+	if w.Total > 0 {
+		w.Mean = sum / time.Duration(w.Total)
+		if s.objective < 1 {
+			w.BurnRate = (1 - float64(w.Good)/float64(w.Total)) / (1 - s.objective)
+		}
+	}
+	return w
+}
+
+// String describes the objective, e.g. "99.9% within 1s over 5m0s".
+func (s *latencySLO) String() string {
+	return fmt.Sprintf("%s%% within %v over %v",
+		strconv.FormatFloat(s.objective*100, 'f', -1, 64), s.threshold, s.width*sloBuckets)
+}
+
+func (s *latencySLO) metricPoints(now time.Time) []*types.MetricPoint {
+	w := s.Window(now)
+	s.Lock()
+	good, total := s.good, s.total
+	s.Unlock()
+
+	ts := now.UnixNano()
+	return []*types.MetricPoint{
+		selfPoint("sensu.otel.handler.export.latency.mean", w.Mean.Seconds(), ts),
+		selfPoint("sensu.otel.handler.export.latency.max", w.Max.Seconds(), ts),
+		selfPoint("sensu.otel.handler.slo.good", float64(good), ts),
+		selfPoint("sensu.otel.handler.slo.total", float64(total), ts),
+		selfPoint("sensu.otel.handler.slo.burn_rate", w.BurnRate, ts),
+	}
+}
+
+// parseObjective converts a percentage such as "99.9" into a fraction.
+func parseObjective(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("%q must be a percentage between 0 and 100", value)
+	}
+	return p / 100, nil
+}
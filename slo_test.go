@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencySLOBurnRate(t *testing.T) {
+	s := newLatencySLO(time.Second, 0.99, 10*time.Minute)
+	now := time.Unix(1600000000, 0)
+	for i := 0; i < 98; i++ {
+		s.Observe(100*time.Millisecond, true, now)
+	}
+	s.Observe(2*time.Second, true, now)
+	s.Observe(100*time.Millisecond, false, now)
+
+	w := s.Window(now)
+	if w.Good != 98 || w.Total != 100 {
+		t.Errorf("got %d good of %d, want 98 of 100", w.Good, w.Total)
+	}
+	// 2% of errors against a 1% budget.
+	if w.BurnRate < 1.99 || w.BurnRate > 2.01 {
+		t.Errorf("got burn rate %v, want 2", w.BurnRate)
+	}
+	if w.Max != 2*time.Second {
+		t.Errorf("got max %v, want 2s", w.Max)
+	}
+
+	// The observations leave the window once it has slid past them.
+	if w := s.Window(now.Add(11 * time.Minute)); w.Total != 0 || w.BurnRate != 0 {
+		t.Errorf("got %d observations and burn rate %v after the window", w.Total, w.BurnRate)
+	}
+}
+
+func TestLatencySLOShortWindow(t *testing.T) {
+	for _, window := range []time.Duration{0, sloBuckets - 1} {
+		if s := newLatencySLO(time.Second, 0.99, window); s != nil {
+			t.Errorf("window %v: got %v, want nothing tracked", window, s)
+		}
+	}
+	if s := newLatencySLO(time.Second, 0.99, sloBuckets); s == nil {
+		t.Errorf("window %v: nothing tracked", time.Duration(sloBuckets))
+	}
+}
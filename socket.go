@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// socketMaxLine is the largest event line accepted by the socket listener.
+const socketMaxLine = 1024 * 1024
+
+// socketResult is a check result as written to the client socket of Sensu
+// 1.x, on port 3030 by default, by the checks and scripts of hosts being
+// migrated:
+//
+//	{"name": "backup", "output": "backup failed", "status": 2, "source": "db01"}
+type socketResult struct {
+	Name     string  `json:"name"`
+	Output   string  `json:"output"`
+	Status   uint32  `json:"status"`
+	Source   string  `json:"source"`
+	Interval uint32  `json:"interval"`
+	Executed int64   `json:"executed"`
+	Duration float64 `json:"duration"`
+	// OutputMetricFormat is not part of Sensu 1.x; results can set it to
+	// have metrics parsed from their output.
+	OutputMetricFormat string `json:"output_metric_format"`
+}
+
+// parseSocketEvent parses a line of the socket listener: a Sensu 1.x check
+// result, or the JSON of a Sensu event for the clients already sending
+// those. Results without a source are attributed to the host they come
+// from.
+func parseSocketEvent(line []byte, host string, now time.Time) (*types.Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["check"]; ok {
+		var event types.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, err
+		}
+		return &event, nil
+	}
+	var r socketResult
+	if err := json.Unmarshal(line, &r); err != nil {
+		return nil, err
+	}
+	if r.Name == "" {
+		return nil, fmt.Errorf("check result without a name")
+	}
+	if r.Source == "" {
+		r.Source = host
+	}
+	if r.Executed == 0 {
+		r.Executed = now.Unix()
+	}
+	namespace := plugin.SocketNamespace
+	return &types.Event{
+		ObjectMeta: types.ObjectMeta{Namespace: namespace},
+		Timestamp:  now.Unix(),
+		Entity: &types.Entity{
+			ObjectMeta:  types.ObjectMeta{Name: r.Source, Namespace: namespace},
+			EntityClass: "proxy",
+		},
+		Check: &types.Check{
+			ObjectMeta:         types.ObjectMeta{Name: r.Name, Namespace: namespace},
+			Output:             r.Output,
+			Status:             r.Status,
+			Interval:           r.Interval,
+			Executed:           r.Executed,
+			Duration:           r.Duration,
+			OutputMetricFormat: r.OutputMetricFormat,
+		},
+	}, nil
+}
+
+// serveSocket starts the listeners of --socket-listen, which accept the
+// events of classic socket handlers, one JSON document per line, over TCP
+// and UDP on the same address. TCP clients get "ok" or "invalid" back for
+// every line.
+func (ot *otelPlugin) serveSocket(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		lis.Close()
+		return err
+	}
+	log.Printf("accepting socket events on %s over tcp and udp", addr)
+	go ot.serveSocketTCP(lis)
+	go ot.serveSocketUDP(conn)
+	return nil
+}
+
+func (ot *otelPlugin) serveSocketTCP(lis net.Listener) {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			errorLog.Printf("socket listener: %v", err)
+			return
+		}
+		go func() {
+			defer conn.Close()
+			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			ot.readSocketLines(conn, host, conn)
+		}()
+	}
+}
+
+func (ot *otelPlugin) serveSocketUDP(conn net.PacketConn) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			errorLog.Printf("socket listener: %v", err)
+			return
+		}
+		host, _, _ := net.SplitHostPort(addr.String())
+		ot.readSocketLines(bytes.NewReader(buf[:n]), host, nil)
+	}
+}
+
+// readSocketLines handles the events read from r, answering each on reply
+// when it is not nil.
+func (ot *otelPlugin) readSocketLines(r io.Reader, host string, reply io.Writer) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), socketMaxLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		answer := "ok\n"
+		event, err := parseSocketEvent(line, host, time.Now())
+		if err != nil {
+			errorLog.Printf("invalid socket event from %s: %v", host, err)
+			answer = "invalid\n"
+		} else if _, err := ot.receiveEvent(context.Background(), event, false); err != nil {
+			errorLog.Printf("could not process socket event from %s: %v", host, err)
+		}
+		if reply != nil {
+			if _, err := io.WriteString(reply, answer); err != nil {
+				return
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errorLog.Printf("socket events from %s: %v", host, err)
+	}
+}
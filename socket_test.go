@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSocketEvent(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	event, err := parseSocketEvent([]byte(`{"name": "backup", "output": "backup failed", "status": 2}`), "10.0.0.7", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Check.Name != "backup" || event.Check.Status != 2 || event.Check.Executed != now.Unix() {
+		t.Errorf("got check %+v", event.Check)
+	}
+	if event.Entity.Name != "10.0.0.7" || event.Namespace != plugin.SocketNamespace {
+		t.Errorf("got entity %q in namespace %q, want 10.0.0.7 in %q", event.Entity.Name, event.Namespace, plugin.SocketNamespace)
+	}
+
+	event, err = parseSocketEvent([]byte(`{"name": "cpu", "source": "db01", "output": "cpu.idle 90 1600000000", "output_metric_format": "graphite_plaintext"}`), "10.0.0.7", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Entity.Name != "db01" || event.Check.OutputMetricFormat != "graphite_plaintext" {
+		t.Errorf("got entity %q, format %q", event.Entity.Name, event.Check.OutputMetricFormat)
+	}
+
+	event, err = parseSocketEvent([]byte(`{"entity": {"metadata": {"name": "web01"}}, "check": {"metadata": {"name": "disk"}}}`), "10.0.0.7", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Entity.Name != "web01" || event.Check.Name != "disk" {
+		t.Errorf("got event of %q/%q, want web01/disk", event.Entity.Name, event.Check.Name)
+	}
+
+	for _, invalid := range []string{`not json`, `{"output": "no name"}`} {
+		if _, err := parseSocketEvent([]byte(invalid), "10.0.0.7", now); err == nil {
+			t.Errorf("parseSocketEvent accepted %s", invalid)
+		}
+	}
+}
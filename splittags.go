@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// Ways of splitting the comma separated values of a tag.
+const (
+	// splitPoints copies the point once for each value.
+	splitPoints = "points"
+	// splitIndexed replaces the tag with one tag per value, named after
+	// the tag and the position of the value: roles.0, roles.1...
+	splitIndexed = "indexed"
+)
+
+// splitTagRule splits the values packed into the tag named tag.
+type splitTagRule struct {
+	tag  string
+	mode string
+}
+
+// parseSplitTags parses the comma separated tag[:mode] rules of
+// --split-tags, mode being points, the default, or indexed, such as
+//
+//	disks, roles:indexed
+func parseSplitTags(value string) ([]splitTagRule, error) {
+	var rules []splitTagRule
+	for _, item := range splitList(value) {
+		r := splitTagRule{tag: item, mode: splitPoints}
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			r.tag, r.mode = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		}
+		if r.tag == "" {
+			return nil, fmt.Errorf("invalid rule %q, expected tag[:mode]", item)
+		}
+		if r.mode != splitPoints && r.mode != splitIndexed {
+			return nil, fmt.Errorf("invalid rule %q: unknown mode %q, expected points or indexed", item, r.mode)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// splitTags applies the rules to the points of event.
+func splitTags(event *types.Event, rules []splitTagRule) {
+	if event.Metrics == nil {
+		return
+	}
+	for _, r := range rules {
+		var points []*types.MetricPoint
+		for _, p := range event.Metrics.Points {
+			points = append(points, r.apply(p)...)
+		}
+		event.Metrics.Points = points
+	}
+}
+
+func (r splitTagRule) apply(p *types.MetricPoint) []*types.MetricPoint {
+	i := -1
+	for j, t := range p.Tags {
+		if t.Name == r.tag && strings.Contains(t.Value, ",") {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return []*types.MetricPoint{p}
+	}
+	values := splitList(p.Tags[i].Value)
+	if len(values) == 0 {
+		return []*types.MetricPoint{p}
+	}
+	if r.mode == splitIndexed {
+		tags := make([]*types.MetricTag, 0, len(p.Tags)+len(values)-1)
+		tags = append(tags, p.Tags[:i]...)
+		for n, v := range values {
+			tags = append(tags, &types.MetricTag{Name: r.tag + "." + strconv.Itoa(n), Value: v})
+		}
+		p.Tags = append(tags, p.Tags[i+1:]...)
+		return []*types.MetricPoint{p}
+	}
+	points := make([]*types.MetricPoint, len(values))
+	for n, v := range values {
+		tags := make([]*types.MetricTag, len(p.Tags))
+		for j, t := range p.Tags {
+			tags[j] = &types.MetricTag{Name: t.Name, Value: t.Value}
+		}
+		tags[i].Value = v
+		points[n] = &types.MetricPoint{Name: p.Name, Value: p.Value, Timestamp: p.Timestamp, Tags: tags}
+	}
+	return points
+}
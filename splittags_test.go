@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestSplitTags(t *testing.T) {
+	rules, err := parseSplitTags("disks, roles:indexed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := corev2.FixtureEvent("web01", "io")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "io.busy", Value: 3, Tags: []*corev2.MetricTag{
+			{Name: "disks", Value: "sda, sdb"},
+			{Name: "roles", Value: "web,db"},
+			{Name: "host", Value: "web01"},
+		}},
+		{Name: "io.wait", Value: 1, Tags: []*corev2.MetricTag{{Name: "disks", Value: "sda"}}},
+	}}
+	splitTags(event, rules)
+	var got []string
+	for _, p := range event.Metrics.Points {
+		got = append(got, p.Name+"{"+tagsKey(p.Tags)+"}")
+	}
+	want := []string{
+		"io.busy{disks=sda,host=web01,roles.0=web,roles.1=db}",
+		"io.busy{disks=sdb,host=web01,roles.0=web,roles.1=db}",
+		"io.wait{disks=sda}",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("point %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{":points", "disks:array"} {
+		if _, err := parseSplitTags(bad); err == nil {
+			t.Errorf("parseSplitTags(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("splunk", func() (Exporter, error) {
+		if plugin.SplunkURL == "" || plugin.SplunkToken == "" {
+			return nil, fmt.Errorf("the splunk exporter requires --splunk-url and --splunk-token")
+		}
+		return &splunkExporter{
+			client: &http.Client{Timeout: exportTimeout},
+			url:    strings.TrimSuffix(plugin.SplunkURL, "/") + "/services/collector",
+			token:  plugin.SplunkToken,
+			index:  plugin.SplunkIndex,
+			events: plugin.SplunkEvents,
+		}, nil
+	})
+}
+
+// splunkEvent is one event of the HTTP Event Collector protocol.
+type splunkEvent struct {
+	Time       float64                `json:"time"`
+	Host       string                 `json:"host,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	Sourcetype string                 `json:"sourcetype,omitempty"`
+	Index      string                 `json:"index,omitempty"`
+	Event      interface{}            `json:"event"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// splunkExporter sends points as metric events to a Splunk HTTP Event
+// Collector and, when enabled, the Sensu events themselves as JSON events.
+type splunkExporter struct {
+	client *http.Client
+	url    string
+	token  string
+	index  string
+	events bool
+}
+
+func (e *splunkExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	var host, source string
+	if len(batch.Events) > 0 {
+		_, host, source = converter.EventNames(batch.Events[0])
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			fields := map[string]interface{}{"metric_name:" + m.Name: pointValue(p)}
+			for _, kv := range p.Attributes {
+				fields[kv.Key] = formatAnyValue(kv.Value)
+			}
+			if err := enc.Encode(splunkEvent{
+				Time:       float64(p.TimeUnixNano) / 1e9,
+				Host:       host,
+				Source:     source,
+				Sourcetype: "sensu:metric",
+				Index:      e.index,
+				Event:      "metric",
+				Fields:     fields,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if e.events {
+		for _, event := range batch.Events {
+			_, entity, check := converter.EventNames(event)
+			if err := enc.Encode(splunkEvent{
+				Time:       float64(event.Timestamp),
+				Host:       entity,
+				Source:     check,
+				Sourcetype: "sensu:event",
+				Index:      e.index,
+				Event:      event,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	return retryExport(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Splunk "+e.token)
+		return doHTTP(e.client, req)
+	})
+}
+
+func (e *splunkExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *splunkExporter) String() string {
+	return "splunk " + e.url
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// staleSweepInterval is how often the series are checked for staleness.
+const staleSweepInterval = 30 * time.Second
+
+// staleAttribute tags the final point of a stale series.
+const staleAttribute = "sensu.stale"
+
+type trackedKey struct {
+	namespace string
+	entity    string
+	check     string
+	name      string
+	tags      string
+}
+
+type trackedSeries struct {
+	point   types.MetricPoint
+	expires time.Time
+}
+
+// seriesTracker remembers the last point of every series received, so that
+// the series whose events stopped, because their entity or check went away,
+// can be ended.
+type seriesTracker struct {
+	sync.Mutex
+	series map[trackedKey]*trackedSeries
+}
+
+func newSeriesTracker() *seriesTracker {
+	return &seriesTracker{series: map[trackedKey]*trackedSeries{}}
+}
+
+// Observe records the points of event, which expire after eventExpiry.
+func (t *seriesTracker) Observe(event *types.Event, now time.Time) {
+	if event.Metrics == nil {
+		return
+	}
+	namespace, entity, check := converter.EventNames(event)
+	expires := now.Add(eventExpiry(event))
+	t.Lock()
+	defer t.Unlock()
+	for _, p := range event.Metrics.Points {
+		key := trackedKey{namespace: namespace, entity: entity, check: check, name: p.Name, tags: tagsKey(p.Tags)}
+		t.series[key] = &trackedSeries{point: *p, expires: expires}
+	}
+}
+
+// Expire forgets the series expired at now and returns their last points,
+// in one event per namespace, entity and check.
+func (t *seriesTracker) Expire(now time.Time) []*types.Event {
+	t.Lock()
+	defer t.Unlock()
+	events := map[lastEventKey]*types.Event{}
+	for key, s := range t.series {
+		if now.Before(s.expires) {
+			continue
+		}
+		delete(t.series, key)
+		ek := lastEventKey{namespace: key.namespace, entity: key.entity, check: key.check}
+		event, ok := events[ek]
+		if !ok {
+			event = &types.Event{
+				Timestamp: now.Unix(),
+				Entity:    &types.Entity{ObjectMeta: types.ObjectMeta{Name: key.entity, Namespace: key.namespace}},
+				Check:     &types.Check{ObjectMeta: types.ObjectMeta{Name: key.check, Namespace: key.namespace}},
+				Metrics:   &types.Metrics{},
+			}
+			events[ek] = event
+		}
+		p := s.point
+		event.Metrics.Points = append(event.Metrics.Points, &p)
+	}
+	stale := make([]*types.Event, 0, len(events))
+	for _, event := range events {
+		stale = append(stale, event)
+	}
+	return stale
+}
+
+// Forget forgets the series of an entity without ending them.
+func (t *seriesTracker) Forget(namespace, entity string) {
+	t.Lock()
+	defer t.Unlock()
+	for key := range t.series {
+		if key.namespace == namespace && key.entity == entity {
+			delete(t.series, key)
+		}
+	}
+}
+
+// tagsKey identifies the tags of a point whatever their order.
+func tagsKey(tags []*types.MetricTag) string {
+	pairs := make([]string, len(tags))
+	for i, t := range tags {
+		pairs[i] = t.Name + "=" + t.Value
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// runStaleSeries ends the stale series periodically. It never returns.
+func (ot *otelPlugin) runStaleSeries() {
+	ticker := time.NewTicker(staleSweepInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ot.endStaleSeries(now)
+	}
+}
+
+// endStaleSeries stops serving the stale series for scraping and, with
+// --stale-markers, exports a final point of each, tagged sensu.stale=true,
+// so that backends with last value semantics don't show them forever. With
+// --leader-election only the leader exports the markers.
+func (ot *otelPlugin) endStaleSeries(now time.Time) {
+	for _, event := range ot.series.Expire(now) {
+		if ot.scrape != nil {
+			ot.scrape.Remove(event, converter.Metrics(event))
+		}
+		if !plugin.StaleMarkers || !ot.leading() {
+			continue
+		}
+		for _, p := range event.Metrics.Points {
+			p.Timestamp = now.UnixNano()
+			p.Tags = append(p.Tags, &types.MetricTag{Name: staleAttribute, Value: "true"})
+		}
+		if err := ot.runPipeline(context.Background(), &pipelineEvent{event: event, stale: true}); err != nil {
+			errorLog.Printf("could not export stale markers: %v", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestSeriesTracker(t *testing.T) {
+	tracker := newSeriesTracker()
+	now := time.Now()
+	event := corev2.FixtureEvent("web01", "disk")
+	event.Check.Interval = 60
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		{Name: "disk.used", Value: 1, Tags: []*corev2.MetricTag{{Name: "mount", Value: "/"}}},
+		{Name: "disk.used", Value: 2, Tags: []*corev2.MetricTag{{Name: "mount", Value: "/var"}}},
+	}}
+	tracker.Observe(event, now)
+	// Only the /var series keeps arriving.
+	event.Metrics.Points = event.Metrics.Points[1:]
+	tracker.Observe(event, now.Add(2*time.Minute))
+
+	if stale := tracker.Expire(now.Add(2 * time.Minute)); len(stale) != 0 {
+		t.Errorf("got %d stale events before the series expired", len(stale))
+	}
+	stale := tracker.Expire(now.Add(4 * time.Minute))
+	if len(stale) != 1 || len(stale[0].Metrics.Points) != 1 {
+		t.Fatalf("got stale events %v, want the / series", stale)
+	}
+	if p := stale[0].Metrics.Points[0]; p.Tags[0].Value != "/" || p.Value != 1 {
+		t.Errorf("got stale point %v", p)
+	}
+	if stale[0].Entity.Name != "web01" || stale[0].Check.Name != "disk" {
+		t.Errorf("stale event of %s/%s, want web01/disk", stale[0].Entity.Name, stale[0].Check.Name)
+	}
+	if stale := tracker.Expire(now.Add(4 * time.Minute)); len(stale) != 0 {
+		t.Errorf("series expired twice")
+	}
+}
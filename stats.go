@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// statsKey identifies the counters of a check within a namespace.
+type statsKey struct {
+	Namespace string
+	Check     string
+}
+
+// exportStats counts events and points by outcome.
+type exportStats struct {
+	Namespace      string `json:"namespace"`
+	Check          string `json:"check"`
+	EventsExported int64  `json:"events_exported"`
+	PointsExported int64  `json:"points_exported"`
+	EventsDropped  int64  `json:"events_dropped"`
+	PointsDropped  int64  `json:"points_dropped"`
+	EventsFailed   int64  `json:"events_failed"`
+	PointsFailed   int64  `json:"points_failed"`
+	PointsSkewed   int64  `json:"points_skewed"`
+}
+
+// checkStats keeps export statistics per check and namespace.
+type checkStats struct {
+	sync.Mutex
+	stats map[statsKey]*exportStats
+}
+
+func newCheckStats() *checkStats {
+	return &checkStats{stats: map[statsKey]*exportStats{}}
+}
+
+func (c *checkStats) get(event *types.Event) *exportStats {
+	namespace, _, check := converter.EventNames(event)
+	key := statsKey{Namespace: namespace, Check: check}
+	s, ok := c.stats[key]
+	if !ok {
+		s = &exportStats{Namespace: namespace, Check: check}
+		c.stats[key] = s
+	}
+	return s
+}
+
+// Exported counts an event whose export succeeded or failed.
+func (c *checkStats) Exported(event *types.Event, err error) {
+	c.Lock()
+	defer c.Unlock()
+	s := c.get(event)
+	if err != nil {
+		s.EventsFailed++
+		s.PointsFailed += int64(converter.EventPoints(event))
+	} else {
+		s.EventsExported++
+		s.PointsExported += int64(converter.EventPoints(event))
+	}
+}
+
+// Dropped counts points of an event that were deliberately not exported.
+// The event itself only counts as dropped when none of its points remain.
+func (c *checkStats) Dropped(event *types.Event, points int, whole bool) {
+	c.Lock()
+	defer c.Unlock()
+	s := c.get(event)
+	s.PointsDropped += int64(points)
+	if whole {
+		s.EventsDropped++
+	}
+}
+
+// Skewed counts the points of an event whose timestamp was outside of the
+// clock skew window, removed of which were dropped.
+func (c *checkStats) Skewed(event *types.Event, skewed, removed int) {
+	c.Lock()
+	defer c.Unlock()
+	s := c.get(event)
+	s.PointsSkewed += int64(skewed)
+	s.PointsDropped += int64(removed)
+}
+
+// Snapshot returns a copy of the statistics matching the optional namespace
+// and check, sorted by namespace and check.
+func (c *checkStats) Snapshot(namespace, check string) []exportStats {
+	c.Lock()
+	defer c.Unlock()
+	snapshot := make([]exportStats, 0, len(c.stats))
+	for key, s := range c.stats {
+		if (namespace != "" && key.Namespace != namespace) || (check != "" && key.Check != check) {
+			continue
+		}
+		snapshot = append(snapshot, *s)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Namespace != snapshot[j].Namespace {
+			return snapshot[i].Namespace < snapshot[j].Namespace
+		}
+		return snapshot[i].Check < snapshot[j].Check
+	})
+	return snapshot
+}
+
+func (c *checkStats) metricPoints(now time.Time) []*types.MetricPoint {
+	ts := now.UnixNano()
+	var points []*types.MetricPoint
+	for _, s := range c.Snapshot("", "") {
+		tag := func(outcome string) []*types.MetricTag {
+			return []*types.MetricTag{
+				{Name: "namespace", Value: s.Namespace},
+				{Name: "check", Value: s.Check},
+				{Name: "outcome", Value: outcome},
+			}
+		}
+		points = append(points,
+			selfPoint("sensu.otel.handler.events", float64(s.EventsExported), ts, tag("exported")...),
+			selfPoint("sensu.otel.handler.events", float64(s.EventsDropped), ts, tag("dropped")...),
+			selfPoint("sensu.otel.handler.events", float64(s.EventsFailed), ts, tag("failed")...),
+			selfPoint("sensu.otel.handler.points", float64(s.PointsExported), ts, tag("exported")...),
+			selfPoint("sensu.otel.handler.points", float64(s.PointsDropped), ts, tag("dropped")...),
+			selfPoint("sensu.otel.handler.points", float64(s.PointsFailed), ts, tag("failed")...),
+			selfPoint("sensu.otel.handler.skewed_points", float64(s.PointsSkewed), ts,
+				&types.MetricTag{Name: "namespace", Value: s.Namespace},
+				&types.MetricTag{Name: "check", Value: s.Check}),
+		)
+	}
+	return points
+}
+
+// serveStats returns the export statistics as JSON, optionally filtered by
+// the namespace and check query parameters.
+//
+//	$ curl 'localhost:55788/stats?namespace=default&check=cpu'
+func (ot *otelPlugin) serveStats(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ot.stats.Snapshot(q.Get("namespace"), q.Get("check")))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// statsdCheckName is the check of the events of the StatsD metrics.
+const statsdCheckName = "statsd"
+
+// statsdSample is a line of the StatsD protocol, with the DogStatsD tags:
+//
+//	name:value|type[|@rate][|#tag:value,...]
+type statsdSample struct {
+	name  string
+	value float64
+	// kind is c, g, ms, h or s.
+	kind string
+	rate float64
+	// relative gauges, +n or -n, change the gauge instead of setting it.
+	relative bool
+	// set holds the member of a set, whose value is not a number.
+	set  string
+	tags []*types.MetricTag
+}
+
+// parseStatsdLine parses a StatsD line.
+func parseStatsdLine(line string) (statsdSample, error) {
+	s := statsdSample{rate: 1}
+	j := strings.IndexByte(line, '|')
+	if j < 0 {
+		return s, fmt.Errorf("invalid line %q, expected name:value|type", line)
+	}
+	i := strings.LastIndex(line[:j], ":")
+	if i <= 0 || i == j-1 {
+		return s, fmt.Errorf("invalid line %q, expected name:value|type", line)
+	}
+	s.name = line[:i]
+	fields := strings.Split(line[j+1:], "|")
+	s.kind = fields[0]
+	value := line[i+1 : j]
+	switch s.kind {
+	case "s":
+		s.set = value
+	case "c", "g", "ms", "h":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid value in %q", line)
+		}
+		s.value = v
+		s.relative = s.kind == "g" && (value[0] == '+' || value[0] == '-')
+	default:
+		return s, fmt.Errorf("unknown type %q in %q", s.kind, line)
+	}
+	for _, f := range fields[1:] {
+		switch {
+		case strings.HasPrefix(f, "@"):
+			rate, err := strconv.ParseFloat(f[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return s, fmt.Errorf("invalid sample rate in %q", line)
+			}
+			s.rate = rate
+		case strings.HasPrefix(f, "#"):
+			for _, tag := range splitList(f[1:]) {
+				kv := strings.SplitN(tag, ":", 2)
+				t := &types.MetricTag{Name: kv[0]}
+				if len(kv) == 2 {
+					t.Value = kv[1]
+				}
+				s.tags = append(s.tags, t)
+			}
+		}
+	}
+	return s, nil
+}
+
+// statsdEntry aggregates the samples of a metric of an entity over a flush
+// interval.
+type statsdEntry struct {
+	name  string
+	kind  string
+	tags  []*types.MetricTag
+	value float64
+	count float64
+	sum   float64
+	min   float64
+	max   float64
+	set   map[string]bool
+}
+
+// statsdAggregator aggregates the StatsD samples until they are flushed as
+// one event per entity.
+type statsdAggregator struct {
+	sync.Mutex
+	// entries holds the metrics of each entity by name, type and tags.
+	entries map[string]map[string]*statsdEntry
+	// gauges keeps the last value of the gauges, which relative gauges
+	// change.
+	gauges map[string]map[string]float64
+}
+
+func newStatsdAggregator() *statsdAggregator {
+	return &statsdAggregator{
+		entries: make(map[string]map[string]*statsdEntry),
+		gauges:  make(map[string]map[string]float64),
+	}
+}
+
+// Add aggregates a sample of entity.
+func (a *statsdAggregator) Add(entity string, s statsdSample) {
+	a.Lock()
+	defer a.Unlock()
+	kind := s.kind
+	if kind == "h" {
+		kind = "ms"
+	}
+	key := s.name + "|" + kind + "|" + tagsKey(s.tags)
+	if a.entries[entity] == nil {
+		a.entries[entity] = make(map[string]*statsdEntry)
+	}
+	if a.gauges[entity] == nil {
+		a.gauges[entity] = make(map[string]float64)
+	}
+	e, ok := a.entries[entity][key]
+	if !ok {
+		e = &statsdEntry{name: s.name, kind: kind, tags: s.tags, min: math.Inf(1), max: math.Inf(-1)}
+		a.entries[entity][key] = e
+	}
+	switch kind {
+	case "c":
+		e.value += s.value / s.rate
+	case "g":
+		if s.relative {
+			e.value = a.gauges[entity][key] + s.value
+		} else {
+			e.value = s.value
+		}
+		a.gauges[entity][key] = e.value
+	case "ms":
+		e.count += 1 / s.rate
+		e.sum += s.value / s.rate
+		e.min = math.Min(e.min, s.value)
+		e.max = math.Max(e.max, s.value)
+	case "s":
+		if e.set == nil {
+			e.set = make(map[string]bool)
+		}
+		e.set[s.set] = true
+	}
+}
+
+// Flush returns the aggregated metrics, as one event per entity, and starts
+// a new interval. Counters are the total of the interval, timers have
+// .count, .sum, .min and .max points and sets the number of unique members.
+func (a *statsdAggregator) Flush(now time.Time, interval time.Duration) []*types.Event {
+	a.Lock()
+	entries := a.entries
+	a.entries = make(map[string]map[string]*statsdEntry)
+	a.Unlock()
+
+	entities := make([]string, 0, len(entries))
+	for entity := range entries {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	ts := now.UnixNano()
+	events := make([]*types.Event, 0, len(entities))
+	for _, entity := range entities {
+		keys := make([]string, 0, len(entries[entity]))
+		for key := range entries[entity] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var points []*types.MetricPoint
+		for _, key := range keys {
+			e := entries[entity][key]
+			switch e.kind {
+			case "c", "g":
+				points = append(points, selfPoint(e.name, e.value, ts, e.tags...))
+			case "ms":
+				points = append(points,
+					selfPoint(e.name+".count", e.count, ts, e.tags...),
+					selfPoint(e.name+".sum", e.sum, ts, e.tags...),
+					selfPoint(e.name+".min", e.min, ts, e.tags...),
+					selfPoint(e.name+".max", e.max, ts, e.tags...),
+				)
+			case "s":
+				points = append(points, selfPoint(e.name, float64(len(e.set)), ts, e.tags...))
+			}
+		}
+		namespace := plugin.StatsdNamespace
+		events = append(events, &types.Event{
+			ObjectMeta: types.ObjectMeta{Namespace: namespace},
+			Timestamp:  now.Unix(),
+			Entity: &types.Entity{
+				ObjectMeta:  types.ObjectMeta{Name: entity, Namespace: namespace},
+				EntityClass: "proxy",
+			},
+			Check: &types.Check{
+				ObjectMeta: types.ObjectMeta{Name: statsdCheckName, Namespace: namespace},
+				Interval:   uint32(interval / time.Second),
+				Executed:   now.Unix(),
+			},
+			Metrics: &types.Metrics{Points: points},
+		})
+	}
+	return events
+}
+
+// statsdEntity returns the entity a sample from host is attributed to, as
+// --statsd-entity says: the sending host with "peer", the value of a tag,
+// removed from the sample, with "tag:<name>", or else the name given.
+// Samples without the tag are attributed to the sending host.
+func statsdEntity(source, host string, s *statsdSample) string {
+	switch {
+	case source == "peer":
+		return host
+	case strings.HasPrefix(source, "tag:"):
+		name := strings.TrimPrefix(source, "tag:")
+		for i, t := range s.tags {
+			if t.Name == name && t.Value != "" {
+				s.tags = append(s.tags[:i:i], s.tags[i+1:]...)
+				return t.Value
+			}
+		}
+		return host
+	}
+	return source
+}
+
+// serveStatsd listens for StatsD samples on the UDP address of
+// --statsd-listen and exports their aggregates every interval.
+func (ot *otelPlugin) serveStatsd(addr string, interval time.Duration) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("accepting statsd metrics on %s", addr)
+	a := newStatsdAggregator()
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				errorLog.Printf("statsd listener: %v", err)
+				return
+			}
+			host, _, _ := net.SplitHostPort(peer.String())
+			for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+				if line = bytes.TrimSpace(line); len(line) == 0 {
+					continue
+				}
+				s, err := parseStatsdLine(string(line))
+				if err != nil {
+					errorLog.Printf("invalid statsd line from %s: %v", host, err)
+					continue
+				}
+				a.Add(statsdEntity(plugin.StatsdEntity, host, &s), s)
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			for _, event := range a.Flush(now, interval) {
+				if _, err := ot.receiveEvent(context.Background(), event, false); err != nil {
+					errorLog.Printf("could not export statsd metrics of %s: %v", event.Entity.Name, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
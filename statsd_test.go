@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsd(t *testing.T) {
+	a := newStatsdAggregator()
+	for _, line := range []string{
+		"requests:1|c",
+		"requests:2|c|@0.5",
+		"queue:10|g",
+		"queue:-3|g",
+		"latency:100|ms",
+		"latency:300|ms|#route:/api,host:db01",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+	} {
+		s, err := parseStatsdLine(line)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		a.Add(statsdEntity("tag:host", "10.0.0.7", &s), s)
+	}
+	now := time.Unix(1600000000, 0)
+	events := a.Flush(now, 10*time.Second)
+	if len(events) != 2 || events[0].Entity.Name != "10.0.0.7" || events[1].Entity.Name != "db01" {
+		t.Fatalf("got %d events, want the events of 10.0.0.7 and db01", len(events))
+	}
+	got := map[string]float64{}
+	for _, event := range events {
+		if event.Check.Name != statsdCheckName || event.Check.Interval != 10 {
+			t.Errorf("got check %s, interval %d", event.Check.Name, event.Check.Interval)
+		}
+		for _, p := range event.Metrics.Points {
+			got[event.Entity.Name+"/"+p.Name+"{"+tagsKey(p.Tags)+"}"] = p.Value
+		}
+	}
+	for name, value := range map[string]float64{
+		"10.0.0.7/requests{}":          5,
+		"10.0.0.7/queue{}":             7,
+		"10.0.0.7/latency.count{}":     1,
+		"10.0.0.7/latency.max{}":       100,
+		"10.0.0.7/users{}":             2,
+		"db01/latency.sum{route=/api}": 300,
+		"db01/latency.min{route=/api}": 300,
+	} {
+		if got[name] != value {
+			t.Errorf("%s: got %v, want %v", name, got[name], value)
+		}
+	}
+	if events := a.Flush(now.Add(10*time.Second), 10*time.Second); len(events) != 0 {
+		t.Errorf("got %d events after a flush without samples", len(events))
+	}
+
+	for _, bad := range []string{"requests", "requests:1", "requests:x|c", "requests:1|q", "requests:1|c|@2"} {
+		if _, err := parseStatsdLine(bad); err == nil {
+			t.Errorf("parseStatsdLine(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+)
+
+// recentEvents is the number of events shown on the status page.
+const recentEvents = 25
+
+// eventStatus summarizes the handling of one event.
+type eventStatus struct {
+	Time      time.Time
+	Namespace string
+	Entity    string
+	Check     string
+	Points    int
+	Error     string
+}
+
+// handlerStatus keeps the counters and recent history shown on the status
+// page. The counters come first to keep them 64-bit aligned for atomic
+// access on 32-bit platforms.
+type handlerStatus struct {
+	exported int64
+	failed   int64
+	inFlight int64
+	sync.Mutex
+	recent []eventStatus
+	next   int
+}
+
+func newHandlerStatus() *handlerStatus {
+	return &handlerStatus{recent: make([]eventStatus, 0, recentEvents)}
+}
+
+// Observe records the outcome of exporting an event.
+func (s *handlerStatus) Observe(event *types.Event, err error) {
+	namespace, entity, check := converter.EventNames(event)
+	es := eventStatus{
+		Time:      time.Now(),
+		Namespace: namespace,
+		Entity:    entity,
+		Check:     check,
+		Points:    converter.EventPoints(event),
+	}
+	if err != nil {
+		es.Error = err.Error()
+		atomic.AddInt64(&s.failed, 1)
+	} else {
+		atomic.AddInt64(&s.exported, 1)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if len(s.recent) < recentEvents {
+		s.recent = append(s.recent, es)
+	} else {
+		s.recent[s.next] = es
+	}
+	s.next = (s.next + 1) % recentEvents
+}
+
+// Recent returns the last events handled, newest first.
+func (s *handlerStatus) Recent() []eventStatus {
+	s.Lock()
+	defer s.Unlock()
+	recent := make([]eventStatus, 0, len(s.recent))
+	for i := 1; i <= len(s.recent); i++ {
+		recent = append(recent, s.recent[(s.next-i+len(s.recent))%len(s.recent)])
+	}
+	return recent
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.Name}} status</title>
+<meta http-equiv="refresh" content="10">
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+.failed { color: #b00; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Up {{.Uptime}}. Exported {{.Exported}} events, {{.Failed}} failed, {{.InFlight}} in flight, {{.Crashes}} crashes recovered.</p>
+{{with .SLO}}<p>Latency SLO {{$.SLOObjective}}: {{.Good}} of {{.Total}} exports met it, mean {{.Mean}}, max {{.Max}}, burn rate {{printf "%.2f" .BurnRate}}.</p>
+{{end}}<h2>Exporters</h2>
+<table>
+<tr><th>Exporter</th><th>Status</th><th>Exports</th><th>Failures</th><th>Last error</th></tr>
+{{range .Exporters}}<tr><td>{{.Name}}</td>{{if .Up}}<td>up</td>{{else}}<td class="failed">down ({{.ConsecutiveFailures}} failures in a row)</td>{{end}}<td>{{.Exports}}</td><td>{{.Failures}}</td><td>{{.LastError}}</td></tr>
+{{end}}</table>
+<h2>Recent events</h2>
+<table>
+<tr><th>Time</th><th>Namespace</th><th>Entity</th><th>Check</th><th>Points</th><th>Outcome</th></tr>
+{{range .Recent}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Namespace}}</td><td>{{.Entity}}</td><td>{{.Check}}</td><td>{{.Points}}</td>{{if .Error}}<td class="failed">{{.Error}}</td>{{else}}<td>exported</td>{{end}}</tr>
+{{else}}<tr><td colspan="6">No events received yet.</td></tr>
+{{end}}</table>
+<h2>Configuration</h2>
+<table>
+{{range .Config}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// serveStatus renders a minimal HTML page for humans to inspect the server.
+func (ot *otelPlugin) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	data := struct {
+		Name         string
+		Uptime       time.Duration
+		Exported     int64
+		Failed       int64
+		InFlight     int64
+		Crashes      int64
+		Recent       []eventStatus
+		Exporters    []exporterHealthStatus
+		Config       [][2]string
+		SLO          *sloWindow
+		SLOObjective string
+	}{
+		Name:      plugin.Name,
+		Uptime:    time.Since(startTime).Round(time.Second),
+		Exported:  atomic.LoadInt64(&ot.status.exported),
+		Failed:    atomic.LoadInt64(&ot.status.failed),
+		InFlight:  atomic.LoadInt64(&ot.status.inFlight),
+		Crashes:   atomic.LoadInt64(&crashes),
+		Recent:    ot.status.Recent(),
+		Exporters: exporterHealthStatuses(),
+		Config:    ot.configSummary(),
+	}
+	if ot.slo != nil {
+		w := ot.slo.Window(time.Now())
+		data.SLO = &w
+		data.SLOObjective = ot.slo.String()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, data); err != nil {
+		log.Printf("could not render status page: %v", err)
+	}
+}
+
+// configSummary lists the effective configuration with secrets redacted.
+func (ot *otelPlugin) configSummary() [][2]string {
+	summary := [][2]string{
+		{"destination", ot.destination()},
+		{"access token", redact(accessToken())},
+	}
+	for _, opt := range options {
+		var value string
+		switch v := opt.Value.(type) {
+		case *string:
+			value = *v
+		case *bool:
+			value = fmt.Sprint(*v)
+		case *int64:
+			value = fmt.Sprint(*v)
+		}
+		if isSecretOption(opt.Argument) {
+			value = redact(value)
+		}
+		summary = append(summary, [2]string{opt.Argument, value})
+	}
+	return summary
+}
+
+// isSecretOption guesses from its name whether an option holds a secret:
+// its last word names one. Options naming where a secret is kept, such as
+// --auth-token-file or --kubernetes-secret, are not secrets themselves.
+// --otlp-headers usually carries an authorization header.
+func isSecretOption(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "kubernetes-secret") {
+		return false
+	}
+	if strings.HasSuffix(name, "connection-string") || name == "otlp-headers" {
+		return true
+	}
+	words := strings.Split(name, "-")
+	switch words[len(words)-1] {
+	case "token", "secret", "password", "key":
+		return true
+	}
+	return false
+}
+
+// warnSecretArguments warns about secrets given as command line
+// arguments, which show in the handler definition and the process list,
+// instead of through the environment where Sensu injects secrets.
+func warnSecretArguments() {
+	for _, opt := range options {
+		v, ok := opt.Value.(*string)
+		if !ok || *v == "" || !isSecretOption(opt.Argument) {
+			continue
+		}
+		if _, fromEnv := os.LookupEnv(opt.Env); fromEnv {
+			continue
+		}
+		if def, _ := opt.Default.(string); *v != def {
+			log.Printf("warning: --%s is set on the command line, set %s from the environment or a Sensu secret instead", opt.Argument, opt.Env)
+		}
+	}
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "<redacted>"
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("syslog", func() (Exporter, error) {
+		if plugin.SyslogAddress == "" {
+			return nil, fmt.Errorf("the syslog exporter requires --syslog-address")
+		}
+		facility, err := syslogFacility(plugin.SyslogFacility)
+		if err != nil {
+			return nil, err
+		}
+		return &syslogExporter{address: plugin.SyslogAddress, useTLS: plugin.SyslogTLS, facility: facility}, nil
+	})
+}
+
+// syslogFacilities are the facility codes of RFC 5424.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func syslogFacility(name string) (int, error) {
+	facility, ok := syslogFacilities[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return facility, nil
+}
+
+// syslogEnterpriseID qualifies the structured data ID of the messages.
+const syslogEnterpriseID = "32473"
+
+// syslogExporter forwards the events of non-OK checks as RFC 5424 messages
+// over TCP, optionally with TLS, with the octet counting framing of RFC
+// 6587. Metrics, logs and spans are not sent; the events signal must be
+// enabled for incidents without metrics to reach it.
+type syslogExporter struct {
+	address  string
+	useTLS   bool
+	facility int
+
+	sync.Mutex
+	conn net.Conn
+}
+
+func (e *syslogExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	var buf bytes.Buffer
+	for _, event := range batch.Events {
+		if event.Check == nil || event.Check.Status == 0 {
+			continue
+		}
+		msg := e.format(event)
+		fmt.Fprintf(&buf, "%d %s", len(msg), msg)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	return retryExport(ctx, func(ctx context.Context) error {
+		if e.conn == nil {
+			conn, err := e.dial(ctx)
+			if err != nil {
+				return err
+			}
+			e.conn = conn
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = e.conn.SetWriteDeadline(deadline)
+		}
+		if _, err := e.conn.Write(buf.Bytes()); err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return err
+		}
+		return nil
+	})
+}
+
+func (e *syslogExporter) dial(ctx context.Context) (net.Conn, error) {
+	conn, err := egressDial(ctx, "tcp", e.address)
+	if err != nil || !e.useTLS {
+		return conn, err
+	}
+	host, _, _ := net.SplitHostPort(e.address)
+	cfg := tlsConfig()
+	cfg.ServerName = host
+	tlsConn := tls.Client(conn, cfg)
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = tlsConn.SetDeadline(deadline)
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// format renders an event as an RFC 5424 message. The entity is the
+// hostname and the check the message ID; the check output is the message.
+func (e *syslogExporter) format(event *types.Event) string {
+	namespace, entity, check := converter.EventNames(event)
+	severity := 3 // error
+	switch event.Check.Status {
+	case 1:
+		severity = 4 // warning
+	case 2:
+		severity = 2 // critical
+	}
+	_, statusText := converter.StatusSeverity(event.Check.Status)
+
+	ts := time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339)
+	sd := fmt.Sprintf(`[sensu@%s namespace="%s" entity="%s" check="%s" status="%d" occurrences="%d"]`,
+		syslogEnterpriseID, sdEscape(namespace), sdEscape(entity), sdEscape(check),
+		event.Check.Status, event.Check.Occurrences)
+	output := strings.TrimSpace(strings.Replace(event.Check.Output, "\n", " ", -1))
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s %s %s: %s",
+		e.facility*8+severity, ts, syslogField(entity, 255), syslogField(plugin.Name, 48),
+		syslogField(check, 32), sd, statusText, output)
+}
+
+// syslogField returns value as a header field: printable ASCII without
+// spaces, at most max characters and "-" when empty.
+func syslogField(value string, max int) string {
+	b := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(b) < max; i++ {
+		if c := value[i]; c > 32 && c < 127 {
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// sdEscape escapes a structured data parameter value.
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// Shutdown closes the connection.
+func (e *syslogExporter) Shutdown(context.Context) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+func (e *syslogExporter) String() string {
+	return "syslog " + e.address
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation.
+const listenFdsStart = 3
+
+// listen returns the listener systemd passed to the process through socket
+// activation, or a new TCP listener on addr when there is none.
+func listen(addr string) (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return net.Listen("tcp", addr)
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	if n > 1 {
+		log.Printf("systemd passed %d sockets, using the first one", n)
+	}
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFdsStart), "systemd-socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// sdNotify sends a state change such as "READY=1" to systemd. It does
+// nothing when the process wasn't started by a Type=notify unit.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// runWatchdog keeps pinging the systemd watchdog when the unit has
+// WatchdogSec set. It never returns.
+func runWatchdog() {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(usec) * time.Microsecond / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := sdNotify("WATCHDOG=1"); err != nil {
+			log.Printf("could not notify systemd watchdog: %v", err)
+		}
+	}
+}
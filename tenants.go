@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/smithclay/otel-sensu-handler-plugin/pkg/converter"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// tenant maps the Sensu namespaces matching a glob pattern to a backend
+// tenant: the token sent in the auth header and, optionally, its own
+// endpoint.
+type tenant struct {
+	namespace string
+	tokenEnv  string
+	endpoint  string
+}
+
+// parseTenants parses the tenants of --tenants, separated by semicolons,
+// each namespace=TOKEN_VARIABLE[@endpoint], for example
+//
+//	team-a=TEAM_A_TOKEN; team-b-*=TEAM_B_TOKEN@otlp.team-b.example:443
+//
+// The token is read from the environment variable so that it can come from
+// a Sensu secret and never appears in the arguments.
+func parseTenants(value string) ([]tenant, error) {
+	var tenants []tenant
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.IndexByte(item, '=')
+		if i <= 0 || i == len(item)-1 {
+			return nil, fmt.Errorf("invalid tenant %q, expected namespace=TOKEN_VARIABLE[@endpoint]", item)
+		}
+		t := tenant{namespace: strings.TrimSpace(item[:i]), tokenEnv: strings.TrimSpace(item[i+1:])}
+		if j := strings.IndexByte(t.tokenEnv, '@'); j >= 0 {
+			t.tokenEnv, t.endpoint = strings.TrimSpace(t.tokenEnv[:j]), strings.TrimSpace(t.tokenEnv[j+1:])
+			if t.endpoint == "" {
+				return nil, fmt.Errorf("tenant %q has an empty endpoint", t.namespace)
+			}
+		}
+		if t.tokenEnv == "" {
+			return nil, fmt.Errorf("tenant %q has no token variable", t.namespace)
+		}
+		if _, err := path.Match(t.namespace, ""); err != nil {
+			return nil, fmt.Errorf("invalid tenant namespace %q: %v", t.namespace, err)
+		}
+		tenants = append(tenants, t)
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants")
+	}
+	return tenants, nil
+}
+
+// withTenants returns exporter as is without --tenants. Otherwise it
+// builds one more exporter per tenant with build, from s with the token and
+// endpoint of the tenant, and sends the events of each namespace to the
+// exporter of its tenant. Events of other namespaces go to exporter.
+func withTenants(s otlpSettings, exporter Exporter, build func(otlpSettings) (Exporter, error)) (Exporter, error) {
+	if plugin.Tenants == "" {
+		return exporter, nil
+	}
+	tenants, err := parseTenants(plugin.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	e := &tenantExporter{fallback: exporter}
+	for _, t := range tenants {
+		token := os.Getenv(t.tokenEnv)
+		if token == "" {
+			_ = e.Shutdown(context.Background())
+			return nil, fmt.Errorf("tenant %q: %s is not set", t.namespace, t.tokenEnv)
+		}
+		ts := s
+		ts.auth = staticAuth{plugin.AuthHeader: headerValue(plugin.AuthHeader, token)}
+		if t.endpoint != "" {
+			ts.endpoint = t.endpoint
+		}
+		exporter, err := build(ts)
+		if err != nil {
+			_ = e.Shutdown(context.Background())
+			return nil, fmt.Errorf("tenant %q: %w", t.namespace, err)
+		}
+		e.tenants = append(e.tenants, t)
+		e.exporters = append(e.exporters, exporter)
+	}
+	return e, nil
+}
+
+// tenantExporter sends every batch to the exporter of the first tenant
+// matching the namespace of its event.
+type tenantExporter struct {
+	tenants   []tenant
+	exporters []Exporter
+	fallback  Exporter
+}
+
+func (e *tenantExporter) Export(ctx context.Context, res *resourcepb.Resource, batch *Batch) error {
+	return e.target(batch).Export(ctx, res, batch)
+}
+
+// ExportResources sends the batches of every tenant together.
+func (e *tenantExporter) ExportResources(ctx context.Context, batches []ResourceBatch) error {
+	return exportRouted(ctx, batches, e.target)
+}
+
+// target returns the exporter of the first tenant matching the namespace of
+// the event of batch, the fallback exporter if none does.
+func (e *tenantExporter) target(batch *Batch) Exporter {
+	if len(batch.Events) > 0 {
+		namespace, _, _ := converter.EventNames(batch.Events[0])
+		for i, t := range e.tenants {
+			if ok, _ := path.Match(t.namespace, namespace); ok {
+				return e.exporters[i]
+			}
+		}
+	}
+	return e.fallback
+}
+
+// Shutdown shuts down the exporters of every tenant and the fallback one.
+func (e *tenantExporter) Shutdown(ctx context.Context) error {
+	err := e.fallback.Shutdown(ctx)
+	for _, exporter := range e.exporters {
+		if serr := exporter.Shutdown(ctx); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Preflight checks the fallback exporter and the exporter of every tenant,
+// with the token of the tenant.
+func (e *tenantExporter) Preflight(ctx context.Context) error {
+	for i, exporter := range append([]Exporter{e.fallback}, e.exporters...) {
+		p, ok := exporter.(preflighter)
+		if !ok {
+			continue
+		}
+		if err := p.Preflight(ctx); err != nil {
+			if i > 0 {
+				return fmt.Errorf("tenant %q: %w", e.tenants[i-1].namespace, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *tenantExporter) String() string {
+	return fmt.Sprintf("%s and %d tenants", destination("", e.fallback), len(e.tenants))
+}
+
+type tenantHeaderKey struct{}
+
+// eventTenant returns the tenant of the event of batch: the value of its
+// --tenant-label label, or --tenant-default when it has none. It returns
+// "" without --tenant-label.
+func eventTenant(batch *Batch) string {
+	if plugin.TenantLabel == "" {
+		return ""
+	}
+	tenant := plugin.TenantDefault
+	if len(batch.Events) > 0 {
+		if value := eventLabel(batch.Events[0], plugin.TenantLabel); value != "" {
+			if err := validateHeaderValue(value); err != nil {
+				errorLog.Printf("ignoring tenant label %s=%q: %v", plugin.TenantLabel, value, err)
+			} else {
+				tenant = value
+			}
+		}
+	}
+	return tenant
+}
+
+// withTenantHeader adds the tenant header of the event of batch to ctx, for
+// the exporters to send with their requests.
+func withTenantHeader(ctx context.Context, batch *Batch) context.Context {
+	if tenant := eventTenant(batch); tenant != "" {
+		return context.WithValue(ctx, tenantHeaderKey{}, map[string]string{plugin.TenantHeader: tenant})
+	}
+	return ctx
+}
+
+// tenantGroups splits batches by the tenant of their event, which is sent
+// in the header of their requests.
+func tenantGroups(batches []ResourceBatch) [][]ResourceBatch {
+	if len(batches) == 0 {
+		return nil
+	}
+	if plugin.TenantLabel == "" {
+		return [][]ResourceBatch{batches}
+	}
+	var tenants []string
+	var groups [][]ResourceBatch
+	for _, b := range batches {
+		tenant, i := eventTenant(b.Batch), 0
+		for i < len(tenants) && tenants[i] != tenant {
+			i++
+		}
+		if i == len(tenants) {
+			tenants = append(tenants, tenant)
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], b)
+	}
+	return groups
+}
+
+// tenantHeaderFrom returns the tenant header added to ctx, if any.
+func tenantHeaderFrom(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(tenantHeaderKey{}).(map[string]string)
+	return headers
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// tlsVersions are the accepted values of --tls-min-version.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseCipherSuites returns the IDs of the comma separated cipher suite
+// names, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, or nil for the Go
+// defaults. Insecure suites are refused.
+func parseCipherSuites(value string) ([]uint16, error) {
+	byName := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		byName[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	for _, name := range splitList(value) {
+		id, ok := byName[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// tlsConfig returns a TLS configuration with the minimum version and
+// cipher suites of --tls-min-version and --tls-cipher-suites, for every
+// connection the handler accepts or makes. The options are validated
+// before, so errors are ignored here.
+func tlsConfig() *tls.Config {
+	version, ok := tlsVersions[plugin.TLSMinVersion]
+	if !ok {
+		version = tls.VersionTLS12
+	}
+	suites, _ := parseCipherSuites(plugin.TLSCipherSuites)
+	return &tls.Config{MinVersion: version, CipherSuites: suites}
+}
+
+// configureTLS applies tlsConfig to the default HTTP transport, which the
+// HTTP exporters and auth providers use.
+func configureTLS() {
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = tlsConfig()
+}
+
+// otlpTLSConfig returns tlsConfig with the certificate authorities of
+// --otlp-ca-file and the client certificate of --otlp-cert-file, for the
+// OTLP exporters.
+func otlpTLSConfig() (*tls.Config, error) {
+	cfg := tlsConfig()
+	if plugin.OTLPCAFile != "" {
+		ca, err := ioutil.ReadFile(plugin.OTLPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("--otlp-ca-file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("--otlp-ca-file: no certificates in %s", plugin.OTLPCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if plugin.OTLPCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(plugin.OTLPCertFile, plugin.OTLPKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("--otlp-cert-file: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
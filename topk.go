@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sensu/sensu-go/types"
+)
+
+// topKOther is the tag value the points beyond the top K are aggregated into.
+const topKOther = "other"
+
+// topKRule keeps, for every metric of an event, the points of the k values
+// of tag with the largest sum of point values, and sums the others into
+// points tagged tag=other.
+type topKRule struct {
+	tag string
+	k   int
+}
+
+// parseTopK parses the comma separated tag=K rules of --top-k, such as
+//
+//	process=10, container=5
+func parseTopK(value string) ([]topKRule, error) {
+	var rules []topKRule
+	for _, item := range splitList(value) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid rule %q, expected tag=K", item)
+		}
+		k, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || k < 1 {
+			return nil, fmt.Errorf("invalid rule %q: K must be a positive integer", item)
+		}
+		rules = append(rules, topKRule{tag: strings.TrimSpace(kv[0]), k: k})
+	}
+	return rules, nil
+}
+
+// aggregateTopK applies the rules to the points of event. It returns the
+// number of points removed.
+func aggregateTopK(event *types.Event, rules []topKRule) int {
+	if event.Metrics == nil {
+		return 0
+	}
+	before := len(event.Metrics.Points)
+	for _, r := range rules {
+		event.Metrics.Points = r.apply(event.Metrics.Points)
+	}
+	return before - len(event.Metrics.Points)
+}
+
+func (r topKRule) apply(points []*types.MetricPoint) []*types.MetricPoint {
+	// The sum of the values of each metric by tag value.
+	sums := make(map[string]map[string]float64)
+	for _, p := range points {
+		if v, ok := tagValue(p, r.tag); ok {
+			if sums[p.Name] == nil {
+				sums[p.Name] = make(map[string]float64)
+			}
+			sums[p.Name][v] += p.Value
+		}
+	}
+	top := make(map[string]map[string]bool)
+	for name, byValue := range sums {
+		if len(byValue) <= r.k {
+			continue
+		}
+		values := make([]string, 0, len(byValue))
+		for v := range byValue {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if byValue[values[i]] != byValue[values[j]] {
+				return byValue[values[i]] > byValue[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		top[name] = make(map[string]bool, r.k)
+		for _, v := range values[:r.k] {
+			top[name][v] = true
+		}
+	}
+	if len(top) == 0 {
+		return points
+	}
+
+	kept := points[:0]
+	others := make(map[string]*types.MetricPoint)
+	for _, p := range points {
+		v, ok := tagValue(p, r.tag)
+		if !ok || top[p.Name] == nil || top[p.Name][v] {
+			kept = append(kept, p)
+			continue
+		}
+		tags := make([]*types.MetricTag, len(p.Tags))
+		for i, t := range p.Tags {
+			tags[i] = &types.MetricTag{Name: t.Name, Value: t.Value}
+			if t.Name == r.tag {
+				tags[i].Value = topKOther
+			}
+		}
+		key := p.Name + "{" + tagsKey(tags) + "}"
+		if other, ok := others[key]; ok {
+			other.Value += p.Value
+			if p.Timestamp > other.Timestamp {
+				other.Timestamp = p.Timestamp
+			}
+			continue
+		}
+		other := &types.MetricPoint{Name: p.Name, Value: p.Value, Timestamp: p.Timestamp, Tags: tags}
+		others[key] = other
+		kept = append(kept, other)
+	}
+	return kept
+}
+
+// tagValue returns the value of the tag named name of p.
+func tagValue(p *types.MetricPoint, name string) (string, bool) {
+	for _, t := range p.Tags {
+		if t.Name == name {
+			return t.Value, true
+		}
+	}
+	return "", false
+}
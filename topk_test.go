@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestTopK(t *testing.T) {
+	rules, err := parseTopK("process=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := func(name, process string, value float64) *corev2.MetricPoint {
+		return &corev2.MetricPoint{Name: name, Value: value, Tags: []*corev2.MetricTag{{Name: "process", Value: process}}}
+	}
+	event := corev2.FixtureEvent("web01", "procs")
+	event.Metrics = &corev2.Metrics{Points: []*corev2.MetricPoint{
+		point("proc.cpu", "nginx", 5),
+		point("proc.cpu", "java", 50),
+		point("proc.cpu", "sshd", 1),
+		point("proc.cpu", "postgres", 20),
+		point("proc.cpu", "cron", 2),
+		// Fewer than K processes: kept as is.
+		point("proc.mem", "sshd", 1),
+		{Name: "load", Value: 3},
+	}}
+	if n := aggregateTopK(event, rules); n != 2 {
+		t.Errorf("got %d points removed, want 2", n)
+	}
+	var got []string
+	for _, p := range event.Metrics.Points {
+		v, _ := tagValue(p, "process")
+		got = append(got, p.Name+"/"+v)
+	}
+	want := []string{"proc.cpu/other", "proc.cpu/java", "proc.cpu/postgres", "proc.mem/sshd", "load/"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("point %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+	if other := event.Metrics.Points[0].Value; other != 8 {
+		t.Errorf("got other=%v, want 8", other)
+	}
+
+	for _, bad := range []string{"process", "process=0", "=2", "process=x"} {
+		if _, err := parseTopK(bad); err == nil {
+			t.Errorf("parseTopK(%q) accepted", bad)
+		}
+	}
+}
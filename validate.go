@@ -0,0 +1,635 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configErrors lists every problem found in the configuration, so they can
+// all be fixed at once instead of one per restart.
+type configErrors []error
+
+func (e configErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d configuration problem(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// validateConfig checks the environment and the parsed plugin options. It
+// returns nil when the configuration is usable.
+func validateConfig() error {
+	var errs configErrors
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if exporterSelected("otlp") || exporterSelected("otlphttp") {
+		switch plugin.Auth {
+		case "static":
+			if accessToken() == "" {
+				errs = append(errs, fmt.Errorf("--access-token: required by the static auth provider, set OTEL_SENSU_ACCESS_TOKEN or LS_ACCESS_TOKEN"))
+			}
+		case "token-file":
+			if plugin.AuthTokenFile == "" {
+				errs = append(errs, fmt.Errorf("--auth-token-file: required by the token-file auth provider"))
+			}
+		case "oauth2":
+			if plugin.OAuth2TokenURL == "" || plugin.OAuth2ClientID == "" {
+				errs = append(errs, fmt.Errorf("--oauth2-token-url and --oauth2-client-id: required by the oauth2 auth provider"))
+			}
+			if _, err := oauth2BasicAuth(plugin.OAuth2AuthStyle); err != nil {
+				errs = append(errs, fmt.Errorf("--oauth2-auth-style: %v", err))
+			}
+		case "gcp-id-token":
+			if plugin.GCPAudience == "" {
+				errs = append(errs, fmt.Errorf("--gcp-audience: required by the gcp-id-token auth provider"))
+			}
+		case "vault":
+			if plugin.VaultAddr == "" && os.Getenv("VAULT_ADDR") == "" {
+				errs = append(errs, fmt.Errorf("--vault-addr: required by the vault auth provider unless VAULT_ADDR is set"))
+			} else if plugin.VaultAddr != "" {
+				check(validateURL("--vault-addr", plugin.VaultAddr))
+			}
+			if plugin.VaultPath == "" {
+				errs = append(errs, fmt.Errorf("--vault-path: required by the vault auth provider"))
+			}
+			check(validateDuration("--vault-refresh", plugin.VaultRefresh))
+		case "aws-secrets":
+			if _, err := parseAWSSecretHeaders(plugin.AWSSecretsHeaders); err != nil {
+				errs = append(errs, fmt.Errorf("--aws-secrets-headers: %v", err))
+			}
+			if awsRegion(plugin.AWSSecretsRegion) == "" {
+				errs = append(errs, fmt.Errorf("--aws-secrets-region: required by the aws-secrets auth provider unless AWS_REGION is set"))
+			}
+			check(validateDuration("--aws-secrets-refresh", plugin.AWSSecretsRefresh))
+		case "kubernetes-secret":
+			if plugin.KubernetesSecret == "" {
+				errs = append(errs, fmt.Errorf("--kubernetes-secret: required by the kubernetes-secret auth provider"))
+			}
+			if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+				errs = append(errs, fmt.Errorf("--auth: the kubernetes-secret auth provider only works in a Kubernetes cluster"))
+			}
+		case "sigv4":
+			if plugin.SigV4Service == "" {
+				errs = append(errs, fmt.Errorf("--sigv4-service: required by the sigv4 auth provider"))
+			}
+			if awsRegion(plugin.SigV4Region) == "" {
+				errs = append(errs, fmt.Errorf("--sigv4-region: required by the sigv4 auth provider unless AWS_REGION is set"))
+			}
+			if exporterSelected("otlp") {
+				errs = append(errs, fmt.Errorf("--auth: the sigv4 auth provider only signs otlphttp exports"))
+			}
+		}
+		if _, ok := authProviders[plugin.Auth]; !ok {
+			errs = append(errs, fmt.Errorf("--auth: unknown auth provider %q, expected one of %s", plugin.Auth, strings.Join(authProviderNames(), ", ")))
+		}
+		check(validateEndpoint("--otlp-endpoint", plugin.OTLPEndpoint))
+		if v := plugin.OTLPCompression; v != "" && v != "gzip" && v != "none" {
+			errs = append(errs, fmt.Errorf("--otlp-compression: %q must be gzip or none", v))
+		}
+		if _, err := parseHeaders(plugin.OTLPHeaders); err != nil {
+			errs = append(errs, fmt.Errorf("--otlp-headers: %v", err))
+		}
+		if (plugin.OTLPCertFile == "") != (plugin.OTLPKeyFile == "") {
+			errs = append(errs, fmt.Errorf("--otlp-cert-file: requires --otlp-key-file and the other way around"))
+		}
+		if _, err := otlpTLSConfig(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if plugin.RecordDir != "" {
+		if fi, err := os.Stat(plugin.RecordDir); err == nil && !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("--record-dir: %s is not a directory", plugin.RecordDir))
+		}
+		if plugin.RecordMaxSize <= 0 {
+			errs = append(errs, fmt.Errorf("--record-max-size: must be positive, got %d", plugin.RecordMaxSize))
+		}
+		if plugin.RecordMaxFiles < 0 {
+			errs = append(errs, fmt.Errorf("--record-max-files: must not be negative, got %d", plugin.RecordMaxFiles))
+		}
+		check(validateDuration("--record-max-age", plugin.RecordMaxAge))
+		if plugin.RecordMaxTotalSize < 0 {
+			errs = append(errs, fmt.Errorf("--record-max-total-size: must not be negative, got %d", plugin.RecordMaxTotalSize))
+		}
+	}
+	check(validateDuration("--export-timeout", plugin.ExportTimeout))
+	if plugin.IngestQueue < 0 {
+		errs = append(errs, fmt.Errorf("--ingest-queue: must not be negative, got %d", plugin.IngestQueue))
+	}
+	if plugin.IngestQueue > 0 && plugin.IngestWorkers < 1 {
+		errs = append(errs, fmt.Errorf("--ingest-workers: must be at least 1, got %d", plugin.IngestWorkers))
+	}
+	if plugin.IngestQueue > 0 && plugin.IngestBatch < 1 {
+		errs = append(errs, fmt.Errorf("--ingest-batch: must be at least 1, got %d", plugin.IngestBatch))
+	}
+	if plugin.Listen == "" {
+		errs = append(errs, fmt.Errorf("--listen: must not be empty"))
+	}
+	check(validateDuration("--self-telemetry-interval", plugin.SelfTelemetry))
+	check(validateDuration("--heartbeat-interval", plugin.HeartbeatInterval))
+	check(validateDuration("--canary-interval", plugin.CanaryInterval))
+	if plugin.CanaryQueryURL != "" {
+		if plugin.CanaryInterval == "" {
+			errs = append(errs, fmt.Errorf("--canary-query-url: requires --canary-interval"))
+		}
+		check(validateURL("--canary-query-url", plugin.CanaryQueryURL))
+		if plugin.CanaryQuery == "" {
+			errs = append(errs, fmt.Errorf("--canary-query: must not be empty"))
+		}
+		if plugin.CanaryQueryToken != "" && os.Getenv(plugin.CanaryQueryToken) == "" {
+			errs = append(errs, fmt.Errorf("--canary-query-token: %s is not set", plugin.CanaryQueryToken))
+		}
+		if plugin.CanaryTimeout == "" {
+			errs = append(errs, fmt.Errorf("--canary-timeout: must not be empty"))
+		}
+		check(validateDuration("--canary-timeout", plugin.CanaryTimeout))
+	}
+	if plugin.LeaderElection != "" {
+		if _, _, err := parseLeaderElection(plugin.LeaderElection); err != nil {
+			errs = append(errs, fmt.Errorf("--leader-election: %v", err))
+		}
+		if lease, err := time.ParseDuration(plugin.LeaderElectionLease); err != nil {
+			errs = append(errs, fmt.Errorf("--leader-election-lease: %v", err))
+		} else if lease < 3*time.Second {
+			errs = append(errs, fmt.Errorf("--leader-election-lease: %q must be at least 3s", plugin.LeaderElectionLease))
+		}
+	}
+	if plugin.SLOLatency == "" {
+		errs = append(errs, fmt.Errorf("--slo-latency: must not be empty"))
+	}
+	check(validateDuration("--slo-latency", plugin.SLOLatency))
+	if _, err := parseObjective(plugin.SLOObjective); err != nil {
+		errs = append(errs, fmt.Errorf("--slo-objective: %v", err))
+	}
+	if plugin.SLOWindow == "" {
+		errs = append(errs, fmt.Errorf("--slo-window: must not be empty"))
+	}
+	check(validateDuration("--slo-window", plugin.SLOWindow))
+	if d, err := time.ParseDuration(plugin.SLOWindow); err == nil && d > 0 && d < sloBuckets {
+		errs = append(errs, fmt.Errorf("--slo-window: must be at least %v", time.Duration(sloBuckets)))
+	}
+	if plugin.LogSampleRate < 1 {
+		errs = append(errs, fmt.Errorf("--log-sample-rate: must be at least 1, got %d", plugin.LogSampleRate))
+	}
+	check(validateDuration("--log-summary-interval", plugin.LogSummary))
+	if len(splitList(plugin.Exporter)) == 0 {
+		errs = append(errs, fmt.Errorf("--exporter: no exporter selected"))
+	}
+	for _, name := range splitList(plugin.Exporter) {
+		if _, ok := exporterFactories[name]; !ok {
+			errs = append(errs, fmt.Errorf("--exporter: unknown exporter %q, expected one of %s", name, strings.Join(exporterNames(), ", ")))
+		}
+	}
+	if exporterSelected("file") {
+		if plugin.ExporterFile == "" {
+			errs = append(errs, fmt.Errorf("--exporter-file: required by the file exporter"))
+		} else {
+			check(validateParentDir("--exporter-file", plugin.ExporterFile))
+		}
+	}
+	if exporterSelected("kafka") {
+		if len(splitList(plugin.KafkaBrokers)) == 0 {
+			errs = append(errs, fmt.Errorf("--kafka-brokers: required by the kafka exporter"))
+		}
+		for _, broker := range splitList(plugin.KafkaBrokers) {
+			check(validateEndpoint("--kafka-brokers", broker))
+		}
+		if plugin.KafkaTopic == "" {
+			errs = append(errs, fmt.Errorf("--kafka-topic: must not be empty"))
+		}
+		if _, err := kafkaSASL(plugin.KafkaSASL, plugin.KafkaUsername, plugin.KafkaPassword); err != nil {
+			errs = append(errs, fmt.Errorf("--kafka-sasl-mechanism: %v", err))
+		}
+	}
+	if exporterSelected("nats") {
+		if plugin.NATSURL == "" {
+			errs = append(errs, fmt.Errorf("--nats-url: required by the nats exporter"))
+		}
+		if plugin.NATSSubject == "" || strings.ContainsAny(plugin.NATSSubject, " *>") {
+			errs = append(errs, fmt.Errorf("--nats-subject: %q is not a valid subject prefix", plugin.NATSSubject))
+		}
+	}
+	if exporterSelected("pushgateway") {
+		check(validateURL("--pushgateway-url", plugin.PushgatewayURL))
+		if plugin.PushgatewayJob == "" {
+			errs = append(errs, fmt.Errorf("--pushgateway-job: must not be empty"))
+		}
+	}
+	if exporterSelected("carbon") {
+		if plugin.CarbonAddress == "" {
+			errs = append(errs, fmt.Errorf("--carbon-address: required by the carbon exporter"))
+		} else {
+			check(validateEndpoint("--carbon-address", plugin.CarbonAddress))
+		}
+		if !strings.Contains(plugin.CarbonTemplate, "{name}") {
+			errs = append(errs, fmt.Errorf("--carbon-template: %q must contain {name}", plugin.CarbonTemplate))
+		}
+	}
+	if exporterSelected("influxdb") {
+		check(validateURL("--influxdb-url", plugin.InfluxDBURL))
+		if plugin.InfluxDBOrg == "" {
+			errs = append(errs, fmt.Errorf("--influxdb-org: required by the influxdb exporter"))
+		}
+		if plugin.InfluxDBBucket == "" {
+			errs = append(errs, fmt.Errorf("--influxdb-bucket: required by the influxdb exporter"))
+		}
+	}
+	if exporterSelected("datadog") {
+		if plugin.DatadogAPIKey == "" {
+			errs = append(errs, fmt.Errorf("--datadog-api-key: required by the datadog exporter"))
+		}
+		if plugin.DatadogSite == "" || strings.ContainsAny(plugin.DatadogSite, "/:") {
+			errs = append(errs, fmt.Errorf("--datadog-site: %q must be a site such as datadoghq.com", plugin.DatadogSite))
+		}
+		if _, err := parseTagMap(plugin.DatadogTagMap); err != nil {
+			errs = append(errs, fmt.Errorf("--datadog-tag-map: %v", err))
+		}
+	}
+	if exporterSelected("splunk") {
+		check(validateURL("--splunk-url", plugin.SplunkURL))
+		if plugin.SplunkToken == "" {
+			errs = append(errs, fmt.Errorf("--splunk-token: required by the splunk exporter"))
+		}
+	}
+	if exporterSelected("loki") {
+		check(validateURL("--loki-url", plugin.LokiURL))
+		if signals, err := parseSignals(plugin.Signals); err == nil && !signals[signalLogs] {
+			errs = append(errs, fmt.Errorf("--signals: the loki exporter requires the logs signal"))
+		}
+	}
+	if exporterSelected("elasticsearch") {
+		check(validateURL("--elasticsearch-url", plugin.ElasticsearchURL))
+		if plugin.ElasticsearchIndex == "" || strings.ContainsAny(plugin.ElasticsearchIndex, `/\ *?"<>|,#:`) {
+			errs = append(errs, fmt.Errorf("--elasticsearch-index: %q is not a valid index name", plugin.ElasticsearchIndex))
+		}
+	}
+	if exporterSelected("syslog") {
+		if plugin.SyslogAddress == "" {
+			errs = append(errs, fmt.Errorf("--syslog-address: required by the syslog exporter"))
+		} else {
+			check(validateEndpoint("--syslog-address", plugin.SyslogAddress))
+		}
+		if _, err := syslogFacility(plugin.SyslogFacility); err != nil {
+			errs = append(errs, fmt.Errorf("--syslog-facility: %v", err))
+		}
+		if signals, err := parseSignals(plugin.Signals); err == nil && !signals[signalEvents] {
+			errs = append(errs, fmt.Errorf("--signals: the syslog exporter requires the events signal"))
+		}
+	}
+	if exporterSelected("cloudwatch") {
+		if awsRegion(plugin.CloudWatchRegion) == "" {
+			errs = append(errs, fmt.Errorf("--cloudwatch-region: required by the cloudwatch exporter unless AWS_REGION is set"))
+		}
+		if plugin.CloudWatchNamespace == "" || strings.HasPrefix(plugin.CloudWatchNamespace, "AWS/") {
+			errs = append(errs, fmt.Errorf("--cloudwatch-namespace: %q is not a custom namespace", plugin.CloudWatchNamespace))
+		}
+		if n := len(splitList(plugin.CloudWatchDimensions)); n > cloudWatchMaxDimensions {
+			errs = append(errs, fmt.Errorf("--cloudwatch-dimensions: %d dimensions, at most %d are allowed", n, cloudWatchMaxDimensions))
+		}
+	}
+	if exporterSelected("cloudmonitoring") {
+		if _, err := gcpResourceType(plugin.GCPResource); err != nil {
+			errs = append(errs, fmt.Errorf("--gcp-resource: %v", err))
+		}
+		if plugin.GCPLocation == "" {
+			errs = append(errs, fmt.Errorf("--gcp-location: must not be empty"))
+		}
+	}
+	if exporterSelected("azuremonitor") {
+		if plugin.AzureConnectionString != "" {
+			if _, err := parseAzureConnectionString(plugin.AzureConnectionString); err != nil {
+				errs = append(errs, fmt.Errorf("--azure-connection-string: %v", err))
+			}
+		} else if plugin.AzureResourceID == "" || plugin.AzureRegion == "" {
+			errs = append(errs, fmt.Errorf("--azure-resource-id, --azure-region: required by the azuremonitor exporter without --azure-connection-string"))
+		} else if !strings.HasPrefix(plugin.AzureResourceID, "/subscriptions/") {
+			errs = append(errs, fmt.Errorf("--azure-resource-id: %q is not a resource ID", plugin.AzureResourceID))
+		}
+	}
+	if exporterSelected("webhook") {
+		if plugin.WebhookURL == "" {
+			errs = append(errs, fmt.Errorf("--webhook-url: required by the webhook exporter"))
+		} else {
+			check(validateURL("--webhook-url", plugin.WebhookURL))
+		}
+		if _, err := webhookTemplate(plugin.WebhookTemplateFile); err != nil {
+			errs = append(errs, fmt.Errorf("--webhook-template-file: %v", err))
+		}
+	}
+	if plugin.Routes != "" {
+		if routes, err := parseRoutes(plugin.Routes); err != nil {
+			errs = append(errs, fmt.Errorf("--routes: %v", err))
+		} else {
+			for _, r := range routes {
+				for _, name := range r.exporters {
+					if !exporterSelected(name) {
+						errs = append(errs, fmt.Errorf("--routes: exporter %q is not selected with --exporter", name))
+					}
+				}
+			}
+		}
+	}
+	if _, err := parseChaos(chaosSetting()); err != nil {
+		errs = append(errs, fmt.Errorf("--%s: %v", chaosFlag, err))
+	}
+	if policies, err := parseExportPolicies(plugin.ExporterPolicy); err != nil {
+		errs = append(errs, fmt.Errorf("--exporter-policy: %v", err))
+	} else {
+		for name := range policies {
+			if !exporterSelected(name) {
+				errs = append(errs, fmt.Errorf("--exporter-policy: exporter %q is not selected with --exporter", name))
+			}
+		}
+	}
+	if plugin.Tenants != "" {
+		if tenants, err := parseTenants(plugin.Tenants); err != nil {
+			errs = append(errs, fmt.Errorf("--tenants: %v", err))
+		} else if !exporterSelected("otlp") && !exporterSelected("otlphttp") {
+			errs = append(errs, fmt.Errorf("--tenants: requires the otlp or otlphttp exporter"))
+		} else {
+			for _, t := range tenants {
+				if os.Getenv(t.tokenEnv) == "" {
+					errs = append(errs, fmt.Errorf("--tenants: %s, the token of tenant %q, is not set", t.tokenEnv, t.namespace))
+				}
+				if t.endpoint != "" {
+					check(validateEndpoint("--tenants", t.endpoint))
+				}
+			}
+		}
+	}
+	if plugin.TenantLabel != "" {
+		check(validateHeaderName("--tenant-header", plugin.TenantHeader))
+		if plugin.TenantDefault != "" {
+			if err := validateHeaderValue(plugin.TenantDefault); err != nil {
+				errs = append(errs, fmt.Errorf("--tenant-default: %v", err))
+			}
+		}
+		if !exporterSelected("otlp") && !exporterSelected("otlphttp") && !exporterSelected("loki") {
+			errs = append(errs, fmt.Errorf("--tenant-label: requires the otlp, otlphttp or loki exporter"))
+		}
+	}
+	if _, err := parseCIDRs(plugin.IngestAllowCIDRs); err != nil {
+		errs = append(errs, fmt.Errorf("--ingest-allow-cidrs: %v", err))
+	}
+	if (plugin.IngestTLSCertFile == "") != (plugin.IngestTLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("--ingest-tls-cert-file and --ingest-tls-key-file: must be set together"))
+	}
+	if plugin.IngestClientCAFile != "" && plugin.IngestTLSCertFile == "" {
+		errs = append(errs, fmt.Errorf("--ingest-client-ca-file: requires --ingest-tls-cert-file"))
+	}
+	if plugin.IngestClientNames != "" && plugin.IngestClientCAFile == "" {
+		errs = append(errs, fmt.Errorf("--ingest-client-names: requires --ingest-client-ca-file"))
+	}
+	for _, pattern := range splitList(plugin.IngestClientNames) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("--ingest-client-names: invalid pattern %q: %v", pattern, err))
+		}
+	}
+	if tokens, err := parseIngestTokens(plugin.IngestTokens); err != nil {
+		errs = append(errs, fmt.Errorf("--ingest-tokens: %v", err))
+	} else {
+		for _, t := range tokens {
+			if os.Getenv(t.env) == "" {
+				errs = append(errs, fmt.Errorf("--ingest-tokens: %s is not set", t.env))
+			}
+		}
+	}
+	if quotas, err := parseIngestQuotas(plugin.IngestQuotas); err != nil {
+		errs = append(errs, fmt.Errorf("--ingest-quotas: %v", err))
+	} else if quotas != nil {
+		tokens, _ := parseIngestTokens(plugin.IngestTokens)
+		for _, env := range quotas.tokens() {
+			found := false
+			for _, t := range tokens {
+				found = found || t.env == env
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("--ingest-quotas: %s is not in --ingest-tokens", env))
+			}
+		}
+	}
+	check(validateDuration("--ingest-deadline", plugin.IngestDeadline))
+	if err := validateIngestResponse(plugin.IngestResponse); err != nil {
+		errs = append(errs, fmt.Errorf("--ingest-response: %v", err))
+	}
+	check(validateDuration("--dedup-window", plugin.DedupWindow))
+	if _, ok := tlsVersions[plugin.TLSMinVersion]; !ok {
+		errs = append(errs, fmt.Errorf("--tls-min-version: %q must be 1.0, 1.1, 1.2 or 1.3", plugin.TLSMinVersion))
+	}
+	if _, err := parseCipherSuites(plugin.TLSCipherSuites); err != nil {
+		errs = append(errs, fmt.Errorf("--tls-cipher-suites: %v", err))
+	} else if plugin.TLSCipherSuites != "" && plugin.TLSMinVersion == "1.3" {
+		errs = append(errs, fmt.Errorf("--tls-cipher-suites: TLS 1.3 cipher suites are not configurable, drop the option or lower --tls-min-version"))
+	}
+	if plugin.AdminListen != "" {
+		if _, _, err := net.SplitHostPort(plugin.AdminListen); err != nil {
+			errs = append(errs, fmt.Errorf("--admin-listen: %q must be [host]:port: %v", plugin.AdminListen, err))
+		}
+		for _, env := range splitList(plugin.AdminTokens) {
+			if os.Getenv(env) == "" {
+				errs = append(errs, fmt.Errorf("--admin-tokens: %s is not set", env))
+			}
+		}
+	} else if plugin.AdminTokens != "" {
+		errs = append(errs, fmt.Errorf("--admin-tokens: requires --admin-listen"))
+	}
+	if _, err := parseEgressRules(plugin.EgressAllow); err != nil {
+		errs = append(errs, fmt.Errorf("--egress-allow: %v", err))
+	}
+	if exporterSelected("inmemory") && plugin.InMemoryMaxBatches < 1 {
+		errs = append(errs, fmt.Errorf("--inmemory-max-batches: must be at least 1, got %d", plugin.InMemoryMaxBatches))
+	}
+	check(validateDuration("--timestamp-alignment", plugin.TimestampAlignment))
+	if _, err := parseScaleRules(plugin.MetricScale); err != nil {
+		errs = append(errs, fmt.Errorf("--metric-scale: %v", err))
+	}
+	if _, err := parseKindRules(plugin.MetricKinds); err != nil {
+		errs = append(errs, fmt.Errorf("--metric-kinds: %v", err))
+	}
+	if _, err := parseDerivedMetrics(plugin.DerivedMetrics); err != nil {
+		errs = append(errs, fmt.Errorf("--derived-metrics: %v", err))
+	}
+	if _, err := parseTopK(plugin.TopK); err != nil {
+		errs = append(errs, fmt.Errorf("--top-k: %v", err))
+	}
+	if _, err := parseSplitTags(plugin.SplitTags); err != nil {
+		errs = append(errs, fmt.Errorf("--split-tags: %v", err))
+	}
+	if _, err := parseGraphiteTemplates(plugin.GraphiteTemplates); err != nil {
+		errs = append(errs, fmt.Errorf("--graphite-templates: %v", err))
+	}
+	if _, err := parseClockSkew(plugin.ClockSkewWindow, plugin.ClockSkewAction); err != nil {
+		errs = append(errs, fmt.Errorf("--clock-skew-window, --clock-skew-action: %v", err))
+	}
+	if _, err := parseSeverityMapping(plugin.LogSeverityMap, plugin.LogSeverityOverrides); err != nil {
+		errs = append(errs, fmt.Errorf("--log-severity-map, --log-severity-overrides: %v", err))
+	}
+	if _, err := parsePipeline(plugin.Pipeline); err != nil {
+		errs = append(errs, fmt.Errorf("--pipeline: %v", err))
+	}
+	if plugin.RuntimeConfig != "" {
+		check(validateParentDir("--runtime-config", plugin.RuntimeConfig))
+		if _, err := loadRuntimeConfig(plugin.RuntimeConfig); err != nil {
+			errs = append(errs, fmt.Errorf("--runtime-config: %v", err))
+		}
+	}
+	if plugin.Profiles != "" {
+		if _, err := loadProfiles(plugin.Profiles); err != nil {
+			errs = append(errs, fmt.Errorf("--profiles: %v", err))
+		}
+	}
+	if plugin.SocketListen != "" {
+		if _, _, err := net.SplitHostPort(plugin.SocketListen); err != nil {
+			errs = append(errs, fmt.Errorf("--socket-listen: %q must be [host]:port: %v", plugin.SocketListen, err))
+		}
+		if plugin.SocketNamespace == "" {
+			errs = append(errs, fmt.Errorf("--socket-namespace: required by --socket-listen"))
+		}
+	}
+	if plugin.StatsdListen != "" {
+		if _, _, err := net.SplitHostPort(plugin.StatsdListen); err != nil {
+			errs = append(errs, fmt.Errorf("--statsd-listen: %q must be [host]:port: %v", plugin.StatsdListen, err))
+		}
+		if plugin.StatsdFlushInterval == "" {
+			errs = append(errs, fmt.Errorf("--statsd-flush-interval: required by --statsd-listen"))
+		}
+		check(validateDuration("--statsd-flush-interval", plugin.StatsdFlushInterval))
+		if plugin.StatsdEntity == "" || plugin.StatsdEntity == "tag:" {
+			errs = append(errs, fmt.Errorf("--statsd-entity: expected peer, tag:<name> or an entity name"))
+		}
+		if plugin.StatsdNamespace == "" {
+			errs = append(errs, fmt.Errorf("--statsd-namespace: required by --statsd-listen"))
+		}
+	}
+	if plugin.ScrapeProxyTargets != "" {
+		if _, err := parseScrapeTargets(plugin.ScrapeProxyTargets); err != nil {
+			errs = append(errs, fmt.Errorf("--scrape-proxy-targets: %v", err))
+		}
+		if plugin.ScrapeProxyInterval == "" {
+			errs = append(errs, fmt.Errorf("--scrape-proxy-interval: required by --scrape-proxy-targets"))
+		}
+		check(validateDuration("--scrape-proxy-interval", plugin.ScrapeProxyInterval))
+		if plugin.ScrapeProxyNamespace == "" {
+			errs = append(errs, fmt.Errorf("--scrape-proxy-namespace: required by --scrape-proxy-targets"))
+		}
+	}
+	if _, err := parseFeatureGates(plugin.FeatureGates); err != nil {
+		errs = append(errs, fmt.Errorf("--feature-gates: %v", err))
+	}
+	if plugin.StaleMarkers && !plugin.StaleSeries {
+		errs = append(errs, fmt.Errorf("--stale-markers: requires --stale-series"))
+	}
+	check(validateDuration("--scrape-staleness", plugin.ScrapeStaleness))
+	if _, err := parseSignals(plugin.Signals); err != nil {
+		errs = append(errs, fmt.Errorf("--signals: %v", err))
+	}
+	if plugin.AuditLog != "" {
+		check(validateParentDir("--audit-log", plugin.AuditLog))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateEndpoint checks that an OTLP/gRPC endpoint is a plain host:port.
+func validateEndpoint(name, endpoint string) error {
+	if strings.Contains(endpoint, "://") {
+		return fmt.Errorf("%s: %q must be host:port without a scheme", name, endpoint)
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("%s: %q must be host:port: %v", name, endpoint, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%s: %q has no host", name, endpoint)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("%s: %q has an invalid port", name, endpoint)
+	}
+	return nil
+}
+
+// validateURL checks that a required option is an absolute http(s) URL.
+func validateURL(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: required", name)
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: %q must be an http or https URL", name, value)
+	}
+	return nil
+}
+
+// validateDuration checks an optional positive duration such as "30s".
+func validateDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s: %q must be positive", name, value)
+	}
+	return nil
+}
+
+// validateParentDir checks that the directory a file is to be created in
+// exists.
+func validateParentDir(name, path string) error {
+	dir := filepath.Dir(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", name, dir)
+	}
+	return nil
+}
+
+// validateHeaderName checks that an option names a valid HTTP header.
+func validateHeaderName(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: required", name)
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return fmt.Errorf("%s: %q is not a valid header name", name, value)
+		}
+	}
+	return nil
+}
+
+// validateHeaderValue checks that a value can be sent in a header: printable
+// ASCII without leading or trailing spaces.
+func validateHeaderValue(value string) error {
+	if strings.TrimSpace(value) != value {
+		return fmt.Errorf("leading or trailing spaces")
+	}
+	for _, r := range value {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("invalid character %q", r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestValidateEndpoint(t *testing.T) {
+	for endpoint, valid := range map[string]bool{
+		"ingest.lightstep.com:443": true,
+		"localhost:4317":           true,
+		"[::1]:4317":               true,
+		"localhost":                false,
+		":4317":                    false,
+		"localhost:http":           false,
+		"localhost:70000":          false,
+		"https://localhost:4317":   false,
+	} {
+		err := validateEndpoint("endpoint", endpoint)
+		if valid && err != nil {
+			t.Errorf("validateEndpoint(%q): %v", endpoint, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("validateEndpoint(%q) succeeded, want error", endpoint)
+		}
+	}
+}
+
+func TestValidateHeaderName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"X-Scope-OrgID": true,
+		"authorization": true,
+		"":              false,
+		"X Scope":       false,
+		"X-Scope:":      false,
+	} {
+		err := validateHeaderName("header", name)
+		if valid && err != nil {
+			t.Errorf("validateHeaderName(%q): %v", name, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("validateHeaderName(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("x-scope-orgid=team-a, X-Env = prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 || headers["x-scope-orgid"] != "team-a" || headers["X-Env"] != "prod" {
+		t.Errorf("got %v", headers)
+	}
+	for _, value := range []string{"x-env", "=prod", "x env=prod"} {
+		if _, err := parseHeaders(value); err == nil {
+			t.Errorf("parseHeaders(%q) succeeded, want error", value)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterAuthProvider("vault", func() (AuthProvider, error) {
+		addr := getenv("VAULT_ADDR", "")
+		if plugin.VaultAddr != "" {
+			addr = plugin.VaultAddr
+		}
+		if addr == "" || plugin.VaultPath == "" {
+			return nil, fmt.Errorf("the vault auth provider requires --vault-addr or VAULT_ADDR, and --vault-path")
+		}
+		refresh, err := time.ParseDuration(plugin.VaultRefresh)
+		if err != nil {
+			return nil, fmt.Errorf("--vault-refresh: %v", err)
+		}
+		return &vaultAuth{
+			client:  &http.Client{Timeout: exportTimeout},
+			addr:    strings.TrimSuffix(addr, "/"),
+			path:    strings.Trim(plugin.VaultPath, "/"),
+			field:   plugin.VaultField,
+			header:  plugin.AuthHeader,
+			role:    plugin.VaultRole,
+			mount:   plugin.VaultAuthMount,
+			refresh: refresh,
+		}, nil
+	})
+}
+
+// vaultAuth reads the export token from a field of a Vault secret and
+// sends it in the auth header. The secret is read again after its lease
+// or the refresh interval, whichever is shorter, so rotated tokens are
+// picked up. Vault is logged in to with VAULT_TOKEN, AppRole
+// (VAULT_ROLE_ID and VAULT_SECRET_ID) or, with a role, the Kubernetes
+// service account of the pod.
+type vaultAuth struct {
+	client  *http.Client
+	addr    string
+	path    string
+	field   string
+	header  string
+	role    string
+	mount   string
+	refresh time.Duration
+
+	sync.Mutex
+	vaultToken  string
+	tokenExpiry time.Time
+	headers     map[string]string
+	expiry      time.Time
+}
+
+type vaultResponse struct {
+	LeaseDuration int64                  `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+func (a *vaultAuth) Headers(ctx context.Context) (map[string]string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.headers != nil && time.Now().Before(a.expiry) {
+		return a.headers, nil
+	}
+	secret, err := a.read(ctx)
+	if se, ok := err.(*httpStatusError); ok && se.StatusCode == http.StatusForbidden && os.Getenv("VAULT_TOKEN") == "" {
+		// The Vault token was revoked: log in again.
+		a.vaultToken = ""
+		secret, err = a.read(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s from vault: %w", a.path, err)
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested // KV version 2
+	}
+	value, ok := data[a.field].(string)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("vault secret %s has no field %q", a.path, a.field)
+	}
+	lifetime := a.refresh
+	if lease := time.Duration(secret.LeaseDuration) * time.Second; lease > 0 && lease < lifetime {
+		lifetime = lease
+	}
+	a.expiry = time.Now().Add(lifetime)
+	a.headers = map[string]string{a.header: headerValue(a.header, value)}
+	return a.headers, nil
+}
+
+// invalidate makes the next export read the secret again.
+func (a *vaultAuth) invalidate() {
+	a.Lock()
+	a.headers = nil
+	a.Unlock()
+}
+
+// read reads the secret, logging in first if needed.
+func (a *vaultAuth) read(ctx context.Context) (*vaultResponse, error) {
+	if a.vaultToken == "" || (!a.tokenExpiry.IsZero() && time.Now().After(a.tokenExpiry)) {
+		if err := a.login(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return a.do(ctx, http.MethodGet, "/v1/"+a.path, nil)
+}
+
+// login gets a Vault token with the first login method configured.
+func (a *vaultAuth) login(ctx context.Context) error {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		a.vaultToken, a.tokenExpiry = token, time.Time{}
+		return nil
+	}
+	var method string
+	var body map[string]string
+	switch {
+	case os.Getenv("VAULT_ROLE_ID") != "":
+		method = "approle"
+		body = map[string]string{"role_id": os.Getenv("VAULT_ROLE_ID"), "secret_id": os.Getenv("VAULT_SECRET_ID")}
+	case a.role != "":
+		jwt, err := ioutil.ReadFile(kubernetesServiceAccountDir + "/token")
+		if err != nil {
+			return fmt.Errorf("could not read the service account token: %v", err)
+		}
+		method = "kubernetes"
+		body = map[string]string{"role": a.role, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("no vault credentials: set VAULT_TOKEN, VAULT_ROLE_ID and VAULT_SECRET_ID, or --vault-role")
+	}
+	mount := a.mount
+	if mount == "" {
+		mount = method
+	}
+	a.vaultToken = ""
+	resp, err := a.do(ctx, http.MethodPost, "/v1/auth/"+strings.Trim(mount, "/")+"/login", body)
+	if err != nil {
+		return fmt.Errorf("vault %s login failed: %w", method, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", method)
+	}
+	a.vaultToken = resp.Auth.ClientToken
+	a.tokenExpiry = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		a.tokenExpiry = time.Now().Add(time.Duration(resp.Auth.LeaseDuration)*time.Second - tokenRefreshMargin)
+	}
+	return nil
+}
+
+func (a *vaultAuth) do(ctx context.Context, method, path string, body interface{}) (*vaultResponse, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.addr+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	if a.vaultToken != "" {
+		req.Header.Set("X-Vault-Token", a.vaultToken)
+	}
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+	var vr vaultResponse
+	if err := json.Unmarshal(respBody, &vr); err != nil {
+		return nil, fmt.Errorf("invalid vault response: %v", err)
+	}
+	return &vr, nil
+}
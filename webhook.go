@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/sensu/sensu-go/types"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	RegisterExporter("webhook", func() (Exporter, error) {
+		if plugin.WebhookURL == "" {
+			return nil, fmt.Errorf("the webhook exporter requires --webhook-url")
+		}
+		tmpl, err := webhookTemplate(plugin.WebhookTemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		return &webhookExporter{
+			client:   &http.Client{Timeout: exportTimeout},
+			url:      plugin.WebhookURL,
+			template: tmpl,
+		}, nil
+	})
+}
+
+// defaultWebhookTemplate posts the event and its points.
+const defaultWebhookTemplate = `{"handler": {{json .Handler}}, "event": {{json .Event}}, "points": {{json .Points}}}`
+
+// webhookTemplate parses the template of the webhook bodies from path, or
+// the default template if path is empty. The json function renders a value
+// as JSON.
+func webhookTemplate(path string) (*template.Template, error) {
+	text := defaultWebhookTemplate
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+// webhookData is what the webhook template is rendered from.
+type webhookData struct {
+	Handler string
+	Event   *types.Event
+	Points  []webhookPoint
+}
+
+type webhookPoint struct {
+	Name       string            `json:"name"`
+	Value      float64           `json:"value"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// webhookExporter posts a JSON document rendered from a template for every
+// event of a batch, as a catch-all integration. Points are passed to the
+// template of the first event, where the batch has them; the events signal
+// must be enabled for events without points to be posted.
+type webhookExporter struct {
+	client   *http.Client
+	url      string
+	template *template.Template
+}
+
+func (e *webhookExporter) Export(ctx context.Context, _ *resourcepb.Resource, batch *Batch) error {
+	var points []webhookPoint
+	for _, m := range batch.Metrics {
+		for _, p := range m.GetGauge().GetDataPoints() {
+			point := webhookPoint{
+				Name:      m.Name,
+				Value:     pointValue(p),
+				Timestamp: time.Unix(0, int64(p.TimeUnixNano)).UTC(),
+			}
+			if len(p.Attributes) > 0 {
+				point.Attributes = map[string]string{}
+				for _, kv := range p.Attributes {
+					point.Attributes[kv.Key] = formatAnyValue(kv.Value)
+				}
+			}
+			points = append(points, point)
+		}
+	}
+
+	for i, event := range batch.Events {
+		data := webhookData{Handler: plugin.Name, Event: event}
+		if i == 0 {
+			data.Points = points
+		}
+		var body bytes.Buffer
+		if err := e.template.Execute(&body, data); err != nil {
+			return fmt.Errorf("could not render webhook template: %v", err)
+		}
+		if !json.Valid(body.Bytes()) {
+			return fmt.Errorf("webhook template rendered invalid JSON: %.200s", body.String())
+		}
+		if err := retryExport(ctx, func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body.Bytes()))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return doHTTP(e.client, req)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *webhookExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func (e *webhookExporter) String() string {
+	return "webhook " + e.url
+}